@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"evolution-postgres-backup/internal/api"
+	"evolution-postgres-backup/internal/config"
+	"evolution-postgres-backup/internal/database"
 	"evolution-postgres-backup/internal/service"
 	"flag"
 	"fmt"
@@ -13,6 +16,7 @@ import (
 	"syscall"
 	"time"
 
+	"evolution-postgres-backup/internal/version"
 	"evolution-postgres-backup/internal/worker"
 
 	"github.com/gin-gonic/gin"
@@ -36,6 +40,7 @@ func main() {
 	}
 
 	fmt.Println("🌐 PostgreSQL Backup API Service v2.0")
+	fmt.Printf("Build: %s (commit %s, built %s, %s)\n", version.Version, version.GitCommit, version.BuildTime, version.GoVersion())
 	fmt.Println("=====================================")
 
 	// Development mode configuration
@@ -53,10 +58,11 @@ func main() {
 
 	// Check API key
 	apiKey := os.Getenv("API_KEY")
-	if apiKey == "" {
-		log.Fatal("❌ API_KEY environment variable is required")
+	if apiKey == "" && os.Getenv("API_KEYS") == "" {
+		log.Fatal("❌ API_KEY or API_KEYS environment variable is required")
 	}
 	log.Printf("🔑 API Key set: %t", len(apiKey) > 0)
+	log.Printf("🔑 Role-based API keys set: %t", os.Getenv("API_KEYS") != "")
 
 	// Initialize database service (PostgreSQL connection)
 	log.Println("🐘 Initializing PostgreSQL database connection...")
@@ -70,12 +76,29 @@ func main() {
 	}()
 	log.Println("✅")
 
+	// The detailed health check reports on the S3 bucket when one is configured
+	s3Config := config.S3Config{}
+	s3Config.LoadEnv()
+	if s3Config.Bucket != "" {
+		s3Client := service.NewS3Client()
+		if err := s3Client.Initialize(&config.Config{S3Config: s3Config}); err != nil {
+			log.Printf("⚠️ S3 unavailable, health checks will report it: %v", err)
+		}
+		dbService.SetS3Client(s3Client)
+	}
+
 	// Run migration if requested
 	if *migrate {
 		log.Println("🔄 Performing migration from JSON to SQLite...")
-		// Migration is handled internally by the service
-		log.Println("✅ Migration completed successfully!")
-		log.Println("✅")
+		if err := dbService.PerformMigration(); err != nil {
+			if errors.Is(err, database.ErrMigrationInProgress) {
+				log.Printf("⚠️ Skipping migration: %v", err)
+			} else {
+				log.Fatalf("❌ Migration failed: %v", err)
+			}
+		} else {
+			log.Println("✅ Migration completed successfully!")
+		}
 	}
 
 	// Initialize job queue (for worker communication)
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"evolution-postgres-backup/internal/api"
+	"evolution-postgres-backup/internal/config"
 	"evolution-postgres-backup/internal/service"
 	"evolution-postgres-backup/internal/worker"
 	"flag"
@@ -54,6 +55,21 @@ func main() {
 	defer dbService.Close()
 	fmt.Println("✅")
 
+	// The detailed health check reports on the S3 bucket when one is
+	// configured, and the workers upload, archive and purge backups there
+	s3Config := config.S3Config{}
+	s3Config.LoadEnv()
+	s3Client := service.NewS3Client()
+	s3Ready := false
+	if s3Config.Bucket != "" {
+		if err := s3Client.Initialize(&config.Config{S3Config: s3Config}); err != nil {
+			log.Printf("⚠️ S3 unavailable, health checks will report it, backups stay local and purge jobs will keep S3 copies: %v", err)
+		} else {
+			s3Ready = true
+		}
+		dbService.SetS3Client(s3Client)
+	}
+
 	// Perform migration if requested
 	if *migrate {
 		fmt.Print("🔄 Performing migration from JSON to SQLite... ")
@@ -63,9 +79,26 @@ func main() {
 		fmt.Println("✅")
 	}
 
+	// Backups can't run without the PostgreSQL client tools
+	fmt.Println("🔧 Checking PostgreSQL client tools...")
+	tools, err := worker.CheckClientTools()
+	for _, tool := range tools {
+		if tool.Err != nil {
+			fmt.Printf("   ⚠️ %v\n", tool.Err)
+			continue
+		}
+		fmt.Printf("   %s: %s\n", tool.Path, tool.Version)
+	}
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
 	// Initialize worker system
 	fmt.Printf("👥 Initializing worker system with %d workers... ", *workerCount)
 	jobQueue := worker.NewJobQueue(*workerCount, dbService.GetDB())
+	if s3Ready {
+		jobQueue.SetObjectStore(s3Client)
+	}
 	if err := jobQueue.Start(); err != nil {
 		log.Fatalf("❌ Failed to start worker system: %v", err)
 	}
@@ -180,10 +213,13 @@ func gracefulShutdown(server *http.Server, jobQueue *worker.JobQueue, dbService
 		fmt.Println("✅")
 	}
 
-	// Stop worker system
-	fmt.Print("👥 Stopping worker system... ")
-	jobQueue.Stop()
-	fmt.Println("✅")
+	// Drain worker system so running backups are not aborted
+	fmt.Print("👥 Draining worker system... ")
+	if err := jobQueue.Drain(ctx); err != nil {
+		fmt.Printf("❌ Error: %v\n", err)
+	} else {
+		fmt.Println("✅")
+	}
 
 	// Close database
 	fmt.Print("📊 Closing database... ")
@@ -2,15 +2,17 @@ package main
 
 import (
 	"context"
+	"evolution-postgres-backup/internal/config"
 	"evolution-postgres-backup/internal/database"
 	"evolution-postgres-backup/internal/scheduler"
+	"evolution-postgres-backup/internal/service"
+	"evolution-postgres-backup/internal/version"
 	"evolution-postgres-backup/internal/worker"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"strconv"
 	"syscall"
 	"time"
 
@@ -20,8 +22,9 @@ import (
 func main() {
 	// Command line flags
 	var (
-		dev     = flag.Bool("dev", false, "Run in development mode")
-		workers = flag.Int("workers", 4, "Number of worker threads")
+		dev          = flag.Bool("dev", false, "Run in development mode")
+		workers      = flag.Int("workers", 4, "Number of worker threads")
+		drainTimeout = flag.Duration("drain-timeout", 5*time.Minute, "Maximum time to wait for running jobs on shutdown")
 	)
 	flag.Parse()
 
@@ -33,6 +36,7 @@ func main() {
 	}
 
 	fmt.Println("👥 PostgreSQL Backup Worker Service v2.0")
+	fmt.Printf("Build: %s (commit %s, built %s, %s)\n", version.Version, version.GitCommit, version.BuildTime, version.GoVersion())
 	fmt.Println("========================================")
 
 	// Development mode configuration
@@ -47,11 +51,7 @@ func main() {
 	log.Printf("📁 Working directory: %s", workDir)
 
 	// Worker configuration from environment
-	if envWorkers := os.Getenv("WORKER_COUNT"); envWorkers != "" {
-		if count, err := strconv.Atoi(envWorkers); err == nil {
-			*workers = count
-		}
-	}
+	*workers = worker.WorkerCountFromEnv(*workers)
 	log.Printf("👥 Worker threads: %d", *workers)
 
 	// Initialize database for workers
@@ -74,10 +74,37 @@ func main() {
 	}()
 	log.Println("✅")
 
+	// Backups can't run without the PostgreSQL client tools
+	log.Println("🔧 Checking PostgreSQL client tools...")
+	tools, err := worker.CheckClientTools()
+	for _, tool := range tools {
+		if tool.Err != nil {
+			log.Printf("⚠️ %v", tool.Err)
+			continue
+		}
+		log.Printf("   %s: %s", tool.Path, tool.Version)
+	}
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	log.Println("✅")
+
 	// Initialize worker system
 	log.Printf("👥 Initializing worker system with %d workers...", *workers)
 	jobQueue := worker.NewJobQueue(*workers, db)
 
+	// Backups are uploaded and purge jobs delete uploaded copies when S3 is configured
+	s3Config := config.S3Config{}
+	s3Config.LoadEnv()
+	if s3Config.Bucket != "" {
+		s3Client := service.NewS3Client()
+		if err := s3Client.Initialize(&config.Config{S3Config: s3Config}); err != nil {
+			log.Printf("⚠️ S3 unavailable, backups stay local and purge jobs will keep S3 copies: %v", err)
+		} else {
+			jobQueue.SetObjectStore(s3Client)
+		}
+	}
+
 	// Start worker system
 	if err := jobQueue.Start(); err != nil {
 		log.Fatalf("❌ Failed to start worker system: %v", err)
@@ -162,6 +189,16 @@ func main() {
 	// Cancel monitoring
 	cancel()
 
+	// Let running jobs finish before the deferred cleanup stops the queue
+	log.Printf("⏳ Draining worker system (timeout %s)...", *drainTimeout)
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), *drainTimeout)
+	if err := jobQueue.Drain(drainCtx); err != nil {
+		log.Printf("⚠️ %v", err)
+	} else {
+		log.Println("✅ All running jobs finished")
+	}
+	drainCancel()
+
 	// Final stats
 	finalStats := jobQueue.GetStats()
 	log.Printf("📊 Final Statistics:")
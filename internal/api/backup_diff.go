@@ -0,0 +1,130 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"evolution-postgres-backup/internal/database"
+	"evolution-postgres-backup/internal/models"
+	"evolution-postgres-backup/internal/worker"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// backupDiffWait is how long CompareBackups waits for the worker's result
+// before answering with the job instead
+const backupDiffWait = 2 * time.Minute
+
+type backupDiffRequest struct {
+	BaseBackupID   string `json:"base_backup_id" binding:"required"`
+	TargetBackupID string `json:"target_backup_id" binding:"required"`
+}
+
+// CompareBackups queues a schema diff of two backups and waits for it. Dump
+// files live on the worker, so the comparison runs there as a job.
+func (h *WorkerHandlers) CompareBackups(c *gin.Context) {
+	var req backupDiffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	backupRepo := database.NewBackupRepository(h.jobQueue.GetDB())
+	var base *models.BackupInfo
+	for _, id := range []string{req.BaseBackupID, req.TargetBackupID} {
+		backup, err := backupRepo.GetByID(id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusNotFound, models.APIResponse{
+					Success: false,
+					Error:   "Backup not found: " + id,
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		if backup.Status != models.BackupStatusCompleted {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Backup is not completed: " + id,
+			})
+			return
+		}
+		if base == nil {
+			base = backup
+		}
+	}
+
+	job, err := h.jobQueue.AddSchemaDiffJob(base, req.TargetBackupID, worker.SchemaDiffPriority(), requestJobOptions(c)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create schema diff job: " + err.Error(),
+		})
+		return
+	}
+
+	wake, unsubscribe := h.jobStream.subscribe(job.ID)
+	defer unsubscribe()
+
+	timeout := time.NewTimer(backupDiffWait)
+	defer timeout.Stop()
+	ticker := time.NewTicker(jobStreamPollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, errorMessage, err := h.jobQueue.GetJobStatus(job.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+
+		switch status {
+		case worker.JobStatusCompleted:
+			payload, err := h.jobQueue.GetJobPayload(job.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, models.APIResponse{
+					Success: false,
+					Error:   err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusOK, models.APIResponse{
+				Success: true,
+				Data:    payload["schema_diff"],
+			})
+			return
+		case worker.JobStatusFailed:
+			c.JSON(http.StatusUnprocessableEntity, models.APIResponse{
+				Success: false,
+				Error:   "Schema diff failed: " + errorMessage,
+			})
+			return
+		}
+
+		select {
+		case <-wake:
+		case <-ticker.C:
+		case <-timeout.C:
+			c.JSON(http.StatusAccepted, models.APIResponse{
+				Success: true,
+				Message: "Schema diff is still running; follow /api/v2/workers/jobs/" + job.ID + "/stream",
+				Data:    job,
+			})
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
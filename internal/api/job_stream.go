@@ -0,0 +1,156 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"evolution-postgres-backup/internal/database"
+	"evolution-postgres-backup/internal/models"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jobStreamPollInterval bounds how long a stream waits without a notification,
+// covering notifications lost while the listener reconnects
+const jobStreamPollInterval = 5 * time.Second
+
+// jobStreamHub fans job activity notifications out to the streams following
+// each job. The database listener is started by the first subscriber.
+type jobStreamHub struct {
+	db          *database.DB
+	startOnce   sync.Once
+	mu          sync.Mutex
+	subscribers map[string]map[chan struct{}]struct{}
+}
+
+func newJobStreamHub(db *database.DB) *jobStreamHub {
+	return &jobStreamHub{
+		db:          db,
+		subscribers: make(map[string]map[chan struct{}]struct{}),
+	}
+}
+
+// subscribe returns a channel signalled whenever jobID has new activity, and
+// a function to stop receiving
+func (h *jobStreamHub) subscribe(jobID string) (<-chan struct{}, func()) {
+	h.startOnce.Do(func() {
+		if _, err := h.db.ListenJobActivity(h.notify); err != nil {
+			log.Printf("⚠️ Job streams fall back to polling: %v", err)
+		}
+	})
+
+	wake := make(chan struct{}, 1)
+	h.mu.Lock()
+	if h.subscribers[jobID] == nil {
+		h.subscribers[jobID] = make(map[chan struct{}]struct{})
+	}
+	h.subscribers[jobID][wake] = struct{}{}
+	h.mu.Unlock()
+
+	return wake, func() {
+		h.mu.Lock()
+		delete(h.subscribers[jobID], wake)
+		if len(h.subscribers[jobID]) == 0 {
+			delete(h.subscribers, jobID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// notify wakes the streams of jobID, or all streams when jobID is empty
+func (h *jobStreamHub) notify(jobID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for id, streams := range h.subscribers {
+		if jobID != "" && id != jobID {
+			continue
+		}
+		for wake := range streams {
+			select {
+			case wake <- struct{}{}:
+			default: // Already pending
+			}
+		}
+	}
+}
+
+// StreamJob sends a job's log rows as Server-Sent Events while it runs. Each
+// row is a "log" event; a final "status" event carries the terminal status
+// and the job payload.
+func (h *WorkerHandlers) StreamJob(c *gin.Context) {
+	jobID := c.Param("id")
+
+	if _, _, err := h.jobQueue.GetJobStatus(jobID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   "Job not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Subscribe before the first read so no activity falls in between
+	wake, unsubscribe := h.jobStream.subscribe(jobID)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	logRepo := database.NewLogRepository(h.jobQueue.GetDB())
+	ticker := time.NewTicker(jobStreamPollInterval)
+	defer ticker.Stop()
+
+	var lastID int64
+	for {
+		// Read the status first: logs written before a terminal status are
+		// then guaranteed to be sent before the final event
+		status, errorMessage, err := h.jobQueue.GetJobStatus(jobID)
+		if err != nil {
+			c.SSEvent("error", gin.H{"error": err.Error()})
+			c.Writer.Flush()
+			return
+		}
+
+		entries, err := logRepo.GetByJobIDAfter(jobID, lastID)
+		if err != nil {
+			c.SSEvent("error", gin.H{"error": err.Error()})
+			c.Writer.Flush()
+			return
+		}
+		for _, entry := range entries {
+			c.SSEvent("log", entry)
+			lastID = entry.ID
+		}
+
+		if status.IsTerminal() {
+			// The payload carries results workers store, e.g. a schema diff
+			payload, err := h.jobQueue.GetJobPayload(jobID)
+			if err != nil {
+				log.Printf("⚠️ Failed to read payload of job %s: %v", jobID, err)
+			}
+			c.SSEvent("status", gin.H{"job_id": jobID, "status": status, "error": errorMessage, "payload": payload})
+			c.Writer.Flush()
+			return
+		}
+		c.Writer.Flush()
+
+		select {
+		case <-wake:
+		case <-ticker.C:
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
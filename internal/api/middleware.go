@@ -1,17 +1,155 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"evolution-postgres-backup/internal/models"
+	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// API key roles
+const (
+	RoleAdmin    = "admin"     // Full access
+	RoleReadOnly = "read-only" // GET requests only
+)
+
+// Context keys set by AuthMiddleware
+const (
+	ContextAPIKey     = "api_key"
+	ContextAPIRole    = "api_role"
+	ContextAPIKeyName = "api_key_name" // Name of the key, safe to log and store
+)
+
+// RequestIDHeader carries the request/trace ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// ContextRequestID is the gin context key set by RequestIDMiddleware
+const ContextRequestID = "request_id"
+
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware accepts the caller's X-Request-ID or generates one, stores
+// it in the context and echoes it back in the response
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		c.Set(ContextRequestID, requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// validRequestID accepts short IDs made of printable ASCII without spaces
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLogger is gin's default access log with the request ID appended
+func AccessLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		requestID, _ := param.Keys[ContextRequestID].(string)
+		keyName, _ := param.Keys[ContextAPIKeyName].(string)
+		if keyName == "" {
+			keyName = "-"
+		}
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v | request_id=%s key=%s\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.StatusCode,
+			param.Latency.Round(time.Microsecond),
+			param.ClientIP,
+			param.Method,
+			param.Path,
+			requestID,
+			keyName,
+			param.ErrorMessage,
+		)
+	})
+}
+
+// apiKey is a configured key's role and the name it is logged and recorded under
+type apiKey struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// UnmarshalJSON accepts either a bare role ("admin") or {"name":..,"role":..}
+func (k *apiKey) UnmarshalJSON(data []byte) error {
+	var role string
+	if err := json.Unmarshal(data, &role); err == nil {
+		k.Role = role
+		return nil
+	}
+
+	type plain apiKey
+	return json.Unmarshal(data, (*plain)(k))
+}
+
+// loadAPIKeys returns the configured keys. API_KEYS holds a JSON object
+// mapping each key to its role or to a named entry, such as
+// {"key1":"admin","key2":{"name":"ci","role":"admin"}}; the legacy API_KEY is
+// always an admin key named "default". Keys with any other role are
+// read-only. Unnamed keys get a name derived from a hash of the key, so logs
+// never contain the key itself.
+func loadAPIKeys() map[string]apiKey {
+	keys := make(map[string]apiKey)
+
+	if raw := os.Getenv("API_KEYS"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &keys); err != nil {
+			log.Printf("⚠️ Invalid API_KEYS value, ignoring: %v", err)
+			keys = make(map[string]apiKey)
+		}
+	}
+
+	if key := os.Getenv("API_KEY"); key != "" {
+		name := keys[key].Name
+		if name == "" {
+			name = "default"
+		}
+		keys[key] = apiKey{Name: name, Role: RoleAdmin}
+	}
+
+	for key, entry := range keys {
+		if entry.Name == "" {
+			sum := sha256.Sum256([]byte(key))
+			entry.Name = "key-" + hex.EncodeToString(sum[:4])
+		}
+		// Unknown, misspelt and empty roles get the least access, not full write access
+		if entry.Role != RoleAdmin && entry.Role != RoleReadOnly {
+			log.Printf("⚠️ API key %s has unknown role %q, treating it as %s", entry.Name, entry.Role, RoleReadOnly)
+			entry.Role = RoleReadOnly
+		}
+		keys[key] = entry
+	}
+
+	return keys
+}
+
 func AuthMiddleware() gin.HandlerFunc {
+	apiKeys := loadAPIKeys()
+
 	return func(c *gin.Context) {
-		apiKey := os.Getenv("API_KEY")
-		if apiKey == "" {
+		if len(apiKeys) == 0 {
 			c.JSON(http.StatusInternalServerError, models.APIResponse{
 				Success: false,
 				Error:   "API key not configured",
@@ -35,7 +173,8 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		if requestApiKey != apiKey {
+		key, ok := apiKeys[requestApiKey]
+		if !ok {
 			c.JSON(http.StatusUnauthorized, models.APIResponse{
 				Success: false,
 				Error:   "Invalid API key",
@@ -44,22 +183,47 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if key.Role == RoleReadOnly && c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "API key is read-only",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set(ContextAPIKey, requestApiKey)
+		c.Set(ContextAPIRole, key.Role)
+		c.Set(ContextAPIKeyName, key.Name)
 		c.Next()
 	}
 }
 
-func CORSMiddleware() gin.HandlerFunc {
+// RequireRole only lets requests through whose API key has the given role.
+// Admin keys are accepted everywhere.
+//
+// AuthMiddleware already refuses writes with a read-only key, whatever the
+// route. RequireRole is still set on admin-only writes: it declares them next
+// to the route, matching the Admin flag of apiOperations, and keeps them
+// admin-only should a role that may write be added. On reads, such as the
+// instance export, it is the only check.
+func RequireRole(role string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, api-key, accept, origin, Cache-Control, X-Requested-With")
-		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+		current := c.GetString(ContextAPIRole)
+		if current != role && current != RoleAdmin {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "This operation requires the " + role + " role",
+			})
+			c.Abort()
 			return
 		}
 
 		c.Next()
 	}
 }
+
+// CORSMiddleware applies the same CORS_ALLOWED_ORIGINS policy as the v2 router
+func CORSMiddleware() gin.HandlerFunc {
+	return setupCORS()
+}
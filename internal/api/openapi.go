@@ -0,0 +1,495 @@
+package api
+
+import (
+	"evolution-postgres-backup/internal/config"
+	"evolution-postgres-backup/internal/database"
+	"evolution-postgres-backup/internal/models"
+	"evolution-postgres-backup/internal/service"
+	"evolution-postgres-backup/internal/version"
+	"evolution-postgres-backup/internal/worker"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiParam describes a query parameter of an operation
+type apiParam struct {
+	Name        string
+	Description string
+	Type        string // string (default), integer or boolean
+}
+
+// apiOperation describes one HTTP endpoint. The operations table is the single
+// source for both /openapi.json and /docs.
+type apiOperation struct {
+	Method   string
+	Path     string // gin-style path, e.g. /api/v2/postgres/:id
+	Tag      string
+	Summary  string
+	Query    []apiParam
+	Request  interface{} // Zero value of the request body type, nil for none
+	Response interface{} // Zero value of APIResponse.data, nil when unspecified
+	Public   bool        // No api-key required
+	Admin    bool        // Requires the admin role
+}
+
+var (
+	backupQuery = []apiParam{
+		{Name: "postgres_id", Description: "Filter by PostgreSQL instance ID"},
+		{Name: "status", Description: "pending, in_progress, completed or failed"},
+		{Name: "type", Description: "hourly, daily, weekly, monthly or manual"},
+		{Name: "tag", Description: "Only backups carrying this tag"},
+		{Name: "trashed", Description: "true lists the backups in the trash instead", Type: "boolean"},
+		{Name: "limit", Description: "Maximum number of backups", Type: "integer"},
+	}
+	logQuery = []apiParam{
+		{Name: "start_date", Description: "Start date (YYYY-MM-DD)"},
+		{Name: "end_date", Description: "End date (YYYY-MM-DD)"},
+		{Name: "level", Description: "INFO, WARN, ERROR or DEBUG"},
+		{Name: "component", Description: "BACKUP, RESTORE, WORKER or QUEUE"},
+		{Name: "job_id", Description: "Filter by job ID"},
+		{Name: "backup_id", Description: "Filter by backup ID"},
+		{Name: "q", Description: "Only entries whose message contains this text (case-insensitive)"},
+		{Name: "limit", Description: "Maximum number of entries", Type: "integer"},
+		{Name: "offset", Description: "Number of matching entries to skip, newest first", Type: "integer"},
+	}
+)
+
+// apiOperations lists every documented endpoint
+var apiOperations = []apiOperation{
+	// Health
+	{Method: "GET", Path: "/health", Tag: "health", Summary: "Basic health check", Public: true},
+	{Method: "GET", Path: "/health/detailed", Tag: "health", Summary: "Detailed system health (503 when unhealthy, 200 with status degraded otherwise)", Public: true},
+	{Method: "GET", Path: "/health/live", Tag: "health", Summary: "Liveness probe (503 while shutting down)", Public: true},
+	{Method: "GET", Path: "/health/ready", Tag: "health", Summary: "Readiness probe (503 until the database and workers are available)", Public: true},
+	{Method: "GET", Path: "/openapi.json", Tag: "health", Summary: "OpenAPI specification", Public: true},
+
+	// Dashboard
+	{Method: "GET", Path: "/api/v2/dashboard", Tag: "dashboard", Summary: "Dashboard statistics"},
+	{Method: "GET", Path: "/api/v2/dashboard/trends", Tag: "dashboard", Summary: "Backup trends"},
+
+	// PostgreSQL instances
+	{Method: "GET", Path: "/api/v2/postgres", Tag: "postgres", Summary: "List PostgreSQL instances",
+		Query: []apiParam{
+			{Name: "enabled", Description: "Only enabled instances", Type: "boolean"},
+			{Name: "selector", Description: "Label selector, e.g. team=payments,env=prod; a bare key only has to be present"},
+		}, Response: []config.PostgreSQLConfig{}},
+	{Method: "POST", Path: "/api/v2/postgres", Tag: "postgres", Summary: "Create PostgreSQL instance",
+		Request: config.PostgreSQLConfig{}, Response: config.PostgreSQLConfig{}},
+	{Method: "GET", Path: "/api/v2/postgres/export", Tag: "postgres", Summary: "Export all instance definitions (passwords and SSL keys blanked by default)",
+		Query:    []apiParam{{Name: "include_passwords", Description: "Include passwords and SSL client keys in the export", Type: "boolean"}},
+		Response: instanceImportRequest{}, Admin: true},
+	{Method: "POST", Path: "/api/v2/postgres/import", Tag: "postgres", Summary: "Import instance definitions; existing IDs are skipped unless overwrite is set",
+		Query:   []apiParam{{Name: "overwrite", Description: "Update instances whose ID already exists", Type: "boolean"}},
+		Request: instanceImportRequest{}, Response: service.InstanceImportResult{}, Admin: true},
+	{Method: "GET", Path: "/api/v2/postgres/:id", Tag: "postgres", Summary: "Get specific instance", Response: config.PostgreSQLConfig{}},
+	{Method: "PUT", Path: "/api/v2/postgres/:id", Tag: "postgres", Summary: "Update instance",
+		Request: config.PostgreSQLConfig{}, Response: config.PostgreSQLConfig{}},
+	{Method: "DELETE", Path: "/api/v2/postgres/:id", Tag: "postgres", Summary: "Delete instance", Admin: true,
+		Query: []apiParam{{Name: "purge_backups", Description: "Queue a job deleting all its backups, then the instance", Type: "boolean"}}},
+	{Method: "POST", Path: "/api/v2/postgres/:id/enable", Tag: "postgres", Summary: "Resume scheduled backups of an instance"},
+	{Method: "POST", Path: "/api/v2/postgres/:id/disable", Tag: "postgres", Summary: "Pause scheduled backups of an instance"},
+	{Method: "GET", Path: "/api/v2/postgres/:id/backups", Tag: "postgres", Summary: "Get instance backups", Response: []models.BackupInfo{}},
+	{Method: "GET", Path: "/api/v2/postgres/:id/backups/latest", Tag: "postgres", Summary: "Latest successful backup per database"},
+	{Method: "GET", Path: "/api/v2/postgres/:id/s3-objects", Tag: "postgres",
+		Summary:  "Objects stored in S3 under the instance's backup prefixes, listed from the bucket without reading backup records",
+		Response: instanceS3Objects{}},
+	{Method: "POST", Path: "/api/v2/postgres/:id/backup-all", Tag: "postgres", Summary: "Back up every database of an instance",
+		Request: instanceBackupRequest{}},
+	{Method: "POST", Path: "/api/v2/postgres/:id/cleanup", Tag: "postgres", Summary: "Run retention cleanup for an instance now",
+		Query: []apiParam{
+			{Name: "type", Description: "hourly, daily, weekly or monthly; every type when omitted"},
+			{Name: "dry_run", Description: "Only report what would be deleted or archived", Type: "boolean"},
+		},
+		Admin: true},
+
+	// Backups
+	{Method: "GET", Path: "/api/v2/backups", Tag: "backups", Summary: "List backups (with advanced filtering)",
+		Query: backupQuery, Response: []models.BackupInfo{}},
+	{Method: "GET", Path: "/api/v2/backups/duplicates", Tag: "backups", Summary: "Clusters of completed backups with identical checksums",
+		Query: []apiParam{{Name: "postgres_id", Description: "Only consider backups of this instance"}}},
+	{Method: "GET", Path: "/api/v2/backups/retention-report", Tag: "backups",
+		Summary: "Oldest/newest backup, counts by type and bytes per database, with the effective retention policy"},
+	{Method: "GET", Path: "/api/v2/backups/storage-by-instance", Tag: "backups",
+		Summary: "Bytes used by completed backups per instance, largest first", Response: []database.InstanceStorage{}},
+	{Method: "POST", Path: "/api/v2/backups/diff", Tag: "backups",
+		Summary: "Schema drift between two backups: objects added, removed and changed, with a line diff of each definition",
+		Request: backupDiffRequest{}, Response: worker.SchemaDiff{}},
+	{Method: "GET", Path: "/api/v2/backups/:id", Tag: "backups", Summary: "Get specific backup", Response: models.BackupInfo{}},
+	{Method: "DELETE", Path: "/api/v2/backups/:id", Tag: "backups",
+		Summary: "Move a backup to the trash; it is purged from storage after TRASH_RETENTION_HOURS", Response: models.BackupInfo{}, Admin: true},
+	{Method: "POST", Path: "/api/v2/backups/:id/restore-from-trash", Tag: "backups",
+		Summary: "Take a backup out of the trash before it is purged", Response: models.BackupInfo{}, Admin: true},
+	{Method: "POST", Path: "/api/v2/backups/:id/protect", Tag: "backups", Summary: "Protect a backup from retention cleanup",
+		Request: protectBackupRequest{}, Response: models.BackupInfo{}},
+	{Method: "GET", Path: "/api/v2/backups/:id/failure-output", Tag: "backups", Summary: "Complete pg_dump output captured when the backup failed",
+		Query: []apiParam{{Name: "format", Description: "text returns the output as plain text"}}, Response: backupFailureOutput{}},
+
+	// Restores
+	{Method: "GET", Path: "/api/v2/restores", Tag: "restores", Summary: "Restore audit trail: who restored which backup to which target, newest first",
+		Query: []apiParam{
+			{Name: "backup_id", Description: "Filter by restored backup ID"},
+			{Name: "postgres_id", Description: "Filter by source or target instance ID"},
+			{Name: "status", Description: "in_progress, completed or failed"},
+			{Name: "limit", Description: "Maximum number of restores (default 100)", Type: "integer"},
+		},
+		Response: []database.RestoreRecord{}},
+
+	// Schedules
+	{Method: "POST", Path: "/api/v2/schedules/once", Tag: "schedules", Summary: "Schedule a single backup at an absolute time; it fires within 30 seconds of run_at, or on worker startup if missed",
+		Request: oneOffScheduleRequest{}, Response: database.Schedule{}},
+	{Method: "GET", Path: "/api/v2/schedules/once", Tag: "schedules", Summary: "List one-off schedules, pending ones first",
+		Query: []apiParam{{Name: "pending", Description: "true leaves out schedules that already fired", Type: "boolean"}}, Response: []database.Schedule{}},
+	{Method: "DELETE", Path: "/api/v2/schedules/once/:id", Tag: "schedules", Summary: "Cancel a one-off schedule that hasn't fired"},
+
+	// Logs
+	{Method: "GET", Path: "/api/v2/logs", Tag: "logs", Summary: "List logs (with advanced filtering)",
+		Query: logQuery, Response: []database.LogEntry{}},
+	{Method: "GET", Path: "/api/v2/logs/export", Tag: "logs", Summary: "Download logs for a date range as a txt or json file",
+		Query: []apiParam{
+			{Name: "start_date", Description: "Start date (YYYY-MM-DD), required"},
+			{Name: "end_date", Description: "End date (YYYY-MM-DD), defaults to start_date"},
+			{Name: "format", Description: "txt (default) or json"},
+			{Name: "level", Description: "INFO, WARN, ERROR or DEBUG"},
+			{Name: "component", Description: "BACKUP, RESTORE, WORKER or QUEUE"},
+			{Name: "job_id", Description: "Filter by job ID"},
+			{Name: "backup_id", Description: "Filter by backup ID"},
+		}},
+	{Method: "GET", Path: "/api/v2/logs/job/:job_id", Tag: "logs", Summary: "Get logs for specific job", Response: []database.LogEntry{}},
+	{Method: "GET", Path: "/api/v2/logs/backup/:backup_id", Tag: "logs", Summary: "Get logs for specific backup", Response: []database.LogEntry{}},
+	{Method: "GET", Path: "/api/v2/logs/stream", Tag: "logs", Summary: "Recent logs for streaming clients", Response: []database.LogEntry{}},
+
+	// Workers
+	{Method: "GET", Path: "/api/v2/workers/stats", Tag: "workers", Summary: "Queue statistics", Response: worker.QueueStats{}},
+	{Method: "GET", Path: "/api/v2/workers/health", Tag: "workers", Summary: "Worker system health"},
+	{Method: "GET", Path: "/api/v2/workers/metrics", Tag: "workers", Summary: "Detailed metrics"},
+	{Method: "POST", Path: "/api/v2/workers/restart", Tag: "workers", Summary: "Restart queue", Admin: true},
+	{Method: "POST", Path: "/api/v2/workers/scale", Tag: "workers", Summary: "Change number of workers",
+		Request: scaleWorkersRequest{}, Admin: true},
+	{Method: "POST", Path: "/api/v2/workers/pause", Tag: "workers", Summary: "Stop starting new jobs; running jobs finish", Admin: true},
+	{Method: "POST", Path: "/api/v2/workers/resume", Tag: "workers", Summary: "Start jobs again after a pause", Admin: true},
+	{Method: "GET", Path: "/api/v2/workers/status", Tag: "workers", Summary: "Worker status", Response: []worker.WorkerStatus{}},
+	{Method: "GET", Path: "/api/v2/workers/:worker_id", Tag: "workers", Summary: "Detailed worker information", Response: worker.WorkerStatus{}},
+	{Method: "GET", Path: "/api/v2/workers/jobs/running", Tag: "workers", Summary: "Running jobs", Response: []worker.Job{}},
+	{Method: "GET", Path: "/api/v2/workers/jobs/:id/stream", Tag: "workers", Summary: "Stream a job's log rows and final status as Server-Sent Events (text/event-stream)"},
+	{Method: "POST", Path: "/api/v2/workers/jobs/cancel-pending", Tag: "workers", Admin: true,
+		Summary: "Cancel pending and retrying jobs, optionally only of a type or instance; their pending backups are failed",
+		Request: cancelPendingJobsRequest{}},
+	{Method: "POST", Path: "/api/v2/workers/jobs/backup", Tag: "workers", Summary: "Create backup job, or return the pending/running backup of the same database and type",
+		Query:   []apiParam{{Name: "force", Description: "Queue even if the same backup is pending, running or retrying", Type: "boolean"}},
+		Request: backupJobRequest{}, Response: models.BackupInfo{}},
+	{Method: "POST", Path: "/api/v2/workers/jobs/restore", Tag: "workers", Summary: "Create restore job",
+		Request: restoreJobRequest{}, Response: worker.Job{}, Admin: true},
+	{Method: "POST", Path: "/api/v2/workers/jobs/cleanup", Tag: "workers", Summary: "Create cleanup job",
+		Query:   []apiParam{{Name: "dry_run", Description: "Only report the backups retention would delete or archive (payload.cleanup_plan)", Type: "boolean"}},
+		Request: cleanupJobRequest{}, Response: worker.Job{}, Admin: true},
+	{Method: "POST", Path: "/api/v2/workers/jobs/basebackup", Tag: "workers", Summary: "Create pg_basebackup job (needs REPLICATION privilege)",
+		Request: baseBackupJobRequest{}, Response: worker.Job{}, Admin: true},
+	{Method: "POST", Path: "/api/v2/workers/jobs/backup/bulk", Tag: "workers", Summary: "Create bulk backup jobs, listed or for the instances matching a label selector",
+		Request: bulkBackupJobRequest{}},
+	{Method: "POST", Path: "/api/v2/workers/jobs/backup/all", Tag: "workers", Summary: "Back up every database of every enabled instance",
+		Query: []apiParam{
+			{Name: "type", Description: "hourly, daily, weekly, monthly or manual (default manual)"},
+			{Name: "selector", Description: "Only instances matching this label selector, e.g. team=payments"},
+		}, Admin: true},
+
+	// Migration
+	{Method: "GET", Path: "/api/v2/migration/status", Tag: "migration", Summary: "Migration status"},
+	{Method: "GET", Path: "/api/v2/migration/needed", Tag: "migration", Summary: "Whether JSON data is waiting to be migrated, cached for 10s"},
+	{Method: "POST", Path: "/api/v2/migration/execute", Tag: "migration", Summary: "Start migrating JSON data to the database in the background",
+		Response: database.MigrationRun{}, Admin: true},
+	{Method: "POST", Path: "/api/v2/migration/abort", Tag: "migration", Summary: "Stop the running migration, keeping the data migrated so far",
+		Response: database.MigrationRun{}, Admin: true},
+	{Method: "GET", Path: "/api/v2/migration/backups", Tag: "migration", Summary: "JSON files copied aside before migrations, newest first",
+		Response: []database.JSONBackup{}},
+	{Method: "GET", Path: "/api/v2/migration/backups/:name/download", Tag: "migration", Summary: "Download a pre-migration JSON backup as .tar.gz", Admin: true},
+
+	// System
+	{Method: "GET", Path: "/api/v2/system/info", Tag: "system", Summary: "System information"},
+	{Method: "GET", Path: "/api/v2/system/version", Tag: "system", Summary: "Build metadata and pg_dump/database versions",
+		Response: versionInfo{}},
+	{Method: "GET", Path: "/api/v2/system/config", Tag: "system", Summary: "Effective configuration after environment variables and defaults, secrets masked",
+		Response: systemConfig{}, Admin: true},
+	{Method: "POST", Path: "/api/v2/system/maintenance", Tag: "system", Summary: "Prune old logs and VACUUM ANALYZE the logs, backups and jobs tables",
+		Request: maintenanceRequest{}, Response: service.MaintenanceReport{}, Admin: true},
+	{Method: "POST", Path: "/api/v2/system/selftest", Tag: "system", Summary: "Dump a database, upload it to S3, verify the object's size and checksum, then delete it",
+		Query: []apiParam{
+			{Name: "postgres_id", Description: "Instance to dump (required)"},
+			{Name: "database", Description: "Database to dump (required); use a tiny one, the test runs synchronously with a 2 minute timeout"},
+		}, Response: selfTestReport{}, Admin: true},
+}
+
+// BuildOpenAPISpec generates the OpenAPI 3.0 document from apiOperations
+func BuildOpenAPISpec() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	gen := &schemaGenerator{schemas: schemas}
+	gen.ref(reflect.TypeOf(models.APIResponse{}))
+
+	paths := map[string]interface{}{}
+	for _, op := range apiOperations {
+		path, pathParams := openAPIPath(op.Path)
+
+		var parameters []interface{}
+		for _, name := range pathParams {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, param := range op.Query {
+			paramType := param.Type
+			if paramType == "" {
+				paramType = "string"
+			}
+			parameters = append(parameters, map[string]interface{}{
+				"name":        param.Name,
+				"in":          "query",
+				"description": param.Description,
+				"schema":      map[string]interface{}{"type": paramType},
+			})
+		}
+
+		responseSchema := map[string]interface{}{"$ref": "#/components/schemas/APIResponse"}
+		if op.Response != nil {
+			responseSchema = map[string]interface{}{
+				"allOf": []interface{}{
+					map[string]interface{}{"$ref": "#/components/schemas/APIResponse"},
+					map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{"data": gen.ref(reflect.TypeOf(op.Response))},
+					},
+				},
+			}
+		}
+
+		operation := map[string]interface{}{
+			"tags":        []string{op.Tag},
+			"summary":     op.Summary,
+			"operationId": operationID(op.Method, path),
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "Success",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": responseSchema},
+					},
+				},
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{"$ref": "#/components/schemas/APIResponse"},
+						},
+					},
+				},
+			},
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if op.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": gen.ref(reflect.TypeOf(op.Request))},
+				},
+			}
+		}
+		if op.Public {
+			operation["security"] = []interface{}{}
+		}
+		if op.Admin {
+			operation["description"] = "Requires an admin API key."
+		}
+
+		item, _ := paths[path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+		item[strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Evolution PostgreSQL Backup Service API",
+			"version": version.Version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"ApiKeyAuth": map[string]interface{}{
+					"type": "apiKey",
+					"in":   "header",
+					"name": "api-key",
+				},
+			},
+		},
+		"security": []interface{}{
+			map[string]interface{}{"ApiKeyAuth": []string{}},
+		},
+	}
+}
+
+// checkOpenAPICoverage logs routes that are registered but missing from apiOperations
+func checkOpenAPICoverage(router *gin.Engine) {
+	documented := make(map[string]bool, len(apiOperations))
+	for _, op := range apiOperations {
+		documented[op.Method+" "+op.Path] = true
+	}
+
+	for _, route := range router.Routes() {
+		if strings.HasPrefix(route.Path, "/docs") {
+			continue
+		}
+		if !documented[route.Method+" "+route.Path] {
+			log.Printf("⚠️ Route %s %s is missing from the OpenAPI spec", route.Method, route.Path)
+		}
+	}
+}
+
+// openAPIPath converts /a/:id to /a/{id} and returns the path parameter names
+func openAPIPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID builds a stable identifier such as get_api_v2_postgres_id
+func operationID(method, path string) string {
+	replacer := strings.NewReplacer("/", "_", "{", "", "}", "", "-", "_", ".", "_")
+	return strings.ToLower(method) + strings.TrimRight(replacer.Replace(path), "_")
+}
+
+// schemaEnums lists the allowed values of named string types
+var schemaEnums = map[reflect.Type][]string{
+	reflect.TypeOf(models.BackupType("")): {
+		string(models.BackupTypeHourly), string(models.BackupTypeDaily), string(models.BackupTypeWeekly),
+		string(models.BackupTypeMonthly), string(models.BackupTypeManual),
+	},
+	reflect.TypeOf(models.BackupStatus("")): {
+		string(models.BackupStatusPending), string(models.BackupStatusInProgress),
+		string(models.BackupStatusCompleted), string(models.BackupStatusFailed),
+	},
+	reflect.TypeOf(worker.JobType("")): {
+		string(worker.JobTypeBackup), string(worker.JobTypeRestore), string(worker.JobTypeCleanup),
+		string(worker.JobTypeBaseBackup), string(worker.JobTypeSchemaDiff),
+	},
+	reflect.TypeOf(worker.JobStatus("")): {
+		string(worker.JobStatusPending), string(worker.JobStatusRunning), string(worker.JobStatusCompleted),
+		string(worker.JobStatusFailed), string(worker.JobStatusRetrying), string(worker.JobStatusCancelled),
+	},
+}
+
+// schemaGenerator derives JSON schemas from Go types via their json tags,
+// registering named structs under components/schemas
+type schemaGenerator struct {
+	schemas map[string]interface{}
+}
+
+// ref returns a schema for t, using a $ref for named structs
+func (g *schemaGenerator) ref(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	if t.Kind() == reflect.Struct && t.Name() != "" {
+		name := schemaName(t)
+		if _, exists := g.schemas[name]; !exists {
+			g.schemas[name] = map[string]interface{}{} // Placeholder for recursive types
+			g.schemas[name] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+
+	return g.inline(t)
+}
+
+// inline returns a schema for non-struct types and anonymous structs
+func (g *schemaGenerator) inline(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.String:
+		schema := map[string]interface{}{"type": "string"}
+		if values, ok := schemaEnums[t]; ok {
+			schema["enum"] = values
+		}
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.ref(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.ref(t.Elem())}
+	case reflect.Struct:
+		return g.object(t)
+	default:
+		return map[string]interface{}{} // interface{}: any value
+	}
+}
+
+// object builds an object schema from exported struct fields
+func (g *schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = g.ref(field.Type)
+
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// schemaName returns the component name of a type, e.g. backupJobRequest → BackupJobRequest
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"evolution-postgres-backup/internal/models"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenBucket is a simple token bucket refilled continuously at rate tokens/second
+type tokenBucket struct {
+	tokens   float64
+	lastFill time.Time
+}
+
+// RateLimiter keeps one token bucket per API key
+type RateLimiter struct {
+	rate    float64
+	burst   float64
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewRateLimiter creates a limiter allowing rate requests/second with the given burst
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Allow takes a token for key. When none is available it returns false and
+// how long the caller should wait before retrying.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: l.burst, lastFill: now}
+		l.buckets[key] = bucket
+	}
+
+	// Refill based on elapsed time
+	elapsed := now.Sub(bucket.lastFill).Seconds()
+	bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed*l.rate)
+	bucket.lastFill = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// RateLimitMiddleware rejects requests with 429 once the caller's API key has
+// used up its bucket. It must run after AuthMiddleware; requests without a key
+// are limited by client IP. A rate of zero or less disables limiting.
+func RateLimitMiddleware(rate float64, burst int) gin.HandlerFunc {
+	if rate <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	limiter := NewRateLimiter(rate, burst)
+
+	return func(c *gin.Context) {
+		key := c.GetString(ContextAPIKey)
+		if key == "" {
+			key = c.ClientIP()
+		}
+
+		allowed, wait := limiter.Allow(key)
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, models.APIResponse{
+				Success: false,
+				Error:   "Rate limit exceeded, retry later",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitFromEnv reads a requests/second and burst pair from the environment
+func rateLimitFromEnv(rateEnv, burstEnv string, defaultRate float64, defaultBurst int) (float64, int) {
+	rate := defaultRate
+	if value := os.Getenv(rateEnv); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			rate = parsed
+		}
+	}
+
+	burst := defaultBurst
+	if value := os.Getenv(burstEnv); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			burst = parsed
+		}
+	}
+
+	return rate, burst
+}
+
+// apiRateLimit returns the limit of every v2 request, RATE_LIMIT_RPS and RATE_LIMIT_BURST
+func apiRateLimit() (float64, int) {
+	return rateLimitFromEnv("RATE_LIMIT_RPS", "RATE_LIMIT_BURST", 10, 20)
+}
+
+// jobsRateLimit returns the stricter limit of endpoints that enqueue work,
+// RATE_LIMIT_JOBS_RPS and RATE_LIMIT_JOBS_BURST
+func jobsRateLimit() (float64, int) {
+	return rateLimitFromEnv("RATE_LIMIT_JOBS_RPS", "RATE_LIMIT_JOBS_BURST", 1, 5)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"evolution-postgres-backup/internal/config"
+	"evolution-postgres-backup/internal/models"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gin-gonic/gin"
+)
+
+// s3ObjectTypes are the backup types whose key prefixes are listed for an instance
+var s3ObjectTypes = []models.BackupType{
+	models.BackupTypeHourly, models.BackupTypeDaily, models.BackupTypeWeekly,
+	models.BackupTypeMonthly, models.BackupTypeManual,
+}
+
+// s3Object is an object found in the bucket
+type s3Object struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	StorageClass string    `json:"storage_class,omitempty"`
+	BackupType   string    `json:"backup_type"` // Backup type of the prefix it was listed under
+}
+
+// instanceS3Objects is returned by GET /postgres/:id/s3-objects
+type instanceS3Objects struct {
+	PostgresID string     `json:"postgres_id"`
+	Bucket     string     `json:"bucket"`
+	Prefixes   []string   `json:"prefixes"`
+	Count      int        `json:"count"`
+	TotalSize  int64      `json:"total_size"`
+	Objects    []s3Object `json:"objects"` // Newest first
+}
+
+// GetInstanceS3Objects lists the objects stored in S3 under an instance's
+// backup key prefixes, straight from the bucket. Neither the backups table nor
+// the instance record is read, so it works after losing either; objects
+// uploaded under an earlier S3_KEY_TEMPLATE or S3_KEY_PREFIX aren't found.
+func (h *V2Handlers) GetInstanceS3Objects(c *gin.Context) {
+	postgresID := c.Param("id")
+
+	s3Client := h.dbService.S3Client()
+	if s3Client == nil {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "S3 is not configured (S3_BUCKET is unset)",
+		})
+		return
+	}
+
+	s3Config := config.S3Config{}
+	s3Config.LoadEnv()
+
+	result := instanceS3Objects{PostgresID: postgresID, Bucket: s3Client.Bucket(), Objects: []s3Object{}}
+	seen := make(map[string]bool)
+	for _, backupType := range s3ObjectTypes {
+		// Templates without {backup_type} give every type the same prefix
+		prefix := s3Config.BackupKeyPrefix(postgresID, string(backupType))
+		if seen[prefix] {
+			continue
+		}
+		seen[prefix] = true
+		result.Prefixes = append(result.Prefixes, prefix)
+
+		objects, err := s3Client.ListFiles(prefix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to list S3 objects: " + err.Error(),
+			})
+			return
+		}
+		for _, object := range objects {
+			result.Objects = append(result.Objects, s3Object{
+				Key:          aws.StringValue(object.Key),
+				Size:         aws.Int64Value(object.Size),
+				LastModified: aws.TimeValue(object.LastModified),
+				StorageClass: aws.StringValue(object.StorageClass),
+				BackupType:   string(backupType),
+			})
+			result.TotalSize += aws.Int64Value(object.Size)
+		}
+	}
+
+	sort.Slice(result.Objects, func(i, j int) bool {
+		return result.Objects[i].LastModified.After(result.Objects[j].LastModified)
+	})
+	result.Count = len(result.Objects)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "S3 objects retrieved successfully",
+		Data:    result,
+	})
+}
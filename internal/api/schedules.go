@@ -0,0 +1,125 @@
+package api
+
+import (
+	"database/sql"
+	"evolution-postgres-backup/internal/database"
+	"evolution-postgres-backup/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oneOffScheduleRequest is the body of POST /schedules/once
+type oneOffScheduleRequest struct {
+	PostgresID   string            `json:"postgresql_id" binding:"required"`
+	DatabaseName string            `json:"database_name" binding:"required"`
+	RunAt        time.Time         `json:"run_at" binding:"required"` // RFC 3339, e.g. 2024-05-01T23:00:00+02:00
+	BackupType   models.BackupType `json:"backup_type"`               // Default manual
+}
+
+// CreateOneOffSchedule schedules a single backup at an absolute time. The
+// worker's scheduler checks for due schedules every 30 seconds and on startup,
+// so a schedule survives restarts and fires late rather than not at all.
+func (h *V2Handlers) CreateOneOffSchedule(c *gin.Context) {
+	var req oneOffScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	if req.BackupType == "" {
+		req.BackupType = models.BackupTypeManual
+	}
+	if !isBackupType(req.BackupType) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "backup_type must be hourly, daily, weekly, monthly or manual",
+		})
+		return
+	}
+	if !req.RunAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "run_at must be in the future",
+		})
+		return
+	}
+
+	runAt := req.RunAt
+	schedule := &database.Schedule{
+		PostgreSQLID: req.PostgresID,
+		DatabaseName: req.DatabaseName,
+		BackupType:   req.BackupType,
+		RunAt:        &runAt,
+		CreatedBy:    c.GetString(ContextAPIKeyName),
+	}
+	if err := h.dbService.CreateOneOffSchedule(schedule); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   "PostgreSQL instance not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create schedule: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Backup scheduled for " + runAt.Format(time.RFC3339),
+		Data:    schedule,
+	})
+}
+
+// GetOneOffSchedules lists one-off schedules, pending ones first; ?pending=true
+// leaves out the ones that already fired
+func (h *V2Handlers) GetOneOffSchedules(c *gin.Context) {
+	pendingOnly, _ := strconv.ParseBool(c.Query("pending"))
+
+	schedules, err := h.dbService.GetOneOffSchedules(pendingOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get schedules: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Schedules retrieved successfully",
+		Data:    schedules,
+	})
+}
+
+// CancelOneOffSchedule deletes a one-off schedule before it fires
+func (h *V2Handlers) CancelOneOffSchedule(c *gin.Context) {
+	if err := h.dbService.CancelOneOffSchedule(c.Param("id")); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   "No pending one-off schedule with this ID",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to cancel schedule: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Schedule cancelled",
+	})
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"evolution-postgres-backup/internal/config"
+	"evolution-postgres-backup/internal/models"
+	"evolution-postgres-backup/internal/worker"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// selfTestTimeout bounds the whole self-test; it's meant for a tiny database
+const selfTestTimeout = 2 * time.Minute
+
+// Outcomes of a self-test stage
+const (
+	selfTestPassed  = "passed"
+	selfTestFailed  = "failed"
+	selfTestSkipped = "skipped" // An earlier stage failed
+)
+
+// selfTestStage is the outcome of one step of the pipeline
+type selfTestStage struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Duration string `json:"duration,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// selfTestReport is returned by POST /system/selftest
+type selfTestReport struct {
+	Passed     bool                 `json:"passed"`
+	PostgresID string               `json:"postgres_id"`
+	Database   string               `json:"database"`
+	Bucket     string               `json:"bucket,omitempty"`
+	S3Key      string               `json:"s3_key,omitempty"`
+	Dump       *worker.SelfTestDump `json:"dump,omitempty"`
+	Duration   string               `json:"duration"`
+	Stages     []selfTestStage      `json:"stages"`
+}
+
+// run runs stage unless an earlier one failed or ctx is done, and records the outcome
+func (r *selfTestReport) run(ctx context.Context, name string, stage func() (string, error)) bool {
+	if !r.Passed {
+		r.skip(name)
+		return false
+	}
+	if ctx.Err() != nil {
+		r.fail(selfTestStage{Name: name, Status: selfTestFailed, Error: fmt.Sprintf("self-test timed out after %s", selfTestTimeout)})
+		return false
+	}
+	return r.runAlways(name, stage)
+}
+
+// runAlways runs stage even after a failure or timeout, for cleanup
+func (r *selfTestReport) runAlways(name string, stage func() (string, error)) bool {
+	start := time.Now()
+	detail, err := stage()
+	result := selfTestStage{Name: name, Status: selfTestPassed, Duration: time.Since(start).Round(time.Millisecond).String(), Detail: detail}
+	if err != nil {
+		result.Status = selfTestFailed
+		result.Error = err.Error()
+		r.fail(result)
+		return false
+	}
+	r.Stages = append(r.Stages, result)
+	return true
+}
+
+// skip records a stage that didn't run
+func (r *selfTestReport) skip(name string) {
+	r.Stages = append(r.Stages, selfTestStage{Name: name, Status: selfTestSkipped})
+}
+
+// fail records a failed stage and fails the report
+func (r *selfTestReport) fail(stage selfTestStage) {
+	r.Passed = false
+	r.Stages = append(r.Stages, stage)
+}
+
+// RunSelfTest backs up a database, uploads the dump under selftest/, checks
+// the object's size and checksum, then deletes it. It runs synchronously
+// within selfTestTimeout and doesn't create a backup record, so point it at a
+// tiny database.
+func (h *V2Handlers) RunSelfTest(c *gin.Context) {
+	postgresID := c.Query("postgres_id")
+	databaseName := c.Query("database")
+	if postgresID == "" || databaseName == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "postgres_id and database are required",
+		})
+		return
+	}
+
+	instance, err := h.dbService.GetPostgreSQLInstance(postgresID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "PostgreSQL instance not found",
+		})
+		return
+	}
+
+	s3Client := h.dbService.S3Client()
+	if s3Client == nil {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   "S3 is not configured (S3_BUCKET is unset), so there is no storage to test",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), selfTestTimeout)
+	defer cancel()
+
+	s3Config := config.S3Config{}
+	s3Config.LoadEnv()
+
+	start := time.Now()
+	report := &selfTestReport{Passed: true, PostgresID: postgresID, Database: databaseName, Bucket: s3Client.Bucket()}
+	var uploaded bool
+
+	report.run(ctx, "dump", func() (string, error) {
+		dump, err := worker.DumpForSelfTest(ctx, instance, databaseName)
+		if err != nil {
+			return "", err
+		}
+		report.Dump = dump
+		return fmt.Sprintf("%d bytes, checksum %s", dump.Size, dump.Checksum), nil
+	})
+	if report.Dump != nil {
+		defer os.Remove(report.Dump.Path)
+		report.S3Key = s3Config.KeyPrefix + "selftest/" + postgresID + "/" + filepath.Base(report.Dump.Path)
+	}
+
+	report.run(ctx, "upload", func() (string, error) {
+		if err := s3Client.UploadFile(report.Dump.Path, report.S3Key); err != nil {
+			return "", err
+		}
+		uploaded = true
+		return "uploaded to s3://" + s3Client.Bucket() + "/" + report.S3Key, nil
+	})
+
+	report.run(ctx, "verify", func() (string, error) {
+		size, err := s3Client.GetFileSize(report.S3Key)
+		if err != nil {
+			return "", fmt.Errorf("object not found after upload: %w", err)
+		}
+		if size != report.Dump.Size {
+			return "", fmt.Errorf("object is %d bytes, the dump is %d", size, report.Dump.Size)
+		}
+
+		downloadPath := report.Dump.Path + ".download"
+		defer os.Remove(downloadPath)
+		if err := s3Client.DownloadFile(report.S3Key, downloadPath); err != nil {
+			return "", err
+		}
+		checksum, err := worker.SelfTestChecksum(downloadPath, report.Dump.Gzipped)
+		if err != nil {
+			return "", fmt.Errorf("failed to compute checksum of the downloaded object: %w", err)
+		}
+		if checksum != report.Dump.Checksum {
+			return "", fmt.Errorf("checksum mismatch: object %s, dump %s", checksum, report.Dump.Checksum)
+		}
+		return fmt.Sprintf("%d bytes, checksum matches", size), nil
+	})
+
+	// Delete the object even when verification failed, so failed runs don't pile up
+	if uploaded {
+		report.runAlways("cleanup", func() (string, error) {
+			if err := s3Client.DeleteFile(report.S3Key); err != nil {
+				return "", err
+			}
+			return "deleted " + report.S3Key, nil
+		})
+	} else {
+		report.skip("cleanup")
+	}
+	report.Duration = time.Since(start).Round(time.Millisecond).String()
+
+	statusCode := http.StatusOK
+	message := "Self-test passed"
+	if !report.Passed {
+		statusCode = http.StatusInternalServerError
+		message = "Self-test failed"
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: report.Passed,
+		Message: message,
+		Data:    report,
+	})
+}
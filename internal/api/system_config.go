@@ -0,0 +1,165 @@
+package api
+
+import (
+	"evolution-postgres-backup/internal/config"
+	"evolution-postgres-backup/internal/database"
+	"evolution-postgres-backup/internal/logger"
+	"evolution-postgres-backup/internal/models"
+	"evolution-postgres-backup/internal/worker"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maskedSecret replaces configured secrets, so callers can tell a secret is
+// set without seeing it
+const maskedSecret = "********"
+
+// maskSecret returns maskedSecret for a set value and "" for an unset one
+func maskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return maskedSecret
+}
+
+// storageSettings is the resolved S3 configuration, secrets masked
+type storageSettings struct {
+	Backend              string           `json:"backend"` // "s3", or "local" without a bucket
+	DataDir              string           `json:"data_dir"`
+	Endpoint             string           `json:"endpoint,omitempty"`
+	Region               string           `json:"region,omitempty"`
+	Bucket               string           `json:"bucket,omitempty"`
+	UseSSL               bool             `json:"use_ssl"`
+	AccessKeyID          string           `json:"access_key_id,omitempty"`
+	SecretAccessKey      string           `json:"secret_access_key,omitempty"`
+	KeyPrefix            string           `json:"key_prefix,omitempty"`
+	KeyTemplate          string           `json:"key_template,omitempty"`
+	MaxConcurrentUploads int              `json:"max_concurrent_uploads"` // 0 = unlimited
+	Replicas             []storageReplica `json:"replicas,omitempty"`
+	ReplicaError         string           `json:"replica_error,omitempty"` // Set when the replicas are misconfigured
+}
+
+// storageReplica is a secondary bucket, resolved against the primary
+type storageReplica struct {
+	Name     string `json:"name"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Bucket   string `json:"bucket"`
+	UseSSL   bool   `json:"use_ssl"`
+}
+
+// apiSettings is the resolved configuration of the API process
+type apiSettings struct {
+	CORSAllowedOrigins   []string `json:"cors_allowed_origins"`
+	RateLimitRPS         float64  `json:"rate_limit_rps"`
+	RateLimitBurst       int      `json:"rate_limit_burst"`
+	JobsRateLimitRPS     float64  `json:"jobs_rate_limit_rps"`
+	JobsRateLimitBurst   int      `json:"jobs_rate_limit_burst"`
+	IdempotencyTTL       string   `json:"idempotency_ttl"`
+	EnqueueTimeout       string   `json:"enqueue_timeout"`
+	FailureRateThreshold float64  `json:"failure_rate_threshold"`
+}
+
+// loggingSettings is the resolved logging and alerting configuration
+type loggingSettings struct {
+	Format          string `json:"format"`
+	RetentionDays   int    `json:"retention_days"`              // 0 keeps all logs
+	MaxMessageBytes int    `json:"max_message_bytes"`           // Longer log messages are truncated
+	MaxDetailsBytes int    `json:"max_details_bytes"`           // Full text of truncated messages is kept up to this size
+	AlertWebhookURL string `json:"alert_webhook_url,omitempty"` // Masked, the URL usually carries a token
+}
+
+// systemConfig is the effective configuration reported by GET /system/config
+type systemConfig struct {
+	Storage             storageSettings             `json:"storage"`
+	Retention           config.RetentionPolicy      `json:"retention"` // Default; instances may override it
+	TrashRetention      string                      `json:"trash_retention"`
+	IDPrefix            string                      `json:"id_prefix,omitempty"`
+	PreBackupSQLAllowed bool                        `json:"pre_backup_sql_allowed"`
+	Queue               worker.QueueSettings        `json:"queue"`
+	Database            database.ConnectionSettings `json:"database"`
+	API                 apiSettings                 `json:"api"`
+	Logging             loggingSettings             `json:"logging"`
+}
+
+// resolveStorageSettings reads the S3 configuration the way the processes do at startup
+func resolveStorageSettings() storageSettings {
+	s3Config := config.S3Config{}
+	s3Config.LoadEnv()
+
+	settings := storageSettings{
+		Backend:              "local",
+		DataDir:              os.Getenv("DATA_DIR"),
+		Endpoint:             s3Config.Endpoint,
+		Region:               s3Config.Region,
+		Bucket:               s3Config.Bucket,
+		UseSSL:               s3Config.UseSSL,
+		AccessKeyID:          maskSecret(s3Config.AccessKeyID),
+		SecretAccessKey:      maskSecret(s3Config.SecretAccessKey),
+		KeyPrefix:            s3Config.KeyPrefix,
+		KeyTemplate:          s3Config.KeyTemplate,
+		MaxConcurrentUploads: s3Config.MaxConcurrentUploads,
+	}
+	if settings.DataDir == "" {
+		settings.DataDir = "backup-data"
+	}
+	if s3Config.Bucket != "" {
+		settings.Backend = "s3"
+	}
+	if err := s3Config.ValidateReplicas(); err != nil {
+		settings.ReplicaError = err.Error()
+	}
+	for _, destination := range s3Config.Replicas {
+		replica := s3Config.ReplicaConfig(destination)
+		settings.Replicas = append(settings.Replicas, storageReplica{
+			Name:     destination.DestinationName(),
+			Endpoint: replica.Endpoint,
+			Region:   replica.Region,
+			Bucket:   replica.Bucket,
+			UseSSL:   replica.UseSSL,
+		})
+	}
+	return settings
+}
+
+// GetSystemConfig reports the effective configuration after environment
+// variables and defaults are applied, with secrets masked. Worker settings are
+// resolved from this process's environment, which the worker is expected to share.
+func (h *V2Handlers) GetSystemConfig(c *gin.Context) {
+	rps, burst := apiRateLimit()
+	jobsRPS, jobsBurst := jobsRateLimit()
+	maxMessage, maxDetails := database.LogSizeLimits()
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Configuration retrieved successfully",
+		Data: systemConfig{
+			Storage:             resolveStorageSettings(),
+			Retention:           h.dbService.GetDB().GlobalRetentionPolicy(),
+			TrashRetention:      config.TrashRetention().String(),
+			IDPrefix:            config.IDPrefix(),
+			PreBackupSQLAllowed: config.PreBackupSQLAllowed(),
+			Queue:               h.jobQueue.Settings(),
+			Database:            h.dbService.GetDB().Settings(),
+			API: apiSettings{
+				CORSAllowedOrigins:   corsOriginsFromEnv(),
+				RateLimitRPS:         rps,
+				RateLimitBurst:       burst,
+				JobsRateLimitRPS:     jobsRPS,
+				JobsRateLimitBurst:   jobsBurst,
+				IdempotencyTTL:       idempotencyTTL().String(),
+				EnqueueTimeout:       enqueueTimeout().String(),
+				FailureRateThreshold: failureRateThreshold(),
+			},
+			Logging: loggingSettings{
+				Format:          logger.Format(),
+				RetentionDays:   logRetentionDays(),
+				MaxMessageBytes: maxMessage,
+				MaxDetailsBytes: maxDetails,
+				AlertWebhookURL: maskSecret(os.Getenv("ALERT_WEBHOOK_URL")),
+			},
+		},
+	})
+}
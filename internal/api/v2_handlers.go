@@ -1,27 +1,39 @@
 package api
 
 import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"evolution-postgres-backup/internal/config"
 	"evolution-postgres-backup/internal/database"
 	"evolution-postgres-backup/internal/models"
 	"evolution-postgres-backup/internal/service"
+	"evolution-postgres-backup/internal/version"
+	"evolution-postgres-backup/internal/worker"
+	"fmt"
+	"log"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 // V2Handlers provides modern API handlers using SQLite
 type V2Handlers struct {
 	dbService *service.DatabaseService
+	jobQueue  *worker.JobQueue
 }
 
 // NewV2Handlers creates new V2 API handlers
-func NewV2Handlers(dbService *service.DatabaseService) *V2Handlers {
+func NewV2Handlers(dbService *service.DatabaseService, jobQueue *worker.JobQueue) *V2Handlers {
 	return &V2Handlers{
 		dbService: dbService,
+		jobQueue:  jobQueue,
 	}
 }
 
@@ -89,6 +101,15 @@ func (h *V2Handlers) GetBackupsAdvanced(c *gin.Context) {
 		filters = append(filters, database.FilterByType(models.BackupType(backupType)))
 	}
 
+	if tag := c.Query("tag"); tag != "" {
+		filters = append(filters, database.FilterByTag(tag))
+	}
+
+	// ?trashed=true lists the trash instead of the live backups
+	if trashed, _ := strconv.ParseBool(c.Query("trashed")); trashed {
+		filters = append(filters, database.FilterTrashed())
+	}
+
 	backups, err := h.dbService.GetBackups(filters...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
@@ -105,6 +126,186 @@ func (h *V2Handlers) GetBackupsAdvanced(c *gin.Context) {
 	})
 }
 
+// protectBackupRequest is the optional body of POST /backups/:id/protect
+type protectBackupRequest struct {
+	Protected *bool `json:"protected"` // Defaults to true; false lifts the protection
+}
+
+// ProtectBackup exempts a backup from retention cleanup, or lifts the exemption
+func (h *V2Handlers) ProtectBackup(c *gin.Context) {
+	var req protectBackupRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Invalid JSON format: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	protected := true
+	if req.Protected != nil {
+		protected = *req.Protected
+	}
+
+	backup, err := h.dbService.SetBackupProtected(c.Param("id"), protected)
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   "Backup not found",
+			})
+		case errors.Is(err, service.ErrBackupTrashed):
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to update backup: " + err.Error(),
+			})
+		}
+		return
+	}
+
+	message := "Backup protected from retention cleanup"
+	if !protected {
+		message = "Backup protection removed"
+	}
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    backup,
+	})
+}
+
+// backupFailureOutput is the captured pg_dump output of a failed backup
+type backupFailureOutput struct {
+	BackupID     string              `json:"backup_id"`
+	Status       models.BackupStatus `json:"status"`
+	ErrorMessage string              `json:"error_message,omitempty"`
+	Output       string              `json:"output"` // Complete stderr, or its last 1 MiB
+}
+
+// GetBackupFailureOutput returns the complete pg_dump output captured when a
+// backup's dump failed. ?format=text returns it as plain text.
+func (h *V2Handlers) GetBackupFailureOutput(c *gin.Context) {
+	backup, err := h.dbService.GetBackup(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Backup not found",
+		})
+		return
+	}
+
+	// Only a failed backup has current output; a retry may have succeeded since
+	if backup.Status != models.BackupStatusFailed {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Backup %s has not failed (status %s)", backup.ID, backup.Status),
+		})
+		return
+	}
+
+	output, err := h.dbService.GetBackupFailureOutput(backup.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get failure output: " + err.Error(),
+		})
+		return
+	}
+	if output == "" {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("No pg_dump output was recorded for backup %s (status %s)", backup.ID, backup.Status),
+		})
+		return
+	}
+
+	if c.Query("format") == "text" {
+		c.String(http.StatusOK, output)
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Failure output retrieved successfully",
+		Data: backupFailureOutput{
+			BackupID:     backup.ID,
+			Status:       backup.Status,
+			ErrorMessage: backup.ErrorMessage,
+			Output:       output,
+		},
+	})
+}
+
+// DeleteBackup moves a backup to the trash. It is deleted from storage for good
+// once TRASH_RETENTION_HOURS have passed, unless restored from the trash first.
+func (h *V2Handlers) DeleteBackup(c *gin.Context) {
+	backup, err := h.dbService.TrashBackup(c.Param("id"))
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   "Backup not found",
+			})
+		case errors.Is(err, service.ErrBackupNotTrashable):
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to delete backup: " + err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Backup moved to trash; it is deleted permanently after %s", backup.PurgeAt.Format(time.RFC3339)),
+		Data:    backup,
+	})
+}
+
+// RestoreBackupFromTrash takes a trashed backup back before it is purged
+func (h *V2Handlers) RestoreBackupFromTrash(c *gin.Context) {
+	backup, err := h.dbService.RestoreBackupFromTrash(c.Param("id"))
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   "Backup not found; it may already have been purged from the trash",
+			})
+		case errors.Is(err, service.ErrBackupNotTrashed):
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to restore backup from trash: " + err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Backup restored from trash",
+		Data:    backup,
+	})
+}
+
 // GetBackupsByInstance returns all backups for a specific PostgreSQL instance
 func (h *V2Handlers) GetBackupsByInstance(c *gin.Context) {
 	postgresID := c.Param("id")
@@ -132,6 +333,125 @@ func (h *V2Handlers) GetBackupsByInstance(c *gin.Context) {
 	})
 }
 
+// GetLatestBackupsByInstance returns the most recent completed backup per database
+func (h *V2Handlers) GetLatestBackupsByInstance(c *gin.Context) {
+	postgresID := c.Param("id")
+	if postgresID == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "PostgreSQL instance ID is required",
+		})
+		return
+	}
+
+	if _, err := h.dbService.GetPostgreSQLInstance(postgresID); err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "PostgreSQL instance not found",
+		})
+		return
+	}
+
+	latest, err := h.dbService.GetLatestBackupsByInstance(postgresID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get latest backups: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Latest backups retrieved successfully",
+		Data:    latest,
+	})
+}
+
+// GetDuplicateBackups reports clusters of completed backups with identical checksums
+func (h *V2Handlers) GetDuplicateBackups(c *gin.Context) {
+	duplicates, err := h.dbService.GetDuplicateBackups(c.Query("postgres_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to find duplicate backups: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Duplicate backups retrieved successfully",
+		Data:    duplicates,
+	})
+}
+
+// GetRetentionReport returns the per-database backup coverage and the
+// retention policy in effect, for compliance audits
+func (h *V2Handlers) GetRetentionReport(c *gin.Context) {
+	report, err := h.dbService.GetRetentionReport()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to build retention report: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Retention report generated successfully",
+		Data:    report,
+	})
+}
+
+// GetStorageByInstance reports the bytes of completed backups per instance
+func (h *V2Handlers) GetStorageByInstance(c *gin.Context) {
+	usage, err := h.dbService.GetStorageByInstance()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get storage usage: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    usage,
+	})
+}
+
+// GetRestores returns the restore audit trail, newest first
+func (h *V2Handlers) GetRestores(c *gin.Context) {
+	filters := database.RestoreFilters{
+		BackupID:   c.Query("backup_id"),
+		PostgresID: c.Query("postgres_id"),
+		Status:     c.Query("status"),
+		Limit:      100,
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
+			filters.Limit = limit
+		}
+	}
+
+	restores, err := h.dbService.GetRestores(filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get restores: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Restores retrieved successfully",
+		Data:    restores,
+	})
+}
+
 // ==================== Advanced Log Management ====================
 
 // GetLogsAdvanced returns logs with advanced filtering
@@ -156,6 +476,7 @@ func (h *V2Handlers) GetLogsAdvanced(c *gin.Context) {
 	filters.Component = c.Query("component")
 	filters.JobID = c.Query("job_id")
 	filters.BackupID = c.Query("backup_id")
+	filters.Search = c.Query("q")
 
 	// Parse limit
 	if limitStr := c.Query("limit"); limitStr != "" {
@@ -165,6 +486,11 @@ func (h *V2Handlers) GetLogsAdvanced(c *gin.Context) {
 	} else {
 		filters.Limit = 100 // Default limit
 	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if offset, err := strconv.Atoi(offsetStr); err == nil && offset > 0 {
+			filters.Offset = offset
+		}
+	}
 
 	logs, err := h.dbService.GetLogs(filters)
 	if err != nil {
@@ -182,6 +508,106 @@ func (h *V2Handlers) GetLogsAdvanced(c *gin.Context) {
 	})
 }
 
+// ExportLogs streams the logs of a date range as a downloadable txt or json file
+func (h *V2Handlers) ExportLogs(c *gin.Context) {
+	format := c.DefaultQuery("format", "txt")
+	if format != "txt" && format != "json" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "format must be txt or json",
+		})
+		return
+	}
+
+	startDate, err := time.Parse("2006-01-02", c.Query("start_date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "start_date is required (YYYY-MM-DD)",
+		})
+		return
+	}
+
+	endDate := startDate
+	if value := c.Query("end_date"); value != "" {
+		endDate, err = time.Parse("2006-01-02", value)
+		if err != nil || endDate.Before(startDate) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "end_date must be a date (YYYY-MM-DD) not before start_date",
+			})
+			return
+		}
+	}
+
+	filters := database.LogFilters{
+		StartDate: startDate,
+		EndDate:   endDate.Add(24*time.Hour - time.Nanosecond), // End of day
+		Level:     c.Query("level"),
+		Component: c.Query("component"),
+		JobID:     c.Query("job_id"),
+		BackupID:  c.Query("backup_id"),
+	}
+
+	filename := fmt.Sprintf("logs_%s_%s.%s", startDate.Format("2006-01-02"), endDate.Format("2006-01-02"), format)
+	contentType := "text/plain; charset=utf-8"
+	if format == "json" {
+		contentType = "application/json"
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	count := 0
+	if format == "json" {
+		w.WriteString("[\n")
+	}
+
+	err = h.dbService.StreamLogs(filters, func(entry *database.LogEntry) error {
+		if format == "json" {
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				w.WriteString(",\n")
+			}
+			_, err = w.Write(data)
+			if err != nil {
+				return err
+			}
+		} else {
+			line := fmt.Sprintf("%s [%s] [%s]", entry.Timestamp.Format(time.RFC3339), entry.Level, entry.Component)
+			if entry.JobID != "" {
+				line += " job=" + entry.JobID
+			}
+			if entry.BackupID != "" {
+				line += " backup=" + entry.BackupID
+			}
+			if _, err := w.WriteString(line + " " + entry.Message + "\n"); err != nil {
+				return err
+			}
+		}
+
+		count++
+		if count%500 == 0 {
+			w.Flush()
+		}
+		return nil
+	})
+
+	if format == "json" {
+		w.WriteString("\n]\n")
+	}
+
+	// Headers are already sent, so a failure can only be logged
+	if err != nil {
+		log.Printf("❌ Log export failed after %d entries: %v", count, err)
+	}
+}
+
 // GetLogsByJobID returns all logs for a specific job
 func (h *V2Handlers) GetLogsByJobID(c *gin.Context) {
 	jobID := c.Param("job_id")
@@ -240,8 +666,16 @@ func (h *V2Handlers) GetLogsByBackupID(c *gin.Context) {
 
 // GetPostgreSQLInstances returns PostgreSQL instances with filtering
 func (h *V2Handlers) GetPostgreSQLInstances(c *gin.Context) {
+	selector, err := config.ParseLabelSelector(c.Query("selector"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
 	var instances []*config.PostgreSQLConfig
-	var err error
 
 	if c.Query("enabled") == "true" {
 		instances, err = h.dbService.GetEnabledPostgreSQLInstances()
@@ -260,7 +694,7 @@ func (h *V2Handlers) GetPostgreSQLInstances(c *gin.Context) {
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "PostgreSQL instances retrieved successfully",
-		Data:    instances,
+		Data:    selector.Filter(instances),
 	})
 }
 
@@ -302,6 +736,10 @@ func (h *V2Handlers) CreatePostgreSQLInstance(c *gin.Context) {
 		return
 	}
 
+	if !requireAdminForPreBackupSQL(c, &instance, "") {
+		return
+	}
+
 	if err := h.dbService.CreatePostgreSQLInstance(&instance); err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -317,6 +755,20 @@ func (h *V2Handlers) CreatePostgreSQLInstance(c *gin.Context) {
 	})
 }
 
+// requireAdminForPreBackupSQL rejects changing pre_backup_sql from stored
+// unless the request uses an admin key, since the SQL runs with the
+// instance's credentials on every backup
+func requireAdminForPreBackupSQL(c *gin.Context, instance *config.PostgreSQLConfig, stored string) bool {
+	if strings.TrimSpace(instance.PreBackupSQL) == strings.TrimSpace(stored) || c.GetString(ContextAPIRole) == RoleAdmin {
+		return true
+	}
+	c.JSON(http.StatusForbidden, models.APIResponse{
+		Success: false,
+		Error:   "Changing pre_backup_sql requires the " + RoleAdmin + " role",
+	})
+	return false
+}
+
 // UpdatePostgreSQLInstance updates a PostgreSQL instance
 func (h *V2Handlers) UpdatePostgreSQLInstance(c *gin.Context) {
 	id := c.Param("id")
@@ -329,7 +781,7 @@ func (h *V2Handlers) UpdatePostgreSQLInstance(c *gin.Context) {
 	}
 
 	var instance config.PostgreSQLConfig
-	if err := c.ShouldBindJSON(&instance); err != nil {
+	if err := c.ShouldBindBodyWith(&instance, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
 			Error:   "Invalid JSON format: " + err.Error(),
@@ -339,7 +791,43 @@ func (h *V2Handlers) UpdatePostgreSQLInstance(c *gin.Context) {
 
 	instance.ID = id // Ensure ID matches URL parameter
 
+	current, err := h.dbService.GetPostgreSQLInstance(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   "PostgreSQL instance not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get PostgreSQL instance: " + err.Error(),
+		})
+		return
+	}
+
+	// Omitting pre_backup_sql keeps the stored SQL, so clients that don't
+	// know about it can't drop it by accident
+	var fields struct {
+		PreBackupSQL *string `json:"pre_backup_sql"`
+	}
+	if err := c.ShouldBindBodyWith(&fields, binding.JSON); err == nil && fields.PreBackupSQL == nil {
+		instance.PreBackupSQL = current.PreBackupSQL
+	}
+
+	if !requireAdminForPreBackupSQL(c, &instance, current.PreBackupSQL) {
+		return
+	}
+
 	if err := h.dbService.UpdatePostgreSQLInstance(&instance); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   "PostgreSQL instance not found",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
 			Error:   "Failed to update PostgreSQL instance: " + err.Error(),
@@ -354,6 +842,115 @@ func (h *V2Handlers) UpdatePostgreSQLInstance(c *gin.Context) {
 	})
 }
 
+// instanceImportRequest is the body of POST /postgres/import and the data of
+// GET /postgres/export, so an export can be posted back as is
+type instanceImportRequest struct {
+	Instances []*config.PostgreSQLConfig `json:"instances" binding:"required"`
+}
+
+// ExportPostgreSQLInstances returns every instance definition for import
+// elsewhere. Passwords and SSL client keys are blanked unless
+// ?include_passwords=true.
+func (h *V2Handlers) ExportPostgreSQLInstances(c *gin.Context) {
+	instances, err := h.dbService.GetPostgreSQLInstances()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to get PostgreSQL instances: " + err.Error(),
+		})
+		return
+	}
+
+	if c.Query("include_passwords") != "true" {
+		for _, instance := range instances {
+			instance.Password = ""
+			instance.SSLKey = ""
+		}
+	}
+	if instances == nil {
+		instances = []*config.PostgreSQLConfig{}
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Exported %d PostgreSQL instances", len(instances)),
+		Data:    instanceImportRequest{Instances: instances},
+	})
+}
+
+// ImportPostgreSQLInstances creates the instances of an export, updating
+// existing IDs with ?overwrite=true and skipping them otherwise
+func (h *V2Handlers) ImportPostgreSQLInstances(c *gin.Context) {
+	var req instanceImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid JSON format: " + err.Error(),
+		})
+		return
+	}
+
+	result := h.dbService.ImportPostgreSQLInstances(req.Instances, c.Query("overwrite") == "true")
+	imported := len(result.Created) + len(result.Updated)
+
+	statusCode := http.StatusOK
+	message := fmt.Sprintf("Imported %d PostgreSQL instances (%d created, %d updated, %d skipped)",
+		imported, len(result.Created), len(result.Updated), len(result.Skipped))
+	if len(result.Errors) > 0 {
+		if imported == 0 && len(result.Skipped) == 0 {
+			statusCode = http.StatusBadRequest
+		} else {
+			statusCode = http.StatusPartialContent
+		}
+		message += fmt.Sprintf(", %d failed", len(result.Errors))
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: len(result.Errors) == 0,
+		Message: message,
+		Data:    result,
+	})
+}
+
+// EnablePostgreSQLInstance resumes scheduled backups of an instance
+func (h *V2Handlers) EnablePostgreSQLInstance(c *gin.Context) {
+	h.setInstanceEnabled(c, true)
+}
+
+// DisablePostgreSQLInstance pauses scheduled backups of an instance
+func (h *V2Handlers) DisablePostgreSQLInstance(c *gin.Context) {
+	h.setInstanceEnabled(c, false)
+}
+
+// setInstanceEnabled updates only the enabled flag of the instance in the URL
+func (h *V2Handlers) setInstanceEnabled(c *gin.Context, enabled bool) {
+	id := c.Param("id")
+	if err := h.dbService.SetPostgreSQLInstanceEnabled(id, enabled); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   "PostgreSQL instance not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to update PostgreSQL instance: " + err.Error(),
+		})
+		return
+	}
+
+	state := "disabled"
+	if enabled {
+		state = "enabled"
+	}
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "PostgreSQL instance " + state,
+		Data:    gin.H{"id": id, "enabled": enabled},
+	})
+}
+
 // DeletePostgreSQLInstance deletes a PostgreSQL instance
 func (h *V2Handlers) DeletePostgreSQLInstance(c *gin.Context) {
 	id := c.Param("id")
@@ -365,6 +962,51 @@ func (h *V2Handlers) DeletePostgreSQLInstance(c *gin.Context) {
 		return
 	}
 
+	// Trashed backups still have files in storage until they are purged
+	backups, err := h.dbService.GetBackups(database.FilterByPostgreSQLID(id), database.IncludeTrashed())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to list instance backups: " + err.Error(),
+		})
+		return
+	}
+
+	// ?purge_backups=true: the cleanup job deletes the backups, then the instance
+	if purge, _ := strconv.ParseBool(c.Query("purge_backups")); purge {
+		// Stop scheduled backups while the purge is queued
+		if err := h.dbService.SetPostgreSQLInstanceEnabled(id, false); err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, models.APIResponse{
+					Success: false,
+					Error:   "PostgreSQL instance not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to disable PostgreSQL instance: " + err.Error(),
+			})
+			return
+		}
+
+		job, err := h.jobQueue.AddPurgeJob(id, worker.PurgePriority(), requestJobOptions(c)...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to create purge job: " + err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, models.APIResponse{
+			Success: true,
+			Message: fmt.Sprintf("PostgreSQL instance will be deleted after purging %d backups", len(backups)),
+			Data:    job,
+		})
+		return
+	}
+
 	if err := h.dbService.DeletePostgreSQLInstance(id); err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -373,9 +1015,14 @@ func (h *V2Handlers) DeletePostgreSQLInstance(c *gin.Context) {
 		return
 	}
 
+	data := gin.H{"id": id, "orphaned_backups": len(backups)}
+	if len(backups) > 0 {
+		data["warning"] = fmt.Sprintf("%d backup files of this instance were left in storage; use ?purge_backups=true to delete them with the instance", len(backups))
+	}
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "PostgreSQL instance deleted successfully",
+		Data:    data,
 	})
 }
 
@@ -385,14 +1032,20 @@ func (h *V2Handlers) DeletePostgreSQLInstance(c *gin.Context) {
 func (h *V2Handlers) GetHealthDetailed(c *gin.Context) {
 	health := h.dbService.HealthCheck()
 
-	// Determine overall status
+	// Determine overall status; unreachable instances only degrade the service
 	overallStatus := "healthy"
 	for _, component := range health {
 		if comp, ok := component.(map[string]interface{}); ok {
-			if status, exists := comp["status"]; exists && status != "healthy" {
-				overallStatus = "unhealthy"
-				break
+			status, exists := comp["status"]
+			if !exists || status == "healthy" {
+				continue
 			}
+			if status == "degraded" {
+				overallStatus = "degraded"
+				continue
+			}
+			overallStatus = "unhealthy"
+			break
 		}
 	}
 
@@ -402,11 +1055,12 @@ func (h *V2Handlers) GetHealthDetailed(c *gin.Context) {
 		"components": health,
 	}
 
-	if overallStatus == "healthy" {
-		c.JSON(http.StatusOK, response)
-	} else {
+	// A degraded service still answers; only an unhealthy one fails the probe
+	if overallStatus == "unhealthy" {
 		c.JSON(http.StatusServiceUnavailable, response)
+		return
 	}
+	c.JSON(http.StatusOK, response)
 }
 
 // ==================== Migration Management ====================
@@ -429,9 +1083,36 @@ func (h *V2Handlers) GetMigrationStatus(c *gin.Context) {
 	})
 }
 
-// PerformMigration performs migration from JSON to SQLite
+// GetMigrationNeeded returns only whether a migration is needed, for dashboards
+// that poll it
+func (h *V2Handlers) GetMigrationNeeded(c *gin.Context) {
+	needed, err := h.dbService.MigrationNeeded()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to check migration: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    gin.H{"needed": needed},
+	})
+}
+
+// PerformMigration starts the migration from JSON to SQLite in the
+// background; GET /migration/status reports its progress
 func (h *V2Handlers) PerformMigration(c *gin.Context) {
-	if err := h.dbService.PerformMigration(); err != nil {
+	run, err := h.dbService.StartMigration()
+	if err != nil {
+		if errors.Is(err, database.ErrMigrationInProgress) {
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Error:   "Migration failed: " + err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
 			Error:   "Migration failed: " + err.Error(),
@@ -439,9 +1120,112 @@ func (h *V2Handlers) PerformMigration(c *gin.Context) {
 		return
 	}
 
+	c.JSON(http.StatusAccepted, models.APIResponse{
+		Success: true,
+		Message: "Migration started; follow it with GET /api/v2/migration/status",
+		Data:    run,
+	})
+}
+
+// AbortMigration cancels the running migration. Data migrated so far is
+// kept, and executing the migration again picks up where it stopped.
+func (h *V2Handlers) AbortMigration(c *gin.Context) {
+	run, err := h.dbService.AbortMigration()
+	if err != nil {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.APIResponse{
+		Success: true,
+		Message: "Migration abort requested; it stops before the next record",
+		Data:    run,
+	})
+}
+
+// GetMigrationBackups lists the JSON files copied aside before migrations
+func (h *V2Handlers) GetMigrationBackups(c *gin.Context) {
+	backups, err := h.dbService.ListJSONBackups()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to list JSON backups: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    backups,
+	})
+}
+
+// DownloadMigrationBackup sends a pre-migration JSON backup as a .tar.gz
+func (h *V2Handlers) DownloadMigrationBackup(c *gin.Context) {
+	name := c.Param("name")
+
+	// The files are small; buffer them so a missing backup can still get a 404
+	var archive bytes.Buffer
+	if err := h.dbService.WriteJSONBackupArchive(name, &archive); err != nil {
+		if errors.Is(err, database.ErrJSONBackupNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to archive JSON backup: " + err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".tar.gz"))
+	c.Data(http.StatusOK, "application/gzip", archive.Bytes())
+}
+
+// ==================== System ====================
+
+// versionInfo describes the running build and the PostgreSQL versions it talks to
+type versionInfo struct {
+	Version         string `json:"version"`
+	GitCommit       string `json:"git_commit"`
+	BuildTime       string `json:"build_time"`
+	GoVersion       string `json:"go_version"`
+	PgDumpVersion   string `json:"pg_dump_version,omitempty"`
+	PgDumpError     string `json:"pg_dump_error,omitempty"`
+	DatabaseVersion string `json:"database_version,omitempty"`
+	DatabaseError   string `json:"database_error,omitempty"`
+}
+
+// GetVersion reports build metadata plus the local pg_dump and service
+// database versions, for support requests
+func (h *V2Handlers) GetVersion(c *gin.Context) {
+	info := versionInfo{
+		Version:   version.Version,
+		GitCommit: version.GitCommit,
+		BuildTime: version.BuildTime,
+		GoVersion: version.GoVersion(),
+	}
+
+	// Tool and database problems are part of the answer, not a failed request
+	if pgDump, err := config.ToolVersion(config.ToolPgDump); err != nil {
+		info.PgDumpError = err.Error()
+	} else {
+		info.PgDumpVersion = pgDump
+	}
+	if err := h.dbService.GetDB().QueryRow("SHOW server_version").Scan(&info.DatabaseVersion); err != nil {
+		info.DatabaseError = err.Error()
+	}
+
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
-		Message: "Migration completed successfully",
+		Message: "Version retrieved successfully",
+		Data:    info,
 	})
 }
 
@@ -480,3 +1264,66 @@ func parseStringSlice(str string) []string {
 
 	return result
 }
+
+// maintenanceRequest is the optional body of POST /system/maintenance
+type maintenanceRequest struct {
+	LogRetentionDays *int `json:"log_retention_days"` // Defaults to LOG_RETENTION_DAYS; 0 keeps all logs
+}
+
+// logRetentionDays returns how many days of logs maintenance keeps,
+// LOG_RETENTION_DAYS (0 keeps all logs)
+func logRetentionDays() int {
+	if value, err := strconv.Atoi(os.Getenv("LOG_RETENTION_DAYS")); err == nil && value > 0 {
+		return value
+	}
+	return 0
+}
+
+// RunMaintenance prunes old logs and runs VACUUM ANALYZE on the service's
+// logs, backups and jobs tables
+func (h *V2Handlers) RunMaintenance(c *gin.Context) {
+	var req maintenanceRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Invalid request format: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	retentionDays := logRetentionDays()
+	if req.LogRetentionDays != nil {
+		if *req.LogRetentionDays < 0 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "log_retention_days must not be negative",
+			})
+			return
+		}
+		retentionDays = *req.LogRetentionDays
+	}
+
+	report, err := h.dbService.RunMaintenance(retentionDays)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Maintenance failed: " + err.Error(),
+		})
+		return
+	}
+
+	statusCode := http.StatusOK
+	message := fmt.Sprintf("Maintenance completed: pruned %d logs, vacuumed %d tables", report.PrunedLogs, len(report.Tables))
+	if !report.Success {
+		statusCode = http.StatusInternalServerError
+		message = "Maintenance completed with errors"
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: report.Success,
+		Message: message,
+		Data:    report,
+	})
+}
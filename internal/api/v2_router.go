@@ -2,7 +2,11 @@ package api
 
 import (
 	"evolution-postgres-backup/internal/service"
+	"evolution-postgres-backup/internal/version"
 	"evolution-postgres-backup/internal/worker"
+	"log"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -17,12 +21,14 @@ func SetupV2Router(dbService *service.DatabaseService, jobQueue *worker.JobQueue
 	router := gin.New()
 
 	// Middleware
-	router.Use(gin.Logger())
+	router.Use(RequestIDMiddleware())
+	router.Use(AccessLogger())
 	router.Use(gin.Recovery())
 	router.Use(setupCORS())
+	router.Use(GzipMiddleware())
 
 	// Initialize handlers
-	v2Handlers := NewV2Handlers(dbService)
+	v2Handlers := NewV2Handlers(dbService, jobQueue)
 	workerHandlers := NewWorkerHandlers(jobQueue)
 
 	// Public routes (no auth required)
@@ -39,11 +45,25 @@ func SetupV2Router(dbService *service.DatabaseService, jobQueue *worker.JobQueue
 
 		// Detailed health check
 		public.GET("/health/detailed", v2Handlers.GetHealthDetailed)
+
+		// Kubernetes probes
+		public.GET("/health/live", workerHandlers.GetLiveness)
+		public.GET("/health/ready", workerHandlers.GetReadiness)
+
+		// OpenAPI specification
+		spec := BuildOpenAPISpec()
+		public.GET("/openapi.json", func(c *gin.Context) {
+			c.JSON(200, spec)
+		})
 	}
 
 	// API v2 routes (require authentication)
 	v2 := router.Group("/api/v2")
 	v2.Use(AuthMiddleware())
+	v2.Use(RateLimitMiddleware(apiRateLimit()))
+
+	// Stricter limit for endpoints that enqueue work
+	jobRateLimit := RateLimitMiddleware(jobsRateLimit())
 	{
 		// ==================== Dashboard & Analytics ====================
 		dashboard := v2.Group("/dashboard")
@@ -55,21 +75,33 @@ func SetupV2Router(dbService *service.DatabaseService, jobQueue *worker.JobQueue
 		// ==================== PostgreSQL Instance Management ====================
 		postgres := v2.Group("/postgres")
 		{
-			postgres.GET("", v2Handlers.GetPostgreSQLInstances) // ?enabled=true for filtered
+			postgres.GET("", v2Handlers.GetPostgreSQLInstances) // ?enabled=true&selector=team=payments for filtered
 			postgres.POST("", v2Handlers.CreatePostgreSQLInstance)
+			postgres.GET("/export", RequireRole(RoleAdmin), v2Handlers.ExportPostgreSQLInstances)  // ?include_passwords=true
+			postgres.POST("/import", RequireRole(RoleAdmin), v2Handlers.ImportPostgreSQLInstances) // ?overwrite=true
 			postgres.GET("/:id", v2Handlers.GetPostgreSQLInstance)
 			postgres.PUT("/:id", v2Handlers.UpdatePostgreSQLInstance)
-			postgres.DELETE("/:id", v2Handlers.DeletePostgreSQLInstance)
+			postgres.DELETE("/:id", RequireRole(RoleAdmin), v2Handlers.DeletePostgreSQLInstance)
+			postgres.POST("/:id/enable", v2Handlers.EnablePostgreSQLInstance)
+			postgres.POST("/:id/disable", v2Handlers.DisablePostgreSQLInstance)
 
 			// Instance-specific backups
 			postgres.GET("/:id/backups", v2Handlers.GetBackupsByInstance)
+			postgres.GET("/:id/backups/latest", v2Handlers.GetLatestBackupsByInstance)
+			postgres.GET("/:id/s3-objects", v2Handlers.GetInstanceS3Objects) // Straight from the bucket, for recovery
+			postgres.POST("/:id/backup-all", jobRateLimit, workerHandlers.CreateInstanceBackupJobs)
+			postgres.POST("/:id/cleanup", jobRateLimit, RequireRole(RoleAdmin), workerHandlers.CreateInstanceCleanupJobs) // ?type=daily&dry_run=true
 		}
 
 		// ==================== Advanced Backup Management ====================
 		backups := v2.Group("/backups")
 		{
-			// Advanced filtering: ?postgres_id=x&status=completed&type=daily&limit=10
+			// Advanced filtering: ?postgres_id=x&status=completed&type=daily&tag=pre-deploy&limit=10
 			backups.GET("", v2Handlers.GetBackupsAdvanced)
+			backups.GET("/duplicates", v2Handlers.GetDuplicateBackups) // ?postgres_id=x
+			backups.GET("/retention-report", v2Handlers.GetRetentionReport)
+			backups.GET("/storage-by-instance", v2Handlers.GetStorageByInstance)
+			backups.POST("/diff", jobRateLimit, workerHandlers.CompareBackups)
 			backups.GET("/:id", func(c *gin.Context) {
 				// Delegate to database service
 				backupID := c.Param("id")
@@ -80,6 +112,22 @@ func SetupV2Router(dbService *service.DatabaseService, jobQueue *worker.JobQueue
 				}
 				c.JSON(200, gin.H{"success": true, "data": backup})
 			})
+			backups.DELETE("/:id", RequireRole(RoleAdmin), v2Handlers.DeleteBackup) // Moves it to the trash
+			backups.POST("/:id/protect", v2Handlers.ProtectBackup)                  // {"protected": false} to unprotect
+			backups.GET("/:id/failure-output", v2Handlers.GetBackupFailureOutput)   // ?format=text
+			backups.POST("/:id/restore-from-trash", RequireRole(RoleAdmin), v2Handlers.RestoreBackupFromTrash)
+		}
+
+		// ==================== Restore History ====================
+		// ?backup_id=x&postgres_id=x&status=completed&limit=100
+		v2.GET("/restores", v2Handlers.GetRestores)
+
+		// ==================== Schedules ====================
+		schedules := v2.Group("/schedules")
+		{
+			schedules.POST("/once", jobRateLimit, v2Handlers.CreateOneOffSchedule)
+			schedules.GET("/once", v2Handlers.GetOneOffSchedules) // ?pending=true
+			schedules.DELETE("/once/:id", v2Handlers.CancelOneOffSchedule)
 		}
 
 		// ==================== Advanced Log Management ====================
@@ -87,6 +135,7 @@ func SetupV2Router(dbService *service.DatabaseService, jobQueue *worker.JobQueue
 		{
 			// Advanced filtering: ?start_date=2025-07-18&level=ERROR&component=BACKUP&job_id=abc123&limit=50
 			logs.GET("", v2Handlers.GetLogsAdvanced)
+			logs.GET("/export", v2Handlers.ExportLogs) // ?start_date=2025-07-18&end_date=2025-07-19&format=txt|json
 			logs.GET("/job/:job_id", v2Handlers.GetLogsByJobID)
 			logs.GET("/backup/:backup_id", v2Handlers.GetLogsByBackupID)
 			logs.GET("/stream", func(c *gin.Context) {
@@ -120,7 +169,10 @@ func SetupV2Router(dbService *service.DatabaseService, jobQueue *worker.JobQueue
 			workers.GET("/stats", workerHandlers.GetQueueStats)
 			workers.GET("/health", workerHandlers.GetQueueHealth)
 			workers.GET("/metrics", workerHandlers.GetQueueMetrics)
-			workers.POST("/restart", workerHandlers.RestartQueue) // Admin only
+			workers.POST("/restart", RequireRole(RoleAdmin), workerHandlers.RestartQueue)
+			workers.POST("/scale", RequireRole(RoleAdmin), workerHandlers.ScaleWorkers)
+			workers.POST("/pause", RequireRole(RoleAdmin), workerHandlers.PauseQueue)
+			workers.POST("/resume", RequireRole(RoleAdmin), workerHandlers.ResumeQueue)
 
 			// Worker status and management
 			workers.GET("/status", workerHandlers.GetWorkerStatus)
@@ -130,14 +182,18 @@ func SetupV2Router(dbService *service.DatabaseService, jobQueue *worker.JobQueue
 			jobs := workers.Group("/jobs")
 			{
 				jobs.GET("/running", workerHandlers.GetRunningJobs)
+				jobs.GET("/:id/stream", workerHandlers.StreamJob)
+				jobs.POST("/cancel-pending", RequireRole(RoleAdmin), workerHandlers.CancelPendingJobs)
 
 				// Create individual jobs
-				jobs.POST("/backup", workerHandlers.CreateBackupJob)
-				jobs.POST("/restore", workerHandlers.CreateRestoreJob)
-				jobs.POST("/cleanup", workerHandlers.CreateCleanupJob)
+				jobs.POST("/backup", jobRateLimit, workerHandlers.CreateBackupJob)
+				jobs.POST("/restore", jobRateLimit, RequireRole(RoleAdmin), workerHandlers.CreateRestoreJob)
+				jobs.POST("/cleanup", jobRateLimit, RequireRole(RoleAdmin), workerHandlers.CreateCleanupJob)
+				jobs.POST("/basebackup", jobRateLimit, RequireRole(RoleAdmin), workerHandlers.CreateBaseBackupJob)
 
 				// Bulk operations
-				jobs.POST("/backup/bulk", workerHandlers.CreateBulkBackupJobs)
+				jobs.POST("/backup/bulk", jobRateLimit, workerHandlers.CreateBulkBackupJobs)
+				jobs.POST("/backup/all", jobRateLimit, RequireRole(RoleAdmin), workerHandlers.CreateAllInstancesBackupJobs)
 			}
 		}
 
@@ -145,7 +201,11 @@ func SetupV2Router(dbService *service.DatabaseService, jobQueue *worker.JobQueue
 		migration := v2.Group("/migration")
 		{
 			migration.GET("/status", v2Handlers.GetMigrationStatus)
-			migration.POST("/execute", v2Handlers.PerformMigration) // Admin only
+			migration.GET("/needed", v2Handlers.GetMigrationNeeded)
+			migration.POST("/execute", RequireRole(RoleAdmin), v2Handlers.PerformMigration)
+			migration.POST("/abort", RequireRole(RoleAdmin), v2Handlers.AbortMigration)
+			migration.GET("/backups", v2Handlers.GetMigrationBackups)
+			migration.GET("/backups/:name/download", RequireRole(RoleAdmin), v2Handlers.DownloadMigrationBackup) // config.json holds passwords
 		}
 
 		// ==================== System Information ====================
@@ -153,7 +213,7 @@ func SetupV2Router(dbService *service.DatabaseService, jobQueue *worker.JobQueue
 		{
 			system.GET("/info", func(c *gin.Context) {
 				info := map[string]interface{}{
-					"version":       "2.0.0",
+					"version":       version.Version,
 					"database_type": "SQLite",
 					"worker_system": "enabled",
 					"features": []string{
@@ -167,22 +227,70 @@ func SetupV2Router(dbService *service.DatabaseService, jobQueue *worker.JobQueue
 				}
 				c.JSON(200, gin.H{"success": true, "data": info})
 			})
+			system.GET("/version", v2Handlers.GetVersion)
+			system.GET("/config", RequireRole(RoleAdmin), v2Handlers.GetSystemConfig)
+			system.POST("/maintenance", RequireRole(RoleAdmin), v2Handlers.RunMaintenance)
+			system.POST("/selftest", jobRateLimit, RequireRole(RoleAdmin), v2Handlers.RunSelfTest) // ?postgres_id=...&database=...
 		}
 	}
 
+	checkOpenAPICoverage(router)
+
 	return router
 }
 
+// defaultCORSOrigins are the dev servers allowed when CORS_ALLOWED_ORIGINS is unset
+var defaultCORSOrigins = []string{"http://localhost:3000", "http://localhost:5173"}
+
 // setupCORS configures CORS middleware
 func setupCORS() gin.HandlerFunc {
-	return cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:5173", "*"}, // Vite dev server + production
+	config := cors.Config{
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept", "X-Requested-With", "api-key"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept", "X-Requested-With", "api-key", "Idempotency-Key", RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
-	})
+	}
+
+	origins := corsOriginsFromEnv()
+	for _, origin := range origins {
+		if origin == "*" {
+			// Browsers reject a wildcard origin on credentialed requests
+			log.Println("⚠️ CORS_ALLOWED_ORIGINS contains \"*\": allowing all origins without credentials")
+			config.AllowAllOrigins = true
+			config.AllowCredentials = false
+			return cors.New(config)
+		}
+	}
+	config.AllowOrigins = origins
+
+	return cors.New(config)
+}
+
+// corsOriginsFromEnv parses the comma-separated CORS_ALLOWED_ORIGINS
+func corsOriginsFromEnv() []string {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	if len(origins) == 0 {
+		return defaultCORSOrigins
+	}
+	return origins
+}
+
+// docsEndpoints groups the documented operations by tag as "METHOD path": summary
+func docsEndpoints() map[string]map[string]string {
+	endpoints := make(map[string]map[string]string)
+	for _, op := range apiOperations {
+		if endpoints[op.Tag] == nil {
+			endpoints[op.Tag] = make(map[string]string)
+		}
+		endpoints[op.Tag][op.Method+" "+op.Path] = op.Summary
+	}
+	return endpoints
 }
 
 // API Documentation endpoints
@@ -191,52 +299,16 @@ func SetupDocsRoutes(router *gin.Engine) {
 	{
 		docs.GET("/", func(c *gin.Context) {
 			c.JSON(200, gin.H{
-				"message": "Evolution PostgreSQL Backup Service API Documentation",
-				"version": "2.0.0",
-				"endpoints": map[string]interface{}{
-					"health": map[string]string{
-						"GET /health":          "Basic health check",
-						"GET /health/detailed": "Detailed system health",
-					},
-					"dashboard": map[string]string{
-						"GET /api/v2/dashboard":        "Dashboard statistics",
-						"GET /api/v2/dashboard/trends": "Backup trends",
-					},
-					"postgres": map[string]string{
-						"GET /api/v2/postgres":             "List PostgreSQL instances",
-						"POST /api/v2/postgres":            "Create PostgreSQL instance",
-						"GET /api/v2/postgres/:id":         "Get specific instance",
-						"PUT /api/v2/postgres/:id":         "Update instance",
-						"DELETE /api/v2/postgres/:id":      "Delete instance",
-						"GET /api/v2/postgres/:id/backups": "Get instance backups",
-					},
-					"backups": map[string]string{
-						"GET /api/v2/backups":     "List backups (with advanced filtering)",
-						"GET /api/v2/backups/:id": "Get specific backup",
-					},
-					"logs": map[string]string{
-						"GET /api/v2/logs":                   "List logs (with advanced filtering)",
-						"GET /api/v2/logs/job/:job_id":       "Get logs for specific job",
-						"GET /api/v2/logs/backup/:backup_id": "Get logs for specific backup",
-					},
-					"workers": map[string]string{
-						"GET /api/v2/workers/stats":             "Queue statistics",
-						"GET /api/v2/workers/health":            "Worker system health",
-						"GET /api/v2/workers/metrics":           "Detailed metrics",
-						"GET /api/v2/workers/status":            "Worker status",
-						"POST /api/v2/workers/restart":          "Restart queue",
-						"GET /api/v2/workers/jobs/running":      "Running jobs",
-						"POST /api/v2/workers/jobs/backup":      "Create backup job",
-						"POST /api/v2/workers/jobs/restore":     "Create restore job",
-						"POST /api/v2/workers/jobs/cleanup":     "Create cleanup job",
-						"POST /api/v2/workers/jobs/backup/bulk": "Create bulk backup jobs",
-					},
-				},
+				"message":   "Evolution PostgreSQL Backup Service API Documentation",
+				"version":   version.Version,
+				"openapi":   "/openapi.json",
+				"endpoints": docsEndpoints(),
 				"query_parameters": map[string]interface{}{
 					"backups": []string{
 						"postgres_id=uuid",
 						"status=pending|in_progress|completed|failed",
 						"type=hourly|daily|weekly|monthly|manual",
+						"tag=pre-deploy",
 					},
 					"logs": []string{
 						"start_date=2025-07-18",
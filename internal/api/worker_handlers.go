@@ -1,11 +1,16 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"evolution-postgres-backup/internal/config"
 	"evolution-postgres-backup/internal/models"
 	"evolution-postgres-backup/internal/worker"
 	"fmt"
-	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"evolution-postgres-backup/internal/database"
@@ -13,15 +18,178 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// defaultIdempotencyTTL is how long an Idempotency-Key is remembered
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyTTL returns the Idempotency-Key window, configurable via IDEMPOTENCY_TTL
+func idempotencyTTL() time.Duration {
+	if value := os.Getenv("IDEMPOTENCY_TTL"); value != "" {
+		if ttl, err := time.ParseDuration(value); err == nil && ttl > 0 {
+			return ttl
+		}
+	}
+	return defaultIdempotencyTTL
+}
+
+// Request bodies of the job endpoints; also used to build the OpenAPI schemas
+
+type backupJobRequest struct {
+	PostgresID   string            `json:"postgresql_id" binding:"required"`
+	DatabaseName string            `json:"database_name" binding:"required"`
+	BackupType   models.BackupType `json:"backup_type" binding:"required"`
+	Priority     int               `json:"priority"` // 1-10, higher runs first; 0 uses the default of the backup type
+	Tags         []string          `json:"tags"`
+	Force        bool              `json:"force"`    // Queue even if the same backup is pending, running or retrying
+	NoBlobs      bool              `json:"no_blobs"` // Leave large objects out of the dump
+}
+
+type instanceBackupRequest struct {
+	BackupType models.BackupType `json:"backup_type"`
+	Priority   int               `json:"priority"`
+	Tags       []string          `json:"tags"`
+	NoBlobs    bool              `json:"no_blobs"`
+}
+
+type restoreJobRequest struct {
+	BackupID     string `json:"backup_id" binding:"required"`
+	PostgresID   string `json:"postgresql_id" binding:"required"`
+	DatabaseName string `json:"database_name" binding:"required"`
+	Priority     int    `json:"priority"`
+
+	// Dry run: check the archive (and optionally restore it into a throwaway database)
+	ValidateOnly   bool `json:"validate_only"`
+	ScratchRestore bool `json:"scratch_restore"`
+
+	// Restore only some tables of a custom-format archive ("name" or "schema.name");
+	// data_only restores their contents into tables that still exist
+	IncludeTables []string `json:"include_tables,omitempty"`
+	ExcludeTables []string `json:"exclude_tables,omitempty"`
+	DataOnly      bool     `json:"data_only"`
+}
+
+type cleanupJobRequest struct {
+	PostgresID string            `json:"postgres_id" binding:"required"`
+	BackupType models.BackupType `json:"backup_type" binding:"required"`
+	Priority   int               `json:"priority"`
+	DryRun     bool              `json:"dry_run"` // Report what retention would remove; also ?dry_run=true
+}
+
+type cancelPendingJobsRequest struct {
+	Type       worker.JobType `json:"type"`        // Empty cancels every type
+	PostgresID string         `json:"postgres_id"` // Empty cancels every instance
+}
+
+type baseBackupJobRequest struct {
+	PostgresID string   `json:"postgres_id" binding:"required"`
+	Priority   int      `json:"priority"`
+	Tags       []string `json:"tags"`
+}
+
+type bulkBackupJobItem struct {
+	PostgresID   string            `json:"postgres_id" binding:"required"`
+	DatabaseName string            `json:"database_name" binding:"required"`
+	BackupType   models.BackupType `json:"backup_type" binding:"required"`
+	Priority     int               `json:"priority"`
+	Tags         []string          `json:"tags"`
+	NoBlobs      bool              `json:"no_blobs"`
+}
+
+// bulkBackupJobRequest lists the jobs to create, or gives a label selector to
+// back up every database of the matching enabled instances
+type bulkBackupJobRequest struct {
+	Jobs []bulkBackupJobItem `json:"jobs"`
+
+	// Selector mode, e.g. "team=payments"; backup_type defaults to manual
+	Selector   string            `json:"selector"`
+	BackupType models.BackupType `json:"backup_type"`
+	Priority   int               `json:"priority"`
+	Tags       []string          `json:"tags"`
+	NoBlobs    bool              `json:"no_blobs"`
+}
+
+type scaleWorkersRequest struct {
+	Workers int `json:"workers" binding:"required"`
+}
+
+// defaultEnqueueTimeout is how long bulk submissions wait for room in a full queue
+const defaultEnqueueTimeout = 30 * time.Second
+
+// enqueueTimeout returns the bulk submission wait, configurable via QUEUE_ENQUEUE_TIMEOUT
+func enqueueTimeout() time.Duration {
+	if value := os.Getenv("QUEUE_ENQUEUE_TIMEOUT"); value != "" {
+		if timeout, err := time.ParseDuration(value); err == nil && timeout >= 0 {
+			return timeout
+		}
+	}
+	return defaultEnqueueTimeout
+}
+
+// Queue health failure-rate defaults
+const (
+	defaultFailureRateThreshold = 0.2
+	failureRateWindow           = time.Hour
+)
+
+// failureRateThreshold returns the share of failed jobs over the last hour above
+// which the queue is degraded, configurable via QUEUE_FAILURE_RATE_THRESHOLD
+func failureRateThreshold() float64 {
+	if value := os.Getenv("QUEUE_FAILURE_RATE_THRESHOLD"); value != "" {
+		if threshold, err := strconv.ParseFloat(value, 64); err == nil && threshold >= 0 && threshold <= 1 {
+			return threshold
+		}
+	}
+	return defaultFailureRateThreshold
+}
+
+// Backup tag limits
+const (
+	maxBackupTags   = 20
+	maxBackupTagLen = 64
+)
+
+// normalizeTags trims tags, drops duplicates and enforces the tag limits
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) > maxBackupTags {
+		return nil, fmt.Errorf("at most %d tags are allowed", maxBackupTags)
+	}
+
+	seen := make(map[string]bool, len(tags))
+	var result []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil, fmt.Errorf("tags must not be empty")
+		}
+		if len(tag) > maxBackupTagLen {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, maxBackupTagLen)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result, nil
+}
+
+// requestJobOptions tags jobs with the request ID and the API key that submitted them
+func requestJobOptions(c *gin.Context) []worker.JobOption {
+	return []worker.JobOption{
+		worker.WithRequestID(c.GetString(ContextRequestID)),
+		worker.WithCreatedBy(c.GetString(ContextAPIKeyName)),
+	}
+}
+
 // WorkerHandlers provides API handlers for worker management
 type WorkerHandlers struct {
-	jobQueue *worker.JobQueue
+	jobQueue  *worker.JobQueue
+	jobStream *jobStreamHub
 }
 
 // NewWorkerHandlers creates new worker API handlers
 func NewWorkerHandlers(jobQueue *worker.JobQueue) *WorkerHandlers {
 	return &WorkerHandlers{
-		jobQueue: jobQueue,
+		jobQueue:  jobQueue,
+		jobStream: newJobStreamHub(jobQueue.GetDB()),
 	}
 }
 
@@ -29,12 +197,7 @@ func NewWorkerHandlers(jobQueue *worker.JobQueue) *WorkerHandlers {
 
 // CreateBackupJob creates a new backup job
 func (h *WorkerHandlers) CreateBackupJob(c *gin.Context) {
-	var req struct {
-		PostgresID   string            `json:"postgresql_id" binding:"required"`
-		DatabaseName string            `json:"database_name" binding:"required"`
-		BackupType   models.BackupType `json:"backup_type" binding:"required"`
-		Priority     int               `json:"priority"`
-	}
+	var req backupJobRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
@@ -46,74 +209,316 @@ func (h *WorkerHandlers) CreateBackupJob(c *gin.Context) {
 
 	// Default priority if not specified
 	if req.Priority == 0 {
-		req.Priority = 5 // Medium priority
+		req.Priority = worker.BackupPriority(req.BackupType)
+	}
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid tags: " + err.Error(),
+		})
+		return
+	}
+
+	backupRepo := database.NewBackupRepository(h.jobQueue.GetDB())
+
+	// A retried request with the same Idempotency-Key gets the original backup
+	// back. Keys are scoped to the API key, and free again after the TTL.
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	keyName := c.GetString(ContextAPIKeyName)
+	idempotencySince := time.Now().Add(-idempotencyTTL())
+	if idempotencyKey != "" {
+		if existing, err := backupRepo.GetByIdempotencyKey(keyName, idempotencyKey, idempotencySince); err == nil {
+			replayIdempotentBackup(c, existing)
+			return
+		}
+		if err := backupRepo.ReleaseIdempotencyKey(keyName, idempotencyKey, idempotencySince); err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to check Idempotency-Key: " + err.Error(),
+			})
+			return
+		}
 	}
 
 	// Create backup record first
 	backup := &models.BackupInfo{
-		ID:           fmt.Sprintf("backup_%d", time.Now().UnixNano()),
-		PostgreSQLID: req.PostgresID,
-		DatabaseName: req.DatabaseName,
-		BackupType:   req.BackupType,
-		Status:       models.BackupStatusPending,
-		StartTime:    time.Now(),
-		CreatedAt:    time.Now(),
+		ID:             config.NewID("backup"),
+		PostgreSQLID:   req.PostgresID,
+		DatabaseName:   req.DatabaseName,
+		BackupType:     req.BackupType,
+		Status:         models.BackupStatusPending,
+		StartTime:      time.Now(),
+		CreatedAt:      time.Now(),
+		IdempotencyKey: idempotencyKey,
+		Tags:           tags,
+		CreatedBy:      keyName,
 	}
 
-	// Save backup record to database
-	backupRepo := database.NewBackupRepository(h.jobQueue.GetDB())
-	if err := backupRepo.Create(backup); err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+	opts := append(requestJobOptions(c), worker.NoBlobs(req.NoBlobs))
+	if force, _ := strconv.ParseBool(c.Query("force")); force || req.Force {
+		opts = append(opts, worker.Force())
+	}
+
+	// Save backup record and enqueue its job
+	if _, err := h.jobQueue.EnqueueBackup(backup, req.Priority, opts...); err != nil {
+		// A concurrent request with the same Idempotency-Key won the insert
+		if database.IsIdempotencyKeyConflict(err) {
+			if existing, getErr := backupRepo.GetByIdempotencyKey(keyName, idempotencyKey, idempotencySince); getErr == nil {
+				replayIdempotentBackup(c, existing)
+				return
+			}
+		}
+
+		// Dedupe to the backup already in flight; force queues another one
+		var duplicate *worker.DuplicateBackupError
+		if errors.As(err, &duplicate) {
+			var existing interface{} = gin.H{"job_id": duplicate.JobID}
+			if duplicate.Backup != nil {
+				existing = duplicate.Backup
+			}
+			c.JSON(http.StatusOK, models.APIResponse{
+				Success: true,
+				Message: "A backup of this database is already pending, running or retrying (job " + duplicate.JobID + "); use force to queue another",
+				Data:    existing,
+			})
+			return
+		}
+		if errors.Is(err, worker.ErrQueueFull) {
+			c.Header("Retry-After", "5")
+			c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		c.JSON(jobErrorStatus(err), models.APIResponse{
 			Success: false,
-			Error:   "Failed to create backup record: " + err.Error(),
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	// Create job with backup_id
-	job := &worker.Job{
-		Type:     worker.JobTypeBackup,
-		Priority: req.Priority,
-		Payload: map[string]interface{}{
-			"postgres_id":   req.PostgresID,
-			"database_name": req.DatabaseName,
-			"backup_type":   string(req.BackupType),
-			"backup_id":     backup.ID, // Include backup_id for worker
-		},
-		MaxRetries: 3,
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Backup job created successfully",
+		Data:    backup, // Return backup instead of job
+	})
+}
+
+// replayIdempotentBackup answers a repeated Idempotency-Key with the backup
+// the first request created
+func replayIdempotentBackup(c *gin.Context, backup *models.BackupInfo) {
+	c.Header("Idempotent-Replayed", "true")
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Backup job already created for this Idempotency-Key",
+		Data:    backup,
+	})
+}
+
+// CreateInstanceBackupJobs creates a backup job for every database of an instance
+func (h *WorkerHandlers) CreateInstanceBackupJobs(c *gin.Context) {
+	postgresID := c.Param("id")
+
+	var req instanceBackupRequest
+
+	// The body is optional
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Invalid request format: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	if req.BackupType == "" {
+		req.BackupType = models.BackupTypeManual
+	}
+	if req.Priority == 0 {
+		req.Priority = worker.BackupPriority(req.BackupType)
 	}
 
-	// Add job to queue
-	if err := h.jobQueue.AddJob(job); err != nil {
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid tags: " + err.Error(),
+		})
+		return
+	}
+
+	pgRepo := database.NewPostgreSQLRepository(h.jobQueue.GetDB())
+	instance, err := pgRepo.GetByID(postgresID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "PostgreSQL instance not found",
+		})
+		return
+	}
+
+	var backups []*models.BackupInfo
+	var errors []string
+
+	for _, dbName := range instance.GetDatabases() {
+		backup := &models.BackupInfo{
+			ID:           config.NewID("backup"),
+			PostgreSQLID: instance.ID,
+			DatabaseName: dbName,
+			BackupType:   req.BackupType,
+			Status:       models.BackupStatusPending,
+			StartTime:    time.Now(),
+			CreatedAt:    time.Now(),
+			Tags:         tags,
+		}
+
+		if _, err := h.jobQueue.EnqueueBackup(backup, req.Priority, append(requestJobOptions(c), worker.NoBlobs(req.NoBlobs))...); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", dbName, err))
+			continue
+		}
+		backups = append(backups, backup)
+	}
+
+	response := map[string]interface{}{
+		"backups":         backups,
+		"created_count":   len(backups),
+		"total_databases": len(instance.GetDatabases()),
+	}
+
+	statusCode := http.StatusCreated
+	message := "Backup jobs created for all databases"
+
+	if len(errors) > 0 {
+		response["errors"] = errors
+		response["error_count"] = len(errors)
+		if len(backups) == 0 {
+			statusCode = http.StatusInternalServerError
+			message = "Failed to create any backup jobs"
+		} else {
+			statusCode = http.StatusPartialContent
+			message = "Some backup jobs created with errors"
+		}
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: len(backups) > 0,
+		Message: message,
+		Data:    response,
+	})
+}
+
+// jobErrorStatus maps a job creation error to an HTTP status: malformed jobs
+// are the client's fault
+func jobErrorStatus(err error) int {
+	if errors.Is(err, worker.ErrInvalidPayload) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// isBackupType reports whether t is one of the backup types
+func isBackupType(t models.BackupType) bool {
+	switch t {
+	case models.BackupTypeHourly, models.BackupTypeDaily, models.BackupTypeWeekly, models.BackupTypeMonthly, models.BackupTypeManual:
+		return true
+	}
+	return false
+}
+
+// CreateAllInstancesBackupJobs creates a backup job for every database of every
+// enabled instance, like a scheduler run triggered on demand. ?selector=
+// limits it to the instances with matching labels.
+func (h *WorkerHandlers) CreateAllInstancesBackupJobs(c *gin.Context) {
+	backupType := models.BackupType(c.DefaultQuery("type", string(models.BackupTypeManual)))
+	if !isBackupType(backupType) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "type must be hourly, daily, weekly, monthly or manual",
+		})
+		return
+	}
+
+	selector, err := config.ParseLabelSelector(c.Query("selector"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	pgRepo := database.NewPostgreSQLRepository(h.jobQueue.GetDB())
+	instances, err := pgRepo.GetEnabled()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
-			Error:   "Failed to add job to queue: " + err.Error(),
+			Error:   "Failed to list enabled instances: " + err.Error(),
 		})
 		return
 	}
+	instances = selector.Filter(instances)
 
-	// Associate backup with job and update
-	backup.JobID = job.ID
-	if err := backupRepo.Update(backup); err != nil {
-		// Don't fail, just log the error
-		log.Printf("Failed to update backup with job_id: %v", err)
+	backupIDs := []string{}
+	var errors []string
+	totalDatabases := 0
+
+	for _, instance := range instances {
+		for _, dbName := range instance.GetDatabases() {
+			totalDatabases++
+			backup := &models.BackupInfo{
+				ID:           config.NewID("backup"),
+				PostgreSQLID: instance.ID,
+				DatabaseName: dbName,
+				BackupType:   backupType,
+				Status:       models.BackupStatusPending,
+				StartTime:    time.Now(),
+				CreatedAt:    time.Now(),
+			}
+
+			if _, err := h.jobQueue.EnqueueBackup(backup, worker.BackupPriority(backupType), requestJobOptions(c)...); err != nil {
+				errors = append(errors, fmt.Sprintf("%s/%s: %v", instance.Name, dbName, err))
+				continue
+			}
+			backupIDs = append(backupIDs, backup.ID)
+		}
 	}
 
-	c.JSON(http.StatusCreated, models.APIResponse{
-		Success: true,
-		Message: "Backup job created successfully",
-		Data:    backup, // Return backup instead of job
+	response := map[string]interface{}{
+		"backup_ids":      backupIDs,
+		"created_count":   len(backupIDs),
+		"instance_count":  len(instances),
+		"total_databases": totalDatabases,
+	}
+
+	statusCode := http.StatusCreated
+	message := "Backup jobs created for all enabled instances"
+
+	if len(errors) > 0 {
+		response["errors"] = errors
+		response["error_count"] = len(errors)
+		if len(backupIDs) == 0 {
+			statusCode = http.StatusInternalServerError
+			message = "Failed to create any backup jobs"
+		} else {
+			statusCode = http.StatusPartialContent
+			message = "Some backup jobs created with errors"
+		}
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: len(errors) == 0 || len(backupIDs) > 0,
+		Message: message,
+		Data:    response,
 	})
 }
 
 // CreateRestoreJob creates a new restore job
 func (h *WorkerHandlers) CreateRestoreJob(c *gin.Context) {
-	var req struct {
-		BackupID     string `json:"backup_id" binding:"required"`
-		PostgresID   string `json:"postgresql_id" binding:"required"`
-		DatabaseName string `json:"database_name" binding:"required"`
-		Priority     int    `json:"priority"`
-	}
+	var req restoreJobRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
@@ -125,12 +530,49 @@ func (h *WorkerHandlers) CreateRestoreJob(c *gin.Context) {
 
 	// Default priority if not specified
 	if req.Priority == 0 {
-		req.Priority = 8 // High priority for restores
+		req.Priority = worker.RestorePriority()
 	}
 
-	job, err := h.jobQueue.AddRestoreJob(req.BackupID, req.PostgresID, req.DatabaseName, req.Priority)
+	opts := requestJobOptions(c)
+	if req.ValidateOnly {
+		opts = append(opts, worker.ValidateOnly(req.ScratchRestore))
+	} else if req.ScratchRestore {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "scratch_restore requires validate_only",
+		})
+		return
+	}
+
+	if len(req.IncludeTables) > 0 || len(req.ExcludeTables) > 0 {
+		if req.ValidateOnly {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "include_tables/exclude_tables can't be combined with validate_only",
+			})
+			return
+		}
+		for _, tables := range [][]string{req.IncludeTables, req.ExcludeTables} {
+			if err := worker.ValidateRestoreTables(tables); err != nil {
+				c.JSON(http.StatusBadRequest, models.APIResponse{
+					Success: false,
+					Error:   err.Error(),
+				})
+				return
+			}
+		}
+		opts = append(opts, worker.RestoreTables(req.IncludeTables, req.ExcludeTables, req.DataOnly))
+	} else if req.DataOnly {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "data_only requires include_tables or exclude_tables",
+		})
+		return
+	}
+
+	job, err := h.jobQueue.AddRestoreJob(req.BackupID, req.PostgresID, req.DatabaseName, req.Priority, opts...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(jobErrorStatus(err), models.APIResponse{
 			Success: false,
 			Error:   "Failed to create restore job: " + err.Error(),
 		})
@@ -146,11 +588,7 @@ func (h *WorkerHandlers) CreateRestoreJob(c *gin.Context) {
 
 // CreateCleanupJob creates a new cleanup job
 func (h *WorkerHandlers) CreateCleanupJob(c *gin.Context) {
-	var req struct {
-		PostgresID string            `json:"postgres_id" binding:"required"`
-		BackupType models.BackupType `json:"backup_type" binding:"required"`
-		Priority   int               `json:"priority"`
-	}
+	var req cleanupJobRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
@@ -162,12 +600,17 @@ func (h *WorkerHandlers) CreateCleanupJob(c *gin.Context) {
 
 	// Default priority if not specified
 	if req.Priority == 0 {
-		req.Priority = 3 // Low priority for cleanup
+		req.Priority = worker.CleanupPriority()
+	}
+
+	opts := requestJobOptions(c)
+	if dryRun, _ := strconv.ParseBool(c.Query("dry_run")); dryRun || req.DryRun {
+		opts = append(opts, worker.DryRun())
 	}
 
-	job, err := h.jobQueue.AddCleanupJob(req.PostgresID, req.BackupType, req.Priority)
+	job, err := h.jobQueue.AddCleanupJob(req.PostgresID, req.BackupType, req.Priority, opts...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(jobErrorStatus(err), models.APIResponse{
 			Success: false,
 			Error:   "Failed to create cleanup job: " + err.Error(),
 		})
@@ -181,6 +624,197 @@ func (h *WorkerHandlers) CreateCleanupJob(c *gin.Context) {
 	})
 }
 
+// retentionBackupTypes are the backup types retention cleanup applies to
+var retentionBackupTypes = []models.BackupType{
+	models.BackupTypeHourly, models.BackupTypeDaily, models.BackupTypeWeekly, models.BackupTypeMonthly,
+}
+
+// CreateInstanceCleanupJobs queues retention cleanup for an instance now, for
+// the backup type in ?type= or for every type its retention policy covers
+func (h *WorkerHandlers) CreateInstanceCleanupJobs(c *gin.Context) {
+	postgresID := c.Param("id")
+
+	pgRepo := database.NewPostgreSQLRepository(h.jobQueue.GetDB())
+	instance, err := pgRepo.GetByID(postgresID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "PostgreSQL instance not found",
+		})
+		return
+	}
+
+	// Only types with a configured count or age; cleaning up the others would
+	// delete nothing at best
+	policy := instance.GetRetentionPolicy(h.jobQueue.GetDB().GlobalRetentionPolicy())
+	var backupTypes []models.BackupType
+	if value := c.Query("type"); value != "" {
+		if _, ok := policy.CountFor(value); !ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Backup type %q is not subject to retention; use hourly, daily, weekly or monthly", value),
+			})
+			return
+		}
+		if !policy.Covers(value) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("The retention policy of this instance sets no limit for %s backups", value),
+			})
+			return
+		}
+		backupTypes = []models.BackupType{models.BackupType(value)}
+	} else {
+		for _, backupType := range retentionBackupTypes {
+			if policy.Covers(string(backupType)) {
+				backupTypes = append(backupTypes, backupType)
+			}
+		}
+	}
+	if len(backupTypes) == 0 {
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Message: "The retention policy of this instance sets no limits; nothing to clean up",
+			Data:    map[string]interface{}{"jobs": []*worker.Job{}, "created_count": 0},
+		})
+		return
+	}
+
+	opts := requestJobOptions(c)
+	if dryRun, _ := strconv.ParseBool(c.Query("dry_run")); dryRun {
+		opts = append(opts, worker.DryRun())
+	}
+
+	jobs := []*worker.Job{}
+	var errors []string
+	for _, backupType := range backupTypes {
+		job, err := h.jobQueue.AddCleanupJob(instance.ID, backupType, worker.CleanupPriority(), opts...)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", backupType, err))
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	response := map[string]interface{}{
+		"jobs":          jobs,
+		"created_count": len(jobs),
+	}
+
+	statusCode := http.StatusCreated
+	message := "Cleanup jobs created"
+
+	if len(errors) > 0 {
+		response["errors"] = errors
+		response["error_count"] = len(errors)
+		if len(jobs) == 0 {
+			statusCode = http.StatusInternalServerError
+			message = "Failed to create any cleanup jobs"
+		} else {
+			statusCode = http.StatusPartialContent
+			message = "Some cleanup jobs created with errors"
+		}
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: len(jobs) > 0,
+		Message: message,
+		Data:    response,
+	})
+}
+
+// CreateBaseBackupJob creates a pg_basebackup job for a whole instance
+func (h *WorkerHandlers) CreateBaseBackupJob(c *gin.Context) {
+	var req baseBackupJobRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	// Default priority if not specified
+	if req.Priority == 0 {
+		req.Priority = worker.BaseBackupPriority()
+	}
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid tags: " + err.Error(),
+		})
+		return
+	}
+
+	pgRepo := database.NewPostgreSQLRepository(h.jobQueue.GetDB())
+	if exists, err := pgRepo.Exists(req.PostgresID); err != nil || !exists {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "PostgreSQL instance not found",
+		})
+		return
+	}
+
+	job, err := h.jobQueue.AddBaseBackupJob(req.PostgresID, req.Priority,
+		append(requestJobOptions(c), worker.WithTags(tags))...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to create base backup job: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Message: "Base backup job created successfully",
+		Data:    job,
+	})
+}
+
+// CancelPendingJobs cancels the pending and retrying jobs matching the
+// optional type and postgres_id filters
+func (h *WorkerHandlers) CancelPendingJobs(c *gin.Context) {
+	var req cancelPendingJobsRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   "Invalid request format: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	switch req.Type {
+	case "", worker.JobTypeBackup, worker.JobTypeRestore, worker.JobTypeCleanup, worker.JobTypeBaseBackup, worker.JobTypeSchemaDiff:
+	default:
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Unknown job type %q", req.Type),
+		})
+		return
+	}
+
+	cancelled, err := h.jobQueue.CancelPendingJobs(req.Type, req.PostgresID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to cancel jobs: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Cancelled %d pending jobs", cancelled),
+		Data:    gin.H{"cancelled": cancelled, "type": req.Type, "postgres_id": req.PostgresID},
+	})
+}
+
 // ==================== Queue Monitoring ====================
 
 // GetQueueStats returns queue statistics
@@ -216,6 +850,57 @@ func (h *WorkerHandlers) GetWorkerStatus(c *gin.Context) {
 	})
 }
 
+// GetLiveness reports whether the process is up. It only fails while the
+// queue is draining for shutdown.
+func (h *WorkerHandlers) GetLiveness(c *gin.Context) {
+	if h.jobQueue.IsDraining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down", "timestamp": time.Now()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "alive", "timestamp": time.Now()})
+}
+
+// GetReadiness reports whether the service can handle traffic: the database
+// is reachable and, when this process runs workers, the queue is running
+// with at least one active worker
+func (h *WorkerHandlers) GetReadiness(c *gin.Context) {
+	checks := map[string]string{}
+	ready := true
+
+	if err := h.jobQueue.GetDB().Ping(); err != nil {
+		checks["database"] = "unreachable: " + err.Error()
+		ready = false
+	} else {
+		checks["database"] = "ok"
+	}
+
+	if h.jobQueue.Started() {
+		activeWorkers := 0
+		for _, worker := range h.jobQueue.GetWorkerStatus() {
+			if worker.Status == "working" || worker.Status == "idle" {
+				activeWorkers++
+			}
+		}
+
+		switch {
+		case !h.jobQueue.IsRunning() || h.jobQueue.IsDraining():
+			checks["queue"] = "not running"
+			ready = false
+		case activeWorkers == 0:
+			checks["queue"] = "no active workers"
+			ready = false
+		default:
+			checks["queue"] = fmt.Sprintf("ok (%d active workers)", activeWorkers)
+		}
+	}
+
+	status, statusCode := "ready", http.StatusOK
+	if !ready {
+		status, statusCode = "not_ready", http.StatusServiceUnavailable
+	}
+	c.JSON(statusCode, gin.H{"status": status, "timestamp": time.Now(), "checks": checks})
+}
+
 // GetQueueHealth returns queue health information
 func (h *WorkerHandlers) GetQueueHealth(c *gin.Context) {
 	stats := h.jobQueue.GetStats()
@@ -244,28 +929,53 @@ func (h *WorkerHandlers) GetQueueHealth(c *gin.Context) {
 		issues = append(issues, "no active workers")
 	}
 
-	// Check for too many failed jobs
-	if stats.FailedJobs > 10 {
-		health = "degraded"
-		issues = append(issues, "high number of failed jobs")
+	// Check the recent failure rate; the all-time count only ever grows
+	threshold := failureRateThreshold()
+	recentFailed, recentFinished, err := h.jobQueue.RecentFailures(failureRateWindow)
+	failureRate := 0.0
+	if err != nil {
+		issues = append(issues, "failed to read recent job failures: "+err.Error())
+	} else if recentFinished > 0 {
+		failureRate = float64(recentFailed) / float64(recentFinished)
+	}
+	if failureRate > threshold {
+		if health == "healthy" {
+			health = "degraded"
+		}
+		issues = append(issues, fmt.Sprintf("%.0f%% of jobs failed in the last hour (threshold %.0f%%)", failureRate*100, threshold*100))
+	}
+
+	// Read from the database: the workers may run in another process
+	paused, err := h.jobQueue.Paused()
+	if err != nil {
+		issues = append(issues, "failed to read queue pause flag: "+err.Error())
+	} else if paused {
+		if health == "healthy" {
+			health = "degraded"
+		}
+		issues = append(issues, "queue is paused; no new jobs start until POST /api/v2/workers/resume")
 	}
 
 	response := map[string]interface{}{
-		"status":         health,
-		"active_workers": activeWorkers,
-		"total_workers":  len(workers),
-		"pending_jobs":   stats.PendingJobs,
-		"running_jobs":   stats.RunningJobs,
-		"failed_jobs":    stats.FailedJobs,
-		"completed_jobs": stats.CompletedJobs,
-		"issues":         issues,
+		"status":                 health,
+		"paused":                 paused,
+		"active_workers":         activeWorkers,
+		"total_workers":          len(workers),
+		"pending_jobs":           stats.PendingJobs,
+		"running_jobs":           stats.RunningJobs,
+		"failed_jobs":            stats.FailedJobs,
+		"completed_jobs":         stats.CompletedJobs,
+		"recent_failed_jobs":     recentFailed,
+		"recent_finished_jobs":   recentFinished,
+		"failure_rate":           failureRate,
+		"failure_rate_threshold": threshold,
+		"issues":                 issues,
 	}
 
+	// A degraded queue still answers with 200; the status field says why
 	statusCode := http.StatusOK
 	if health == "unhealthy" {
 		statusCode = http.StatusServiceUnavailable
-	} else if health == "degraded" {
-		statusCode = http.StatusPartialContent
 	}
 
 	c.JSON(statusCode, models.APIResponse{
@@ -279,14 +989,7 @@ func (h *WorkerHandlers) GetQueueHealth(c *gin.Context) {
 
 // CreateBulkBackupJobs creates multiple backup jobs at once
 func (h *WorkerHandlers) CreateBulkBackupJobs(c *gin.Context) {
-	var req struct {
-		Jobs []struct {
-			PostgresID   string            `json:"postgres_id" binding:"required"`
-			DatabaseName string            `json:"database_name" binding:"required"`
-			BackupType   models.BackupType `json:"backup_type" binding:"required"`
-			Priority     int               `json:"priority"`
-		} `json:"jobs" binding:"required,min=1"`
-	}
+	var req bulkBackupJobRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
@@ -296,16 +999,51 @@ func (h *WorkerHandlers) CreateBulkBackupJobs(c *gin.Context) {
 		return
 	}
 
+	if (len(req.Jobs) > 0) == (req.Selector != "") {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Give either jobs or selector",
+		})
+		return
+	}
+	if req.Selector != "" {
+		jobs, status, err := h.selectorBackupJobs(req)
+		if err != nil {
+			c.JSON(status, models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		req.Jobs = jobs
+	}
+
 	var createdJobs []*worker.Job
 	var errors []string
 
+	// Wait for room when the queue is saturated instead of failing part of the batch
+	ctx, cancel := context.WithTimeout(c.Request.Context(), enqueueTimeout())
+	defer cancel()
+
 	for i, jobReq := range req.Jobs {
 		priority := jobReq.Priority
 		if priority == 0 {
-			priority = 5 // Medium priority
+			priority = worker.BackupPriority(jobReq.BackupType)
 		}
 
-		job, err := h.jobQueue.AddBackupJob(jobReq.PostgresID, jobReq.DatabaseName, jobReq.BackupType, priority)
+		tags, err := normalizeTags(jobReq.Tags)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("Job %d: invalid tags: %v", i+1, err))
+			continue
+		}
+
+		if err := h.jobQueue.WaitForCapacity(ctx); err != nil {
+			errors = append(errors, fmt.Sprintf("Job %d: %v", i+1, err))
+			continue
+		}
+
+		job, err := h.jobQueue.AddBackupJob(jobReq.PostgresID, jobReq.DatabaseName, jobReq.BackupType, priority,
+			append(requestJobOptions(c), worker.WithTags(tags), worker.NoBlobs(jobReq.NoBlobs))...)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("Job %d: %v", i+1, err))
 		} else {
@@ -344,11 +1082,58 @@ func (h *WorkerHandlers) CreateBulkBackupJobs(c *gin.Context) {
 	})
 }
 
+// selectorBackupJobs expands a selector bulk request into one job per database
+// of every enabled instance the selector matches
+func (h *WorkerHandlers) selectorBackupJobs(req bulkBackupJobRequest) ([]bulkBackupJobItem, int, error) {
+	selector, err := config.ParseLabelSelector(req.Selector)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if req.BackupType == "" {
+		req.BackupType = models.BackupTypeManual
+	}
+	if !isBackupType(req.BackupType) {
+		return nil, http.StatusBadRequest, fmt.Errorf("backup_type must be hourly, daily, weekly, monthly or manual")
+	}
+
+	instances, err := database.NewPostgreSQLRepository(h.jobQueue.GetDB()).GetEnabled()
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to list enabled instances: %w", err)
+	}
+
+	var jobs []bulkBackupJobItem
+	for _, instance := range selector.Filter(instances) {
+		for _, dbName := range instance.GetDatabases() {
+			jobs = append(jobs, bulkBackupJobItem{
+				PostgresID:   instance.ID,
+				DatabaseName: dbName,
+				BackupType:   req.BackupType,
+				Priority:     req.Priority,
+				Tags:         req.Tags,
+				NoBlobs:      req.NoBlobs,
+			})
+		}
+	}
+	if len(jobs) == 0 {
+		return nil, http.StatusNotFound, fmt.Errorf("no enabled instances match selector %q", req.Selector)
+	}
+	return jobs, 0, nil
+}
+
 // GetQueueMetrics returns detailed queue metrics
 func (h *WorkerHandlers) GetQueueMetrics(c *gin.Context) {
 	stats := h.jobQueue.GetStats()
 	workers := h.jobQueue.GetWorkerStatus()
 	runningJobs := h.jobQueue.GetRunningJobs()
+	capacity := h.jobQueue.Capacity()
+	restoreLimit, err := h.jobQueue.GetRestoreLimit()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
 
 	// Calculate additional metrics
 	totalWorkers := len(workers)
@@ -379,10 +1164,12 @@ func (h *WorkerHandlers) GetQueueMetrics(c *gin.Context) {
 			"stopped": totalWorkers - idleWorkers - workingWorkers,
 		},
 		"job_type_breakdown": jobTypeBreakdown,
+		"job_durations":      h.jobQueue.GetJobDurations(),
+		"restore_limit":      restoreLimit,
 		"queue_capacity": map[string]interface{}{
-			"max_jobs":     1000, // From queue buffer size
-			"current_jobs": stats.PendingJobs,
-			"utilization":  float64(stats.PendingJobs) / 1000.0 * 100,
+			"max_jobs":     capacity.Capacity,
+			"current_jobs": capacity.Buffered,
+			"utilization":  capacity.Utilization,
 		},
 	}
 
@@ -423,6 +1210,71 @@ func (h *WorkerHandlers) RestartQueue(c *gin.Context) {
 	})
 }
 
+// PauseQueue stops workers from starting new jobs; running jobs finish and
+// new jobs stay pending until ResumeQueue (admin only)
+func (h *WorkerHandlers) PauseQueue(c *gin.Context) {
+	if err := h.jobQueue.Pause(); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to pause queue: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Queue paused; running jobs will finish",
+		Data:    map[string]interface{}{"paused": true},
+	})
+}
+
+// ResumeQueue lets workers start jobs again after PauseQueue (admin only)
+func (h *WorkerHandlers) ResumeQueue(c *gin.Context) {
+	if err := h.jobQueue.Resume(); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to resume queue: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Queue resumed",
+		Data:    map[string]interface{}{"paused": false},
+	})
+}
+
+// ScaleWorkers changes the number of workers at runtime (admin only)
+func (h *WorkerHandlers) ScaleWorkers(c *gin.Context) {
+	var req scaleWorkersRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	count, err := h.jobQueue.Scale(req.Workers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Failed to scale workers: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Workers scaled successfully",
+		Data: map[string]interface{}{
+			"worker_count": count,
+		},
+	})
+}
+
 // GetDetailedWorkerInfo returns detailed information about a specific worker
 func (h *WorkerHandlers) GetDetailedWorkerInfo(c *gin.Context) {
 	workerID := c.Param("worker_id")
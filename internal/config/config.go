@@ -2,10 +2,13 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type PostgreSQLConfig struct {
@@ -18,7 +21,400 @@ type PostgreSQLConfig struct {
 	Username  string   `json:"username"`
 	Password  string   `json:"password"`
 	Enabled   bool     `json:"enabled"`
-	SSLMode   string   `json:"ssl_mode,omitempty"` // For PostgreSQL connections: disable, allow, prefer, require
+	SSLMode   string   `json:"ssl_mode,omitempty"` // For PostgreSQL connections: disable, allow, prefer, require, verify-ca, verify-full
+
+	// Certificates for verify-ca/verify-full and client auth: file path or PEM content
+	SSLRootCert string `json:"ssl_root_cert,omitempty"`
+	SSLCert     string `json:"ssl_cert,omitempty"`
+	SSLKey      string `json:"ssl_key,omitempty"`
+
+	// RetentionPolicy overrides the global retention policy for this instance when set
+	RetentionPolicy *RetentionPolicy `json:"retention_policy,omitempty"`
+
+	// Extra pg_dump flags, restricted to allowedDumpOptions
+	DumpOptions []string `json:"dump_options,omitempty"`
+
+	// Compression of the dump file: "" (none) or gzip, with level 1-9
+	Compression      string `json:"compression,omitempty"`
+	CompressionLevel int    `json:"compression_level,omitempty"`
+
+	// TempDir overrides BACKUP_TEMP_DIR for this instance's dump files
+	TempDir string `json:"temp_dir,omitempty"`
+
+	// Timeouts for backup sessions in PostgreSQL syntax, e.g. "30min" or "5s"
+	StatementTimeout string `json:"statement_timeout,omitempty"`
+	LockTimeout      string `json:"lock_timeout,omitempty"`
+
+	// DumpTimeout stops a pg_dump run that takes longer, in the same syntax.
+	// pg_dump resets statement_timeout on its session, so only this bounds a dump.
+	DumpTimeout string `json:"dump_timeout,omitempty"`
+
+	// MaxBackupSizeBytes aborts backups whose file grows past this size; 0 = no limit
+	MaxBackupSizeBytes int64 `json:"max_backup_size_bytes,omitempty"`
+
+	// Scheduled backup types (hourly, daily, weekly, monthly) to run; empty = all.
+	// DatabaseBackupTypes overrides the list per database; [] disables scheduled backups.
+	BackupTypes         []string            `json:"backup_types,omitempty"`
+	DatabaseBackupTypes map[string][]string `json:"database_backup_types,omitempty"`
+
+	// Pooled marks Host/Port as a connection pooler such as PgBouncer in
+	// transaction mode; backup tools then connect to DirectHost/DirectPort
+	Pooled     bool   `json:"pooled,omitempty"`
+	DirectHost string `json:"direct_host,omitempty"`
+	DirectPort int    `json:"direct_port,omitempty"` // 0 = Port
+
+	// Labels group instances for listing and bulk backups, e.g. {"team": "payments"}
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Backup hooks: SQL run against each database before it is dumped (only
+	// with ALLOW_PRE_BACKUP_SQL), and a URL posted to after a successful backup
+	PreBackupSQL      string `json:"pre_backup_sql,omitempty"`
+	PostBackupWebhook string `json:"post_backup_webhook,omitempty"`
+}
+
+// Compression formats
+const (
+	CompressionNone = ""
+	CompressionGzip = "gzip"
+)
+
+// DefaultCompressionLevel is used when gzip is enabled without a level
+const DefaultCompressionLevel = 6
+
+// allowedDumpOptions lists the pg_dump flags instances may add. Flags ending in
+// "=" take a value and must be written as --flag=value so the value can never
+// become a separate argument.
+var allowedDumpOptions = map[string]bool{
+	"--no-owner":                      true,
+	"--no-privileges":                 true,
+	"--no-acl":                        true,
+	"--clean":                         true,
+	"--if-exists":                     true,
+	"--create":                        true,
+	"--no-comments":                   true,
+	"--no-publications":               true,
+	"--no-subscriptions":              true,
+	"--no-tablespaces":                true,
+	"--no-security-labels":            true,
+	"--no-unlogged-table-data":        true,
+	"--no-sync":                       true,
+	"--inserts":                       true,
+	"--column-inserts":                true,
+	"--quote-all-identifiers":         true,
+	"--serializable-deferrable":       true,
+	"--disable-triggers":              true,
+	"--data-only":                     true,
+	"--schema-only":                   true,
+	"--schema=":                       true,
+	"--exclude-schema=":               true,
+	"--table=":                        true,
+	"--exclude-table=":                true,
+	"--exclude-table-data=":           true,
+	"--lock-wait-timeout=":            true,
+	"--extra-float-digits=":           true,
+	"--rows-per-insert=":              true,
+	"--on-conflict-do-nothing":        true,
+	"--load-via-partition-root":       true,
+	"--enable-row-security":           true,
+	"--no-toast-compression":          true,
+	"--no-table-access-method":        true,
+	"--strict-names":                  true,
+	"--use-set-session-authorization": true,
+}
+
+// ValidateDumpOptions checks every dump option against the allowlist
+func (pg *PostgreSQLConfig) ValidateDumpOptions() error {
+	for _, option := range pg.DumpOptions {
+		name, value, hasValue := strings.Cut(option, "=")
+		if hasValue {
+			if !allowedDumpOptions[name+"="] {
+				return fmt.Errorf("pg_dump option %q is not allowed", option)
+			}
+			if value == "" || strings.ContainsAny(value, "\x00\n\r") {
+				return fmt.Errorf("pg_dump option %q has an invalid value", option)
+			}
+			continue
+		}
+		if !allowedDumpOptions[name] {
+			return fmt.Errorf("pg_dump option %q is not allowed", option)
+		}
+	}
+	return nil
+}
+
+// DumpArgs returns the pg_dump arguments for databaseName shared by every
+// dump of the instance: the connection, --verbose and --no-password,
+// --lock-wait-timeout for lock_timeout (pg_dump clears lock_timeout on its
+// session), the extra flags and then dump_options, which are re-checked so a
+// bad row can't inject arguments. An empty outputPath leaves out -f, so the
+// dump goes to stdout. Call it on the config returned by ForBackup.
+func (pg *PostgreSQLConfig) DumpArgs(databaseName, outputPath string, extra ...string) ([]string, error) {
+	if err := pg.ValidateDumpOptions(); err != nil {
+		return nil, fmt.Errorf("invalid dump options: %w", err)
+	}
+
+	args := []string{
+		"-h", pg.Host,
+		"-p", fmt.Sprintf("%d", pg.Port),
+		"-U", pg.Username,
+		"-d", databaseName,
+	}
+	if outputPath != "" {
+		args = append(args, "-f", outputPath)
+	}
+	args = append(args, "--verbose", "--no-password")
+	if pg.LockTimeout != "" {
+		args = append(args, "--lock-wait-timeout="+pg.LockTimeout)
+	}
+	args = append(args, extra...)
+	return append(args, pg.DumpOptions...), nil
+}
+
+// ValidateCompression checks the compression format and level
+func (pg *PostgreSQLConfig) ValidateCompression() error {
+	switch pg.Compression {
+	case CompressionNone:
+		if pg.CompressionLevel != 0 {
+			return fmt.Errorf("compression_level requires compression to be %s", CompressionGzip)
+		}
+	case CompressionGzip:
+		if pg.CompressionLevel != 0 && (pg.CompressionLevel < 1 || pg.CompressionLevel > 9) {
+			return fmt.Errorf("compression_level must be between 1 and 9")
+		}
+	default:
+		return fmt.Errorf("invalid compression %q (use %s or leave empty)", pg.Compression, CompressionGzip)
+	}
+	return nil
+}
+
+// GetCompressionLevel returns the gzip level, with default fallback
+func (pg *PostgreSQLConfig) GetCompressionLevel() int {
+	if pg.CompressionLevel != 0 {
+		return pg.CompressionLevel
+	}
+	return DefaultCompressionLevel
+}
+
+// ValidateTimeouts checks that the statement, lock and dump timeouts parse
+func (pg *PostgreSQLConfig) ValidateTimeouts() error {
+	if pg.StatementTimeout != "" {
+		if _, err := ParsePGDuration(pg.StatementTimeout); err != nil {
+			return fmt.Errorf("statement_timeout: %w", err)
+		}
+	}
+	if pg.LockTimeout != "" {
+		if _, err := ParsePGDuration(pg.LockTimeout); err != nil {
+			return fmt.Errorf("lock_timeout: %w", err)
+		}
+	}
+	if _, err := pg.GetDumpTimeout(); err != nil {
+		return fmt.Errorf("dump_timeout: %w", err)
+	}
+	return nil
+}
+
+// GetDumpTimeout returns the limit of a pg_dump run, 0 when there is none
+func (pg *PostgreSQLConfig) GetDumpTimeout() (time.Duration, error) {
+	if pg.DumpTimeout == "" {
+		return 0, nil
+	}
+	return ParsePGDuration(pg.DumpTimeout)
+}
+
+// ValidateMaxBackupSize checks that the backup size limit is not negative
+func (pg *PostgreSQLConfig) ValidateMaxBackupSize() error {
+	if pg.MaxBackupSizeBytes < 0 {
+		return fmt.Errorf("%d must be 0 (no limit) or a positive number of bytes", pg.MaxBackupSizeBytes)
+	}
+	return nil
+}
+
+// scheduledBackupTypes are the backup types the scheduler creates
+var scheduledBackupTypes = map[string]bool{
+	"hourly":  true,
+	"daily":   true,
+	"weekly":  true,
+	"monthly": true,
+}
+
+// ValidateBackupTypes checks the scheduled backup type lists, and that every
+// per-database override names a database of the instance
+func (pg *PostgreSQLConfig) ValidateBackupTypes() error {
+	check := func(types []string) error {
+		for _, backupType := range types {
+			if !scheduledBackupTypes[backupType] {
+				return fmt.Errorf("%q is not a scheduled backup type (use hourly, daily, weekly or monthly)", backupType)
+			}
+		}
+		return nil
+	}
+
+	if err := check(pg.BackupTypes); err != nil {
+		return err
+	}
+	databases := make(map[string]bool)
+	for _, database := range pg.GetDatabases() {
+		databases[database] = true
+	}
+	for database, types := range pg.DatabaseBackupTypes {
+		if !databases[database] {
+			return fmt.Errorf("database %q is not backed up by this instance", database)
+		}
+		if err := check(types); err != nil {
+			return fmt.Errorf("database %q: %w", database, err)
+		}
+	}
+	return nil
+}
+
+// BackupTypeEnabled reports whether scheduled backups of backupType run for
+// database. A database's override applies when present, an empty one
+// disabling scheduled backups; otherwise an empty instance list enables every type.
+func (pg *PostgreSQLConfig) BackupTypeEnabled(database, backupType string) bool {
+	types, ok := pg.DatabaseBackupTypes[database]
+	if !ok {
+		if len(pg.BackupTypes) == 0 {
+			return true
+		}
+		types = pg.BackupTypes
+	}
+	for _, enabled := range types {
+		if enabled == backupType {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduledBackupIntervals is how often the scheduler runs each backup type
+var scheduledBackupIntervals = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 31 * 24 * time.Hour,
+}
+
+// ExpectedBackupInterval returns the longest database should go without a
+// scheduled backup: the interval of its most frequent enabled backup type, or
+// 0 when no scheduled backups run for it
+func (pg *PostgreSQLConfig) ExpectedBackupInterval(database string) time.Duration {
+	var interval time.Duration
+	for backupType, typeInterval := range scheduledBackupIntervals {
+		if pg.BackupTypeEnabled(database, backupType) && (interval == 0 || typeInterval < interval) {
+			interval = typeInterval
+		}
+	}
+	return interval
+}
+
+// ValidatePooling checks that a pooled instance has a direct endpoint for backups
+func (pg *PostgreSQLConfig) ValidatePooling() error {
+	if pg.DirectPort < 0 || pg.DirectPort > 65535 {
+		return fmt.Errorf("direct_port %d is out of range", pg.DirectPort)
+	}
+	if pg.Pooled && pg.DirectHost == "" {
+		return fmt.Errorf("pooled instances need a direct_host that bypasses the pooler")
+	}
+	return nil
+}
+
+// ForBackup returns the config pg_dump, pg_restore and other client tools
+// should use: for pooled instances a copy pointing at the direct endpoint
+func (pg *PostgreSQLConfig) ForBackup() *PostgreSQLConfig {
+	if !pg.Pooled || pg.DirectHost == "" {
+		return pg
+	}
+	direct := *pg
+	direct.Host = pg.DirectHost
+	if pg.DirectPort > 0 {
+		direct.Port = pg.DirectPort
+	}
+	return &direct
+}
+
+// poolerErrorMarkers are messages client tools print when the session goes
+// through PgBouncer or another pooler in transaction mode
+var poolerErrorMarkers = []string{
+	"pgbouncer",
+	"unsupported startup parameter",
+	"prepared statement", // e.g. pg_dump's dumpFunc query landing on another server connection
+}
+
+// PoolerError explains a client tool failure whose output shows the
+// connection went through a transaction pooler, or returns nil
+func (pg *PostgreSQLConfig) PoolerError(tool, output string) error {
+	lower := strings.ToLower(output)
+	matched := false
+	for _, marker := range poolerErrorMarkers {
+		if strings.Contains(lower, marker) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return nil
+	}
+	if pg.Pooled {
+		return fmt.Errorf("%s failed in a way that suggests %s:%d is still a connection pooler; direct_host/direct_port must point at PostgreSQL itself", tool, pg.Host, pg.Port)
+	}
+	return fmt.Errorf("%s failed in a way that suggests %s:%d is a connection pooler (e.g. PgBouncer in transaction mode), which %s can't work through; set pooled with a direct_host/direct_port that bypasses it", tool, pg.Host, pg.Port, tool)
+}
+
+// ErrBackupSizeLimit is returned when a backup grows past max_backup_size_bytes
+var ErrBackupSizeLimit = errors.New("backup size limit exceeded")
+
+// CheckBackupSize returns an error wrapping ErrBackupSizeLimit when a backup
+// of size bytes exceeds the instance's max_backup_size_bytes
+func (pg *PostgreSQLConfig) CheckBackupSize(size int64) error {
+	if pg.MaxBackupSizeBytes > 0 && size > pg.MaxBackupSizeBytes {
+		return fmt.Errorf("%w: %d bytes is over max_backup_size_bytes (%d)", ErrBackupSizeLimit, size, pg.MaxBackupSizeBytes)
+	}
+	return nil
+}
+
+// PGOptionsEnv returns the PGOPTIONS variable setting the instance's timeouts
+// on client tool sessions, or nil when none are set
+func (pg *PostgreSQLConfig) PGOptionsEnv() []string {
+	var options []string
+	if pg.StatementTimeout != "" {
+		options = append(options, "-c statement_timeout="+pg.StatementTimeout)
+	}
+	if pg.LockTimeout != "" {
+		options = append(options, "-c lock_timeout="+pg.LockTimeout)
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return []string{"PGOPTIONS=" + strings.Join(options, " ")}
+}
+
+// pgDurationUnits are the time units PostgreSQL accepts for timeout settings
+var pgDurationUnits = map[string]time.Duration{
+	"":    time.Millisecond, // Bare numbers are milliseconds
+	"us":  time.Microsecond,
+	"ms":  time.Millisecond,
+	"s":   time.Second,
+	"min": time.Minute,
+	"h":   time.Hour,
+	"d":   24 * time.Hour,
+}
+
+// ParsePGDuration parses a PostgreSQL time setting such as "500", "30s" or "10min"
+func ParsePGDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	digits := strings.TrimLeft(value, "0123456789")
+	number, unit := value[:len(value)-len(digits)], strings.TrimSpace(digits)
+
+	multiplier, ok := pgDurationUnits[unit]
+	if number == "" || !ok {
+		return 0, fmt.Errorf("invalid duration %q (use e.g. 500ms, 30s, 10min, 1h)", value)
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid duration %q: must be a positive number", value)
+	}
+	return time.Duration(n) * multiplier, nil
 }
 
 // GetSSLMode returns the SSL mode for PostgreSQL connection, with default fallback
@@ -29,6 +425,116 @@ func (pg *PostgreSQLConfig) GetSSLMode() string {
 	return "prefer" // Default SSL mode
 }
 
+// ConnectionString builds a lib/pq connection string for the given database
+func (pg *PostgreSQLConfig) ConnectionString(database string, connectTimeout int) string {
+	params := []string{
+		"host=" + quoteConnValue(pg.Host),
+		fmt.Sprintf("port=%d", pg.Port),
+		"user=" + quoteConnValue(pg.Username),
+		"password=" + quoteConnValue(pg.Password),
+		"dbname=" + quoteConnValue(database),
+		"sslmode=" + quoteConnValue(pg.GetSSLMode()),
+	}
+	if pg.SSLRootCert != "" {
+		params = append(params, "sslrootcert="+quoteConnValue(pg.SSLRootCert))
+	}
+	if pg.SSLCert != "" {
+		params = append(params, "sslcert="+quoteConnValue(pg.SSLCert))
+	}
+	if pg.SSLKey != "" {
+		params = append(params, "sslkey="+quoteConnValue(pg.SSLKey))
+	}
+	if connectTimeout > 0 {
+		params = append(params, fmt.Sprintf("connect_timeout=%d", connectTimeout))
+	}
+	return strings.Join(params, " ")
+}
+
+// SSLEnv returns libpq environment variables for pg_dump and other client tools
+func (pg *PostgreSQLConfig) SSLEnv() []string {
+	env := []string{"PGSSLMODE=" + pg.GetSSLMode()}
+	if pg.SSLRootCert != "" {
+		env = append(env, "PGSSLROOTCERT="+pg.SSLRootCert)
+	}
+	if pg.SSLCert != "" {
+		env = append(env, "PGSSLCERT="+pg.SSLCert)
+	}
+	if pg.SSLKey != "" {
+		env = append(env, "PGSSLKEY="+pg.SSLKey)
+	}
+	return env
+}
+
+// WithSSLFiles returns a copy of the config where PEM content in the SSL fields
+// has been written to files in dir, so the fields can be handed to libpq as paths.
+// The returned cleanup function removes any files that were created.
+func (pg *PostgreSQLConfig) WithSSLFiles(dir string) (*PostgreSQLConfig, func(), error) {
+	resolved := *pg
+	var created []string
+	cleanup := func() {
+		for _, path := range created {
+			os.Remove(path)
+		}
+	}
+
+	fields := []struct {
+		value *string
+		name  string
+	}{
+		{&resolved.SSLRootCert, "root.crt"},
+		{&resolved.SSLCert, "client.crt"},
+		{&resolved.SSLKey, "client.key"},
+	}
+
+	for _, field := range fields {
+		if !isPEM(*field.value) {
+			continue
+		}
+
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to create SSL directory: %w", err)
+		}
+
+		file, err := os.CreateTemp(dir, pg.ID+"-*-"+field.name)
+		if err != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to create %s: %w", field.name, err)
+		}
+		created = append(created, file.Name())
+
+		// libpq refuses private keys readable by others, so keep everything 0600
+		_, writeErr := file.WriteString(*field.value)
+		closeErr := file.Close()
+		if writeErr == nil {
+			writeErr = closeErr
+		}
+		if writeErr == nil {
+			writeErr = os.Chmod(file.Name(), 0600)
+		}
+		if writeErr != nil {
+			cleanup()
+			return nil, nil, fmt.Errorf("failed to write %s: %w", field.name, writeErr)
+		}
+
+		*field.value = file.Name()
+	}
+
+	return &resolved, cleanup, nil
+}
+
+// isPEM reports whether value holds PEM content rather than a file path
+func isPEM(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN")
+}
+
+// quoteConnValue quotes a connection string value so spaces and quotes are preserved
+func quoteConnValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
 // GetDatabases returns all databases for this PostgreSQL instance
 func (pg *PostgreSQLConfig) GetDatabases() []string {
 	// If databases array is specified, use it
@@ -56,6 +562,16 @@ func (pg *PostgreSQLConfig) GetDatabases() []string {
 	return []string{"postgres"}
 }
 
+// ScheduledDatabases returns the databases scheduled backups cover: the
+// Databases list, or "postgres" when it is empty. Unlike GetDatabases it
+// ignores a comma-separated Database.
+func (pg *PostgreSQLConfig) ScheduledDatabases() []string {
+	if len(pg.Databases) > 0 {
+		return pg.Databases
+	}
+	return []string{"postgres"}
+}
+
 // GetDefaultDatabase returns the first database (for API compatibility)
 func (pg *PostgreSQLConfig) GetDefaultDatabase() string {
 	databases := pg.GetDatabases()
@@ -65,11 +581,169 @@ func (pg *PostgreSQLConfig) GetDefaultDatabase() string {
 	return "postgres"
 }
 
+// Retention modes
+const (
+	RetentionModeCount = "count" // Keep the newest N backups of each type
+	RetentionModeAge   = "age"   // Keep backups younger than KeepDays
+)
+
+// RetentionPolicy says which backups cleanup keeps. A count of 0 sets no limit
+// for the type; in instance overrides it inherits the global count (see Merge).
 type RetentionPolicy struct {
-	Hourly  int `json:"hourly"`  // hours
-	Daily   int `json:"daily"`   // days
-	Weekly  int `json:"weekly"`  // weeks
-	Monthly int `json:"monthly"` // months
+	Mode     string `json:"mode,omitempty"`      // count (default) or age
+	Hourly   int    `json:"hourly"`              // hours
+	Daily    int    `json:"daily"`               // days
+	Weekly   int    `json:"weekly"`              // weeks
+	Monthly  int    `json:"monthly"`             // months
+	KeepDays int    `json:"keep_days,omitempty"` // age mode: days to keep backups
+
+	// ArchiveStorageClass moves S3 backups outside retention to this storage
+	// class (e.g. GLACIER) instead of deleting them
+	ArchiveStorageClass string `json:"archive_storage_class,omitempty"`
+}
+
+// archiveStorageClasses are the S3 storage classes backups can be archived to
+var archiveStorageClasses = map[string]bool{
+	"STANDARD_IA":         true,
+	"ONEZONE_IA":          true,
+	"INTELLIGENT_TIERING": true,
+	"GLACIER_IR":          true,
+	"GLACIER":             true,
+	"DEEP_ARCHIVE":        true,
+}
+
+// DefaultRetentionPolicy is used when neither the instance nor the config file sets one
+var DefaultRetentionPolicy = RetentionPolicy{
+	Mode:    RetentionModeCount,
+	Hourly:  24,
+	Daily:   30,
+	Weekly:  8,
+	Monthly: 12,
+}
+
+// DefaultTrashRetentionHours is how long deleted backups stay in the trash
+const DefaultTrashRetentionHours = 72
+
+// TrashRetention returns how long deleted backups can be restored before they
+// are purged, configurable via TRASH_RETENTION_HOURS (0 purges them on the next run)
+func TrashRetention() time.Duration {
+	if value, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_HOURS")); err == nil && value >= 0 {
+		return time.Duration(value) * time.Hour
+	}
+	return DefaultTrashRetentionHours * time.Hour
+}
+
+// GetMode returns the active retention mode. A policy that only sets
+// keep_days is treated as age-based.
+func (r RetentionPolicy) GetMode() string {
+	if r.Mode != "" {
+		return r.Mode
+	}
+	if r.KeepDays > 0 && r.Hourly == 0 && r.Daily == 0 && r.Weekly == 0 && r.Monthly == 0 {
+		return RetentionModeAge
+	}
+	return RetentionModeCount
+}
+
+// Validate checks that the policy can be applied
+func (r RetentionPolicy) Validate() error {
+	switch r.GetMode() {
+	case RetentionModeCount:
+		if r.Hourly < 0 || r.Daily < 0 || r.Weekly < 0 || r.Monthly < 0 {
+			return fmt.Errorf("retention counts must not be negative")
+		}
+	case RetentionModeAge:
+		if r.KeepDays <= 0 {
+			return fmt.Errorf("keep_days must be greater than zero in age mode")
+		}
+	default:
+		return fmt.Errorf("invalid retention mode %q (use %s or %s)", r.Mode, RetentionModeCount, RetentionModeAge)
+	}
+	if r.ArchiveStorageClass != "" && !archiveStorageClasses[r.ArchiveStorageClass] {
+		return fmt.Errorf("invalid archive_storage_class %q (use STANDARD_IA, ONEZONE_IA, INTELLIGENT_TIERING, GLACIER_IR, GLACIER or DEEP_ARCHIVE)", r.ArchiveStorageClass)
+	}
+	return nil
+}
+
+// CountFor returns how many backups of the given type to keep. Manual and
+// unknown backup types are never cleaned up and report false.
+func (r RetentionPolicy) CountFor(backupType string) (int, bool) {
+	switch backupType {
+	case "hourly":
+		return r.Hourly, true
+	case "daily":
+		return r.Daily, true
+	case "weekly":
+		return r.Weekly, true
+	case "monthly":
+		return r.Monthly, true
+	default:
+		return 0, false
+	}
+}
+
+// ShouldDelete reports whether a backup violates the active policy. position is
+// the backup's index among backups of the same type and database, newest first.
+func (r RetentionPolicy) ShouldDelete(backupType string, position int, createdAt, now time.Time) bool {
+	count, ok := r.CountFor(backupType)
+	if !ok {
+		return false
+	}
+
+	if r.GetMode() == RetentionModeAge {
+		return r.KeepDays > 0 && createdAt.Before(now.AddDate(0, 0, -r.KeepDays))
+	}
+	// A count of 0 means no limit is configured for the type, not "keep none"
+	return count > 0 && position >= count
+}
+
+// Covers reports whether cleanup deletes backups of backupType under this
+// policy: in age mode every scheduled type, in count mode those with a count
+func (r RetentionPolicy) Covers(backupType string) bool {
+	count, ok := r.CountFor(backupType)
+	if !ok {
+		return false
+	}
+	if r.GetMode() == RetentionModeAge {
+		return r.KeepDays > 0
+	}
+	return count > 0
+}
+
+// Merge returns the policy with its unset fields taken from fallback, so an
+// override such as {"daily": 7} only changes daily retention. Counts, keep_days
+// and archive_storage_class inherit when 0 or empty; the mode is the override's.
+func (r RetentionPolicy) Merge(fallback RetentionPolicy) RetentionPolicy {
+	merged := r
+	merged.Mode = r.GetMode()
+	if merged.Hourly == 0 {
+		merged.Hourly = fallback.Hourly
+	}
+	if merged.Daily == 0 {
+		merged.Daily = fallback.Daily
+	}
+	if merged.Weekly == 0 {
+		merged.Weekly = fallback.Weekly
+	}
+	if merged.Monthly == 0 {
+		merged.Monthly = fallback.Monthly
+	}
+	if merged.KeepDays == 0 {
+		merged.KeepDays = fallback.KeepDays
+	}
+	if merged.ArchiveStorageClass == "" {
+		merged.ArchiveStorageClass = fallback.ArchiveStorageClass
+	}
+	return merged
+}
+
+// GetRetentionPolicy returns the instance override merged over the given
+// policy, or that policy when the instance has no override
+func (pg *PostgreSQLConfig) GetRetentionPolicy(fallback RetentionPolicy) RetentionPolicy {
+	if pg.RetentionPolicy != nil {
+		return pg.RetentionPolicy.Merge(fallback)
+	}
+	return fallback
 }
 
 type Config struct {
@@ -85,44 +759,155 @@ type S3Config struct {
 	AccessKeyID     string `json:"access_key_id"`
 	SecretAccessKey string `json:"secret_access_key"`
 	UseSSL          bool   `json:"use_ssl"`
+
+	// CreateBucket creates the bucket at startup when it doesn't exist, for
+	// MinIO and other self-hosted stores; off so production buckets are never
+	// created by accident
+	CreateBucket bool `json:"create_bucket,omitempty"`
+
+	// MaxConcurrentUploads caps simultaneous uploads independently of how many
+	// dumps run in parallel; 0 means unlimited
+	MaxConcurrentUploads int `json:"max_concurrent_uploads,omitempty"`
+
+	// Object key layout, see BackupKey; KeyPrefix separates environments sharing a bucket
+	KeyPrefix   string `json:"key_prefix,omitempty"`
+	KeyTemplate string `json:"key_template,omitempty"`
+
+	// Replicas are secondary buckets every backup is copied to after the
+	// upload to this one succeeds
+	Replicas []S3Destination `json:"replicas,omitempty"`
 }
 
-func Load(filename string) (*Config, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open config file: %w", err)
+// S3Destination is a secondary backup bucket. Empty fields fall back to the
+// primary S3Config, so a replica in another region of the same account only
+// needs bucket and region.
+type S3Destination struct {
+	Name            string `json:"name,omitempty"` // Defaults to the bucket name
+	Endpoint        string `json:"endpoint,omitempty"`
+	Region          string `json:"region,omitempty"`
+	Bucket          string `json:"bucket"`
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+	UseSSL          *bool  `json:"use_ssl,omitempty"`
+}
+
+// DestinationName returns the name a replica is reported under
+func (d S3Destination) DestinationName() string {
+	if d.Name != "" {
+		return d.Name
 	}
-	defer file.Close()
+	return d.Bucket
+}
 
-	data, err := ioutil.ReadAll(file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+// ReplicaConfig returns the settings of a replica, filling in what it leaves
+// empty from the primary. Keys are the same in every destination.
+func (s *S3Config) ReplicaConfig(d S3Destination) S3Config {
+	replica := *s
+	replica.Replicas = nil
+	replica.Bucket = d.Bucket
+	if d.Endpoint != "" {
+		replica.Endpoint = d.Endpoint
+	}
+	if d.Region != "" {
+		replica.Region = d.Region
 	}
+	if d.AccessKeyID != "" {
+		replica.AccessKeyID = d.AccessKeyID
+		replica.SecretAccessKey = d.SecretAccessKey
+	}
+	if d.UseSSL != nil {
+		replica.UseSSL = *d.UseSSL
+	}
+	return replica
+}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+// ValidateReplicas checks that every replica names a bucket other than the
+// primary and that replica names are unique
+func (s *S3Config) ValidateReplicas() error {
+	names := make(map[string]bool)
+	for _, d := range s.Replicas {
+		if d.Bucket == "" {
+			return fmt.Errorf("S3 replica %q has no bucket", d.Name)
+		}
+		replica := s.ReplicaConfig(d)
+		if replica.Bucket == s.Bucket && replica.Endpoint == s.Endpoint && replica.Region == s.Region {
+			return fmt.Errorf("S3 replica %q is the primary bucket", d.DestinationName())
+		}
+		if names[d.DestinationName()] {
+			return fmt.Errorf("duplicate S3 replica %q", d.DestinationName())
+		}
+		names[d.DestinationName()] = true
 	}
+	return nil
+}
 
-	// Override S3 configuration from environment variables if available
+// LoadEnv overrides the S3 settings with the S3_* environment variables that are set
+func (s *S3Config) LoadEnv() {
 	if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" {
-		config.S3Config.Endpoint = endpoint
+		s.Endpoint = endpoint
 	}
 	if region := os.Getenv("S3_REGION"); region != "" {
-		config.S3Config.Region = region
+		s.Region = region
 	}
 	if bucket := os.Getenv("S3_BUCKET"); bucket != "" {
-		config.S3Config.Bucket = bucket
+		s.Bucket = bucket
 	}
 	if accessKey := os.Getenv("S3_ACCESS_KEY_ID"); accessKey != "" {
-		config.S3Config.AccessKeyID = accessKey
+		s.AccessKeyID = accessKey
 	}
 	if secretKey := os.Getenv("S3_SECRET_ACCESS_KEY"); secretKey != "" {
-		config.S3Config.SecretAccessKey = secretKey
+		s.SecretAccessKey = secretKey
 	}
 	if useSSL := os.Getenv("S3_USE_SSL"); useSSL != "" {
-		config.S3Config.UseSSL = useSSL == "true"
+		s.UseSSL = useSSL == "true"
+	}
+	if createBucket := os.Getenv("S3_CREATE_BUCKET"); createBucket != "" {
+		s.CreateBucket = createBucket == "true"
 	}
+	if value, err := strconv.Atoi(os.Getenv("MAX_CONCURRENT_UPLOADS")); err == nil && value >= 0 {
+		s.MaxConcurrentUploads = value
+	}
+	if prefix := os.Getenv("S3_KEY_PREFIX"); prefix != "" {
+		s.KeyPrefix = prefix
+	}
+	if template := os.Getenv("S3_KEY_TEMPLATE"); template != "" {
+		s.KeyTemplate = template
+	}
+	if s.KeyPrefix != "" && !strings.HasSuffix(s.KeyPrefix, "/") {
+		s.KeyPrefix += "/"
+	}
+	// S3_REPLICA_BUCKETS="bucket@region,other-bucket" adds replicas using the
+	// primary's endpoint and credentials
+	if buckets := os.Getenv("S3_REPLICA_BUCKETS"); buckets != "" {
+		s.Replicas = nil
+		for _, entry := range strings.Split(buckets, ",") {
+			bucket, region, _ := strings.Cut(strings.TrimSpace(entry), "@")
+			if bucket != "" {
+				s.Replicas = append(s.Replicas, S3Destination{Bucket: bucket, Region: region})
+			}
+		}
+	}
+}
+
+func Load(filename string) (*Config, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	// Override S3 configuration from environment variables if available
+	config.S3Config.LoadEnv()
 
 	// Validate required S3 configuration
 	if config.S3Config.Region == "" {
@@ -137,6 +922,12 @@ func Load(filename string) (*Config, error) {
 	if config.S3Config.SecretAccessKey == "" {
 		return nil, fmt.Errorf("S3_SECRET_ACCESS_KEY environment variable is required")
 	}
+	if err := config.S3Config.ValidateKeyTemplate(); err != nil {
+		return nil, err
+	}
+	if err := config.S3Config.ValidateReplicas(); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
@@ -163,6 +954,14 @@ func (c *Config) GetPostgreSQLByID(id string) (*PostgreSQLConfig, bool) {
 	return nil, false
 }
 
+// RetentionPolicyFor returns the effective retention policy for an instance
+func (c *Config) RetentionPolicyFor(postgresID string) RetentionPolicy {
+	if pg, exists := c.GetPostgreSQLByID(postgresID); exists {
+		return pg.GetRetentionPolicy(c.RetentionPolicy)
+	}
+	return c.RetentionPolicy
+}
+
 func (c *Config) RemovePostgreSQLByID(id string) bool {
 	for i, pg := range c.PostgreSQLInstances {
 		if pg.ID == id {
@@ -0,0 +1,18 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScheduledDatabasesIgnoresCommaSeparatedDatabase(t *testing.T) {
+	pg := &PostgreSQLConfig{Database: "app, analytics"}
+	if got := pg.ScheduledDatabases(); !reflect.DeepEqual(got, []string{"postgres"}) {
+		t.Errorf("ScheduledDatabases() = %v, want [postgres] like the scheduler", got)
+	}
+
+	pg.Databases = []string{"app"}
+	if got := pg.ScheduledDatabases(); !reflect.DeepEqual(got, []string{"app"}) {
+		t.Errorf("ScheduledDatabases() = %v, want [app]", got)
+	}
+}
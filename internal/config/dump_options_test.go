@@ -0,0 +1,33 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDumpArgs(t *testing.T) {
+	pg := &PostgreSQLConfig{Host: "db", Port: 5432, Username: "backup", LockTimeout: "30s", DumpOptions: []string{"--no-owner"}}
+
+	args, err := pg.DumpArgs("app", "/tmp/app.sql", "--no-blobs")
+	if err != nil {
+		t.Fatalf("DumpArgs: %v", err)
+	}
+	want := []string{"-h", "db", "-p", "5432", "-U", "backup", "-d", "app", "-f", "/tmp/app.sql",
+		"--verbose", "--no-password", "--lock-wait-timeout=30s", "--no-blobs", "--no-owner"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("DumpArgs = %v, want %v", args, want)
+	}
+
+	// Dumps streamed to stdout for compression have no -f
+	args, _ = pg.DumpArgs("app", "")
+	for _, arg := range args {
+		if arg == "-f" {
+			t.Errorf("DumpArgs without an output path = %v, want no -f", args)
+		}
+	}
+
+	pg.DumpOptions = []string{"--file=/etc/passwd"}
+	if _, err := pg.DumpArgs("app", ""); err == nil {
+		t.Error("DumpArgs accepted a disallowed dump option")
+	}
+}
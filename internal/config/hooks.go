@@ -0,0 +1,34 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ErrPreBackupSQLDisabled is returned for instances with pre_backup_sql while
+// ALLOW_PRE_BACKUP_SQL is not set
+var ErrPreBackupSQLDisabled = errors.New("pre_backup_sql is disabled; set ALLOW_PRE_BACKUP_SQL=true on the API and worker to allow it")
+
+// PreBackupSQLAllowed reports whether ALLOW_PRE_BACKUP_SQL=true. The SQL runs
+// with the instance's credentials, so operators have to opt in.
+func PreBackupSQLAllowed() bool {
+	return strings.EqualFold(os.Getenv("ALLOW_PRE_BACKUP_SQL"), "true")
+}
+
+// ValidateHooks checks that pre_backup_sql is allowed when set and that
+// post_backup_webhook is an absolute http(s) URL
+func (pg *PostgreSQLConfig) ValidateHooks() error {
+	if strings.TrimSpace(pg.PreBackupSQL) != "" && !PreBackupSQLAllowed() {
+		return ErrPreBackupSQLDisabled
+	}
+	if pg.PostBackupWebhook != "" {
+		parsed, err := url.Parse(pg.PostBackupWebhook)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("post_backup_webhook %q must be an http or https URL", pg.PostBackupWebhook)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"os"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// idPrefixPattern keeps ID_PREFIX safe for file names, S3 keys and URLs
+var idPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]{0,31}$`)
+
+// IDPrefix returns ID_PREFIX, e.g. "staging", which NewID puts in front of
+// generated IDs so processes sharing a database can be told apart. It is
+// empty when unset or not 1-32 letters, digits and '-'.
+func IDPrefix() string {
+	if prefix := os.Getenv("ID_PREFIX"); idPrefixPattern.MatchString(prefix) {
+		return prefix
+	}
+	return ""
+}
+
+// NewID returns a globally unique ID such as "backup_<uuid>", or
+// "staging-backup_<uuid>" with ID_PREFIX=staging
+func NewID(kind string) string {
+	id := kind + "_" + uuid.New().String()
+	if prefix := IDPrefix(); prefix != "" {
+		return prefix + "-" + id
+	}
+	return id
+}
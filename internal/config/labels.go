@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// labelPattern restricts label keys and values so selectors stay unambiguous
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]{0,62}$`)
+
+// ValidateLabels checks that label keys and values are 1-63 characters of
+// letters, digits, '.', '_', '-' and '/', starting with a letter or digit
+func (pg *PostgreSQLConfig) ValidateLabels() error {
+	for key, value := range pg.Labels {
+		if !labelPattern.MatchString(key) {
+			return fmt.Errorf("invalid label key %q", key)
+		}
+		if !labelPattern.MatchString(value) {
+			return fmt.Errorf("invalid value %q for label %s", value, key)
+		}
+	}
+	return nil
+}
+
+// LabelSelector matches instances by label. Each requirement is key=value, or
+// a bare key that only has to be present; all of them must match.
+type LabelSelector map[string]string
+
+// ParseLabelSelector parses "team=payments,env=prod". An empty string selects
+// every instance.
+func ParseLabelSelector(selector string) (LabelSelector, error) {
+	parsed := LabelSelector{}
+	for _, requirement := range strings.Split(selector, ",") {
+		requirement = strings.TrimSpace(requirement)
+		if requirement == "" {
+			continue
+		}
+		key, value, hasValue := strings.Cut(requirement, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !labelPattern.MatchString(key) || (hasValue && !labelPattern.MatchString(value)) {
+			return nil, fmt.Errorf("invalid label selector %q: use key=value[,key=value]", selector)
+		}
+		if existing, ok := parsed[key]; ok && existing != value {
+			return nil, fmt.Errorf("invalid label selector %q: %s is given twice", selector, key)
+		}
+		parsed[key] = value
+	}
+	return parsed, nil
+}
+
+// Matches reports whether the instance has every label of the selector
+func (s LabelSelector) Matches(pg *PostgreSQLConfig) bool {
+	for key, value := range s {
+		actual, ok := pg.Labels[key]
+		if !ok || (value != "" && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the instances the selector matches
+func (s LabelSelector) Filter(instances []*PostgreSQLConfig) []*PostgreSQLConfig {
+	if len(s) == 0 {
+		return instances
+	}
+	matched := []*PostgreSQLConfig{}
+	for _, instance := range instances {
+		if s.Matches(instance) {
+			matched = append(matched, instance)
+		}
+	}
+	return matched
+}
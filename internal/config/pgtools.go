@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// PostgreSQL client tools used for backups and restores
+const (
+	ToolPgDump       = "pg_dump"
+	ToolPsql         = "psql"
+	ToolPgRestore    = "pg_restore"
+	ToolPgBasebackup = "pg_basebackup"
+)
+
+// toolPathEnv maps each client tool to the env variable overriding its path
+var toolPathEnv = map[string]string{
+	ToolPgDump:       "PG_DUMP_PATH",
+	ToolPsql:         "PSQL_PATH",
+	ToolPgRestore:    "PG_RESTORE_PATH",
+	ToolPgBasebackup: "PG_BASEBACKUP_PATH",
+}
+
+// ToolPath returns the binary to run for a client tool: the env override
+// (PG_DUMP_PATH, PSQL_PATH, PG_RESTORE_PATH, PG_BASEBACKUP_PATH) or the bare name looked up on PATH
+func ToolPath(tool string) string {
+	if path := os.Getenv(toolPathEnv[tool]); path != "" {
+		return path
+	}
+	return tool
+}
+
+// DefaultConnectTimeout is how long, in seconds, connections to instances wait
+// for the server before failing
+const DefaultConnectTimeout = 10
+
+// ConnectTimeout returns the instance connect timeout in seconds, configurable
+// via PG_CONNECT_TIMEOUT; 0 waits as long as the OS does
+func ConnectTimeout() int {
+	if value, err := strconv.Atoi(os.Getenv("PG_CONNECT_TIMEOUT")); err == nil && value >= 0 {
+		return value
+	}
+	return DefaultConnectTimeout
+}
+
+// ConnectTimeoutEnv returns the PGCONNECT_TIMEOUT variable for client tools,
+// so an unreachable host fails fast instead of hanging
+func ConnectTimeoutEnv() []string {
+	if timeout := ConnectTimeout(); timeout > 0 {
+		return []string{fmt.Sprintf("PGCONNECT_TIMEOUT=%d", timeout)}
+	}
+	return nil
+}
+
+// ToolVersion runs "<tool> --version" and returns its output, e.g.
+// "pg_dump (PostgreSQL) 16.2"
+func ToolVersion(tool string) (string, error) {
+	path := ToolPath(tool)
+	if _, err := exec.LookPath(path); err != nil {
+		return "", fmt.Errorf("%s not found (set %s or add it to PATH): %w", tool, toolPathEnv[tool], err)
+	}
+
+	output, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s --version failed: %w", path, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
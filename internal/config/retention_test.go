@@ -0,0 +1,58 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetRetentionPolicyPartialOverrideKeepsOtherTypes(t *testing.T) {
+	fallback := RetentionPolicy{Mode: RetentionModeCount, Hourly: 24, Daily: 30, Weekly: 8, Monthly: 12}
+	pg := &PostgreSQLConfig{RetentionPolicy: &RetentionPolicy{Daily: 7}}
+
+	policy := pg.GetRetentionPolicy(fallback)
+	if policy.Daily != 7 {
+		t.Errorf("daily = %d, want the override's 7", policy.Daily)
+	}
+	if policy.Hourly != 24 || policy.Weekly != 8 || policy.Monthly != 12 {
+		t.Errorf("hourly/weekly/monthly = %d/%d/%d, want the fallback's 24/8/12", policy.Hourly, policy.Weekly, policy.Monthly)
+	}
+
+	now := time.Now()
+	for _, backupType := range []string{"hourly", "weekly", "monthly"} {
+		if policy.ShouldDelete(backupType, 0, now, now) {
+			t.Errorf("newest %s backup would be deleted by a daily-only override", backupType)
+		}
+	}
+	if !policy.ShouldDelete("daily", 7, now, now) {
+		t.Errorf("8th daily backup is kept, want it deleted")
+	}
+}
+
+func TestGetRetentionPolicyAgeOverride(t *testing.T) {
+	fallback := RetentionPolicy{Mode: RetentionModeCount, Hourly: 24, Daily: 30, Weekly: 8, Monthly: 12}
+	pg := &PostgreSQLConfig{RetentionPolicy: &RetentionPolicy{KeepDays: 10}}
+
+	policy := pg.GetRetentionPolicy(fallback)
+	if policy.GetMode() != RetentionModeAge {
+		t.Fatalf("mode = %s, want %s", policy.GetMode(), RetentionModeAge)
+	}
+	now := time.Now()
+	if policy.ShouldDelete("daily", 100, now.AddDate(0, 0, -5), now) {
+		t.Errorf("5 day old backup deleted under keep_days=10")
+	}
+	if !policy.ShouldDelete("daily", 0, now.AddDate(0, 0, -11), now) {
+		t.Errorf("11 day old backup kept under keep_days=10")
+	}
+}
+
+func TestShouldDeleteZeroCountKeepsAll(t *testing.T) {
+	policy := RetentionPolicy{Mode: RetentionModeCount, Daily: 3}
+	now := time.Now()
+	if policy.ShouldDelete("hourly", 50, now, now) {
+		t.Errorf("hourly backup deleted although no hourly count is set")
+	}
+	if policy.Covers("hourly") || !policy.Covers("daily") || policy.Covers("manual") {
+		t.Errorf("Covers = hourly %v, daily %v, manual %v; want false, true, false",
+			policy.Covers("hourly"), policy.Covers("daily"), policy.Covers("manual"))
+	}
+}
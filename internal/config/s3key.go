@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultS3KeyTemplate is the object layout used unless S3_KEY_TEMPLATE is set
+const DefaultS3KeyTemplate = "backups/{postgres_id}/{backup_type}/{year}/{month}/{filename}"
+
+// s3KeyPlaceholder matches the {name} placeholders of a key template
+var s3KeyPlaceholder = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// s3KeyPlaceholders are the placeholders a key template may use
+var s3KeyPlaceholders = map[string]bool{
+	"{postgres_id}": true,
+	"{backup_type}": true,
+	"{year}":        true,
+	"{month}":       true,
+	"{day}":         true,
+	"{filename}":    true,
+}
+
+// keyTemplate returns the configured template or the default
+func (s *S3Config) keyTemplate() string {
+	if s.KeyTemplate == "" {
+		return DefaultS3KeyTemplate
+	}
+	return s.KeyTemplate
+}
+
+// ValidateKeyTemplate checks that the key template only uses known
+// placeholders, ends the key with {filename}, and places {postgres_id} and
+// {backup_type} before any date placeholder, so that every instance and type
+// has its own prefix for retention cleanup to list.
+func (s *S3Config) ValidateKeyTemplate() error {
+	template := s.keyTemplate()
+
+	for _, placeholder := range s3KeyPlaceholder.FindAllString(template, -1) {
+		if !s3KeyPlaceholders[placeholder] {
+			return fmt.Errorf("S3 key template %q: unknown placeholder %s", template, placeholder)
+		}
+	}
+	if !strings.HasSuffix(template, "{filename}") {
+		return fmt.Errorf("S3 key template %q must end with {filename}", template)
+	}
+
+	stable := stableKeyPrefix(template)
+	if !strings.Contains(stable, "{postgres_id}/") || !strings.Contains(stable, "{backup_type}/") {
+		return fmt.Errorf("S3 key template %q must have {postgres_id}/ and {backup_type}/ directories before any date placeholder", template)
+	}
+	return nil
+}
+
+// BackupKey returns the object key of a backup file. timestamp has the
+// 2006-01-02-15-04-05 format used in backup filenames.
+func (s *S3Config) BackupKey(postgresID, backupType, timestamp, filename string) string {
+	parts := strings.SplitN(timestamp, "-", 4)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+
+	key := strings.NewReplacer(
+		"{postgres_id}", postgresID,
+		"{backup_type}", backupType,
+		"{year}", parts[0],
+		"{month}", parts[1],
+		"{day}", parts[2],
+		"{filename}", filename,
+	).Replace(s.keyTemplate())
+	return s.KeyPrefix + key
+}
+
+// BackupKeyPrefix returns the prefix shared by every key BackupKey generates
+// for an instance and backup type
+func (s *S3Config) BackupKeyPrefix(postgresID, backupType string) string {
+	prefix := strings.NewReplacer(
+		"{postgres_id}", postgresID,
+		"{backup_type}", backupType,
+	).Replace(stableKeyPrefix(s.keyTemplate()))
+	return s.KeyPrefix + prefix
+}
+
+// stableKeyPrefix returns the directories of template before the first
+// placeholder other than {postgres_id} and {backup_type}
+func stableKeyPrefix(template string) string {
+	for _, loc := range s3KeyPlaceholder.FindAllStringIndex(template, -1) {
+		placeholder := template[loc[0]:loc[1]]
+		if placeholder != "{postgres_id}" && placeholder != "{backup_type}" {
+			template = template[:loc[0]]
+			break
+		}
+	}
+	return template[:strings.LastIndex(template, "/")+1]
+}
@@ -2,10 +2,23 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"evolution-postgres-backup/internal/models"
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
+// backupColumns is the column list read by scanBackup
+const backupColumns = `id, postgresql_id, database_name, backup_type, status,
+			   start_time, end_time, file_path, file_size, s3_key,
+			   error_message, created_at, job_id, idempotency_key, checksum, tags, protected,
+			   storage_class, created_by, uncompressed_size, compression_ratio, throughput_mbps,
+			   failure_category, trashed_at, purge_at, format, blobs_excluded`
+
 type BackupRepository struct {
 	db *DB
 }
@@ -16,14 +29,32 @@ func NewBackupRepository(db *DB) *BackupRepository {
 
 // Create inserts a new backup record
 func (r *BackupRepository) Create(backup *models.BackupInfo) error {
+	return insertBackup(r.db, backup)
+}
+
+// CreateTx inserts a backup record within a transaction
+func (r *BackupRepository) CreateTx(tx *sql.Tx, backup *models.BackupInfo) error {
+	return insertBackup(tx, backup)
+}
+
+// insertBackup inserts a backup record through a database or a transaction
+func insertBackup(db interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}, backup *models.BackupInfo) error {
 	query := `
 		INSERT INTO backups (
 			id, postgresql_id, database_name, backup_type, status,
 			start_time, end_time, file_path, file_size, s3_key,
-			error_message, created_at, job_id
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+			error_message, created_at, job_id, idempotency_key, checksum, tags, protected,
+			storage_class, created_by, format, blobs_excluded
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)`
 
-	_, err := r.db.Exec(
+	tagsJSON, err := marshalTags(backup.Tags)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
 		query,
 		backup.ID,
 		backup.PostgreSQLID,
@@ -38,6 +69,14 @@ func (r *BackupRepository) Create(backup *models.BackupInfo) error {
 		backup.ErrorMessage,
 		backup.CreatedAt,
 		backup.JobID,
+		nullString(backup.IdempotencyKey),
+		backup.Checksum,
+		tagsJSON,
+		backup.Protected,
+		backup.StorageClass,
+		backup.CreatedBy,
+		backup.Format,
+		backup.BlobsExcluded,
 	)
 
 	return err
@@ -52,8 +91,17 @@ func (r *BackupRepository) Update(backup *models.BackupInfo) error {
 			file_path = $3,
 			file_size = $4,
 			s3_key = $5,
-			error_message = $6
-		WHERE id = $7`
+			error_message = $6,
+			job_id = $7,
+			checksum = $8,
+			storage_class = $9,
+			uncompressed_size = $10,
+			compression_ratio = $11,
+			throughput_mbps = $12,
+			failure_category = $13,
+			format = $14,
+			blobs_excluded = $15
+		WHERE id = $16`
 
 	_, err := r.db.Exec(
 		query,
@@ -63,6 +111,15 @@ func (r *BackupRepository) Update(backup *models.BackupInfo) error {
 		backup.FileSize,
 		backup.S3Key,
 		backup.ErrorMessage,
+		backup.JobID,
+		backup.Checksum,
+		backup.StorageClass,
+		backup.UncompressedSize,
+		backup.CompressionRatio,
+		backup.ThroughputMBps,
+		string(backup.FailureCategory),
+		backup.Format,
+		backup.BlobsExcluded,
 		backup.ID,
 	)
 
@@ -72,30 +129,34 @@ func (r *BackupRepository) Update(backup *models.BackupInfo) error {
 // GetByID retrieves a backup by ID
 func (r *BackupRepository) GetByID(id string) (*models.BackupInfo, error) {
 	query := `
-		SELECT id, postgresql_id, database_name, backup_type, status,
-			   start_time, end_time, file_path, file_size, s3_key,
-			   error_message, created_at
+		SELECT ` + backupColumns + `
 		FROM backups WHERE id = $1`
 
 	row := r.db.QueryRow(query, id)
 	return r.scanBackup(row)
 }
 
-// GetAll retrieves all backups with optional filters
+// GetAll retrieves backups with optional filters. Trashed backups are left out
+// unless FilterTrashed or IncludeTrashed is given.
 func (r *BackupRepository) GetAll(filters ...BackupFilter) ([]*models.BackupInfo, error) {
 	query := `
-		SELECT id, postgresql_id, database_name, backup_type, status,
-			   start_time, end_time, file_path, file_size, s3_key,
-			   error_message, created_at
+		SELECT ` + backupColumns + `
 		FROM backups`
 
 	args := []interface{}{}
 	whereClauses := []string{}
 
-	// Apply filters
+	// Trashed backups are only listed when a filter asks for them
+	trashScoped := false
+
+	// Apply filters; each clause uses $1, renumbered to its position in args
 	for _, filter := range filters {
+		if _, ok := filter.(*backupTrashFilter); ok {
+			trashScoped = true
+		}
 		clause, arg := filter.Apply()
 		if clause != "" {
+			clause = strings.Replace(clause, "$1", fmt.Sprintf("$%d", len(args)+1), 1)
 			whereClauses = append(whereClauses, clause)
 			if arg != nil {
 				args = append(args, arg)
@@ -103,6 +164,10 @@ func (r *BackupRepository) GetAll(filters ...BackupFilter) ([]*models.BackupInfo
 		}
 	}
 
+	if !trashScoped {
+		whereClauses = append(whereClauses, "trashed_at IS NULL")
+	}
+
 	if len(whereClauses) > 0 {
 		query += " WHERE " + whereClauses[0]
 		for i := 1; i < len(whereClauses); i++ {
@@ -130,6 +195,71 @@ func (r *BackupRepository) GetAll(filters ...BackupFilter) ([]*models.BackupInfo
 	return backups, rows.Err()
 }
 
+// idempotencyScopeIndex is the unique index making an idempotency key
+// unique per creator (API key)
+const idempotencyScopeIndex = "idx_backups_idempotency_scope"
+
+// IsIdempotencyKeyConflict reports whether err is the insert of a backup
+// whose idempotency key its creator already used
+func IsIdempotencyKeyConflict(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == idempotencyScopeIndex
+}
+
+// GetByIdempotencyKey retrieves the backup createdBy created with the given
+// idempotency key since the given time
+func (r *BackupRepository) GetByIdempotencyKey(createdBy, key string, since time.Time) (*models.BackupInfo, error) {
+	query := `
+		SELECT ` + backupColumns + `
+		FROM backups
+		WHERE created_by = $1 AND idempotency_key = $2 AND created_at >= $3`
+
+	row := r.db.QueryRow(query, createdBy, key, since)
+	return r.scanBackup(row)
+}
+
+// ReleaseIdempotencyKey frees an idempotency key createdBy used before the
+// given time, so a new backup can be created with it
+func (r *BackupRepository) ReleaseIdempotencyKey(createdBy, key string, before time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE backups SET idempotency_key = NULL
+		WHERE created_by = $1 AND idempotency_key = $2 AND created_at < $3`, createdBy, key, before)
+	return err
+}
+
+// GetLatestCompleted retrieves the most recent completed backup of each database
+// on a PostgreSQL instance
+func (r *BackupRepository) GetLatestCompleted(postgresID string) ([]*models.BackupInfo, error) {
+	query := `
+		SELECT ` + backupColumns + `
+		FROM backups
+		WHERE postgresql_id = $1 AND status = 'completed' AND trashed_at IS NULL
+		  AND (database_name, created_at) IN (
+			SELECT database_name, MAX(created_at)
+			FROM backups
+			WHERE postgresql_id = $1 AND status = 'completed' AND trashed_at IS NULL
+			GROUP BY database_name
+		  )
+		ORDER BY database_name`
+
+	rows, err := r.db.Query(query, postgresID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var backups []*models.BackupInfo
+	for rows.Next() {
+		backup, err := r.scanBackup(rows)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+
+	return backups, rows.Err()
+}
+
 // GetByPostgreSQLID retrieves backups for a specific PostgreSQL instance
 func (r *BackupRepository) GetByPostgreSQLID(postgresID string) ([]*models.BackupInfo, error) {
 	return r.GetAll(FilterByPostgreSQLID(postgresID))
@@ -148,11 +278,9 @@ func (r *BackupRepository) GetByType(backupType models.BackupType) ([]*models.Ba
 // GetOldBackups retrieves backups older than the specified time for cleanup
 func (r *BackupRepository) GetOldBackups(postgresID string, backupType models.BackupType, olderThan time.Time) ([]*models.BackupInfo, error) {
 	query := `
-		SELECT id, postgresql_id, database_name, backup_type, status,
-			   start_time, end_time, file_path, file_size, s3_key,
-			   error_message, created_at
+		SELECT ` + backupColumns + `
 		FROM backups 
-		WHERE postgresql_id = $1 AND backup_type = $2 AND created_at < $3
+		WHERE postgresql_id = $1 AND backup_type = $2 AND created_at < $3 AND NOT protected AND trashed_at IS NULL
 		ORDER BY created_at ASC`
 
 	rows, err := r.db.Query(query, postgresID, string(backupType), olderThan)
@@ -173,6 +301,298 @@ func (r *BackupRepository) GetOldBackups(postgresID string, backupType models.Ba
 	return backups, rows.Err()
 }
 
+// GetCompletedByType retrieves completed backups of a type for an instance, newest first
+func (r *BackupRepository) GetCompletedByType(postgresID string, backupType models.BackupType) ([]*models.BackupInfo, error) {
+	query := `
+		SELECT ` + backupColumns + `
+		FROM backups
+		WHERE postgresql_id = $1 AND backup_type = $2 AND status = 'completed' AND trashed_at IS NULL
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(query, postgresID, string(backupType))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var backups []*models.BackupInfo
+	for rows.Next() {
+		backup, err := r.scanBackup(rows)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+
+	return backups, rows.Err()
+}
+
+// GetDuplicates retrieves completed backups whose checksum is shared with at
+// least one other backup, ordered by checksum then age. An empty postgresID
+// searches every instance.
+func (r *BackupRepository) GetDuplicates(postgresID string) ([]*models.BackupInfo, error) {
+	query := `
+		SELECT ` + backupColumns + `
+		FROM backups
+		WHERE status = 'completed' AND checksum IS NOT NULL AND checksum <> '' AND trashed_at IS NULL
+		  AND ($1 = '' OR postgresql_id = $1)
+		  AND checksum IN (
+			SELECT checksum
+			FROM backups
+			WHERE status = 'completed' AND checksum IS NOT NULL AND checksum <> '' AND trashed_at IS NULL
+			  AND ($1 = '' OR postgresql_id = $1)
+			GROUP BY checksum
+			HAVING COUNT(*) > 1
+		  )
+		ORDER BY checksum, created_at`
+
+	rows, err := r.db.Query(query, postgresID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var backups []*models.BackupInfo
+	for rows.Next() {
+		backup, err := r.scanBackup(rows)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+
+	return backups, rows.Err()
+}
+
+// BackupTypeSummary aggregates the completed backups of one database and type
+type BackupTypeSummary struct {
+	PostgreSQLID string
+	DatabaseName string
+	BackupType   models.BackupType
+	Count        int
+	Protected    int
+	TotalBytes   int64
+	Oldest       time.Time
+	Newest       time.Time
+}
+
+// GetRetentionSummary aggregates completed backups per instance, database and
+// type, ordered by instance and database
+func (r *BackupRepository) GetRetentionSummary() ([]*BackupTypeSummary, error) {
+	query := `
+		SELECT postgresql_id, database_name, backup_type, COUNT(*),
+		       COUNT(*) FILTER (WHERE protected), COALESCE(SUM(file_size), 0),
+		       MIN(created_at), MAX(created_at)
+		FROM backups
+		WHERE status = 'completed' AND trashed_at IS NULL
+		GROUP BY postgresql_id, database_name, backup_type
+		ORDER BY postgresql_id, database_name, backup_type`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []*BackupTypeSummary
+	for rows.Next() {
+		summary := &BackupTypeSummary{}
+		var backupType string
+		if err := rows.Scan(&summary.PostgreSQLID, &summary.DatabaseName, &backupType, &summary.Count,
+			&summary.Protected, &summary.TotalBytes, &summary.Oldest, &summary.Newest); err != nil {
+			return nil, err
+		}
+		summary.BackupType = models.BackupType(backupType)
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, rows.Err()
+}
+
+// GetLastCompletedTimes returns when the newest completed backup of each
+// database finished, keyed by instance ID and database name
+func (r *BackupRepository) GetLastCompletedTimes() (map[string]map[string]time.Time, error) {
+	query := `
+		SELECT postgresql_id, database_name, MAX(COALESCE(end_time, created_at))
+		FROM backups
+		WHERE status = 'completed' AND trashed_at IS NULL
+		GROUP BY postgresql_id, database_name`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	times := make(map[string]map[string]time.Time)
+	for rows.Next() {
+		var postgresID, databaseName string
+		var lastCompleted time.Time
+		if err := rows.Scan(&postgresID, &databaseName, &lastCompleted); err != nil {
+			return nil, err
+		}
+		if times[postgresID] == nil {
+			times[postgresID] = make(map[string]time.Time)
+		}
+		times[postgresID][databaseName] = lastCompleted
+	}
+
+	return times, rows.Err()
+}
+
+// InstanceStorage is the storage consumed by one instance's completed backups
+type InstanceStorage struct {
+	PostgreSQLID string `json:"postgres_id"`
+	Name         string `json:"name"` // Empty when the instance has been deleted
+	BackupCount  int    `json:"backup_count"`
+	TotalBytes   int64  `json:"total_bytes"`
+}
+
+// GetStorageByInstance sums the size of completed backups per instance,
+// largest first
+func (r *BackupRepository) GetStorageByInstance() ([]*InstanceStorage, error) {
+	query := `
+		SELECT b.postgresql_id, COALESCE(p.name, ''), COUNT(*), COALESCE(SUM(b.file_size), 0)
+		FROM backups b
+		LEFT JOIN postgresql_instances p ON p.id = b.postgresql_id
+		WHERE b.status = 'completed'
+		GROUP BY b.postgresql_id, p.name
+		ORDER BY 4 DESC, b.postgresql_id`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make([]*InstanceStorage, 0)
+	for rows.Next() {
+		instance := &InstanceStorage{}
+		if err := rows.Scan(&instance.PostgreSQLID, &instance.Name, &instance.BackupCount, &instance.TotalBytes); err != nil {
+			return nil, err
+		}
+		usage = append(usage, instance)
+	}
+
+	return usage, rows.Err()
+}
+
+// SetProtected marks a backup as exempt from (or subject to) retention cleanup,
+// returning sql.ErrNoRows if it doesn't exist or, when protecting, is in the trash
+func (r *BackupRepository) SetProtected(id string, protected bool) error {
+	result, err := r.db.Exec(`
+		UPDATE backups SET protected = $1
+		WHERE id = $2 AND (NOT $1 OR trashed_at IS NULL)`, protected, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// maxFailureOutputBytes caps the stored failure output; the end is kept, as
+// that is where pg_dump reports what went wrong
+const maxFailureOutputBytes = 1 << 20
+
+// SetFailureOutput stores the tool output of a failed backup, keeping the
+// last maxFailureOutputBytes
+func (r *BackupRepository) SetFailureOutput(id, output string) error {
+	if len(output) > maxFailureOutputBytes {
+		output = "[... truncated ...]\n" + strings.ToValidUTF8(output[len(output)-maxFailureOutputBytes:], "")
+	}
+	_, err := r.db.Exec("UPDATE backups SET failure_output = $1 WHERE id = $2", output, id)
+	return err
+}
+
+// GetFailureOutput returns the stored tool output of a failed backup, "" when
+// none was recorded, or sql.ErrNoRows when the backup doesn't exist
+func (r *BackupRepository) GetFailureOutput(id string) (string, error) {
+	var output string
+	err := r.db.QueryRow("SELECT failure_output FROM backups WHERE id = $1", id).Scan(&output)
+	return output, err
+}
+
+// SetStorageClass records the S3 storage class a backup was moved to
+func (r *BackupRepository) SetStorageClass(id, storageClass string) error {
+	_, err := r.db.Exec("UPDATE backups SET storage_class = $1 WHERE id = $2", storageClass, id)
+	return err
+}
+
+// Trash moves a finished, unprotected backup to the trash until purgeAt. It
+// returns sql.ErrNoRows when no such backup is outside the trash; a backup
+// already in the trash is left as it is.
+func (r *BackupRepository) Trash(id string, purgeAt time.Time) error {
+	result, err := r.db.Exec(`
+		UPDATE backups SET trashed_at = NOW(), purge_at = $2
+		WHERE id = $1 AND trashed_at IS NULL AND NOT protected
+		  AND status IN ('completed', 'failed')`, id, purgeAt)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Untrash takes a backup out of the trash, returning sql.ErrNoRows if it isn't in it
+func (r *BackupRepository) Untrash(id string) error {
+	result, err := r.db.Exec(`
+		UPDATE backups SET trashed_at = NULL, purge_at = NULL
+		WHERE id = $1 AND trashed_at IS NOT NULL`, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetExpiredTrash retrieves trashed backups whose grace period ended before
+// now. Protected backups are never purged.
+func (r *BackupRepository) GetExpiredTrash(now time.Time) ([]*models.BackupInfo, error) {
+	query := `
+		SELECT ` + backupColumns + `
+		FROM backups
+		WHERE trashed_at IS NOT NULL AND purge_at <= $1 AND NOT protected
+		ORDER BY purge_at`
+
+	rows, err := r.db.Query(query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var backups []*models.BackupInfo
+	for rows.Next() {
+		backup, err := r.scanBackup(rows)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+
+	return backups, rows.Err()
+}
+
 // Delete removes a backup record
 func (r *BackupRepository) Delete(id string) error {
 	query := "DELETE FROM backups WHERE id = $1"
@@ -180,11 +600,11 @@ func (r *BackupRepository) Delete(id string) error {
 	return err
 }
 
-// DeleteOldBackups removes backups older than the specified time
+// DeleteOldBackups removes unprotected backups older than the specified time
 func (r *BackupRepository) DeleteOldBackups(postgresID string, backupType models.BackupType, olderThan time.Time) (int64, error) {
 	query := `
 		DELETE FROM backups 
-		WHERE postgresql_id = $1 AND backup_type = $2 AND created_at < $3`
+		WHERE postgresql_id = $1 AND backup_type = $2 AND created_at < $3 AND NOT protected AND trashed_at IS NULL`
 
 	result, err := r.db.Exec(query, postgresID, string(backupType), olderThan)
 	if err != nil {
@@ -244,6 +664,30 @@ func (r *BackupRepository) GetStats() (map[string]interface{}, error) {
 	}
 	stats["by_type"] = typeCounts
 
+	// Failed backups by cause; failures recorded before categories existed are left out
+	failureQuery := `
+		SELECT failure_category, COUNT(*)
+		FROM backups
+		WHERE status = 'failed' AND failure_category <> ''
+		GROUP BY failure_category`
+
+	rows, err = r.db.Query(failureQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	failureCounts := make(map[string]int)
+	for rows.Next() {
+		var category string
+		var count int
+		if err := rows.Scan(&category, &count); err != nil {
+			return nil, err
+		}
+		failureCounts[category] = count
+	}
+	stats["failures_by_category"] = failureCounts
+
 	// Total storage used
 	var totalSize sql.NullInt64
 	sizeQuery := "SELECT SUM(file_size) FROM backups WHERE status = 'completed'"
@@ -255,6 +699,46 @@ func (r *BackupRepository) GetStats() (map[string]interface{}, error) {
 		stats["total_size_mb"] = float64(totalSize.Int64) / 1024 / 1024
 	}
 
+	// Compression and throughput per database, best compressing first
+	compressionQuery := `
+		SELECT postgresql_id, database_name, COUNT(*),
+		       AVG(compression_ratio), AVG(throughput_mbps),
+		       SUM(uncompressed_size), SUM(file_size)
+		FROM backups
+		WHERE status = 'completed' AND uncompressed_size > 0
+		GROUP BY postgresql_id, database_name
+		ORDER BY AVG(compression_ratio) DESC`
+
+	rows, err = r.db.Query(compressionQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	compression := []map[string]interface{}{}
+	for rows.Next() {
+		var postgresID, databaseName string
+		var count int
+		var avgRatio, avgThroughput float64
+		var uncompressed, compressed int64
+		if err := rows.Scan(&postgresID, &databaseName, &count, &avgRatio, &avgThroughput, &uncompressed, &compressed); err != nil {
+			return nil, err
+		}
+		compression = append(compression, map[string]interface{}{
+			"postgresql_id":           postgresID,
+			"database_name":           databaseName,
+			"backup_count":            count,
+			"avg_compression_ratio":   avgRatio,
+			"avg_throughput_mbps":     avgThroughput,
+			"total_uncompressed_size": uncompressed,
+			"total_compressed_size":   compressed,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	stats["compression_by_database"] = compression
+
 	return stats, nil
 }
 
@@ -265,6 +749,9 @@ func (r *BackupRepository) scanBackup(scanner interface {
 	backup := &models.BackupInfo{}
 	var backupType, status string
 	var endTime sql.NullTime
+	var jobID, idempotencyKey, checksum sql.NullString
+	var tagsJSON, failureCategory string
+	var trashedAt, purgeAt sql.NullTime
 
 	err := scanner.Scan(
 		&backup.ID,
@@ -279,6 +766,21 @@ func (r *BackupRepository) scanBackup(scanner interface {
 		&backup.S3Key,
 		&backup.ErrorMessage,
 		&backup.CreatedAt,
+		&jobID,
+		&idempotencyKey,
+		&checksum,
+		&tagsJSON,
+		&backup.Protected,
+		&backup.StorageClass,
+		&backup.CreatedBy,
+		&backup.UncompressedSize,
+		&backup.CompressionRatio,
+		&backup.ThroughputMBps,
+		&failureCategory,
+		&trashedAt,
+		&purgeAt,
+		&backup.Format,
+		&backup.BlobsExcluded,
 	)
 
 	if err != nil {
@@ -287,14 +789,43 @@ func (r *BackupRepository) scanBackup(scanner interface {
 
 	backup.BackupType = models.BackupType(backupType)
 	backup.Status = models.BackupStatus(status)
+	backup.FailureCategory = models.FailureCategory(failureCategory)
 
 	if endTime.Valid {
 		backup.EndTime = &endTime.Time
 	}
+	if trashedAt.Valid {
+		backup.TrashedAt = &trashedAt.Time
+	}
+	if purgeAt.Valid {
+		backup.PurgeAt = &purgeAt.Time
+	}
+	backup.JobID = jobID.String
+	backup.IdempotencyKey = idempotencyKey.String
+	backup.Checksum = checksum.String
+
+	if tagsJSON != "" {
+		var tags []string
+		if err := json.Unmarshal([]byte(tagsJSON), &tags); err == nil && len(tags) > 0 {
+			backup.Tags = tags
+		}
+	}
 
 	return backup, nil
 }
 
+// marshalTags encodes backup tags for the JSONB column
+func marshalTags(tags []string) (string, error) {
+	if len(tags) == 0 {
+		return "[]", nil
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 // BackupFilter interface for filtering backups
 type BackupFilter interface {
 	Apply() (string, interface{})
@@ -336,3 +867,38 @@ func FilterByType(backupType models.BackupType) BackupFilter {
 func (f *backupTypeFilter) Apply() (string, interface{}) {
 	return "backup_type = $1", string(f.backupType)
 }
+
+type backupTagFilter struct {
+	tag string
+}
+
+// FilterByTag matches backups carrying the given tag
+func FilterByTag(tag string) BackupFilter {
+	return &backupTagFilter{tag: tag}
+}
+
+func (f *backupTagFilter) Apply() (string, interface{}) {
+	tagJSON, _ := json.Marshal([]string{f.tag})
+	return "tags @> $1::jsonb", string(tagJSON)
+}
+
+type backupTrashFilter struct {
+	only bool
+}
+
+// FilterTrashed matches backups in the trash
+func FilterTrashed() BackupFilter {
+	return &backupTrashFilter{only: true}
+}
+
+// IncludeTrashed lists trashed backups along with the others
+func IncludeTrashed() BackupFilter {
+	return &backupTrashFilter{}
+}
+
+func (f *backupTrashFilter) Apply() (string, interface{}) {
+	if f.only {
+		return "trashed_at IS NOT NULL", nil
+	}
+	return "", nil
+}
@@ -3,20 +3,158 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"log"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/lib/pq" // PostgreSQL driver
 )
 
+// InstancesChangedChannel is the NOTIFY channel signalled whenever a
+// PostgreSQL instance is created, updated, enabled/disabled or deleted
+const InstancesChangedChannel = "postgresql_instances_changed"
+
+// JobActivityChannel is the NOTIFY channel signalled with a job ID whenever a
+// log row is written for that job or its status changes
+const JobActivityChannel = "job_activity"
+
 // DB wraps sql.DB with PostgreSQL connection
 type DB struct {
 	*sql.DB
 	connStr string
+	pool    poolSettings
 }
 
-// NewDB creates a new PostgreSQL database connection
+// Pool settings DATABASE_URL may carry as query parameters. They are removed
+// from the URL, since the driver would send unknown parameters to the server.
+const (
+	poolMaxConnsParam     = "pool_max_conns"
+	poolMaxIdleConnsParam = "pool_max_idle_conns"
+	poolMaxLifetimeParam  = "pool_max_conn_lifetime"
+)
+
+// poolSettings configures the connection pool of the service database
+type poolSettings struct {
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+}
+
+var defaultPoolSettings = poolSettings{
+	maxOpenConns:    25,
+	maxIdleConns:    5,
+	connMaxLifetime: 5 * time.Minute,
+}
+
+// NewDB creates a new PostgreSQL database connection. DATABASE_URL, as
+// provided by most hosting platforms, takes precedence over the POSTGRES_* variables.
 func NewDB(dataDir string) (*DB, error) {
+	connStr, pool, err := connectionConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Open PostgreSQL database
+	sqlDB, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PostgreSQL database: %w", err)
+	}
+
+	db := &DB{
+		DB:      sqlDB,
+		connStr: connStr,
+		pool:    pool,
+	}
+
+	// Configure connection pool for PostgreSQL
+	db.SetMaxOpenConns(pool.maxOpenConns)
+	db.SetMaxIdleConns(pool.maxIdleConns)
+	db.SetConnMaxLifetime(pool.connMaxLifetime)
+
+	// Test connection, waiting for a database that is still starting up
+	if err := db.waitForConnection(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+
+	if schemaAutoMigrate() {
+		if err := db.MigrateSchema(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to migrate database schema: %w", err)
+		}
+	}
+
+	// Slow queries are the only symptom of a missing index, so say so up front
+	if missing, err := db.MissingIndexes(); err != nil {
+		log.Printf("⚠️ Failed to check database indexes: %v", err)
+	} else if len(missing) > 0 {
+		log.Printf("⚠️ Missing database indexes %s; apply internal/database/schema_postgres.sql or the matching migrate_add_*.sql", strings.Join(missing, ", "))
+	}
+
+	return db, nil
+}
+
+// Defaults of the startup connection retries
+const (
+	defaultConnectAttempts = 10
+	defaultConnectInterval = 3 * time.Second
+	defaultConnectTimeout  = 10 * time.Second
+)
+
+// connectTimeoutSeconds returns the connect_timeout of service database
+// connections, configurable via DB_CONNECT_TIMEOUT (0 waits as long as the OS does)
+func connectTimeoutSeconds() int {
+	timeout := defaultConnectTimeout
+	if value, err := time.ParseDuration(os.Getenv("DB_CONNECT_TIMEOUT")); err == nil && value >= 0 {
+		timeout = value
+	}
+	// libpq takes whole seconds
+	return int((timeout + time.Second - 1) / time.Second)
+}
+
+// connectRetries returns how many times and how often NewDB tries to reach the
+// database, configurable via DB_CONNECT_ATTEMPTS and DB_CONNECT_INTERVAL
+func connectRetries() (int, time.Duration) {
+	attempts := defaultConnectAttempts
+	if value, err := strconv.Atoi(os.Getenv("DB_CONNECT_ATTEMPTS")); err == nil && value > 0 {
+		attempts = value
+	}
+	interval := defaultConnectInterval
+	if value, err := time.ParseDuration(os.Getenv("DB_CONNECT_INTERVAL")); err == nil && value > 0 {
+		interval = value
+	}
+	return attempts, interval
+}
+
+// waitForConnection pings the database until it answers or the attempts run
+// out, so processes started alongside it (e.g. by docker-compose) don't exit
+func (db *DB) waitForConnection() error {
+	attempts, interval := connectRetries()
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = db.Ping(); err == nil {
+			return nil
+		}
+		if attempt < attempts {
+			log.Printf("⏳ Database not reachable (attempt %d/%d): %v; retrying in %s", attempt, attempts, err, interval)
+			time.Sleep(interval)
+		}
+	}
+	return fmt.Errorf("gave up after %d attempts: %w", attempts, err)
+}
+
+// connectionConfig returns the connection string and pool settings, from
+// DATABASE_URL when set and the POSTGRES_* variables otherwise
+func connectionConfig() (string, poolSettings, error) {
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		return parseDatabaseURL(databaseURL)
+	}
+
 	host := os.Getenv("POSTGRES_HOST")
 	if host == "" {
 		host = "localhost"
@@ -48,32 +186,110 @@ func NewDB(dataDir string) (*DB, error) {
 	}
 
 	// Build connection string
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		host, port, user, password, dbname, sslmode)
+	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s connect_timeout=%d",
+		host, port, user, password, dbname, sslmode, connectTimeoutSeconds())
+	return connStr, defaultPoolSettings, nil
+}
 
-	// Open PostgreSQL database
-	sqlDB, err := sql.Open("postgres", connStr)
+// parseDatabaseURL validates a postgres:// URL and extracts the pool settings
+// from its query parameters
+func parseDatabaseURL(raw string) (string, poolSettings, error) {
+	pool := defaultPoolSettings
+
+	parsed, err := url.Parse(raw)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open PostgreSQL database: %w", err)
+		return "", pool, fmt.Errorf("invalid DATABASE_URL: %w", err)
+	}
+	if parsed.Scheme != "postgres" && parsed.Scheme != "postgresql" {
+		return "", pool, fmt.Errorf("invalid DATABASE_URL: scheme must be postgres:// or postgresql://, got %q", parsed.Scheme)
 	}
 
-	db := &DB{
-		DB:      sqlDB,
-		connStr: connStr,
+	query := parsed.Query()
+	for param, target := range map[string]*int{
+		poolMaxConnsParam:     &pool.maxOpenConns,
+		poolMaxIdleConnsParam: &pool.maxIdleConns,
+	} {
+		if value := query.Get(param); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return "", pool, fmt.Errorf("invalid DATABASE_URL: %s must be a non-negative integer", param)
+			}
+			*target = n
+		}
+		query.Del(param)
+	}
+	if value := query.Get(poolMaxLifetimeParam); value != "" {
+		lifetime, err := time.ParseDuration(value)
+		if err != nil || lifetime < 0 {
+			return "", pool, fmt.Errorf("invalid DATABASE_URL: %s must be a duration such as 5m", poolMaxLifetimeParam)
+		}
+		pool.connMaxLifetime = lifetime
 	}
+	query.Del(poolMaxLifetimeParam)
 
-	// Configure connection pool for PostgreSQL
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	// An explicit connect_timeout in the URL wins over DB_CONNECT_TIMEOUT
+	if query.Get("connect_timeout") == "" {
+		query.Set("connect_timeout", strconv.Itoa(connectTimeoutSeconds()))
+	}
 
-	// Test connection
-	if err := db.Ping(); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), pool, nil
+}
+
+// ListenInstanceChanges calls onChange whenever InstancesChangedChannel is
+// notified, including by other processes, and after the listener reconnects
+// (notifications may have been missed meanwhile). The returned function stops
+// listening.
+func (db *DB) ListenInstanceChanges(onChange func()) (func(), error) {
+	return db.listen(InstancesChangedChannel, func(string) { onChange() })
+}
+
+// ListenJobActivity calls onActivity with the job ID whenever JobActivityChannel
+// is notified, and with "" after the listener reconnects, meaning any job may
+// have changed. The returned function stops listening.
+func (db *DB) ListenJobActivity(onActivity func(jobID string)) (func(), error) {
+	return db.listen(JobActivityChannel, onActivity)
+}
+
+// listen calls onNotify with the payload of every notification on channel, and
+// with "" after the listener reconnects
+func (db *DB) listen(channel string, onNotify func(payload string)) (func(), error) {
+	listener := pq.NewListener(db.connStr, 10*time.Second, time.Minute, nil)
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", channel, err)
 	}
 
-	return db, nil
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case notification, ok := <-listener.Notify:
+				if !ok {
+					return
+				}
+				// A nil notification means the connection was re-established
+				payload := ""
+				if notification != nil {
+					payload = notification.Extra
+				}
+				onNotify(payload)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		listener.Close()
+	}, nil
+}
+
+// NotifyJobActivity signals listeners of JobActivityChannel that jobID changed
+func (db *DB) NotifyJobActivity(jobID string) error {
+	_, err := db.Exec("SELECT pg_notify($1, $2)", JobActivityChannel, jobID)
+	return err
 }
 
 // GetDBType returns "postgres"
@@ -83,7 +299,93 @@ func (db *DB) GetDBType() string {
 
 // GetDBPath returns the PostgreSQL connection string (sanitized)
 func (db *DB) GetDBPath() string {
-	return db.connStr
+	return maskConnStr(db.connStr)
+}
+
+// passwordParam matches the password of a key=value connection string
+var passwordParam = regexp.MustCompile(`password=\S+`)
+
+// maskConnStr hides the password of a URL or key=value connection string
+func maskConnStr(connStr string) string {
+	if parsed, err := url.Parse(connStr); err == nil && parsed.Scheme != "" {
+		return parsed.Redacted()
+	}
+	return passwordParam.ReplaceAllString(connStr, "password=xxxxx")
+}
+
+// ConnectionSettings is the resolved configuration of the service database connection
+type ConnectionSettings struct {
+	Connection        string `json:"connection"` // Password masked
+	MaxOpenConns      int    `json:"max_open_conns"`
+	MaxIdleConns      int    `json:"max_idle_conns"`
+	ConnMaxLifetime   string `json:"conn_max_lifetime"`
+	ConnectAttempts   int    `json:"connect_attempts"`
+	ConnectInterval   string `json:"connect_interval"`
+	ConnectTimeout    int    `json:"connect_timeout_seconds"`
+	SchemaAutoMigrate bool   `json:"schema_auto_migrate"`
+}
+
+// Settings returns the connection configuration the database was opened with
+func (db *DB) Settings() ConnectionSettings {
+	attempts, interval := connectRetries()
+	return ConnectionSettings{
+		Connection:        db.GetDBPath(),
+		MaxOpenConns:      db.pool.maxOpenConns,
+		MaxIdleConns:      db.pool.maxIdleConns,
+		ConnMaxLifetime:   db.pool.connMaxLifetime.String(),
+		ConnectAttempts:   attempts,
+		ConnectInterval:   interval.String(),
+		ConnectTimeout:    connectTimeoutSeconds(),
+		SchemaAutoMigrate: schemaAutoMigrate(),
+	}
+}
+
+// MaintenanceTables are the service tables VacuumAnalyze may be run on
+var MaintenanceTables = []string{"logs", "backups", "jobs"}
+
+// TableMaintenance is the outcome of VACUUM ANALYZE on one table
+type TableMaintenance struct {
+	Table      string `json:"table"`
+	SizeBefore int64  `json:"size_before_bytes"`
+	SizeAfter  int64  `json:"size_after_bytes"`
+	DeadTuples int64  `json:"dead_tuples_before"` // Rows VACUUM can reclaim for reuse
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// VacuumAnalyze runs VACUUM ANALYZE on one of MaintenanceTables. VACUUM can't
+// run in a transaction, so it goes through the pool directly.
+func (db *DB) VacuumAnalyze(table string) *TableMaintenance {
+	result := &TableMaintenance{Table: table}
+
+	allowed := false
+	for _, name := range MaintenanceTables {
+		if name == table {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		result.Error = fmt.Sprintf("table %s is not maintained by the service", table)
+		return result
+	}
+
+	statsQuery := `
+		SELECT pg_total_relation_size(relid), n_dead_tup
+		FROM pg_stat_user_tables WHERE relname = $1`
+	if err := db.QueryRow(statsQuery, table).Scan(&result.SizeBefore, &result.DeadTuples); err != nil {
+		result.Error = fmt.Sprintf("failed to read table statistics: %v", err)
+		return result
+	}
+
+	start := time.Now()
+	if _, err := db.Exec("VACUUM ANALYZE " + table); err != nil {
+		result.Error = err.Error()
+	}
+	result.DurationMS = time.Since(start).Milliseconds()
+
+	db.QueryRow("SELECT pg_total_relation_size($1::regclass)", table).Scan(&result.SizeAfter)
+	return result
 }
 
 // GetStats returns PostgreSQL database statistics
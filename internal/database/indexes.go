@@ -0,0 +1,52 @@
+package database
+
+import (
+	_ "embed"
+	"regexp"
+)
+
+//go:embed schema_postgres.sql
+var schemaSQL string
+
+// schemaIndexPattern extracts the index names created by the schema
+var schemaIndexPattern = regexp.MustCompile(`CREATE (?:UNIQUE )?INDEX IF NOT EXISTS (\w+)`)
+
+// ExpectedIndexes returns the names of the indexes schema_postgres.sql creates
+func ExpectedIndexes() []string {
+	var names []string
+	for _, match := range schemaIndexPattern.FindAllStringSubmatch(schemaSQL, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// MissingIndexes returns the schema indexes that don't exist in the database,
+// e.g. because it was created from an older schema and a migrate_add_*.sql
+// file wasn't applied
+func (db *DB) MissingIndexes() ([]string, error) {
+	rows, err := db.Query("SELECT indexname FROM pg_indexes WHERE schemaname = current_schema()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, name := range ExpectedIndexes() {
+		if !existing[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
@@ -3,6 +3,8 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -20,6 +22,52 @@ type LogEntry struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
+// Defaults of the log size caps
+const (
+	defaultMaxLogMessageBytes = 8 << 10
+	defaultMaxLogDetailsBytes = 1 << 20
+)
+
+// LogSizeLimits returns the longest message and details stored per log entry,
+// configurable via LOG_MAX_MESSAGE_BYTES and LOG_MAX_DETAILS_BYTES
+func LogSizeLimits() (message, details int) {
+	message, details = defaultMaxLogMessageBytes, defaultMaxLogDetailsBytes
+	if value, err := strconv.Atoi(os.Getenv("LOG_MAX_MESSAGE_BYTES")); err == nil && value > 0 {
+		message = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("LOG_MAX_DETAILS_BYTES")); err == nil && value > 0 {
+		details = value
+	}
+	return message, details
+}
+
+// limitLogEntry returns the message and details to store for entry. A long
+// message is cut to the message limit with a marker; its full text moves to
+// details when the entry has none and it fits the details limit.
+func limitLogEntry(entry *LogEntry) (message, details string) {
+	maxMessage, maxDetails := LogSizeLimits()
+	message, details = entry.Message, entry.Details
+
+	if len(details) > maxDetails {
+		details = truncateLogText(details, maxDetails) + fmt.Sprintf("\n[... truncated, %d bytes in total ...]", len(entry.Details))
+	}
+	if len(message) > maxMessage {
+		message = truncateLogText(message, maxMessage)
+		if details == "" && len(entry.Message) <= maxDetails {
+			details = entry.Message
+			message += fmt.Sprintf(" [... truncated, %d bytes in total; full text in details ...]", len(entry.Message))
+		} else {
+			message += fmt.Sprintf(" [... truncated, %d bytes in total ...]", len(entry.Message))
+		}
+	}
+	return message, details
+}
+
+// truncateLogText keeps the first limit bytes of text without splitting a character
+func truncateLogText(text string, limit int) string {
+	return strings.ToValidUTF8(text[:limit], "")
+}
+
 type LogRepository struct {
 	db *DB
 }
@@ -36,6 +84,7 @@ func (r *LogRepository) Create(entry *LogEntry) error {
 			message, details, created_at
 		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
 
+	message, details := limitLogEntry(entry)
 	result, err := r.db.Exec(
 		query,
 		entry.Timestamp,
@@ -43,8 +92,8 @@ func (r *LogRepository) Create(entry *LogEntry) error {
 		entry.Component,
 		nullString(entry.JobID),
 		nullString(entry.BackupID),
-		entry.Message,
-		nullString(entry.Details),
+		message,
+		nullString(details),
 		time.Now(),
 	)
 
@@ -59,6 +108,11 @@ func (r *LogRepository) Create(entry *LogEntry) error {
 	}
 
 	_ = result // Suppress unused variable warning
+
+	// Wake job progress streams; they also poll, so a failed notification is harmless
+	if entry.JobID != "" {
+		_ = r.db.NotifyJobActivity(entry.JobID)
+	}
 	return nil
 }
 
@@ -68,27 +122,35 @@ func (r *LogRepository) CreateBatch(entries []*LogEntry) error {
 		return nil
 	}
 
+	return insertLogs(r.db, entries)
+}
+
+// insertLogs inserts log entries through a database or a transaction
+func insertLogs(db interface {
+	Prepare(query string) (*sql.Stmt, error)
+}, entries []*LogEntry) error {
 	query := `
 		INSERT INTO logs (
 			timestamp, level, component, job_id, backup_id, 
 			message, details, created_at
 		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
 
-	stmt, err := r.db.Prepare(query)
+	stmt, err := db.Prepare(query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
 	for _, entry := range entries {
+		message, details := limitLogEntry(entry)
 		_, err := stmt.Exec(
 			entry.Timestamp,
 			entry.Level,
 			entry.Component,
 			nullString(entry.JobID),
 			nullString(entry.BackupID),
-			entry.Message,
-			nullString(entry.Details),
+			message,
+			nullString(details),
 			time.Now(),
 		)
 		if err != nil {
@@ -101,6 +163,52 @@ func (r *LogRepository) CreateBatch(entries []*LogEntry) error {
 
 // GetFiltered retrieves logs with filtering options
 func (r *LogRepository) GetFiltered(filters LogFilters) ([]*LogEntry, error) {
+	query, args := r.filteredQuery(filters, "DESC")
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []*LogEntry
+	for rows.Next() {
+		log, err := r.scanLog(rows)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+
+	return logs, rows.Err()
+}
+
+// StreamFiltered calls fn for every log matching filters, oldest first, reading
+// rows through the cursor instead of loading them all into memory
+func (r *LogRepository) StreamFiltered(filters LogFilters, fn func(*LogEntry) error) error {
+	query, args := r.filteredQuery(filters, "ASC")
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := r.scanLog(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// filteredQuery builds the SELECT for filters, ordered by timestamp in the given direction
+func (r *LogRepository) filteredQuery(filters LogFilters, order string) (string, []interface{}) {
 	query := `
 		SELECT id, timestamp, level, component, job_id, backup_id, message, details, created_at
 		FROM logs`
@@ -152,19 +260,53 @@ func (r *LogRepository) GetFiltered(filters LogFilters) ([]*LogEntry, error) {
 		argIndex++
 	}
 
+	// Apply message search; % and _ in the term match literally
+	if filters.Search != "" {
+		whereClauses = append(whereClauses, fmt.Sprintf(`message ILIKE $%d ESCAPE '\'`, argIndex))
+		args = append(args, "%"+likeEscaper.Replace(filters.Search)+"%")
+		argIndex++
+	}
+
 	// Add WHERE clause if we have filters
 	if len(whereClauses) > 0 {
 		query += " WHERE " + strings.Join(whereClauses, " AND ")
 	}
 
 	// Add ordering and limit
-	query += " ORDER BY timestamp DESC"
+	query += " ORDER BY timestamp " + order
 	if filters.Limit > 0 {
 		query += fmt.Sprintf(" LIMIT $%d", argIndex)
 		args = append(args, filters.Limit)
+		argIndex++
+	}
+	if filters.Offset > 0 {
+		query += fmt.Sprintf(" OFFSET $%d", argIndex)
+		args = append(args, filters.Offset)
 	}
 
-	rows, err := r.db.Query(query, args...)
+	return query, args
+}
+
+// likeEscaper escapes LIKE wildcards so a search term matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// GetByJobID retrieves all logs for a specific job
+func (r *LogRepository) GetByJobID(jobID string) ([]*LogEntry, error) {
+	return r.GetFiltered(LogFilters{
+		JobID: jobID,
+	})
+}
+
+// GetByJobIDAfter retrieves the logs of a job with an ID above afterID, in
+// insertion order, so a follower can resume where it left off
+func (r *LogRepository) GetByJobIDAfter(jobID string, afterID int64) ([]*LogEntry, error) {
+	query := `
+		SELECT id, timestamp, level, component, job_id, backup_id, message, details, created_at
+		FROM logs
+		WHERE job_id = $1 AND id > $2
+		ORDER BY id`
+
+	rows, err := r.db.Query(query, jobID, afterID)
 	if err != nil {
 		return nil, err
 	}
@@ -182,13 +324,6 @@ func (r *LogRepository) GetFiltered(filters LogFilters) ([]*LogEntry, error) {
 	return logs, rows.Err()
 }
 
-// GetByJobID retrieves all logs for a specific job
-func (r *LogRepository) GetByJobID(jobID string) ([]*LogEntry, error) {
-	return r.GetFiltered(LogFilters{
-		JobID: jobID,
-	})
-}
-
 // GetByBackupID retrieves all logs for a specific backup
 func (r *LogRepository) GetByBackupID(backupID string) ([]*LogEntry, error) {
 	return r.GetFiltered(LogFilters{
@@ -331,7 +466,9 @@ type LogFilters struct {
 	Component string
 	JobID     string
 	BackupID  string
+	Search    string // Case-insensitive substring of the message
 	Limit     int
+	Offset    int
 }
 
 // Helper function to convert string to sql.NullString
@@ -0,0 +1,11 @@
+-- Add format column to existing backups table
+-- Run this if you have an existing table without the format column
+
+-- Restores pick psql, gunzip or pg_restore from it; '' (older backups) means detect from the file
+ALTER TABLE backups
+ADD COLUMN IF NOT EXISTS format TEXT NOT NULL DEFAULT '';
+
+-- Backups taken before this migration keep '' and are detected by their leading bytes on restore
+
+-- Verify the migration
+SELECT format, COUNT(*) FROM backups GROUP BY format;
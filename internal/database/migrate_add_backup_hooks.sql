@@ -0,0 +1,13 @@
+-- Add backup hook columns to existing postgresql_instances table
+-- Run this if your database was created before pre/post-backup hooks were added
+
+-- SQL run against each database before it is dumped; only used with ALLOW_PRE_BACKUP_SQL=true
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS pre_backup_sql TEXT NOT NULL DEFAULT '';
+
+-- URL posted to after each successful backup
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS post_backup_webhook TEXT NOT NULL DEFAULT '';
+
+-- Verify the migration
+SELECT id, name, pre_backup_sql, post_backup_webhook FROM postgresql_instances;
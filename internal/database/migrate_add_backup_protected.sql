@@ -0,0 +1,9 @@
+-- Add protected column to existing backups table
+-- Run this if you have an existing table without protected
+
+-- Protected backups are never removed by retention cleanup
+ALTER TABLE backups
+ADD COLUMN IF NOT EXISTS protected BOOLEAN NOT NULL DEFAULT false;
+
+-- Verify the migration
+SELECT id, database_name, backup_type, protected FROM backups ORDER BY created_at DESC LIMIT 10;
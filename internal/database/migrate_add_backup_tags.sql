@@ -0,0 +1,11 @@
+-- Add tags column to existing backups table
+-- Run this if you have an existing table without tags
+
+-- Free-form labels, e.g. ["pre-deploy"]
+ALTER TABLE backups
+ADD COLUMN IF NOT EXISTS tags JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+CREATE INDEX IF NOT EXISTS idx_backups_tags ON backups USING GIN (tags);
+
+-- Verify the migration
+SELECT id, database_name, tags FROM backups ORDER BY created_at DESC LIMIT 10;
@@ -0,0 +1,12 @@
+-- Add trashed_at and purge_at columns to existing backups table
+-- Run this if you have an existing table without the trash columns
+
+-- Deleted backups stay in the trash until purge_at, then the purge job removes them
+ALTER TABLE backups
+ADD COLUMN IF NOT EXISTS trashed_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN IF NOT EXISTS purge_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_backups_purge_at ON backups(purge_at) WHERE trashed_at IS NOT NULL;
+
+-- Verify the migration
+SELECT id, status, trashed_at, purge_at FROM backups WHERE trashed_at IS NOT NULL LIMIT 10;
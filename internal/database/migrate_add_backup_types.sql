@@ -0,0 +1,11 @@
+-- Add backup_types and database_backup_types columns to existing postgresql_instances table
+-- Run this if your database was created before per-instance backup types were added
+
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS backup_types JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS database_backup_types JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+-- Verify the migration
+SELECT id, name, backup_types, database_backup_types FROM postgresql_instances;
@@ -0,0 +1,10 @@
+-- Allow the basebackup job type in an existing jobs table
+-- Run this if your jobs table only accepts backup, restore and cleanup
+
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_type_check;
+
+ALTER TABLE jobs
+ADD CONSTRAINT jobs_type_check CHECK(type IN ('backup', 'restore', 'cleanup', 'basebackup'));
+
+-- Verify the migration
+SELECT conname, pg_get_constraintdef(oid) FROM pg_constraint WHERE conname = 'jobs_type_check';
@@ -0,0 +1,9 @@
+-- Add blobs_excluded column to existing backups table
+-- Run this if you have an existing table without the blobs_excluded column
+
+-- Marks backups dumped with --no-blobs, which don't contain large objects
+ALTER TABLE backups
+ADD COLUMN IF NOT EXISTS blobs_excluded BOOLEAN NOT NULL DEFAULT false;
+
+-- Verify the migration
+SELECT blobs_excluded, COUNT(*) FROM backups GROUP BY blobs_excluded;
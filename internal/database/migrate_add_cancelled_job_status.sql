@@ -0,0 +1,11 @@
+-- Allow the cancelled job status in an existing jobs table
+-- Run this if your jobs table was created by migrate_add_jobs_table.sql, whose
+-- status check rejects the jobs CancelPendingJobs cancels
+
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_status_check;
+
+ALTER TABLE jobs
+ADD CONSTRAINT jobs_status_check CHECK(status IN ('pending', 'running', 'completed', 'failed', 'cancelled', 'retrying'));
+
+-- Verify the migration
+SELECT conname, pg_get_constraintdef(oid) FROM pg_constraint WHERE conname = 'jobs_status_check';
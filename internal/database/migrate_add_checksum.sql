@@ -0,0 +1,11 @@
+-- Add checksum column to existing backups table
+-- Run this if you have an existing table without checksum
+
+-- SHA-256 of the dump content, excluding pg_dump timestamp comments
+ALTER TABLE backups
+ADD COLUMN IF NOT EXISTS checksum TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_backups_checksum ON backups(checksum) WHERE checksum IS NOT NULL;
+
+-- Verify the migration
+SELECT id, database_name, checksum FROM backups ORDER BY created_at DESC LIMIT 10;
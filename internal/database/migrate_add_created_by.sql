@@ -0,0 +1,9 @@
+-- Add created_by column to existing backups table
+-- Run this if you have an existing table without the created_by column
+
+-- API key name or 'scheduler' that requested the backup
+ALTER TABLE backups
+ADD COLUMN IF NOT EXISTS created_by TEXT NOT NULL DEFAULT '';
+
+-- Verify the migration
+SELECT id, backup_type, created_by FROM backups LIMIT 10;
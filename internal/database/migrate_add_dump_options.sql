@@ -0,0 +1,17 @@
+-- Add pg_dump option and compression columns to existing postgresql_instances table
+-- Run this if you have an existing table without dump_options, compression and compression_level
+
+-- Extra allowlisted pg_dump flags
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS dump_options JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+-- Dump compression: '' (none) or 'gzip'
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS compression TEXT NOT NULL DEFAULT '' CHECK(compression IN ('', 'gzip'));
+
+-- gzip level 1-9, 0 uses the default level
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS compression_level INTEGER NOT NULL DEFAULT 0 CHECK(compression_level BETWEEN 0 AND 9);
+
+-- Verify the migration
+SELECT id, name, dump_options, compression, compression_level FROM postgresql_instances;
@@ -0,0 +1,9 @@
+-- Add dump_timeout column to existing postgresql_instances table
+-- Run this if your postgresql_instances table was created before dump_timeout was added
+
+-- Wall-clock limit of a whole pg_dump run, e.g. '2h'; empty = none
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS dump_timeout TEXT NOT NULL DEFAULT '';
+
+-- Verify the migration
+SELECT id, name, statement_timeout, lock_timeout, dump_timeout FROM postgresql_instances;
@@ -0,0 +1,9 @@
+-- Add failure_category column to existing backups table
+-- Run this if you have an existing table without the failure_category column
+
+-- Cause of a failed backup, e.g. 'auth_error'; '' otherwise
+ALTER TABLE backups
+ADD COLUMN IF NOT EXISTS failure_category TEXT NOT NULL DEFAULT '';
+
+-- Verify the migration
+SELECT id, status, failure_category, error_message FROM backups WHERE status = 'failed' LIMIT 10;
@@ -0,0 +1,9 @@
+-- Add failure_output column to existing backups table
+-- Run this if you have an existing table without the failure_output column
+
+-- Complete pg_dump stderr of failed dumps, served by GET /api/v2/backups/:id/failure-output
+ALTER TABLE backups
+ADD COLUMN IF NOT EXISTS failure_output TEXT NOT NULL DEFAULT '';
+
+-- Verify the migration
+SELECT id, status, LENGTH(failure_output) AS failure_output_bytes FROM backups WHERE failure_output <> '' LIMIT 10;
@@ -0,0 +1,10 @@
+-- Add idempotency_key column to existing backups table
+-- Run this if you have an existing table without the idempotency_key column
+
+ALTER TABLE backups
+ADD COLUMN IF NOT EXISTS idempotency_key TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_backups_idempotency_key ON backups(idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+-- Verify the migration
+SELECT id, job_id, idempotency_key FROM backups LIMIT 5;
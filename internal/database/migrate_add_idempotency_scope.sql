@@ -0,0 +1,21 @@
+-- Make Idempotency-Key unique per API key on existing backups table
+-- Run this if your backups table was created before idempotency keys were scoped
+
+-- Requests without the header used to store '' instead of NULL
+UPDATE backups SET idempotency_key = NULL WHERE idempotency_key = '';
+
+-- Only the newest backup of each API key keeps a key used more than once
+UPDATE backups older SET idempotency_key = NULL
+WHERE idempotency_key IS NOT NULL AND EXISTS (
+    SELECT 1 FROM backups newer
+    WHERE newer.created_by = older.created_by
+      AND newer.idempotency_key = older.idempotency_key
+      AND (newer.created_at, newer.id) > (older.created_at, older.id)
+);
+
+DROP INDEX IF EXISTS idx_backups_idempotency_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_backups_idempotency_scope ON backups(created_by, idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+-- Verify the migration
+SELECT indexname, indexdef FROM pg_indexes WHERE indexname = 'idx_backups_idempotency_scope';
@@ -0,0 +1,9 @@
+-- Add labels column to existing postgresql_instances table
+-- Run this if your database was created before instance labels were added
+
+-- Grouping labels, e.g. {"team": "payments"}
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+-- Verify the migration
+SELECT id, name, labels FROM postgresql_instances;
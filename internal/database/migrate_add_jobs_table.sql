@@ -10,7 +10,7 @@ CREATE TABLE IF NOT EXISTS jobs (
     backup_id TEXT,
     priority INTEGER NOT NULL DEFAULT 5,
     payload TEXT, -- JSON payload (optional)
-    status TEXT NOT NULL CHECK(status IN ('pending', 'running', 'completed', 'failed', 'retrying')),
+    status TEXT NOT NULL CHECK(status IN ('pending', 'running', 'completed', 'failed', 'cancelled', 'retrying')),
     retry_count INTEGER NOT NULL DEFAULT 0,
     max_retries INTEGER NOT NULL DEFAULT 3,
     error_message TEXT,
@@ -0,0 +1,9 @@
+-- Add max_backup_size_bytes column to existing postgresql_instances table
+-- Run this if you have an existing table without the backup size limit
+
+-- Backups larger than this are aborted and marked failed; 0 = no limit
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS max_backup_size_bytes BIGINT NOT NULL DEFAULT 0 CHECK(max_backup_size_bytes >= 0);
+
+-- Verify the migration
+SELECT id, name, max_backup_size_bytes FROM postgresql_instances;
@@ -0,0 +1,12 @@
+-- Add migrated_files table to an existing database
+-- Run this if you have an existing database without migrated_files
+
+-- Log files imported by the JSON migration, with the number of lines read
+CREATE TABLE IF NOT EXISTS migrated_files (
+    path TEXT PRIMARY KEY,
+    lines INTEGER NOT NULL DEFAULT 0,
+    migrated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Verify the migration
+SELECT * FROM migrated_files;
@@ -0,0 +1,20 @@
+-- Add one-off schedule columns to existing schedules table
+-- Run this if your schedules table was created before one-off schedules were added
+
+-- One-off schedules fire a single backup at run_at and are then disabled
+ALTER TABLE schedules
+ADD COLUMN IF NOT EXISTS one_off BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN IF NOT EXISTS run_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN IF NOT EXISTS backup_id TEXT NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS created_by TEXT NOT NULL DEFAULT '';
+
+-- One-off backups default to the manual type
+ALTER TABLE schedules DROP CONSTRAINT IF EXISTS schedules_backup_type_check;
+
+ALTER TABLE schedules
+ADD CONSTRAINT schedules_backup_type_check CHECK(backup_type IN ('hourly', 'daily', 'weekly', 'monthly', 'manual'));
+
+CREATE INDEX IF NOT EXISTS idx_schedules_one_off_run_at ON schedules(run_at) WHERE one_off AND enabled;
+
+-- Verify the migration
+SELECT id, postgresql_id, database_name, one_off, run_at, enabled FROM schedules;
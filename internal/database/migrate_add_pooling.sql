@@ -0,0 +1,14 @@
+-- Add pooled, direct_host and direct_port columns to existing postgresql_instances table
+-- Run this if your database was created before connection pooler support was added
+
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS pooled BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS direct_host TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS direct_port INTEGER NOT NULL DEFAULT 0 CHECK(direct_port >= 0 AND direct_port <= 65535);
+
+-- Verify the migration
+SELECT id, name, pooled, direct_host, direct_port FROM postgresql_instances;
@@ -0,0 +1,11 @@
+-- Add composite indexes to an existing database
+-- Run this if the service logs missing indexes at startup
+
+-- Worker job loader: filters on status, orders by priority and age
+CREATE INDEX IF NOT EXISTS idx_jobs_status_priority_created ON jobs(status, priority DESC, created_at);
+
+-- Retention cleanup and per-instance backup listing
+CREATE INDEX IF NOT EXISTS idx_backups_instance_type_created ON backups(postgresql_id, backup_type, created_at DESC);
+
+-- Verify the migration
+SELECT indexname, tablename FROM pg_indexes WHERE indexname IN ('idx_jobs_status_priority_created', 'idx_backups_instance_type_created');
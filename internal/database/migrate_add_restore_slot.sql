@@ -0,0 +1,10 @@
+-- Add restore_slot column to existing jobs table
+-- Run this if your jobs table was created before restore_slot was added
+
+-- 'waiting' or 'held' while a restore job waits for or holds one of the
+-- MAX_CONCURRENT_RESTORES slots shared by all worker processes; NULL otherwise
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS restore_slot TEXT CHECK(restore_slot IN ('waiting', 'held'));
+
+-- Verify the migration
+SELECT id, type, status, restore_slot FROM jobs WHERE restore_slot IS NOT NULL;
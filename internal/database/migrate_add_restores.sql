@@ -0,0 +1,23 @@
+-- Add restores table to an existing database
+-- Run this if you have an existing database without restores
+
+-- Restore audit trail; no foreign keys so records outlive backups and instances
+CREATE TABLE IF NOT EXISTS restores (
+    id TEXT PRIMARY KEY,
+    job_id TEXT,
+    backup_id TEXT NOT NULL,
+    source_postgresql_id TEXT NOT NULL DEFAULT '', -- Instance the backup was taken from
+    target_postgresql_id TEXT NOT NULL,
+    target_database TEXT NOT NULL,
+    requested_by TEXT NOT NULL DEFAULT '', -- API key name
+    status TEXT NOT NULL CHECK(status IN ('in_progress', 'completed', 'failed')),
+    error_message TEXT,
+    start_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    end_time TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_restores_start_time ON restores(start_time DESC);
+CREATE INDEX IF NOT EXISTS idx_restores_backup_id ON restores(backup_id);
+
+-- Verify the migration
+SELECT * FROM restores LIMIT 10;
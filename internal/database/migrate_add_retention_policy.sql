@@ -0,0 +1,9 @@
+-- Add retention_policy column to existing postgresql_instances table
+-- Run this if you have an existing table without retention_policy
+
+-- NULL means the instance uses the global retention policy
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS retention_policy JSONB;
+
+-- Verify the migration
+SELECT id, name, retention_policy FROM postgresql_instances;
@@ -0,0 +1,10 @@
+-- Allow the schema_diff job type in an existing jobs table
+-- Run this if your jobs table doesn't accept schema_diff jobs
+
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_type_check;
+
+ALTER TABLE jobs
+ADD CONSTRAINT jobs_type_check CHECK(type IN ('backup', 'restore', 'cleanup', 'basebackup', 'schema_diff'));
+
+-- Verify the migration
+SELECT conname, pg_get_constraintdef(oid) FROM pg_constraint WHERE conname = 'jobs_type_check';
@@ -0,0 +1,23 @@
+-- Add SSL certificate columns to existing postgresql_instances table
+-- Run this if you have an existing table without ssl_root_cert, ssl_cert and ssl_key
+
+-- Allow verify-ca and verify-full SSL modes
+ALTER TABLE postgresql_instances
+DROP CONSTRAINT IF EXISTS postgresql_instances_ssl_mode_check;
+
+ALTER TABLE postgresql_instances
+ADD CONSTRAINT postgresql_instances_ssl_mode_check
+CHECK (ssl_mode IN ('disable', 'allow', 'prefer', 'require', 'verify-ca', 'verify-full'));
+
+-- Certificates can be stored as file paths or PEM content
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS ssl_root_cert TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS ssl_cert TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS ssl_key TEXT NOT NULL DEFAULT '';
+
+-- Verify the migration
+SELECT id, name, ssl_mode, ssl_root_cert <> '' AS has_root_cert FROM postgresql_instances;
@@ -0,0 +1,9 @@
+-- Add storage_class column to existing backups table
+-- Run this if you have an existing table without the storage_class column
+
+-- S3 storage class of the uploaded backup, '' = STANDARD
+ALTER TABLE backups
+ADD COLUMN IF NOT EXISTS storage_class TEXT NOT NULL DEFAULT '';
+
+-- Verify the migration
+SELECT id, s3_key, storage_class FROM backups LIMIT 10;
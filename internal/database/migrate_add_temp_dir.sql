@@ -0,0 +1,9 @@
+-- Add temp_dir column to existing postgresql_instances table
+-- Run this if you have an existing table without temp_dir
+
+-- Empty means BACKUP_TEMP_DIR is used
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS temp_dir TEXT NOT NULL DEFAULT '';
+
+-- Verify the migration
+SELECT id, name, temp_dir FROM postgresql_instances;
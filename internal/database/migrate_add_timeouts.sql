@@ -0,0 +1,12 @@
+-- Add statement_timeout and lock_timeout columns to existing postgresql_instances table
+-- Run this if you have an existing table without the timeout columns
+
+-- Backup session timeouts in PostgreSQL syntax, e.g. '30min'; empty = none
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS statement_timeout TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE postgresql_instances
+ADD COLUMN IF NOT EXISTS lock_timeout TEXT NOT NULL DEFAULT '';
+
+-- Verify the migration
+SELECT id, name, statement_timeout, lock_timeout FROM postgresql_instances;
@@ -0,0 +1,17 @@
+-- Add compression and throughput columns to existing backups table
+-- Run this if you have an existing table without the uncompressed_size column
+
+-- Dump size before compression; file_size is the compressed size
+ALTER TABLE backups
+ADD COLUMN IF NOT EXISTS uncompressed_size BIGINT NOT NULL DEFAULT 0;
+
+-- uncompressed_size / file_size
+ALTER TABLE backups
+ADD COLUMN IF NOT EXISTS compression_ratio DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- Uncompressed MB per second of the run
+ALTER TABLE backups
+ADD COLUMN IF NOT EXISTS throughput_mbps DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- Verify the migration
+SELECT id, file_size, uncompressed_size, compression_ratio, throughput_mbps FROM backups LIMIT 10;
@@ -0,0 +1,15 @@
+-- Add worker_stats table to an existing database
+-- Run this if you have an existing database without worker_stats
+
+-- Lifetime job counters per worker, kept across restarts
+CREATE TABLE IF NOT EXISTS worker_stats (
+    worker_id TEXT PRIMARY KEY,
+    jobs_handled BIGINT NOT NULL DEFAULT 0,
+    jobs_failed BIGINT NOT NULL DEFAULT 0,
+    bytes_processed BIGINT NOT NULL DEFAULT 0, -- Size of the backup files written
+    last_job_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Verify the migration
+SELECT * FROM worker_stats;
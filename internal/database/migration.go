@@ -1,21 +1,39 @@
 package database
 
 import (
+	"archive/tar"
 	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"evolution-postgres-backup/internal/config"
 	"evolution-postgres-backup/internal/models"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 type MigrationService struct {
 	db      *DB
 	dataDir string
+
+	// Cached MigrationNeeded answer, see migrationNeededTTL
+	neededMu sync.Mutex
+	needed   bool
+	neededAt time.Time
+
+	// Background migration started by StartMigration, see migration_run.go
+	runMu  sync.Mutex
+	run    *MigrationRun
+	cancel context.CancelFunc
 }
 
 func NewMigrationService(db *DB, dataDir string) *MigrationService {
@@ -25,31 +43,86 @@ func NewMigrationService(db *DB, dataDir string) *MigrationService {
 	}
 }
 
-// MigrateAll performs complete migration from JSON to SQLite
+// migrationLockKey is the pg_advisory_lock key held while a migration runs
+const migrationLockKey = 7_346_201
+
+// ErrMigrationInProgress is returned by MigrateAll while another process or
+// request is already migrating
+var ErrMigrationInProgress = errors.New("a migration is already in progress")
+
+// ErrMigrationAborted is returned when a migration is cancelled; what was
+// migrated before that stays in the database
+var ErrMigrationAborted = errors.New("migration aborted")
+
+// MigrateAll performs complete migration from JSON to SQLite. Only one
+// migration runs at a time across all processes sharing the database.
 func (m *MigrationService) MigrateAll() error {
+	unlock, err := m.lockMigration()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return m.migrate(context.Background(), &MigrationRun{})
+}
+
+// lockMigration takes the migration advisory lock. Advisory locks belong to a
+// session, so the returned function releases it and the connection holding it.
+func (m *MigrationService) lockMigration() (func(), error) {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection for migration lock: %w", err)
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockKey).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	if !locked {
+		conn.Close()
+		return nil, ErrMigrationInProgress
+	}
+	return func() {
+		conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+		conn.Close()
+	}, nil
+}
+
+// migrate runs the migration steps, recording progress in run. It stops
+// between records when ctx is cancelled, returning ErrMigrationAborted.
+func (m *MigrationService) migrate(ctx context.Context, run *MigrationRun) error {
 	fmt.Println("🔄 Starting migration from JSON to SQLite...")
 
 	// 1. Migrate config.json (PostgreSQL instances)
-	if err := m.migrateConfig(); err != nil {
+	m.setStage(run, "config")
+	if err := m.migrateConfig(ctx, run); err != nil {
 		return fmt.Errorf("failed to migrate config: %w", err)
 	}
 
 	// 2. Migrate backups.json
-	if err := m.migrateBackups(); err != nil {
+	m.setStage(run, "backups")
+	if err := m.migrateBackups(ctx, run); err != nil {
 		return fmt.Errorf("failed to migrate backups: %w", err)
 	}
 
 	// 3. Migrate log files
-	if err := m.migrateLogs(); err != nil {
+	m.setStage(run, "logs")
+	if err := m.migrateLogs(ctx, run); err != nil {
 		return fmt.Errorf("failed to migrate logs: %w", err)
 	}
 
+	m.neededMu.Lock()
+	m.neededAt = time.Time{}
+	m.neededMu.Unlock()
+
 	fmt.Println("✅ Migration completed successfully!")
 	return nil
 }
 
 // migrateConfig migrates PostgreSQL instances from config.json
-func (m *MigrationService) migrateConfig() error {
+func (m *MigrationService) migrateConfig(ctx context.Context, run *MigrationRun) error {
 	fmt.Print("📋 Migrating PostgreSQL instances from config.json... ")
 
 	configPath := filepath.Join(m.dataDir, "../config.json")
@@ -68,11 +141,23 @@ func (m *MigrationService) migrateConfig() error {
 		return err
 	}
 
+	// The global retention policy is the fallback of instance overrides;
+	// one stored by an earlier run is kept
+	if cfg.RetentionPolicy != (config.RetentionPolicy{}) {
+		if err := m.db.SetGlobalRetentionPolicy(cfg.RetentionPolicy, false); err != nil {
+			return fmt.Errorf("failed to store retention policy: %w", err)
+		}
+	}
+
 	// Migrate PostgreSQL instances
 	postgresRepo := NewPostgreSQLRepository(m.db)
 	migratedCount := 0
 
 	for _, instance := range cfg.PostgreSQLInstances {
+		if err := checkAborted(ctx); err != nil {
+			return err
+		}
+
 		// Check if already exists
 		exists, err := postgresRepo.Exists(instance.ID)
 		if err != nil {
@@ -84,6 +169,7 @@ func (m *MigrationService) migrateConfig() error {
 				return fmt.Errorf("failed to create PostgreSQL instance %s: %w", instance.ID, err)
 			}
 			migratedCount++
+			m.updateRun(func() { run.Instances++ })
 		}
 	}
 
@@ -92,7 +178,7 @@ func (m *MigrationService) migrateConfig() error {
 }
 
 // migrateBackups migrates backup data from backups.json
-func (m *MigrationService) migrateBackups() error {
+func (m *MigrationService) migrateBackups(ctx context.Context, run *MigrationRun) error {
 	fmt.Print("💾 Migrating backups from backups.json... ")
 
 	backupsPath := filepath.Join(m.dataDir, "backups.json")
@@ -117,6 +203,10 @@ func (m *MigrationService) migrateBackups() error {
 	migratedCount := 0
 
 	for _, backup := range backups {
+		if err := checkAborted(ctx); err != nil {
+			return err
+		}
+
 		// Check if already exists
 		existing, err := backupRepo.GetByID(backup.ID)
 		if err == nil && existing != nil {
@@ -127,6 +217,7 @@ func (m *MigrationService) migrateBackups() error {
 			return fmt.Errorf("failed to create backup %s: %w", backup.ID, err)
 		}
 		migratedCount++
+		m.updateRun(func() { run.Backups++ })
 	}
 
 	fmt.Printf("✅ (%d backups)\n", migratedCount)
@@ -134,7 +225,7 @@ func (m *MigrationService) migrateBackups() error {
 }
 
 // migrateLogs migrates log files to structured database logs
-func (m *MigrationService) migrateLogs() error {
+func (m *MigrationService) migrateLogs(ctx context.Context, run *MigrationRun) error {
 	fmt.Print("📝 Migrating log files... ")
 
 	logsDir := os.Getenv("LOG_DIR")
@@ -162,23 +253,43 @@ func (m *MigrationService) migrateLogs() error {
 
 	logRepo := NewLogRepository(m.db)
 	totalMigrated := 0
+	m.updateRun(func() { run.LogFilesTotal = len(logFiles) })
 
+	// Each file is committed on its own, so an abort keeps the finished ones
 	for _, logFile := range logFiles {
+		if err := checkAborted(ctx); err != nil {
+			return err
+		}
 		count, err := m.migrateLogFile(logFile, logRepo)
+		m.updateRun(func() { run.LogFiles++ })
 		if err != nil {
 			fmt.Printf("⚠️  failed to migrate %s: %v\n", logFile, err)
 			continue
 		}
 		totalMigrated += count
+		m.updateRun(func() { run.LogEntries += count })
 	}
 
 	fmt.Printf("✅ (%d log entries from %d files)\n", totalMigrated, len(logFiles))
 	return nil
 }
 
-// migrateLogFile migrates a single log file
+// migrateLogFile migrates a single log file. The number of lines already
+// imported is kept in migrated_files, so running the migration again only
+// imports lines appended since.
 func (m *MigrationService) migrateLogFile(logFilePath string, logRepo *LogRepository) (int, error) {
-	file, err := os.Open(logFilePath)
+	path, err := filepath.Abs(logFilePath)
+	if err != nil {
+		return 0, err
+	}
+
+	var done int
+	err = m.db.QueryRow("SELECT lines FROM migrated_files WHERE path = $1", path).Scan(&done)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	file, err := os.Open(path)
 	if err != nil {
 		return 0, err
 	}
@@ -192,7 +303,13 @@ func (m *MigrationService) migrateLogFile(logFilePath string, logRepo *LogReposi
 	dateStr := strings.TrimPrefix(filename, "backup_")
 	dateStr = strings.TrimSuffix(dateStr, ".log")
 
+	lines := 0
 	for scanner.Scan() {
+		lines++
+		if lines <= done {
+			continue // Imported by an earlier migration
+		}
+
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
@@ -207,13 +324,32 @@ func (m *MigrationService) migrateLogFile(logFilePath string, logRepo *LogReposi
 	if err := scanner.Err(); err != nil {
 		return 0, err
 	}
+	if lines <= done {
+		return 0, nil
+	}
+
+	// Insert the entries and advance the marker together so a failure can't duplicate them
+	tx, err := m.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
-	// Batch insert for efficiency
 	if len(entries) > 0 {
-		if err := logRepo.CreateBatch(entries); err != nil {
+		if err := insertLogs(tx, entries); err != nil {
 			return 0, err
 		}
 	}
+	_, err = tx.Exec(`
+		INSERT INTO migrated_files (path, lines, migrated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (path) DO UPDATE SET lines = EXCLUDED.lines, migrated_at = EXCLUDED.migrated_at`,
+		path, lines, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
 
 	return len(entries), nil
 }
@@ -334,6 +470,115 @@ func (m *MigrationService) CreateBackupDatabase() error {
 	return nil
 }
 
+// jsonBackupName matches the directories CreateBackupDatabase creates
+var jsonBackupName = regexp.MustCompile(`^json_backup_\d{8}_\d{6}$`)
+
+// ErrJSONBackupNotFound is returned for unknown JSON backup directories
+var ErrJSONBackupNotFound = errors.New("JSON backup not found")
+
+// JSONBackup is a copy of the JSON files taken before a migration
+type JSONBackup struct {
+	Name      string           `json:"name"`
+	CreatedAt time.Time        `json:"created_at"`
+	Files     []JSONBackupFile `json:"files"`
+	TotalSize int64            `json:"total_size"`
+}
+
+// JSONBackupFile is one file of a JSON backup
+type JSONBackupFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// ListJSONBackups returns the JSON backups in the data directory, newest first
+func (m *MigrationService) ListJSONBackups() ([]*JSONBackup, error) {
+	entries, err := os.ReadDir(m.dataDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*JSONBackup{}, nil
+		}
+		return nil, err
+	}
+
+	backups := []*JSONBackup{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !jsonBackupName.MatchString(entry.Name()) {
+			continue
+		}
+		backup, err := m.readJSONBackup(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+
+	// The timestamped names sort chronologically
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name > backups[j].Name })
+	return backups, nil
+}
+
+// readJSONBackup describes the JSON backup directory name
+func (m *MigrationService) readJSONBackup(name string) (*JSONBackup, error) {
+	entries, err := os.ReadDir(filepath.Join(m.dataDir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, _ := time.ParseInLocation("20060102_150405", strings.TrimPrefix(name, "json_backup_"), time.Local)
+	backup := &JSONBackup{Name: name, CreatedAt: createdAt, Files: []JSONBackupFile{}}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backup.Files = append(backup.Files, JSONBackupFile{Name: entry.Name(), Size: info.Size()})
+		backup.TotalSize += info.Size()
+	}
+	return backup, nil
+}
+
+// WriteJSONBackupArchive writes the files of a JSON backup to w as a .tar.gz
+func (m *MigrationService) WriteJSONBackupArchive(name string, w io.Writer) error {
+	if !jsonBackupName.MatchString(name) {
+		return ErrJSONBackupNotFound
+	}
+	backup, err := m.readJSONBackup(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrJSONBackupNotFound
+		}
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+	for _, file := range backup.Files {
+		data, err := os.ReadFile(filepath.Join(m.dataDir, name, file.Name))
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{
+			Name:    name + "/" + file.Name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: backup.CreatedAt,
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := archive.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
 // GetMigrationStatus checks what data needs to be migrated
 func (m *MigrationService) GetMigrationStatus() (map[string]interface{}, error) {
 	status := make(map[string]interface{})
@@ -348,7 +593,7 @@ func (m *MigrationService) GetMigrationStatus() (map[string]interface{}, error)
 
 	// Check backups
 	backupRepo := NewBackupRepository(m.db)
-	backups, err := backupRepo.GetAll()
+	backups, err := backupRepo.GetAll(IncludeTrashed())
 	if err != nil {
 		return nil, err
 	}
@@ -362,6 +607,27 @@ func (m *MigrationService) GetMigrationStatus() (map[string]interface{}, error)
 	}
 	status["has_logs"] = len(logs) > 0
 
+	// A single-key advisory lock shows up in pg_locks with the key in objid and objsubid 1
+	var inProgress bool
+	lockQuery := `SELECT EXISTS (SELECT 1 FROM pg_locks
+		WHERE locktype = 'advisory' AND classid = 0 AND objid::bigint = $1 AND objsubid = 1 AND granted)`
+	if err := m.db.QueryRow(lockQuery, migrationLockKey).Scan(&inProgress); err != nil {
+		return nil, err
+	}
+	status["in_progress"] = inProgress
+
+	// The latest run of this process; a migration held by another process only shows in in_progress
+	status["state"] = "idle"
+	if inProgress {
+		status["state"] = MigrationStateRunning
+	}
+	if run := m.CurrentMigration(); run != nil {
+		status["run"] = run
+		if !inProgress {
+			status["state"] = run.State
+		}
+	}
+
 	// Check if JSON files exist
 	status["json_files_exist"] = map[string]bool{
 		"config.json":  m.fileExists("config.json") || m.fileExists(filepath.Join(m.dataDir, "../config.json")),
@@ -372,6 +638,35 @@ func (m *MigrationService) GetMigrationStatus() (map[string]interface{}, error)
 	return status, nil
 }
 
+// migrationNeededTTL is how long MigrationNeeded reuses its last answer, so
+// polling dashboards don't query the database on every refresh
+const migrationNeededTTL = 10 * time.Second
+
+// MigrationNeeded reports whether JSON data files exist while the database
+// holds no instances or backups yet. It is a cheap alternative to
+// GetMigrationStatus and is cached for migrationNeededTTL.
+func (m *MigrationService) MigrationNeeded() (bool, error) {
+	m.neededMu.Lock()
+	defer m.neededMu.Unlock()
+
+	if !m.neededAt.IsZero() && time.Since(m.neededAt) < migrationNeededTTL {
+		return m.needed, nil
+	}
+
+	needed := false
+	jsonExists := m.fileExists("config.json") || m.fileExists(filepath.Join(m.dataDir, "../config.json")) ||
+		m.fileExists(filepath.Join(m.dataDir, "backups.json"))
+	if jsonExists {
+		query := `SELECT NOT EXISTS (SELECT 1 FROM postgresql_instances) AND NOT EXISTS (SELECT 1 FROM backups)`
+		if err := m.db.QueryRow(query).Scan(&needed); err != nil {
+			return false, err
+		}
+	}
+
+	m.needed, m.neededAt = needed, time.Now()
+	return needed, nil
+}
+
 // Helper functions
 func (m *MigrationService) fileExists(path string) bool {
 	_, err := os.Stat(path)
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// States of a background migration
+const (
+	MigrationStateRunning   = "running"
+	MigrationStateCompleted = "completed"
+	MigrationStateAborted   = "aborted"
+	MigrationStateFailed    = "failed"
+)
+
+// ErrNoMigrationRunning is returned by AbortMigration when this process isn't migrating
+var ErrNoMigrationRunning = errors.New("no migration is running in this process")
+
+// MigrationRun is the progress of a migration started with StartMigration
+type MigrationRun struct {
+	State      string     `json:"state"`
+	Stage      string     `json:"stage,omitempty"` // config, backups or logs while running
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+
+	// Records migrated so far; rows that already existed are not counted
+	Instances     int `json:"instances"`
+	Backups       int `json:"backups"`
+	LogFiles      int `json:"log_files"` // Processed, out of LogFilesTotal
+	LogFilesTotal int `json:"log_files_total"`
+	LogEntries    int `json:"log_entries"`
+}
+
+// StartMigration runs MigrateAll in the background and returns at once. The
+// run's progress is reported by CurrentMigration and AbortMigration stops it.
+// Like MigrateAll it fails with ErrMigrationInProgress when another request
+// or process is migrating.
+func (m *MigrationService) StartMigration() (*MigrationRun, error) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	if m.run != nil && m.run.State == MigrationStateRunning {
+		return nil, ErrMigrationInProgress
+	}
+	unlock, err := m.lockMigration()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &MigrationRun{State: MigrationStateRunning, StartedAt: time.Now()}
+	m.run, m.cancel = run, cancel
+
+	go func() {
+		defer unlock()
+		defer cancel()
+
+		err := m.migrate(ctx, run)
+
+		m.runMu.Lock()
+		defer m.runMu.Unlock()
+		finishedAt := time.Now()
+		run.FinishedAt = &finishedAt
+		run.Stage = ""
+		switch {
+		case errors.Is(err, ErrMigrationAborted):
+			run.State = MigrationStateAborted
+			log.Printf("⏹️ Migration aborted after %d instances, %d backups and %d log entries",
+				run.Instances, run.Backups, run.LogEntries)
+		case err != nil:
+			run.State = MigrationStateFailed
+			run.Error = err.Error()
+			log.Printf("❌ Migration failed: %v", err)
+		default:
+			run.State = MigrationStateCompleted
+		}
+	}()
+
+	snapshot := *run
+	return &snapshot, nil
+}
+
+// AbortMigration cancels the migration StartMigration is running. It stops
+// before the next instance, backup or log file; everything migrated until
+// then is kept, and running the migration again resumes where it stopped.
+func (m *MigrationService) AbortMigration() (*MigrationRun, error) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	if m.run == nil || m.run.State != MigrationStateRunning {
+		return nil, ErrNoMigrationRunning
+	}
+	m.cancel()
+
+	snapshot := *m.run
+	return &snapshot, nil
+}
+
+// CurrentMigration returns the latest migration started by this process, or nil
+func (m *MigrationService) CurrentMigration() *MigrationRun {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	if m.run == nil {
+		return nil
+	}
+	snapshot := *m.run
+	return &snapshot
+}
+
+// setStage records the migration step run is in
+func (m *MigrationService) setStage(run *MigrationRun, stage string) {
+	m.updateRun(func() { run.Stage = stage })
+}
+
+// updateRun applies a change to a run under the lock CurrentMigration reads it with
+func (m *MigrationService) updateRun(update func()) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+	update()
+}
+
+// checkAborted returns ErrMigrationAborted once ctx is cancelled
+func checkAborted(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrMigrationAborted, err)
+	}
+	return nil
+}
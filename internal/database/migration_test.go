@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openTestDB connects to the database in TEST_DATABASE_URL and brings its
+// schema up to date. Tests using it are skipped when the variable is unset.
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	t.Setenv("DATABASE_URL", url)
+	t.Setenv("DB_CONNECT_ATTEMPTS", "1")
+
+	db, err := NewDB(t.TempDir())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.MigrateSchema(); err != nil {
+		t.Fatalf("migrate schema: %v", err)
+	}
+	return db
+}
+
+func TestMigrateLogsTwiceDoesNotDuplicate(t *testing.T) {
+	db := openTestDB(t)
+
+	logsDir := t.TempDir()
+	t.Setenv("LOG_DIR", logsDir)
+	component := fmt.Sprintf("TEST%d", time.Now().UnixNano())
+	logFile := filepath.Join(logsDir, "backup_2025-07-18.log")
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM logs WHERE component = $1", component)
+		if path, err := filepath.Abs(logFile); err == nil {
+			db.Exec("DELETE FROM migrated_files WHERE path = $1", path)
+		}
+	})
+
+	writeLines := func(lines ...string) {
+		t.Helper()
+		file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatalf("write log file: %v", err)
+		}
+		defer file.Close()
+		for _, line := range lines {
+			fmt.Fprintf(file, "2025/07/18 08:41:03 [2025-07-18 08:41:03] [INFO] [%s] %s\n", component, line)
+		}
+	}
+	countLogs := func() int {
+		t.Helper()
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM logs WHERE component = $1", component).Scan(&count); err != nil {
+			t.Fatalf("count logs: %v", err)
+		}
+		return count
+	}
+
+	m := NewMigrationService(db, t.TempDir())
+	migrate := func() {
+		t.Helper()
+		if err := m.migrateLogs(context.Background(), &MigrationRun{}); err != nil {
+			t.Fatalf("migrate logs: %v", err)
+		}
+	}
+
+	writeLines("first", "second")
+	migrate()
+	migrate()
+	if count := countLogs(); count != 2 {
+		t.Fatalf("got %d log rows after migrating twice, want 2", count)
+	}
+
+	// Lines appended since the last run are still imported
+	writeLines("third")
+	migrate()
+	if count := countLogs(); count != 3 {
+		t.Fatalf("got %d log rows after appending a line, want 3", count)
+	}
+}
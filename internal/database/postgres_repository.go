@@ -4,9 +4,17 @@ import (
 	"database/sql"
 	"encoding/json"
 	"evolution-postgres-backup/internal/config"
+	"log"
 	"time"
 )
 
+// postgresColumns is the column list read by scanPostgreSQL
+const postgresColumns = `id, name, host, port, username, password, databases, enabled, ssl_mode,
+			   ssl_root_cert, ssl_cert, ssl_key, retention_policy, dump_options,
+			   compression, compression_level, temp_dir, statement_timeout, lock_timeout,
+			   dump_timeout, max_backup_size_bytes, backup_types, database_backup_types, pooled, direct_host,
+			   direct_port, labels, pre_backup_sql, post_backup_webhook, created_at, updated_at`
+
 type PostgreSQLRepository struct {
 	db *DB
 }
@@ -23,10 +31,34 @@ func (r *PostgreSQLRepository) Create(instance *config.PostgreSQLConfig) error {
 		return err
 	}
 
+	retentionJSON, err := marshalRetentionPolicy(instance.RetentionPolicy)
+	if err != nil {
+		return err
+	}
+
+	dumpOptionsJSON, err := marshalDumpOptions(instance.DumpOptions)
+	if err != nil {
+		return err
+	}
+
+	backupTypesJSON, databaseBackupTypesJSON, err := marshalBackupTypes(instance)
+	if err != nil {
+		return err
+	}
+
+	labelsJSON, err := marshalLabels(instance.Labels)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		INSERT INTO postgresql_instances (
-			id, name, host, port, username, password, databases, enabled, ssl_mode, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+			id, name, host, port, username, password, databases, enabled, ssl_mode, ssl_root_cert, ssl_cert, ssl_key,
+			retention_policy, dump_options, compression, compression_level, temp_dir,
+			statement_timeout, lock_timeout, dump_timeout, max_backup_size_bytes, backup_types, database_backup_types,
+			pooled, direct_host, direct_port, labels, pre_backup_sql, post_backup_webhook, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24,
+			$25, $26, $27, $28, $29, $30, $31)`
 
 	now := time.Now()
 	_, err = r.db.Exec(
@@ -40,9 +72,32 @@ func (r *PostgreSQLRepository) Create(instance *config.PostgreSQLConfig) error {
 		string(databasesJSON),
 		instance.Enabled,
 		instance.GetSSLMode(),
+		instance.SSLRootCert,
+		instance.SSLCert,
+		instance.SSLKey,
+		retentionJSON,
+		dumpOptionsJSON,
+		instance.Compression,
+		instance.CompressionLevel,
+		instance.TempDir,
+		instance.StatementTimeout,
+		instance.LockTimeout,
+		instance.DumpTimeout,
+		instance.MaxBackupSizeBytes,
+		backupTypesJSON,
+		databaseBackupTypesJSON,
+		instance.Pooled,
+		instance.DirectHost,
+		instance.DirectPort,
+		labelsJSON,
+		instance.PreBackupSQL,
+		instance.PostBackupWebhook,
 		now,
 		now,
 	)
+	if err == nil {
+		r.notifyChanged()
+	}
 
 	return err
 }
@@ -55,6 +110,26 @@ func (r *PostgreSQLRepository) Update(instance *config.PostgreSQLConfig) error {
 		return err
 	}
 
+	retentionJSON, err := marshalRetentionPolicy(instance.RetentionPolicy)
+	if err != nil {
+		return err
+	}
+
+	dumpOptionsJSON, err := marshalDumpOptions(instance.DumpOptions)
+	if err != nil {
+		return err
+	}
+
+	backupTypesJSON, databaseBackupTypesJSON, err := marshalBackupTypes(instance)
+	if err != nil {
+		return err
+	}
+
+	labelsJSON, err := marshalLabels(instance.Labels)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE postgresql_instances SET
 			name = $1,
@@ -65,8 +140,28 @@ func (r *PostgreSQLRepository) Update(instance *config.PostgreSQLConfig) error {
 			databases = $6,
 			enabled = $7,
 			ssl_mode = $8,
-			updated_at = $9
-		WHERE id = $10`
+			ssl_root_cert = $9,
+			ssl_cert = $10,
+			ssl_key = $11,
+			retention_policy = $12,
+			dump_options = $13,
+			compression = $14,
+			compression_level = $15,
+			temp_dir = $16,
+			statement_timeout = $17,
+			lock_timeout = $18,
+			dump_timeout = $19,
+			max_backup_size_bytes = $20,
+			backup_types = $21,
+			database_backup_types = $22,
+			pooled = $23,
+			direct_host = $24,
+			direct_port = $25,
+			labels = $26,
+			pre_backup_sql = $27,
+			post_backup_webhook = $28,
+			updated_at = $29
+		WHERE id = $30`
 
 	_, err = r.db.Exec(
 		query,
@@ -78,9 +173,32 @@ func (r *PostgreSQLRepository) Update(instance *config.PostgreSQLConfig) error {
 		string(databasesJSON),
 		instance.Enabled,
 		instance.GetSSLMode(),
+		instance.SSLRootCert,
+		instance.SSLCert,
+		instance.SSLKey,
+		retentionJSON,
+		dumpOptionsJSON,
+		instance.Compression,
+		instance.CompressionLevel,
+		instance.TempDir,
+		instance.StatementTimeout,
+		instance.LockTimeout,
+		instance.DumpTimeout,
+		instance.MaxBackupSizeBytes,
+		backupTypesJSON,
+		databaseBackupTypesJSON,
+		instance.Pooled,
+		instance.DirectHost,
+		instance.DirectPort,
+		labelsJSON,
+		instance.PreBackupSQL,
+		instance.PostBackupWebhook,
 		time.Now(),
 		instance.ID,
 	)
+	if err == nil {
+		r.notifyChanged()
+	}
 
 	return err
 }
@@ -88,7 +206,7 @@ func (r *PostgreSQLRepository) Update(instance *config.PostgreSQLConfig) error {
 // GetByID retrieves a PostgreSQL instance by ID
 func (r *PostgreSQLRepository) GetByID(id string) (*config.PostgreSQLConfig, error) {
 	query := `
-		SELECT id, name, host, port, username, password, databases, enabled, ssl_mode, created_at, updated_at
+		SELECT ` + postgresColumns + `
 		FROM postgresql_instances WHERE id = $1`
 
 	row := r.db.QueryRow(query, id)
@@ -98,7 +216,7 @@ func (r *PostgreSQLRepository) GetByID(id string) (*config.PostgreSQLConfig, err
 // GetAll retrieves all PostgreSQL instances
 func (r *PostgreSQLRepository) GetAll() ([]*config.PostgreSQLConfig, error) {
 	query := `
-		SELECT id, name, host, port, username, password, databases, enabled, ssl_mode, created_at, updated_at
+		SELECT ` + postgresColumns + `
 		FROM postgresql_instances 
 		ORDER BY name`
 
@@ -123,7 +241,7 @@ func (r *PostgreSQLRepository) GetAll() ([]*config.PostgreSQLConfig, error) {
 // GetEnabled retrieves all enabled PostgreSQL instances
 func (r *PostgreSQLRepository) GetEnabled() ([]*config.PostgreSQLConfig, error) {
 	query := `
-		SELECT id, name, host, port, username, password, databases, enabled, ssl_mode, created_at, updated_at
+		SELECT ` + postgresColumns + `
 		FROM postgresql_instances 
 		WHERE enabled = true
 		ORDER BY name`
@@ -150,9 +268,40 @@ func (r *PostgreSQLRepository) GetEnabled() ([]*config.PostgreSQLConfig, error)
 func (r *PostgreSQLRepository) Delete(id string) error {
 	query := `DELETE FROM postgresql_instances WHERE id = $1`
 	_, err := r.db.Exec(query, id)
+	if err == nil {
+		r.notifyChanged()
+	}
 	return err
 }
 
+// SetEnabled flips only the enabled flag of an instance, returning sql.ErrNoRows
+// if it doesn't exist
+func (r *PostgreSQLRepository) SetEnabled(id string, enabled bool) error {
+	query := `UPDATE postgresql_instances SET enabled = $1, updated_at = $2 WHERE id = $3`
+	result, err := r.db.Exec(query, enabled, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	r.notifyChanged()
+	return nil
+}
+
+// notifyChanged tells listeners in any process that instances were modified.
+// Listeners fall back to a cache TTL, so a failed notification is only logged.
+func (r *PostgreSQLRepository) notifyChanged() {
+	if _, err := r.db.Exec("SELECT pg_notify($1, '')", InstancesChangedChannel); err != nil {
+		log.Printf("⚠️ Failed to notify %s: %v", InstancesChangedChannel, err)
+	}
+}
+
 // Exists checks if a PostgreSQL instance exists
 func (r *PostgreSQLRepository) Exists(id string) (bool, error) {
 	query := "SELECT 1 FROM postgresql_instances WHERE id = $1 LIMIT 1"
@@ -197,6 +346,9 @@ func (r *PostgreSQLRepository) scanPostgreSQL(scanner interface {
 }) (*config.PostgreSQLConfig, error) {
 	var instance config.PostgreSQLConfig
 	var databasesJSON string
+	var retentionJSON sql.NullString
+	var dumpOptionsJSON string
+	var backupTypesJSON, databaseBackupTypesJSON, labelsJSON string
 	var createdAt, updatedAt time.Time
 
 	err := scanner.Scan(
@@ -209,6 +361,26 @@ func (r *PostgreSQLRepository) scanPostgreSQL(scanner interface {
 		&databasesJSON,
 		&instance.Enabled,
 		&instance.SSLMode,
+		&instance.SSLRootCert,
+		&instance.SSLCert,
+		&instance.SSLKey,
+		&retentionJSON,
+		&dumpOptionsJSON,
+		&instance.Compression,
+		&instance.CompressionLevel,
+		&instance.TempDir,
+		&instance.StatementTimeout,
+		&instance.LockTimeout,
+		&instance.DumpTimeout,
+		&instance.MaxBackupSizeBytes,
+		&backupTypesJSON,
+		&databaseBackupTypesJSON,
+		&instance.Pooled,
+		&instance.DirectHost,
+		&instance.DirectPort,
+		&labelsJSON,
+		&instance.PreBackupSQL,
+		&instance.PostBackupWebhook,
 		&createdAt,
 		&updatedAt,
 	)
@@ -225,6 +397,44 @@ func (r *PostgreSQLRepository) scanPostgreSQL(scanner interface {
 		}
 	}
 
+	// Parse retention policy override
+	if retentionJSON.Valid && retentionJSON.String != "" {
+		var policy config.RetentionPolicy
+		if err := json.Unmarshal([]byte(retentionJSON.String), &policy); err == nil {
+			instance.RetentionPolicy = &policy
+		}
+	}
+
+	// Parse dump options
+	if dumpOptionsJSON != "" {
+		var options []string
+		if err := json.Unmarshal([]byte(dumpOptionsJSON), &options); err == nil && len(options) > 0 {
+			instance.DumpOptions = options
+		}
+	}
+
+	// Parse scheduled backup types
+	if backupTypesJSON != "" {
+		var types []string
+		if err := json.Unmarshal([]byte(backupTypesJSON), &types); err == nil && len(types) > 0 {
+			instance.BackupTypes = types
+		}
+	}
+	if databaseBackupTypesJSON != "" {
+		var overrides map[string][]string
+		if err := json.Unmarshal([]byte(databaseBackupTypesJSON), &overrides); err == nil && len(overrides) > 0 {
+			instance.DatabaseBackupTypes = overrides
+		}
+	}
+
+	// Parse labels
+	if labelsJSON != "" {
+		var labels map[string]string
+		if err := json.Unmarshal([]byte(labelsJSON), &labels); err == nil && len(labels) > 0 {
+			instance.Labels = labels
+		}
+	}
+
 	// Set default if no databases
 	if len(instance.Databases) == 0 {
 		instance.Databases = []string{"postgres"}
@@ -232,3 +442,62 @@ func (r *PostgreSQLRepository) scanPostgreSQL(scanner interface {
 
 	return &instance, nil
 }
+
+// marshalRetentionPolicy converts a retention override to JSON, or NULL when unset
+func marshalRetentionPolicy(policy *config.RetentionPolicy) (interface{}, error) {
+	if policy == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// marshalDumpOptions converts dump options to a JSON array
+func marshalDumpOptions(options []string) (string, error) {
+	if options == nil {
+		options = []string{}
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// marshalBackupTypes converts the scheduled backup types of an instance to a
+// JSON array and a JSON object of per-database overrides
+func marshalBackupTypes(instance *config.PostgreSQLConfig) (string, string, error) {
+	types := instance.BackupTypes
+	if types == nil {
+		types = []string{}
+	}
+	overrides := instance.DatabaseBackupTypes
+	if overrides == nil {
+		overrides = map[string][]string{}
+	}
+
+	typesJSON, err := json.Marshal(types)
+	if err != nil {
+		return "", "", err
+	}
+	overridesJSON, err := json.Marshal(overrides)
+	if err != nil {
+		return "", "", err
+	}
+	return string(typesJSON), string(overridesJSON), nil
+}
+
+// marshalLabels converts instance labels to a JSON object
+func marshalLabels(labels map[string]string) (string, error) {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Restore statuses
+const (
+	RestoreStatusInProgress = "in_progress"
+	RestoreStatusCompleted  = "completed"
+	RestoreStatusFailed     = "failed"
+)
+
+// RestoreRecord is the audit entry of one restore. It has no foreign keys so
+// it outlives the backup and instances it refers to.
+type RestoreRecord struct {
+	ID                 string     `json:"id" db:"id"`
+	JobID              string     `json:"job_id,omitempty" db:"job_id"`
+	BackupID           string     `json:"backup_id" db:"backup_id"`
+	SourcePostgreSQLID string     `json:"source_postgresql_id,omitempty" db:"source_postgresql_id"`
+	TargetPostgreSQLID string     `json:"target_postgresql_id" db:"target_postgresql_id"`
+	TargetDatabase     string     `json:"target_database" db:"target_database"`
+	RequestedBy        string     `json:"requested_by,omitempty" db:"requested_by"` // API key name
+	Status             string     `json:"status" db:"status"`
+	ErrorMessage       string     `json:"error_message,omitempty" db:"error_message"`
+	StartTime          time.Time  `json:"start_time" db:"start_time"`
+	EndTime            *time.Time `json:"end_time,omitempty" db:"end_time"`
+}
+
+// RestoreFilters narrows GetFiltered; zero values don't filter
+type RestoreFilters struct {
+	BackupID   string
+	PostgresID string // Matches the source or the target instance
+	Status     string
+	Limit      int
+}
+
+type RestoreRepository struct {
+	db *DB
+}
+
+func NewRestoreRepository(db *DB) *RestoreRepository {
+	return &RestoreRepository{db: db}
+}
+
+// Create inserts a restore record
+func (r *RestoreRepository) Create(restore *RestoreRecord) error {
+	query := `
+		INSERT INTO restores (
+			id, job_id, backup_id, source_postgresql_id, target_postgresql_id,
+			target_database, requested_by, status, start_time
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := r.db.Exec(
+		query,
+		restore.ID,
+		nullString(restore.JobID),
+		restore.BackupID,
+		restore.SourcePostgreSQLID,
+		restore.TargetPostgreSQLID,
+		restore.TargetDatabase,
+		restore.RequestedBy,
+		restore.Status,
+		restore.StartTime,
+	)
+	return err
+}
+
+// Finish records the outcome of a restore
+func (r *RestoreRepository) Finish(restore *RestoreRecord) error {
+	query := `UPDATE restores SET status = $1, error_message = $2, end_time = $3 WHERE id = $4`
+	_, err := r.db.Exec(query, restore.Status, nullString(restore.ErrorMessage), restore.EndTime, restore.ID)
+	return err
+}
+
+// GetFiltered returns restores matching filters, newest first
+func (r *RestoreRepository) GetFiltered(filters RestoreFilters) ([]*RestoreRecord, error) {
+	query := `
+		SELECT id, job_id, backup_id, source_postgresql_id, target_postgresql_id,
+		       target_database, requested_by, status, error_message, start_time, end_time
+		FROM restores`
+
+	var whereClauses []string
+	var args []interface{}
+
+	if filters.BackupID != "" {
+		args = append(args, filters.BackupID)
+		whereClauses = append(whereClauses, fmt.Sprintf("backup_id = $%d", len(args)))
+	}
+	if filters.PostgresID != "" {
+		args = append(args, filters.PostgresID)
+		whereClauses = append(whereClauses, fmt.Sprintf("(source_postgresql_id = $%d OR target_postgresql_id = $%d)", len(args), len(args)))
+	}
+	if filters.Status != "" {
+		args = append(args, filters.Status)
+		whereClauses = append(whereClauses, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	if len(whereClauses) > 0 {
+		query += " WHERE " + strings.Join(whereClauses, " AND ")
+	}
+	query += " ORDER BY start_time DESC"
+	if filters.Limit > 0 {
+		args = append(args, filters.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	restores := []*RestoreRecord{}
+	for rows.Next() {
+		restore := &RestoreRecord{}
+		var jobID, errorMessage sql.NullString
+		var endTime sql.NullTime
+		if err := rows.Scan(
+			&restore.ID,
+			&jobID,
+			&restore.BackupID,
+			&restore.SourcePostgreSQLID,
+			&restore.TargetPostgreSQLID,
+			&restore.TargetDatabase,
+			&restore.RequestedBy,
+			&restore.Status,
+			&errorMessage,
+			&restore.StartTime,
+			&endTime,
+		); err != nil {
+			return nil, err
+		}
+		restore.JobID = jobID.String
+		restore.ErrorMessage = errorMessage.String
+		if endTime.Valid {
+			restore.EndTime = &endTime.Time
+		}
+		restores = append(restores, restore)
+	}
+
+	return restores, rows.Err()
+}
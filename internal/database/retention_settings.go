@@ -0,0 +1,57 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"evolution-postgres-backup/internal/config"
+	"fmt"
+	"log"
+)
+
+// retentionPolicyKey is the config table row holding the global retention
+// policy, migrated from config.json's retention_policy
+const retentionPolicyKey = "retention_policy"
+
+// GlobalRetentionPolicy returns the configured global retention policy merged
+// over config.DefaultRetentionPolicy, or the default when none is stored.
+// Instance overrides are merged over this policy.
+func (db *DB) GlobalRetentionPolicy() config.RetentionPolicy {
+	var value string
+	err := db.QueryRow("SELECT value FROM config WHERE key = $1", retentionPolicyKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return config.DefaultRetentionPolicy
+	}
+	if err != nil {
+		log.Printf("⚠️ Failed to read the global retention policy, using the default: %v", err)
+		return config.DefaultRetentionPolicy
+	}
+
+	var policy config.RetentionPolicy
+	if err := json.Unmarshal([]byte(value), &policy); err != nil {
+		log.Printf("⚠️ Invalid global retention policy %q, using the default: %v", value, err)
+		return config.DefaultRetentionPolicy
+	}
+	return policy.Merge(config.DefaultRetentionPolicy)
+}
+
+// SetGlobalRetentionPolicy stores the global retention policy. overwrite
+// false keeps a policy that is already stored.
+func (db *DB) SetGlobalRetentionPolicy(policy config.RetentionPolicy, overwrite bool) error {
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("invalid retention policy: %w", err)
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	conflict := "DO NOTHING"
+	if overwrite {
+		conflict = "DO UPDATE SET value = EXCLUDED.value, updated_at = NOW()"
+	}
+	_, err = db.Exec(`
+		INSERT INTO config (key, value, description, updated_at)
+		VALUES ($1, $2, 'Global backup retention policy; instance overrides are merged over it', NOW())
+		ON CONFLICT (key) `+conflict, retentionPolicyKey, string(data))
+	return err
+}
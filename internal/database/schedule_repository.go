@@ -0,0 +1,177 @@
+package database
+
+import (
+	"database/sql"
+	"evolution-postgres-backup/internal/models"
+	"time"
+)
+
+// Schedule is a row of the schedules table. One-off schedules fire a single
+// backup at RunAt; the scheduler disables them and records LastRun when it does.
+type Schedule struct {
+	ID             string            `json:"id" db:"id"`
+	PostgreSQLID   string            `json:"postgresql_id" db:"postgresql_id"`
+	DatabaseName   string            `json:"database_name" db:"database_name"`
+	BackupType     models.BackupType `json:"backup_type" db:"backup_type"`
+	Enabled        bool              `json:"enabled" db:"enabled"` // Pending, for one-off schedules
+	CronExpression string            `json:"cron_expression,omitempty" db:"cron_expression"`
+	OneOff         bool              `json:"one_off" db:"one_off"`
+	RunAt          *time.Time        `json:"run_at,omitempty" db:"run_at"`
+	LastRun        *time.Time        `json:"last_run,omitempty" db:"last_run"`
+	BackupID       string            `json:"backup_id,omitempty" db:"backup_id"`   // Backup a one-off schedule created
+	CreatedBy      string            `json:"created_by,omitempty" db:"created_by"` // API key name
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+}
+
+// scheduleColumns is the column list read by scanSchedule
+const scheduleColumns = `id, postgresql_id, database_name, backup_type, enabled, cron_expression,
+			   one_off, run_at, last_run, backup_id, created_by, created_at`
+
+type ScheduleRepository struct {
+	db *DB
+}
+
+func NewScheduleRepository(db *DB) *ScheduleRepository {
+	return &ScheduleRepository{db: db}
+}
+
+// CreateOneOff inserts a pending one-off schedule
+func (r *ScheduleRepository) CreateOneOff(schedule *Schedule) error {
+	schedule.OneOff = true
+	schedule.Enabled = true
+	schedule.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(`
+		INSERT INTO schedules (
+			id, postgresql_id, database_name, backup_type, enabled, cron_expression,
+			one_off, run_at, created_by, created_at
+		) VALUES ($1, $2, $3, $4, true, '', true, $5, $6, $7)`,
+		schedule.ID,
+		schedule.PostgreSQLID,
+		schedule.DatabaseName,
+		schedule.BackupType,
+		schedule.RunAt,
+		schedule.CreatedBy,
+		schedule.CreatedAt,
+	)
+	return err
+}
+
+// GetByID retrieves a schedule by ID
+func (r *ScheduleRepository) GetByID(id string) (*Schedule, error) {
+	row := r.db.QueryRow("SELECT "+scheduleColumns+" FROM schedules WHERE id = $1", id)
+	return r.scanSchedule(row)
+}
+
+// GetOneOff returns one-off schedules, the next to fire first and fired ones last.
+// pendingOnly leaves out schedules that already fired. The slice is empty,
+// not nil, when there are none.
+func (r *ScheduleRepository) GetOneOff(pendingOnly bool) ([]*Schedule, error) {
+	query := "SELECT " + scheduleColumns + " FROM schedules WHERE one_off"
+	if pendingOnly {
+		query += " AND enabled"
+	}
+	query += " ORDER BY enabled DESC, run_at"
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []*Schedule{}
+	for rows.Next() {
+		schedule, err := r.scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+// DeletePendingOneOff cancels a one-off schedule that hasn't fired. It
+// returns sql.ErrNoRows when there is no such schedule.
+func (r *ScheduleRepository) DeletePendingOneOff(id string) error {
+	result, err := r.db.Exec("DELETE FROM schedules WHERE id = $1 AND one_off AND enabled", id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetDueOneOff returns the pending one-off schedules due at now
+func (r *ScheduleRepository) GetDueOneOff(now time.Time) ([]*Schedule, error) {
+	rows, err := r.db.Query("SELECT "+scheduleColumns+" FROM schedules WHERE one_off AND enabled AND run_at <= $1 ORDER BY run_at", now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []*Schedule
+	for rows.Next() {
+		schedule, err := r.scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+// ClaimOneOff marks a pending one-off schedule as fired at now, recording the
+// backup it created, if any. Run it in the transaction that saves the backup
+// so both commit together; the update locks the row, so a schedule is claimed
+// by one scheduler only. It returns sql.ErrNoRows when the schedule is no
+// longer pending.
+func (r *ScheduleRepository) ClaimOneOff(db interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}, id, backupID string, now time.Time) error {
+	result, err := db.Exec(`
+		UPDATE schedules SET enabled = false, last_run = $1, backup_id = $2
+		WHERE id = $3 AND one_off AND enabled`, now, backupID, id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// scanSchedule scans a row into a Schedule
+func (r *ScheduleRepository) scanSchedule(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*Schedule, error) {
+	var schedule Schedule
+	var runAt, lastRun sql.NullTime
+
+	err := scanner.Scan(
+		&schedule.ID,
+		&schedule.PostgreSQLID,
+		&schedule.DatabaseName,
+		&schedule.BackupType,
+		&schedule.Enabled,
+		&schedule.CronExpression,
+		&schedule.OneOff,
+		&runAt,
+		&lastRun,
+		&schedule.BackupID,
+		&schedule.CreatedBy,
+		&schedule.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if runAt.Valid {
+		schedule.RunAt = &runAt.Time
+	}
+	if lastRun.Valid {
+		schedule.LastRun = &lastRun.Time
+	}
+	return &schedule, nil
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+//go:embed schema_postgres.sql migrate_add_*.sql
+var migrationFiles embed.FS
+
+// schemaLockKey is the pg_advisory_lock key held while schema migrations run,
+// so the API and worker starting together don't apply them twice
+const schemaLockKey = 7_346_202
+
+// schemaMigration is one versioned step of the service schema
+type schemaMigration struct {
+	Version int
+	File    string
+}
+
+// schemaMigrations are applied in order. Version 1 is the full schema, which
+// must always include every later migration: fresh databases get it alone and
+// have the rest recorded as applied. Append new migrate_add_*.sql files here.
+var schemaMigrations = []schemaMigration{
+	{1, "schema_postgres.sql"},
+	{2, "migrate_add_databases.sql"},
+	{3, "migrate_add_enabled.sql"},
+	{4, "migrate_add_logs_columns.sql"},
+	{5, "migrate_add_jobs_table.sql"},
+	{6, "migrate_add_ssl_certs.sql"},
+	{7, "migrate_add_idempotency_key.sql"},
+	{8, "migrate_add_retention_policy.sql"},
+	{9, "migrate_add_dump_options.sql"},
+	{10, "migrate_add_checksum.sql"},
+	{11, "migrate_add_temp_dir.sql"},
+	{12, "migrate_add_backup_tags.sql"},
+	{13, "migrate_add_backup_protected.sql"},
+	{14, "migrate_add_worker_stats.sql"},
+	{15, "migrate_add_timeouts.sql"},
+	{16, "migrate_add_basebackup_job_type.sql"},
+	{17, "migrate_add_max_backup_size.sql"},
+	{18, "migrate_add_storage_class.sql"},
+	{19, "migrate_add_migrated_files.sql"},
+	{20, "migrate_add_created_by.sql"},
+	{21, "migrate_add_transfer_stats.sql"},
+	{22, "migrate_add_restores.sql"},
+	{23, "migrate_add_query_indexes.sql"},
+	{24, "migrate_add_schema_diff_job_type.sql"},
+	{25, "migrate_add_backup_types.sql"},
+	{26, "migrate_add_pooling.sql"},
+	{27, "migrate_add_failure_category.sql"},
+	{28, "migrate_add_instance_labels.sql"},
+	{29, "migrate_add_backup_trash.sql"},
+	{30, "migrate_add_backup_format.sql"},
+	{31, "migrate_add_blobs_excluded.sql"},
+	{32, "migrate_add_failure_output.sql"},
+	{33, "migrate_add_backup_hooks.sql"},
+	{34, "migrate_add_one_off_schedules.sql"},
+	{35, "migrate_add_idempotency_scope.sql"},
+	{36, "migrate_add_dump_timeout.sql"},
+	{37, "migrate_add_restore_slot.sql"},
+	{38, "migrate_add_cancelled_job_status.sql"},
+}
+
+// schemaAutoMigrate reports whether NewDB applies schema migrations,
+// configurable via SCHEMA_AUTO_MIGRATE (default true)
+func schemaAutoMigrate() bool {
+	if enabled, err := strconv.ParseBool(os.Getenv("SCHEMA_AUTO_MIGRATE")); err == nil {
+		return enabled
+	}
+	return true
+}
+
+// MigrateSchema brings the service schema up to date, recording applied
+// versions in schema_migrations. Databases created before versioning (tables
+// exist but nothing is recorded) get every migrate_add_*.sql file, which are
+// all idempotent.
+func (db *DB) MigrateSchema() error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection for schema lock: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", schemaLockKey); err != nil {
+		return fmt.Errorf("failed to acquire schema lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", schemaLockKey)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			file TEXT NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied, err := appliedSchemaVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		var existing sql.NullString
+		if err := conn.QueryRowContext(ctx, "SELECT to_regclass('backups')::text").Scan(&existing); err != nil {
+			return err
+		}
+		if !existing.Valid {
+			// Fresh database: the full schema covers every migration
+			if err := applySchemaMigration(ctx, conn, schemaMigrations[0], schemaMigrations...); err != nil {
+				return err
+			}
+			log.Printf("✅ Created service schema (version %d)", schemaMigrations[len(schemaMigrations)-1].Version)
+			return nil
+		}
+		// Created before versioning; its tables come from an older schema
+		if err := recordSchemaVersions(ctx, conn, schemaMigrations[0]); err != nil {
+			return err
+		}
+		applied[schemaMigrations[0].Version] = true
+	}
+
+	for _, migration := range schemaMigrations {
+		if applied[migration.Version] {
+			continue
+		}
+		if err := applySchemaMigration(ctx, conn, migration, migration); err != nil {
+			return err
+		}
+		log.Printf("✅ Applied schema migration %d (%s)", migration.Version, migration.File)
+	}
+	return nil
+}
+
+// appliedSchemaVersions returns the versions recorded in schema_migrations
+func appliedSchemaVersions(ctx context.Context, conn *sql.Conn) (map[int]bool, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// applySchemaMigration runs a migration file and records the given versions
+// in one transaction
+func applySchemaMigration(ctx context.Context, conn *sql.Conn, migration schemaMigration, record ...schemaMigration) error {
+	script, err := migrationFiles.ReadFile(migration.File)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+		return fmt.Errorf("schema migration %d (%s) failed: %w", migration.Version, migration.File, err)
+	}
+	if err := recordSchemaVersions(ctx, tx, record...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// recordSchemaVersions marks migrations as applied
+func recordSchemaVersions(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}, migrations ...schemaMigration) error {
+	for _, migration := range migrations {
+		_, err := db.ExecContext(ctx,
+			"INSERT INTO schema_migrations (version, file) VALUES ($1, $2) ON CONFLICT (version) DO NOTHING",
+			migration.Version, migration.File)
+		if err != nil {
+			return fmt.Errorf("failed to record schema migration %d: %w", migration.Version, err)
+		}
+	}
+	return nil
+}
@@ -14,7 +14,27 @@ CREATE TABLE IF NOT EXISTS postgresql_instances (
     password TEXT NOT NULL,
     databases JSONB DEFAULT '["postgres"]'::jsonb, -- Array of database names
     enabled BOOLEAN NOT NULL DEFAULT true, -- Whether instance is enabled for backups
-    ssl_mode TEXT NOT NULL DEFAULT 'prefer' CHECK(ssl_mode IN ('disable', 'allow', 'prefer', 'require')),
+    ssl_mode TEXT NOT NULL DEFAULT 'prefer' CHECK(ssl_mode IN ('disable', 'allow', 'prefer', 'require', 'verify-ca', 'verify-full')),
+    ssl_root_cert TEXT NOT NULL DEFAULT '', -- Root CA: file path or PEM content
+    ssl_cert TEXT NOT NULL DEFAULT '', -- Client certificate: file path or PEM content
+    ssl_key TEXT NOT NULL DEFAULT '', -- Client key: file path or PEM content
+    retention_policy JSONB, -- Per-instance retention override, NULL uses the global policy
+    dump_options JSONB NOT NULL DEFAULT '[]'::jsonb, -- Extra allowlisted pg_dump flags
+    compression TEXT NOT NULL DEFAULT '' CHECK(compression IN ('', 'gzip')),
+    compression_level INTEGER NOT NULL DEFAULT 0 CHECK(compression_level BETWEEN 0 AND 9), -- 0 = default level
+    temp_dir TEXT NOT NULL DEFAULT '', -- Overrides BACKUP_TEMP_DIR for this instance
+    statement_timeout TEXT NOT NULL DEFAULT '', -- Backup session timeouts, e.g. '30min'; empty = none
+    lock_timeout TEXT NOT NULL DEFAULT '',
+    dump_timeout TEXT NOT NULL DEFAULT '', -- Wall-clock limit of a whole pg_dump run, e.g. '2h'; empty = none
+    max_backup_size_bytes BIGINT NOT NULL DEFAULT 0 CHECK(max_backup_size_bytes >= 0), -- 0 = no limit
+    backup_types JSONB NOT NULL DEFAULT '[]'::jsonb, -- Scheduled backup types to run; empty = all
+    database_backup_types JSONB NOT NULL DEFAULT '{}'::jsonb, -- Per-database overrides of backup_types
+    pooled BOOLEAN NOT NULL DEFAULT false, -- host/port is a connection pooler; backups use direct_host/direct_port
+    direct_host TEXT NOT NULL DEFAULT '',
+    direct_port INTEGER NOT NULL DEFAULT 0 CHECK(direct_port >= 0 AND direct_port <= 65535), -- 0 = port
+    labels JSONB NOT NULL DEFAULT '{}'::jsonb, -- Grouping labels, e.g. {"team": "payments"}
+    pre_backup_sql TEXT NOT NULL DEFAULT '', -- Run against each database before it is dumped; needs ALLOW_PRE_BACKUP_SQL
+    post_backup_webhook TEXT NOT NULL DEFAULT '', -- Posted to after each successful backup
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
@@ -33,6 +53,21 @@ CREATE TABLE IF NOT EXISTS backups (
     s3_key TEXT,
     error_message TEXT,
     job_id TEXT,
+    idempotency_key TEXT, -- Idempotency-Key header of the request that created the backup, unique per created_by
+    checksum TEXT, -- SHA-256 of the dump content, excluding pg_dump timestamp comments
+    tags JSONB NOT NULL DEFAULT '[]'::jsonb, -- Free-form labels, e.g. ["pre-deploy"]
+    protected BOOLEAN NOT NULL DEFAULT false, -- Never removed by retention cleanup
+    storage_class TEXT NOT NULL DEFAULT '', -- S3 storage class of s3_key, '' = STANDARD
+    created_by TEXT NOT NULL DEFAULT '', -- API key name or 'scheduler' that requested the backup
+    uncompressed_size BIGINT NOT NULL DEFAULT 0, -- Dump size before compression; file_size is the compressed size
+    compression_ratio DOUBLE PRECISION NOT NULL DEFAULT 0, -- uncompressed_size / file_size
+    throughput_mbps DOUBLE PRECISION NOT NULL DEFAULT 0, -- Uncompressed MB per second of the run
+    failure_category TEXT NOT NULL DEFAULT '', -- Cause of a failed backup, e.g. 'auth_error'; '' otherwise
+    trashed_at TIMESTAMP WITH TIME ZONE, -- Set by DELETE; hidden from listings until restored or purged
+    purge_at TIMESTAMP WITH TIME ZONE, -- When a trashed backup is deleted for good
+    format TEXT NOT NULL DEFAULT '', -- Dump format: 'plain', 'plain_gzip' or 'custom'; '' = unknown, detected from the file
+    blobs_excluded BOOLEAN NOT NULL DEFAULT false, -- Dumped with --no-blobs: large objects are missing
+    failure_output TEXT NOT NULL DEFAULT '', -- Captured pg_dump stderr of a failed dump; not loaded with the backup
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (postgresql_id) REFERENCES postgresql_instances(id) ON DELETE CASCADE
 );
@@ -55,12 +90,16 @@ CREATE TABLE IF NOT EXISTS schedules (
     id TEXT PRIMARY KEY,
     postgresql_id TEXT NOT NULL,
     database_name TEXT NOT NULL,
-    backup_type TEXT NOT NULL CHECK(backup_type IN ('hourly', 'daily', 'weekly', 'monthly')),
+    backup_type TEXT NOT NULL CHECK(backup_type IN ('hourly', 'daily', 'weekly', 'monthly', 'manual')),
     enabled BOOLEAN NOT NULL DEFAULT true,
-    cron_expression TEXT NOT NULL,
+    cron_expression TEXT NOT NULL, -- Empty for one-off schedules
     retention_days INTEGER NOT NULL DEFAULT 30,
     last_run TIMESTAMP WITH TIME ZONE,
     next_run TIMESTAMP WITH TIME ZONE,
+    one_off BOOLEAN NOT NULL DEFAULT false, -- Fires once at run_at, then is disabled
+    run_at TIMESTAMP WITH TIME ZONE,
+    backup_id TEXT NOT NULL DEFAULT '', -- Backup a one-off schedule created
+    created_by TEXT NOT NULL DEFAULT '', -- API key name
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (postgresql_id) REFERENCES postgresql_instances(id) ON DELETE CASCADE
 );
@@ -68,7 +107,7 @@ CREATE TABLE IF NOT EXISTS schedules (
 -- Jobs table (for API-Worker communication)
 CREATE TABLE IF NOT EXISTS jobs (
     id TEXT PRIMARY KEY,
-    type TEXT NOT NULL CHECK(type IN ('backup', 'restore', 'cleanup')),
+    type TEXT NOT NULL CHECK(type IN ('backup', 'restore', 'cleanup', 'basebackup', 'schema_diff')),
     postgres_id TEXT NOT NULL,
     database_name TEXT NOT NULL,
     backup_id TEXT,
@@ -80,7 +119,40 @@ CREATE TABLE IF NOT EXISTS jobs (
     error_message TEXT,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     started_at TIMESTAMP WITH TIME ZONE,
-    completed_at TIMESTAMP WITH TIME ZONE
+    completed_at TIMESTAMP WITH TIME ZONE,
+    restore_slot TEXT CHECK(restore_slot IN ('waiting', 'held')) -- MAX_CONCURRENT_RESTORES slot of a running restore
+);
+
+-- Restore audit trail; no foreign keys so records outlive backups and instances
+CREATE TABLE IF NOT EXISTS restores (
+    id TEXT PRIMARY KEY,
+    job_id TEXT,
+    backup_id TEXT NOT NULL,
+    source_postgresql_id TEXT NOT NULL DEFAULT '', -- Instance the backup was taken from
+    target_postgresql_id TEXT NOT NULL,
+    target_database TEXT NOT NULL,
+    requested_by TEXT NOT NULL DEFAULT '', -- API key name
+    status TEXT NOT NULL CHECK(status IN ('in_progress', 'completed', 'failed')),
+    error_message TEXT,
+    start_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    end_time TIMESTAMP WITH TIME ZONE
+);
+
+-- Log files imported by the JSON migration, with the number of lines read
+CREATE TABLE IF NOT EXISTS migrated_files (
+    path TEXT PRIMARY KEY,
+    lines INTEGER NOT NULL DEFAULT 0,
+    migrated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Lifetime job counters per worker, kept across restarts
+CREATE TABLE IF NOT EXISTS worker_stats (
+    worker_id TEXT PRIMARY KEY,
+    jobs_handled BIGINT NOT NULL DEFAULT 0,
+    jobs_failed BIGINT NOT NULL DEFAULT 0,
+    bytes_processed BIGINT NOT NULL DEFAULT 0, -- Size of the backup files written
+    last_job_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
 -- Configuration table
@@ -98,6 +170,11 @@ CREATE INDEX IF NOT EXISTS idx_backups_status ON backups(status);
 CREATE INDEX IF NOT EXISTS idx_backups_type ON backups(backup_type);
 CREATE INDEX IF NOT EXISTS idx_backups_database ON backups(database_name);
 CREATE INDEX IF NOT EXISTS idx_backups_job_id ON backups(job_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_backups_idempotency_scope ON backups(created_by, idempotency_key) WHERE idempotency_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_backups_checksum ON backups(checksum) WHERE checksum IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_backups_tags ON backups USING GIN (tags);
+CREATE INDEX IF NOT EXISTS idx_backups_instance_type_created ON backups(postgresql_id, backup_type, created_at DESC); -- Cleanup and per-instance listing
+CREATE INDEX IF NOT EXISTS idx_backups_purge_at ON backups(purge_at) WHERE trashed_at IS NOT NULL;
 
 CREATE INDEX IF NOT EXISTS idx_logs_timestamp ON logs(timestamp DESC);
 CREATE INDEX IF NOT EXISTS idx_logs_job_id ON logs(job_id);
@@ -108,11 +185,16 @@ CREATE INDEX IF NOT EXISTS idx_logs_component ON logs(component);
 CREATE INDEX IF NOT EXISTS idx_schedules_postgresql_id ON schedules(postgresql_id);
 CREATE INDEX IF NOT EXISTS idx_schedules_enabled ON schedules(enabled);
 CREATE INDEX IF NOT EXISTS idx_schedules_next_run ON schedules(next_run);
+CREATE INDEX IF NOT EXISTS idx_schedules_one_off_run_at ON schedules(run_at) WHERE one_off AND enabled; -- Due one-off schedules
 
 CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
 CREATE INDEX IF NOT EXISTS idx_jobs_type ON jobs(type);
 CREATE INDEX IF NOT EXISTS idx_jobs_created_at ON jobs(created_at DESC);
 CREATE INDEX IF NOT EXISTS idx_jobs_priority ON jobs(priority DESC);
+CREATE INDEX IF NOT EXISTS idx_jobs_status_priority_created ON jobs(status, priority DESC, created_at); -- Worker job loader
+
+CREATE INDEX IF NOT EXISTS idx_restores_start_time ON restores(start_time DESC);
+CREATE INDEX IF NOT EXISTS idx_restores_backup_id ON restores(backup_id);
 
 -- Insert default configuration
 INSERT INTO config (key, value, description) VALUES 
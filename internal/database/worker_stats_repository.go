@@ -0,0 +1,72 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// WorkerStats holds the lifetime counters of a worker, kept across restarts
+type WorkerStats struct {
+	WorkerID       string     `json:"worker_id" db:"worker_id"`
+	JobsHandled    int64      `json:"jobs_handled" db:"jobs_handled"`
+	JobsFailed     int64      `json:"jobs_failed" db:"jobs_failed"`
+	BytesProcessed int64      `json:"bytes_processed" db:"bytes_processed"`
+	LastJobAt      *time.Time `json:"last_job_at,omitempty" db:"last_job_at"`
+}
+
+type WorkerStatsRepository struct {
+	db *DB
+}
+
+func NewWorkerStatsRepository(db *DB) *WorkerStatsRepository {
+	return &WorkerStatsRepository{db: db}
+}
+
+// RecordJob adds a finished job to the lifetime counters of a worker
+func (r *WorkerStatsRepository) RecordJob(workerID string, failed bool, bytes int64, finishedAt time.Time) error {
+	failedCount := 0
+	if failed {
+		failedCount = 1
+	}
+
+	query := `
+		INSERT INTO worker_stats (worker_id, jobs_handled, jobs_failed, bytes_processed, last_job_at, updated_at)
+		VALUES ($1, 1, $2, $3, $4, $4)
+		ON CONFLICT (worker_id) DO UPDATE SET
+			jobs_handled = worker_stats.jobs_handled + 1,
+			jobs_failed = worker_stats.jobs_failed + EXCLUDED.jobs_failed,
+			bytes_processed = worker_stats.bytes_processed + EXCLUDED.bytes_processed,
+			last_job_at = EXCLUDED.last_job_at,
+			updated_at = EXCLUDED.updated_at`
+
+	_, err := r.db.Exec(query, workerID, failedCount, bytes, finishedAt)
+	return err
+}
+
+// GetAll returns the lifetime counters of every worker that has finished a job, by worker ID
+func (r *WorkerStatsRepository) GetAll() (map[string]*WorkerStats, error) {
+	query := `
+		SELECT worker_id, jobs_handled, jobs_failed, bytes_processed, last_job_at
+		FROM worker_stats`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[string]*WorkerStats)
+	for rows.Next() {
+		entry := &WorkerStats{}
+		var lastJobAt sql.NullTime
+		if err := rows.Scan(&entry.WorkerID, &entry.JobsHandled, &entry.JobsFailed, &entry.BytesProcessed, &lastJobAt); err != nil {
+			return nil, err
+		}
+		if lastJobAt.Valid {
+			entry.LastJobAt = &lastJobAt.Time
+		}
+		stats[entry.WorkerID] = entry
+	}
+
+	return stats, rows.Err()
+}
@@ -1,10 +1,13 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,6 +20,69 @@ const (
 	LogLevelDebug LogLevel = "DEBUG"
 )
 
+// Output formats, selected with the LOG_FORMAT environment variable
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Entry is a single structured log line
+type Entry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Level     LogLevel  `json:"level"`
+	Component string    `json:"component"`
+	JobID     string    `json:"job_id,omitempty"`
+	BackupID  string    `json:"backup_id,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Message   string    `json:"message"`
+}
+
+var (
+	formatOnce sync.Once
+	format     string
+	stdoutMu   sync.Mutex
+)
+
+// Format returns the configured output format (LOG_FORMAT, default text)
+func Format() string {
+	formatOnce.Do(func() {
+		format = FormatText
+		if strings.EqualFold(os.Getenv("LOG_FORMAT"), FormatJSON) {
+			format = FormatJSON
+		}
+	})
+	return format
+}
+
+// Emit writes an entry to stdout, as a JSON object per line in JSON mode or
+// as a "[COMPONENT] message" line otherwise
+func Emit(entry Entry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	if Format() == FormatJSON {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			log.Printf("[LOGGER] failed to encode log entry: %v", err)
+			return
+		}
+		stdoutMu.Lock()
+		os.Stdout.Write(append(data, '\n'))
+		stdoutMu.Unlock()
+		return
+	}
+
+	prefix := "[" + entry.Component + "] "
+	if entry.Level == LogLevelError {
+		prefix += "ERROR: "
+	}
+	if entry.JobID != "" {
+		prefix += "Job " + entry.JobID + ": "
+	}
+	log.Print(prefix + entry.Message)
+}
+
 type Logger struct {
 	logDir     string
 	consoleLog *log.Logger
@@ -55,13 +121,17 @@ func (l *Logger) Close() error {
 }
 
 func (l *Logger) log(level LogLevel, component, message string, args ...interface{}) {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	now := time.Now()
 	formattedMessage := fmt.Sprintf(message, args...)
 
-	logEntry := fmt.Sprintf("[%s] [%s] [%s] %s", timestamp, level, component, formattedMessage)
+	logEntry := fmt.Sprintf("[%s] [%s] [%s] %s", now.Format("2006-01-02 15:04:05"), level, component, formattedMessage)
 
 	// Log to console
-	l.consoleLog.Println(logEntry)
+	if Format() == FormatJSON {
+		Emit(Entry{Timestamp: now, Level: level, Component: component, Message: formattedMessage})
+	} else {
+		l.consoleLog.Println(logEntry)
+	}
 
 	// Log to file
 	if l.fileLog != nil {
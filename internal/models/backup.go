@@ -37,6 +37,95 @@ type BackupInfo struct {
 	S3Key        string       `json:"s3_key"`
 	ErrorMessage string       `json:"error_message,omitempty"`
 	CreatedAt    time.Time    `json:"created_at"`
+
+	IdempotencyKey string `json:"idempotency_key,omitempty"` // Client-supplied key to deduplicate retried requests
+	Checksum       string `json:"checksum,omitempty"`        // SHA-256 of the dump content, see worker.dumpChecksum
+
+	Tags      []string `json:"tags,omitempty"` // Free-form labels such as "pre-deploy"
+	Protected bool     `json:"protected"`      // Never removed by retention cleanup
+
+	StorageClass string `json:"storage_class,omitempty"` // S3 storage class of S3Key; empty means STANDARD
+
+	CreatedBy string `json:"created_by,omitempty"` // API key name or "scheduler" that requested the backup
+
+	// Set when a dump completes; FileSize is the compressed size
+	UncompressedSize int64   `json:"uncompressed_size,omitempty"`
+	CompressionRatio float64 `json:"compression_ratio,omitempty"` // UncompressedSize / FileSize
+	ThroughputMBps   float64 `json:"throughput_mbps,omitempty"`   // Uncompressed MB per second of StartTime..EndTime
+
+	// Dump format recorded when the dump completes: plain, plain_gzip or custom.
+	// Empty for older backups, whose format is detected from the file.
+	Format string `json:"format,omitempty"`
+
+	// Set when the dump left out large objects (no_blobs), so it is not a full backup
+	BlobsExcluded bool `json:"blobs_excluded,omitempty"`
+
+	FailureCategory FailureCategory `json:"failure_category,omitempty"` // Set with ErrorMessage by Fail
+
+	// Copies in secondary S3 buckets; only the primary upload (S3Key) decides Status
+	Replicas []ReplicaUpload `json:"replicas,omitempty"`
+
+	// Set when the backup is deleted; it is removed for good at PurgeAt unless restored
+	TrashedAt *time.Time `json:"trashed_at,omitempty"`
+	PurgeAt   *time.Time `json:"purge_at,omitempty"`
+}
+
+// Trashed reports whether the backup is in the trash
+func (b *BackupInfo) Trashed() bool {
+	return b.TrashedAt != nil
+}
+
+// ReplicaStatus is the state of a backup's copy in a secondary bucket
+type ReplicaStatus string
+
+const (
+	ReplicaStatusPending   ReplicaStatus = "pending"
+	ReplicaStatusCompleted ReplicaStatus = "completed"
+	ReplicaStatusFailed    ReplicaStatus = "failed"
+)
+
+// ReplicaUpload records the upload of a backup to one secondary bucket
+type ReplicaUpload struct {
+	Destination string        `json:"destination"`
+	Bucket      string        `json:"bucket"`
+	Status      ReplicaStatus `json:"status"`
+	Attempts    int           `json:"attempts"`
+	Error       string        `json:"error,omitempty"` // Last upload error
+	UploadedAt  *time.Time    `json:"uploaded_at,omitempty"`
+
+	// When the next retry of a pending upload is due, kept so retries resume
+	// after a restart
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+// RecordTransferStats stores the uncompressed size of a completed backup and
+// derives the compression ratio and throughput from FileSize and the run time
+func (b *BackupInfo) RecordTransferStats(uncompressedSize int64) {
+	b.UncompressedSize = uncompressedSize
+	if b.FileSize > 0 {
+		b.CompressionRatio = float64(uncompressedSize) / float64(b.FileSize)
+	}
+	if b.EndTime != nil {
+		if seconds := b.EndTime.Sub(b.StartTime).Seconds(); seconds > 0 {
+			b.ThroughputMBps = float64(uncompressedSize) / 1024 / 1024 / seconds
+		}
+	}
+}
+
+// ReplicationPending reports whether a secondary upload is still running
+func (b *BackupInfo) ReplicationPending() bool {
+	for _, replica := range b.Replicas {
+		if replica.Status == ReplicaStatusPending {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsRetrieval reports whether the backup is in a Glacier storage class that
+// must be restored before it can be downloaded
+func (b *BackupInfo) NeedsRetrieval() bool {
+	return b.StorageClass == "GLACIER" || b.StorageClass == "DEEP_ARCHIVE"
 }
 
 type RestoreRequest struct {
@@ -0,0 +1,65 @@
+package models
+
+import "strings"
+
+// FailureCategory groups backup failures by cause so they can be aggregated
+type FailureCategory string
+
+const (
+	FailureConnection FailureCategory = "connection_error"
+	FailureAuth       FailureCategory = "auth_error"
+	FailureTimeout    FailureCategory = "timeout"
+	FailureDiskFull   FailureCategory = "disk_full"
+	FailureUpload     FailureCategory = "upload_error"
+	FailurePgDump     FailureCategory = "pg_dump_error"
+)
+
+// failureMarkers are checked in order against the lowercased error message.
+// Upload, auth and disk errors come first because their messages often also
+// mention the connection or the dump.
+var failureMarkers = []struct {
+	category FailureCategory
+	markers  []string
+}{
+	{FailureUpload, []string{
+		"upload failed", "s3 upload",
+	}},
+	{FailureAuth, []string{
+		"password authentication failed", "no pg_hba.conf entry", "authentication failed",
+		"no password supplied", "permission denied", "must be superuser", "role \"",
+	}},
+	{FailureDiskFull, []string{
+		"no space left on device", "disk quota exceeded", "insufficient disk space",
+	}},
+	{FailureConnection, []string{
+		"could not connect", "connection refused", "could not translate host name",
+		"no route to host", "network is unreachable", "connection reset",
+		"server closed the connection", "timeout expired", "connection pooler",
+		"ssl error", "connection to server",
+	}},
+	{FailureTimeout, []string{
+		"timeout exceeded", "timed out", "deadline exceeded", "canceling statement due to",
+	}},
+}
+
+// ClassifyFailure maps a backup error message to a failure category. Errors
+// that match no known cause are counted as pg_dump errors, since the dump is
+// what a backup job runs.
+func ClassifyFailure(message string) FailureCategory {
+	text := strings.ToLower(message)
+	for _, group := range failureMarkers {
+		for _, marker := range group.markers {
+			if strings.Contains(text, marker) {
+				return group.category
+			}
+		}
+	}
+	return FailurePgDump
+}
+
+// Fail marks the backup as failed with message and its failure category
+func (b *BackupInfo) Fail(message string) {
+	b.Status = BackupStatusFailed
+	b.ErrorMessage = message
+	b.FailureCategory = ClassifyFailure(message)
+}
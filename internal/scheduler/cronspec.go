@@ -0,0 +1,29 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+)
+
+// cronSpecFormat describes the specs the scheduler accepts, for error messages
+const cronSpecFormat = `6 fields "second minute hour day-of-month month day-of-week" (e.g. "0 30 2 * * *" for 02:30 every day), or a descriptor such as "@daily" or "@every 6h"`
+
+// specParser is the parser cron.WithSeconds() installs, kept here so specs
+// are validated exactly as the scheduler parses them
+var specParser = cron.NewParser(
+	cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// ValidateCronSpec checks a user-supplied schedule before it reaches the
+// scheduler, and explains the expected format when it is malformed
+func ValidateCronSpec(spec string) error {
+	if strings.TrimSpace(spec) == "" {
+		return fmt.Errorf("cron spec is empty: use %s", cronSpecFormat)
+	}
+	if _, err := specParser.Parse(spec); err != nil {
+		return fmt.Errorf("invalid cron spec %q: %v; use %s", spec, err, cronSpecFormat)
+	}
+	return nil
+}
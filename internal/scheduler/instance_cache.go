@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"evolution-postgres-backup/internal/config"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultInstanceCacheTTL bounds how stale the cached instance list can get
+// when a change notification is missed
+const defaultInstanceCacheTTL = 5 * time.Minute
+
+// instanceCacheTTL returns the cache lifetime, configurable via
+// SCHEDULER_INSTANCE_CACHE_TTL ("0" disables caching)
+func instanceCacheTTL() time.Duration {
+	if value := os.Getenv("SCHEDULER_INSTANCE_CACHE_TTL"); value != "" {
+		if ttl, err := time.ParseDuration(value); err == nil && ttl >= 0 {
+			return ttl
+		}
+	}
+	return defaultInstanceCacheTTL
+}
+
+// instanceCache keeps the enabled instances between cron ticks so each tick
+// doesn't query them again. It is refreshed after ttl or when invalidated.
+type instanceCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	load      func() ([]*config.PostgreSQLConfig, error)
+	instances []*config.PostgreSQLConfig
+	loadedAt  time.Time
+	valid     bool
+}
+
+func newInstanceCache(ttl time.Duration, load func() ([]*config.PostgreSQLConfig, error)) *instanceCache {
+	return &instanceCache{ttl: ttl, load: load}
+}
+
+// Get returns the cached instances, loading them if the cache is empty,
+// expired or invalidated. Callers must not modify the returned configs.
+func (c *instanceCache) Get() ([]*config.PostgreSQLConfig, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.valid && time.Now().Sub(c.loadedAt) < c.ttl {
+		return c.instances, nil
+	}
+
+	instances, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	c.instances = instances
+	c.loadedAt = time.Now()
+	c.valid = true
+	return instances, nil
+}
+
+// Invalidate makes the next Get reload the instances
+func (c *instanceCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.valid = false
+	c.instances = nil
+}
@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"evolution-postgres-backup/internal/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeInstances stands in for PostgreSQLRepository.GetEnabled
+type fakeInstances struct {
+	mu      sync.Mutex
+	enabled []*config.PostgreSQLConfig
+	loads   int
+}
+
+func (f *fakeInstances) GetEnabled() ([]*config.PostgreSQLConfig, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loads++
+	return append([]*config.PostgreSQLConfig(nil), f.enabled...), nil
+}
+
+func (f *fakeInstances) disable(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, instance := range f.enabled {
+		if instance.ID == id {
+			f.enabled = append(f.enabled[:i], f.enabled[i+1:]...)
+			return
+		}
+	}
+}
+
+func TestInstanceCacheInvalidatedWhenInstanceDisabled(t *testing.T) {
+	repo := &fakeInstances{enabled: []*config.PostgreSQLConfig{{ID: "pg-1"}, {ID: "pg-2"}}}
+	cache := newInstanceCache(time.Hour, repo.GetEnabled)
+
+	for i := 0; i < 3; i++ {
+		instances, err := cache.Get()
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if len(instances) != 2 {
+			t.Fatalf("got %d instances, want 2", len(instances))
+		}
+	}
+	if repo.loads != 1 {
+		t.Errorf("loaded %d times for 3 ticks, want 1", repo.loads)
+	}
+
+	// The API disables pg-2 and notifies the scheduler
+	repo.disable("pg-2")
+	cache.Invalidate()
+
+	instances, err := cache.Get()
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(instances) != 1 || instances[0].ID != "pg-1" {
+		t.Errorf("got %v after disabling pg-2, want only pg-1", instanceIDs(instances))
+	}
+	if repo.loads != 2 {
+		t.Errorf("loaded %d times, want a reload after Invalidate", repo.loads)
+	}
+}
+
+func TestInstanceCacheExpiresWithoutNotification(t *testing.T) {
+	repo := &fakeInstances{enabled: []*config.PostgreSQLConfig{{ID: "pg-1"}}}
+	cache := newInstanceCache(20*time.Millisecond, repo.GetEnabled)
+
+	if _, err := cache.Get(); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	repo.disable("pg-1")
+
+	// A missed notification is covered by the TTL
+	time.Sleep(30 * time.Millisecond)
+	instances, err := cache.Get()
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if len(instances) != 0 {
+		t.Errorf("got %v after the TTL, want none", instanceIDs(instances))
+	}
+}
+
+func TestInstanceCacheZeroTTLDisablesCaching(t *testing.T) {
+	repo := &fakeInstances{}
+	cache := newInstanceCache(0, repo.GetEnabled)
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get(); err != nil {
+			t.Fatalf("get: %v", err)
+		}
+	}
+	if repo.loads != 3 {
+		t.Errorf("loaded %d times with caching disabled, want 3", repo.loads)
+	}
+}
+
+func instanceIDs(instances []*config.PostgreSQLConfig) []string {
+	ids := make([]string, len(instances))
+	for i, instance := range instances {
+		ids[i] = instance.ID
+	}
+	return ids
+}
@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"database/sql"
+	"errors"
+	"evolution-postgres-backup/internal/config"
+	"evolution-postgres-backup/internal/database"
+	"evolution-postgres-backup/internal/models"
+	"evolution-postgres-backup/internal/worker"
+	"log"
+	"time"
+)
+
+// oneOffCheckSpec is how often the scheduler looks for due one-off schedules.
+// The API creates them in another process, so they are polled rather than
+// armed as timers; a schedule fires at most this late.
+const oneOffCheckSpec = "*/30 * * * * *"
+
+// runDueOneOffSchedules creates the backups of one-off schedules whose time
+// has come, including ones that came while no scheduler was running. Each
+// schedule is claimed in the transaction that saves its backup job, so a crash
+// can't leave it fired without a backup.
+func (s *Scheduler) runDueOneOffSchedules() int {
+	scheduleRepo := database.NewScheduleRepository(s.dbService)
+	now := time.Now()
+	due, err := scheduleRepo.GetDueOneOff(now)
+	if err != nil {
+		log.Printf("❌ Failed to check one-off schedules: %v", err)
+		return 0
+	}
+
+	jobsCreated := 0
+	for _, schedule := range due {
+		if late := time.Since(*schedule.RunAt); late > time.Minute {
+			log.Printf("⏰ One-off schedule %s is %s late", schedule.ID, late.Round(time.Second))
+		}
+
+		backupType := schedule.BackupType
+		createdBy := schedule.CreatedBy
+		if createdBy == "" {
+			createdBy = "scheduler"
+		}
+		backup := &models.BackupInfo{
+			ID:           config.NewID("backup"),
+			PostgreSQLID: schedule.PostgreSQLID,
+			DatabaseName: schedule.DatabaseName,
+			BackupType:   backupType,
+			Status:       models.BackupStatusPending,
+			StartTime:    time.Now(),
+			CreatedAt:    time.Now(),
+			CreatedBy:    createdBy,
+		}
+
+		claim := worker.WithTx(func(tx *sql.Tx) error {
+			return scheduleRepo.ClaimOneOff(tx, schedule.ID, backup.ID, now)
+		})
+		job, err := s.jobQueue.SaveBackupJob(backup, worker.BackupPriority(backupType), claim)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Another scheduler claimed it first
+			continue
+		}
+		if errors.Is(err, worker.ErrDuplicateBackup) {
+			log.Printf("⏭️ Skipping one-off schedule %s for %s/%s: %v", schedule.ID, schedule.PostgreSQLID, schedule.DatabaseName, err)
+			if err := scheduleRepo.ClaimOneOff(s.dbService, schedule.ID, "", now); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("❌ Failed to mark one-off schedule %s as fired: %v", schedule.ID, err)
+			}
+			continue
+		}
+		if err != nil {
+			// It stays pending, so the next check tries again
+			log.Printf("❌ Failed to create backup job for one-off schedule %s: %v", schedule.ID, err)
+			continue
+		}
+
+		log.Printf("📋 One-off schedule %s created %s backup job for %s/%s (job: %s, backup: %s)",
+			schedule.ID, backupType, schedule.PostgreSQLID, schedule.DatabaseName, job.ID, backup.ID)
+		jobsCreated++
+	}
+
+	return jobsCreated
+}
@@ -1,10 +1,11 @@
 package scheduler
 
 import (
+	"errors"
+	"evolution-postgres-backup/internal/config"
 	"evolution-postgres-backup/internal/database"
 	"evolution-postgres-backup/internal/models"
 	"evolution-postgres-backup/internal/worker"
-	"fmt"
 	"log"
 	"time"
 
@@ -12,22 +13,37 @@ import (
 )
 
 type Scheduler struct {
-	cron      *cron.Cron
-	jobQueue  *worker.JobQueue
-	dbService *database.DB
+	cron         *cron.Cron
+	jobQueue     *worker.JobQueue
+	dbService    *database.DB
+	pgRepo       *database.PostgreSQLRepository
+	instances    *instanceCache
+	stopListener func()
 }
 
 func NewScheduler(jobQueue *worker.JobQueue) *Scheduler {
+	db := jobQueue.GetDB()
+	pgRepo := database.NewPostgreSQLRepository(db)
 	return &Scheduler{
-		cron:      cron.New(cron.WithSeconds()),
+		cron:      cron.New(cron.WithParser(specParser)),
 		jobQueue:  jobQueue,
-		dbService: jobQueue.GetDB(),
+		dbService: db,
+		pgRepo:    pgRepo,
+		instances: newInstanceCache(instanceCacheTTL(), pgRepo.GetEnabled),
 	}
 }
 
 func (s *Scheduler) Start() error {
+	// Drop cached instances as soon as the API changes one; the TTL covers missed notifications
+	stopListener, err := s.dbService.ListenInstanceChanges(s.instances.Invalidate)
+	if err != nil {
+		log.Printf("⚠️ Instance change notifications unavailable, relying on cache TTL: %v", err)
+	} else {
+		s.stopListener = stopListener
+	}
+
 	// Hourly backups - every hour at minute 0
-	_, err := s.cron.AddFunc("0 0 * * * *", func() {
+	_, err = s.cron.AddFunc("0 0 * * * *", func() {
 		log.Println("🕐 Starting automatic hourly backup jobs")
 		count := s.createBackupJobsForAllEnabledInstances(models.BackupTypeHourly)
 		log.Printf("✅ Created %d hourly backup jobs", count)
@@ -66,6 +82,32 @@ func (s *Scheduler) Start() error {
 		return err
 	}
 
+	// Trash purge - every hour at minute 30, deletes backups past TRASH_RETENTION_HOURS
+	_, err = s.cron.AddFunc("0 30 * * * *", func() {
+		job, err := s.jobQueue.AddEmptyTrashJob(worker.CleanupPriority())
+		if err != nil {
+			log.Printf("❌ Failed to create trash purge job: %v", err)
+			return
+		}
+		log.Printf("🗑️ Created trash purge job %s", job.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	// One-off schedules created through the API
+	_, err = s.cron.AddFunc(oneOffCheckSpec, func() {
+		s.runDueOneOffSchedules()
+	})
+	if err != nil {
+		return err
+	}
+
+	// Fire one-off schedules that came due while no scheduler was running
+	if count := s.runDueOneOffSchedules(); count > 0 {
+		log.Printf("✅ Created %d backup jobs for one-off schedules that were due", count)
+	}
+
 	// Start the cron scheduler
 	s.cron.Start()
 	log.Println("⏰ Automatic backup scheduler started successfully")
@@ -79,10 +121,18 @@ func (s *Scheduler) Start() error {
 
 func (s *Scheduler) Stop() {
 	s.cron.Stop()
+	if s.stopListener != nil {
+		s.stopListener()
+	}
 	log.Println("⏰ Automatic backup scheduler stopped")
 }
 
+// AddCustomJob schedules backups of every enabled instance on a 6-field cron
+// spec; see ValidateCronSpec
 func (s *Scheduler) AddCustomJob(spec string, backupType models.BackupType) error {
+	if err := ValidateCronSpec(spec); err != nil {
+		return err
+	}
 	_, err := s.cron.AddFunc(spec, func() {
 		log.Printf("🔧 Starting custom %s backup jobs", backupType)
 		count := s.createBackupJobsForAllEnabledInstances(backupType)
@@ -94,68 +144,45 @@ func (s *Scheduler) AddCustomJob(spec string, backupType models.BackupType) erro
 // createBackupJobsForAllEnabledInstances creates backup jobs for all enabled PostgreSQL instances
 func (s *Scheduler) createBackupJobsForAllEnabledInstances(backupType models.BackupType) int {
 	// Get all enabled PostgreSQL instances
-	pgRepo := database.NewPostgreSQLRepository(s.dbService)
-	instances, err := pgRepo.GetEnabled()
+	instances, err := s.instances.Get()
 	if err != nil {
 		log.Printf("❌ Failed to get enabled PostgreSQL instances: %v", err)
 		return 0
 	}
 
 	jobsCreated := 0
-	backupRepo := database.NewBackupRepository(s.dbService)
 
 	for _, instance := range instances {
 		// Create backup jobs for each database in this instance
-		databases := instance.Databases
-		if len(databases) == 0 {
-			// Fallback to default database if none specified
-			databases = []string{"postgres"}
-		}
+		for _, dbName := range instance.ScheduledDatabases() {
+			if !instance.BackupTypeEnabled(dbName, string(backupType)) {
+				continue
+			}
 
-		for _, dbName := range databases {
 			// Create backup record first (same as API does)
 			backup := &models.BackupInfo{
-				ID:           fmt.Sprintf("backup_%d", time.Now().UnixNano()),
+				ID:           config.NewID("backup"),
 				PostgreSQLID: instance.ID,
 				DatabaseName: dbName,
 				BackupType:   backupType,
 				Status:       models.BackupStatusPending,
 				StartTime:    time.Now(),
 				CreatedAt:    time.Now(),
+				CreatedBy:    "scheduler",
 			}
 
-			// Save backup record to database
-			if err := backupRepo.Create(backup); err != nil {
-				log.Printf("❌ Failed to create backup record for %s/%s: %v", instance.Name, dbName, err)
+			// Save the backup record and its job together; the database loader
+			// runs it, so a full buffer during a backlog doesn't drop it
+			job, err := s.jobQueue.SaveBackupJob(backup, worker.BackupPriority(backupType))
+			if errors.Is(err, worker.ErrDuplicateBackup) {
+				log.Printf("⏭️ Skipping %s backup of %s/%s: %v", backupType, instance.Name, dbName, err)
 				continue
 			}
-
-			// Create job with backup_id
-			job := &worker.Job{
-				Type:     worker.JobTypeBackup,
-				Priority: 7, // High priority for automatic backups
-				Payload: map[string]interface{}{
-					"postgres_id":   instance.ID,
-					"database_name": dbName,
-					"backup_type":   string(backupType),
-					"backup_id":     backup.ID, // Include backup_id for worker
-				},
-				MaxRetries: 3,
-			}
-
-			// Add job to queue
-			if err := s.jobQueue.AddJob(job); err != nil {
+			if err != nil {
 				log.Printf("❌ Failed to create %s backup job for %s/%s: %v", backupType, instance.Name, dbName, err)
 				continue
 			}
 
-			// Associate backup with job and update
-			backup.JobID = job.ID
-			if err := backupRepo.Update(backup); err != nil {
-				// Don't fail, just log the error
-				log.Printf("⚠️ Failed to update backup with job_id for %s/%s: %v", instance.Name, dbName, err)
-			}
-
 			log.Printf("📋 Created %s backup job for %s/%s (job: %s, backup: %s)", backupType, instance.Name, dbName, job.ID, backup.ID)
 			jobsCreated++
 		}
@@ -8,10 +8,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
-
-	"github.com/google/uuid"
 )
 
 type BackupService struct {
@@ -20,6 +21,12 @@ type BackupService struct {
 	backups     map[string]*models.BackupInfo
 	tempDir     string
 	persistence *BackupPersistence
+	keepLocal   int // Uploaded dumps kept on local disk per database, newest first
+
+	// Secondary buckets every backup is copied to; replicaMu guards the
+	// Replicas of backups being copied
+	replicas  []*replicaStore
+	replicaMu sync.Mutex
 }
 
 func NewBackupService(s3Client *S3Client, cfg *config.Config) *BackupService {
@@ -38,6 +45,13 @@ func NewBackupService(s3Client *S3Client, cfg *config.Config) *BackupService {
 	}
 	persistence := NewBackupPersistence(dataDir)
 
+	// KEEP_LOCAL_LATEST keeps the newest N uploaded dumps of each database on
+	// disk so they can be restored without a download (default 0)
+	keepLocal, _ := strconv.Atoi(os.Getenv("KEEP_LOCAL_LATEST"))
+	if keepLocal < 0 {
+		keepLocal = 0
+	}
+
 	// Load existing backups from disk
 	backups, err := persistence.LoadBackups()
 	if err != nil {
@@ -53,12 +67,15 @@ func NewBackupService(s3Client *S3Client, cfg *config.Config) *BackupService {
 		backups:     backups,
 		tempDir:     tempDir,
 		persistence: persistence,
+		keepLocal:   keepLocal,
 	}
 
 	// Initialize S3 client
 	if err := s3Client.Initialize(cfg); err != nil {
 		panic(fmt.Sprintf("Failed to initialize S3 client: %v", err))
 	}
+	service.replicas = newReplicaStores(cfg)
+	service.resumeReplication()
 
 	// Clean up old backup files periodically
 	go func() {
@@ -84,7 +101,7 @@ func (bs *BackupService) CreateBackup(postgresID string, backupType models.Backu
 		databaseName = pgConfig.GetDefaultDatabase()
 	}
 
-	backupID := uuid.New().String()
+	backupID := config.NewID("backup")
 	timestamp := time.Now().Format("2006-01-02-15-04-05")
 	filename := fmt.Sprintf("%s_%s_%s_%s.sql", pgConfig.Name, databaseName, string(backupType), timestamp)
 	filename = strings.ReplaceAll(filename, " ", "_")
@@ -128,26 +145,29 @@ func (bs *BackupService) performBackup(backupInfo *models.BackupInfo, pgConfig *
 	localPath := filepath.Join(bs.tempDir, filename)
 	log.LogJobProgress(jobID, "Local file: %s", localPath)
 
-	// Build pg_dump command
-	cmd := exec.Command("pg_dump",
-		"-h", pgConfig.Host,
-		"-p", fmt.Sprintf("%d", pgConfig.Port),
-		"-U", pgConfig.Username,
-		"-d", backupInfo.DatabaseName,
-		"-f", localPath,
-		"--verbose",
-		"--no-password",
-	)
+	// Build pg_dump command, bypassing the pooler of pooled instances
+	pgConfig = pgConfig.ForBackup()
+	args, err := pgConfig.DumpArgs(backupInfo.DatabaseName, localPath)
+	if err != nil {
+		backupInfo.Fail(err.Error())
+		endTime := time.Now()
+		backupInfo.EndTime = &endTime
+		log.LogJobError(jobID, "%v", err)
+		bs.persistence.SaveSingleBackup(bs.backups)
+		return
+	}
+	cmd := exec.Command(config.ToolPath(config.ToolPgDump), args...)
 
 	// Set password via environment variable
 	cmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", pgConfig.Password))
+	cmd.Env = append(cmd.Env, pgConfig.PGOptionsEnv()...)
+	cmd.Env = append(cmd.Env, config.ConnectTimeoutEnv()...)
 
 	log.LogJobProgress(jobID, "Executing pg_dump: %s@%s:%d/%s", pgConfig.Username, pgConfig.Host, pgConfig.Port, backupInfo.DatabaseName)
 
 	// Execute backup
 	if err := cmd.Run(); err != nil {
-		backupInfo.Status = models.BackupStatusFailed
-		backupInfo.ErrorMessage = fmt.Sprintf("pg_dump failed: %v", err)
+		backupInfo.Fail(fmt.Sprintf("pg_dump failed: %v", err))
 		endTime := time.Now()
 		backupInfo.EndTime = &endTime
 		log.LogJobError(jobID, "pg_dump failed: %v", err)
@@ -162,8 +182,7 @@ func (bs *BackupService) performBackup(backupInfo *models.BackupInfo, pgConfig *
 	// Get file size
 	fileInfo, err := os.Stat(localPath)
 	if err != nil {
-		backupInfo.Status = models.BackupStatusFailed
-		backupInfo.ErrorMessage = fmt.Sprintf("failed to get file info: %v", err)
+		backupInfo.Fail(fmt.Sprintf("failed to get file info: %v", err))
 		endTime := time.Now()
 		backupInfo.EndTime = &endTime
 		log.LogJobError(jobID, "Failed to get file info: %v", err)
@@ -177,16 +196,29 @@ func (bs *BackupService) performBackup(backupInfo *models.BackupInfo, pgConfig *
 	backupInfo.FilePath = localPath
 	log.LogJobProgress(jobID, "Backup file size: %d bytes (%.2f MB)", fileInfo.Size(), float64(fileInfo.Size())/1024/1024)
 
+	// Don't let a runaway database fill the bucket
+	if err := pgConfig.CheckBackupSize(fileInfo.Size()); err != nil {
+		os.Remove(localPath)
+		backupInfo.Fail(err.Error())
+		backupInfo.FilePath = ""
+		endTime := time.Now()
+		backupInfo.EndTime = &endTime
+		log.LogJobError(jobID, "%v", err)
+
+		// Save error status
+		bs.persistence.SaveSingleBackup(bs.backups)
+		return
+	}
+
 	// Generate S3 key
-	s3Key := GenerateS3Key(backupInfo.PostgreSQLID, string(backupInfo.BackupType), timestamp, filename)
+	s3Key := bs.config.S3Config.BackupKey(backupInfo.PostgreSQLID, string(backupInfo.BackupType), timestamp, filename)
 	backupInfo.S3Key = s3Key
 	log.LogJobProgress(jobID, "S3 key: %s", s3Key)
 
 	// Upload to S3
 	log.LogJobProgress(jobID, "Starting S3 upload...")
 	if err := bs.s3Client.UploadFile(localPath, s3Key); err != nil {
-		backupInfo.Status = models.BackupStatusFailed
-		backupInfo.ErrorMessage = fmt.Sprintf("S3 upload failed: %v", err)
+		backupInfo.Fail(fmt.Sprintf("S3 upload failed: %v", err))
 		endTime := time.Now()
 		backupInfo.EndTime = &endTime
 		log.LogJobError(jobID, "S3 upload failed: %v", err)
@@ -197,15 +229,18 @@ func (bs *BackupService) performBackup(backupInfo *models.BackupInfo, pgConfig *
 	}
 	log.LogJobProgress(jobID, "S3 upload completed successfully")
 
-	// Clean up local file
-	os.Remove(localPath)
-	log.LogJobProgress(jobID, "Local file cleaned up")
-
-	// Mark as completed
+	// Mark as completed; secondary buckets can't fail the backup
 	backupInfo.Status = models.BackupStatusCompleted
 	endTime := time.Now()
 	backupInfo.EndTime = &endTime
 
+	// The local file is released once every secondary bucket has its copy
+	if len(bs.replicas) > 0 {
+		backupInfo.Replicas = bs.pendingReplicas()
+	} else {
+		bs.releaseLocalFile(jobID, backupInfo, localPath)
+	}
+
 	duration := endTime.Sub(backupInfo.StartTime)
 	log.LogJobSuccess(jobID, "Backup completed in %v (%.2f MB)", duration, float64(backupInfo.FileSize)/1024/1024)
 
@@ -216,8 +251,16 @@ func (bs *BackupService) performBackup(backupInfo *models.BackupInfo, pgConfig *
 
 	// Clean up old backups based on retention policy
 	go bs.cleanupOldBackups(backupInfo.PostgreSQLID, backupInfo.BackupType)
+
+	if len(bs.replicas) > 0 {
+		log.LogJobProgress(jobID, "Copying to %d replica buckets", len(bs.replicas))
+		bs.replicateBackup(jobID, backupInfo, localPath)
+	}
 }
 
+// archiveRetrievalDays is how long a backup retrieved from Glacier stays readable
+const archiveRetrievalDays = 3
+
 func (bs *BackupService) RestoreBackup(backupID, postgresID, databaseName string) error {
 	backupInfo, exists := bs.backups[backupID]
 	if !exists {
@@ -233,16 +276,20 @@ func (bs *BackupService) RestoreBackup(backupID, postgresID, databaseName string
 		return fmt.Errorf("PostgreSQL instance %s not found", postgresID)
 	}
 
-	// Download backup file from S3
-	localPath := filepath.Join(bs.tempDir, fmt.Sprintf("restore_%s_%d.sql", backupID, time.Now().Unix()))
-
-	if err := bs.s3Client.DownloadFile(backupInfo.S3Key, localPath); err != nil {
-		return fmt.Errorf("failed to download backup: %w", err)
+	// A local copy kept by KEEP_LOCAL_LATEST saves the download
+	localPath := backupInfo.FilePath
+	if _, err := os.Stat(localPath); err != nil {
+		downloaded, err := bs.downloadBackup(backupInfo)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(downloaded)
+		localPath = downloaded
 	}
-	defer os.Remove(localPath)
 
 	// Build psql command for restore
-	cmd := exec.Command("psql",
+	pgConfig = pgConfig.ForBackup()
+	cmd := exec.Command(config.ToolPath(config.ToolPsql),
 		"-h", pgConfig.Host,
 		"-p", fmt.Sprintf("%d", pgConfig.Port),
 		"-U", pgConfig.Username,
@@ -253,6 +300,7 @@ func (bs *BackupService) RestoreBackup(backupID, postgresID, databaseName string
 
 	// Set password via environment variable
 	cmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", pgConfig.Password))
+	cmd.Env = append(cmd.Env, config.ConnectTimeoutEnv()...)
 
 	// Execute restore
 	if err := cmd.Run(); err != nil {
@@ -262,6 +310,62 @@ func (bs *BackupService) RestoreBackup(backupID, postgresID, databaseName string
 	return nil
 }
 
+// downloadBackup fetches a backup from S3 into the temp dir, requesting a
+// retrieval first when it is archived in Glacier
+func (bs *BackupService) downloadBackup(backupInfo *models.BackupInfo) (string, error) {
+	if backupInfo.NeedsRetrieval() {
+		ready, err := bs.s3Client.RetrieveArchived(backupInfo.S3Key, archiveRetrievalDays)
+		if err != nil {
+			return "", err
+		}
+		if !ready {
+			return "", fmt.Errorf("backup %s is archived in %s; retrieval is in progress, retry the restore once it completes (usually hours)", backupInfo.ID, backupInfo.StorageClass)
+		}
+	}
+
+	localPath := filepath.Join(bs.tempDir, fmt.Sprintf("restore_%s_%d.sql", backupInfo.ID, time.Now().Unix()))
+	if err := bs.s3Client.DownloadFile(backupInfo.S3Key, localPath); err != nil {
+		return "", fmt.Errorf("failed to download backup: %w", err)
+	}
+	return localPath, nil
+}
+
+// releaseLocalFile removes an uploaded backup's local file, unless it is one of
+// the newest kept for fast restores
+func (bs *BackupService) releaseLocalFile(jobID string, backupInfo *models.BackupInfo, localPath string) {
+	log := logger.GetLogger()
+	if bs.keepLocal > 0 {
+		bs.pruneLocalCopies(backupInfo.PostgreSQLID, backupInfo.DatabaseName)
+		log.LogJobProgress(jobID, "Local file kept (newest %d per database)", bs.keepLocal)
+		return
+	}
+	os.Remove(localPath)
+	backupInfo.FilePath = ""
+	log.LogJobProgress(jobID, "Local file cleaned up")
+}
+
+// pruneLocalCopies removes the local files of a database's completed backups
+// beyond the newest keepLocal; their S3 copies are untouched. Files still
+// being copied to replica buckets are left alone.
+func (bs *BackupService) pruneLocalCopies(postgresID, databaseName string) {
+	var local []*models.BackupInfo
+	for _, backup := range bs.backups {
+		if backup.PostgreSQLID == postgresID && backup.DatabaseName == databaseName &&
+			backup.Status == models.BackupStatusCompleted && backup.FilePath != "" && !backup.ReplicationPending() {
+			local = append(local, backup)
+		}
+	}
+	sort.Slice(local, func(i, j int) bool { return local[i].StartTime.After(local[j].StartTime) })
+
+	for i := bs.keepLocal; i < len(local); i++ {
+		if err := os.Remove(local[i].FilePath); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Failed to remove local copy %s: %v\n", local[i].FilePath, err)
+			continue
+		}
+		local[i].FilePath = ""
+	}
+}
+
 func (bs *BackupService) GetBackups() []*models.BackupInfo {
 	backups := make([]*models.BackupInfo, 0, len(bs.backups))
 	for _, backup := range bs.backups {
@@ -276,24 +380,73 @@ func (bs *BackupService) GetBackup(backupID string) (*models.BackupInfo, bool) {
 }
 
 func (bs *BackupService) cleanupOldBackups(postgresID string, backupType models.BackupType) {
-	var retentionCount int
-
-	switch backupType {
-	case models.BackupTypeHourly:
-		retentionCount = bs.config.RetentionPolicy.Hourly
-	case models.BackupTypeDaily:
-		retentionCount = bs.config.RetentionPolicy.Daily
-	case models.BackupTypeWeekly:
-		retentionCount = bs.config.RetentionPolicy.Weekly
-	case models.BackupTypeMonthly:
-		retentionCount = bs.config.RetentionPolicy.Monthly
-	default:
-		return // Don't clean up manual backups
-	}
-
-	prefix := fmt.Sprintf("backups/%s/%s/", postgresID, string(backupType))
-	if err := bs.s3Client.CleanupOldBackups(prefix, retentionCount); err != nil {
-		fmt.Printf("Failed to cleanup old backups: %v\n", err)
+	policy := bs.config.RetentionPolicyFor(postgresID)
+	if !policy.Covers(string(backupType)) {
+		return // Manual backups and types without a limit are kept
+	}
+	retentionCount, _ := policy.CountFor(string(backupType))
+
+	prefix := bs.config.S3Config.BackupKeyPrefix(postgresID, string(backupType))
+
+	protected := make(map[string]bool)
+	for _, backup := range bs.backups {
+		if backup.Protected && backup.S3Key != "" {
+			protected[backup.S3Key] = true
+		}
+	}
+
+	if policy.ArchiveStorageClass != "" {
+		bs.archiveOldBackups(policy, prefix, retentionCount, protected)
+		return
+	}
+
+	// Replica buckets follow the same retention as the primary
+	clients := []*S3Client{bs.s3Client}
+	for _, store := range bs.replicas {
+		clients = append(clients, store.client)
+	}
+	for _, client := range clients {
+		var err error
+		if policy.GetMode() == config.RetentionModeAge {
+			err = client.CleanupBackupsOlderThan(prefix, time.Now().AddDate(0, 0, -policy.KeepDays), protected)
+		} else {
+			err = client.CleanupOldBackups(prefix, retentionCount, protected)
+		}
+		if err != nil {
+			fmt.Printf("Failed to cleanup old backups in %s: %v\n", client.Bucket(), err)
+		}
+	}
+}
+
+// archiveOldBackups moves the backups outside retention to the policy's
+// archive storage class and records the new class on their backup info
+func (bs *BackupService) archiveOldBackups(policy config.RetentionPolicy, prefix string, retentionCount int, protected map[string]bool) {
+	var archived []string
+	var err error
+	if policy.GetMode() == config.RetentionModeAge {
+		archived, err = bs.s3Client.ArchiveBackupsOlderThan(prefix, time.Now().AddDate(0, 0, -policy.KeepDays), protected, policy.ArchiveStorageClass)
+	} else {
+		archived, err = bs.s3Client.ArchiveOldBackups(prefix, retentionCount, protected, policy.ArchiveStorageClass)
+	}
+	if err != nil {
+		fmt.Printf("Failed to archive old backups: %v\n", err)
+		return
+	}
+	if len(archived) == 0 {
+		return
+	}
+
+	keys := make(map[string]bool, len(archived))
+	for _, key := range archived {
+		keys[key] = true
+	}
+	for _, backup := range bs.backups {
+		if keys[backup.S3Key] {
+			backup.StorageClass = policy.ArchiveStorageClass
+		}
+	}
+	if err := bs.persistence.SaveSingleBackup(bs.backups); err != nil {
+		fmt.Printf("Warning: failed to save archived backups to disk: %v\n", err)
 	}
 }
 
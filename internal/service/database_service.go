@@ -1,14 +1,28 @@
 package service
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"evolution-postgres-backup/internal/config"
 	"evolution-postgres-backup/internal/database"
 	"evolution-postgres-backup/internal/models"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
+const (
+	instanceProbeTimeout     = 5 * time.Second        // Overall budget per instance
+	instanceProbeAttempts    = 3                      // Attempts before giving up
+	instanceProbeBackoff     = 250 * time.Millisecond // Initial delay, doubled after each failure
+	instanceProbeConcurrency = 5                      // Instances probed in parallel
+	s3ProbeTimeout           = 5 * time.Second        // HeadBucket budget of the health check
+)
+
 // DatabaseService integrates all PostgreSQL repositories and provides high-level operations
 type DatabaseService struct {
 	db           *database.DB
@@ -16,6 +30,9 @@ type DatabaseService struct {
 	postgresRepo *database.PostgreSQLRepository
 	logRepo      *database.LogRepository
 	migrationSvc *database.MigrationService
+
+	// s3Client is checked by HealthCheck when S3 is configured
+	s3Client *S3Client
 }
 
 // NewDatabaseService creates a new integrated database service
@@ -45,6 +62,16 @@ func NewDatabaseService() (*DatabaseService, error) {
 	}, nil
 }
 
+// SetS3Client makes HealthCheck report on the S3 bucket
+func (s *DatabaseService) SetS3Client(client *S3Client) {
+	s.s3Client = client
+}
+
+// S3Client returns the client set with SetS3Client, or nil without S3
+func (s *DatabaseService) S3Client() *S3Client {
+	return s.s3Client
+}
+
 // Close closes the database connection
 func (s *DatabaseService) Close() error {
 	return s.db.Close()
@@ -74,14 +101,136 @@ func (s *DatabaseService) CreatePostgreSQLInstance(instance *config.PostgreSQLCo
 		instance.ID = generateID()
 	}
 
+	if err := validateInstance(instance); err != nil {
+		return err
+	}
+
 	return s.postgresRepo.Create(instance)
 }
 
-// UpdatePostgreSQLInstance updates an existing PostgreSQL instance
+// UpdatePostgreSQLInstance updates an existing PostgreSQL instance. An empty
+// password keeps the stored one, so clients don't have to resend secrets they
+// never read back.
 func (s *DatabaseService) UpdatePostgreSQLInstance(instance *config.PostgreSQLConfig) error {
+	if err := validateInstance(instance); err != nil {
+		return err
+	}
+
+	existing, err := s.postgresRepo.GetByID(instance.ID)
+	if err != nil {
+		return err
+	}
+	if instance.Password == "" {
+		instance.Password = existing.Password
+	}
+
 	return s.postgresRepo.Update(instance)
 }
 
+// InstanceImportResult lists what ImportPostgreSQLInstances did with each instance, by ID
+type InstanceImportResult struct {
+	Created []string          `json:"created"`
+	Updated []string          `json:"updated"`
+	Skipped []string          `json:"skipped"`
+	Errors  map[string]string `json:"errors,omitempty"` // Keyed by ID, or name when the ID is missing
+}
+
+// ImportPostgreSQLInstances creates the given instances, and updates those whose
+// ID already exists when overwrite is set (they are skipped otherwise). New
+// instances need a password; updated ones keep theirs when it is empty, so a
+// masked export can be re-imported.
+func (s *DatabaseService) ImportPostgreSQLInstances(instances []*config.PostgreSQLConfig, overwrite bool) *InstanceImportResult {
+	result := &InstanceImportResult{
+		Created: []string{},
+		Updated: []string{},
+		Skipped: []string{},
+		Errors:  make(map[string]string),
+	}
+
+	for _, instance := range instances {
+		if instance == nil {
+			continue
+		}
+		key := instance.ID
+		if key == "" {
+			key = instance.Name
+		}
+
+		exists := false
+		if instance.ID != "" {
+			var err error
+			if exists, err = s.postgresRepo.Exists(instance.ID); err != nil {
+				result.Errors[key] = err.Error()
+				continue
+			}
+		}
+
+		switch {
+		case exists && !overwrite:
+			result.Skipped = append(result.Skipped, instance.ID)
+		case exists:
+			if err := s.UpdatePostgreSQLInstance(instance); err != nil {
+				result.Errors[key] = err.Error()
+				continue
+			}
+			result.Updated = append(result.Updated, instance.ID)
+		case instance.Password == "":
+			result.Errors[key] = "password is required for a new instance"
+		default:
+			if err := s.CreatePostgreSQLInstance(instance); err != nil {
+				result.Errors[key] = err.Error()
+				continue
+			}
+			result.Created = append(result.Created, instance.ID)
+		}
+	}
+
+	return result
+}
+
+// validateInstance checks the optional settings of an instance
+func validateInstance(instance *config.PostgreSQLConfig) error {
+	if instance.RetentionPolicy != nil {
+		if err := instance.RetentionPolicy.Validate(); err != nil {
+			return fmt.Errorf("invalid retention_policy: %w", err)
+		}
+	}
+	if err := instance.ValidateDumpOptions(); err != nil {
+		return fmt.Errorf("invalid dump_options: %w", err)
+	}
+	if err := instance.ValidateCompression(); err != nil {
+		return fmt.Errorf("invalid compression: %w", err)
+	}
+	if err := instance.ValidateTimeouts(); err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+	if err := instance.ValidateMaxBackupSize(); err != nil {
+		return fmt.Errorf("invalid max_backup_size_bytes: %w", err)
+	}
+	if err := instance.ValidateBackupTypes(); err != nil {
+		return fmt.Errorf("invalid backup_types: %w", err)
+	}
+	if err := instance.ValidatePooling(); err != nil {
+		return fmt.Errorf("invalid pooling settings: %w", err)
+	}
+	if err := instance.ValidateLabels(); err != nil {
+		return fmt.Errorf("invalid labels: %w", err)
+	}
+	if err := instance.ValidateHooks(); err != nil {
+		return fmt.Errorf("invalid hooks: %w", err)
+	}
+	if instance.TempDir != "" && !filepath.IsAbs(instance.TempDir) {
+		return fmt.Errorf("invalid temp_dir: %q must be an absolute path", instance.TempDir)
+	}
+	return nil
+}
+
+// SetPostgreSQLInstanceEnabled enables or disables scheduled backups of an
+// instance without touching its other settings
+func (s *DatabaseService) SetPostgreSQLInstanceEnabled(id string, enabled bool) error {
+	return s.postgresRepo.SetEnabled(id, enabled)
+}
+
 // DeletePostgreSQLInstance deletes a PostgreSQL instance
 func (s *DatabaseService) DeletePostgreSQLInstance(id string) error {
 	return s.postgresRepo.Delete(id)
@@ -99,6 +248,11 @@ func (s *DatabaseService) GetBackup(id string) (*models.BackupInfo, error) {
 	return s.backupRepo.GetByID(id)
 }
 
+// GetBackupFailureOutput returns the pg_dump output stored for a failed backup
+func (s *DatabaseService) GetBackupFailureOutput(id string) (string, error) {
+	return s.backupRepo.GetFailureOutput(id)
+}
+
 // CreateBackup creates a new backup record
 func (s *DatabaseService) CreateBackup(backup *models.BackupInfo) error {
 	return s.backupRepo.Create(backup)
@@ -109,11 +263,253 @@ func (s *DatabaseService) UpdateBackup(backup *models.BackupInfo) error {
 	return s.backupRepo.Update(backup)
 }
 
+// ErrBackupTrashed is returned when protecting a backup that is in the trash
+var ErrBackupTrashed = errors.New("backup is in the trash, restore it first")
+
+// SetBackupProtected protects a backup from retention cleanup, or lifts the
+// protection. Trashed backups can't be protected.
+func (s *DatabaseService) SetBackupProtected(id string, protected bool) (*models.BackupInfo, error) {
+	if err := s.backupRepo.SetProtected(id, protected); err != nil {
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+		if _, err := s.backupRepo.GetByID(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrBackupTrashed
+	}
+	return s.backupRepo.GetByID(id)
+}
+
+// ErrBackupNotTrashable is returned when deleting a protected or unfinished backup
+var ErrBackupNotTrashable = errors.New("backup can't be deleted")
+
+// ErrBackupNotTrashed is returned when restoring a backup that isn't in the trash
+var ErrBackupNotTrashed = errors.New("backup is not in the trash")
+
+// TrashBackup moves a backup to the trash, where it stays for
+// config.TrashRetention before the purge job deletes it. Deleting a backup
+// that is already in the trash returns it unchanged.
+func (s *DatabaseService) TrashBackup(id string) (*models.BackupInfo, error) {
+	err := s.backupRepo.Trash(id, time.Now().Add(config.TrashRetention()))
+	if err == sql.ErrNoRows {
+		backup, err := s.backupRepo.GetByID(id)
+		switch {
+		case err != nil:
+			return nil, err
+		case backup.Trashed():
+			return backup, nil
+		case backup.Protected:
+			return nil, fmt.Errorf("%w: it is protected, unprotect it first", ErrBackupNotTrashable)
+		default:
+			return nil, fmt.Errorf("%w: it is still %s", ErrBackupNotTrashable, backup.Status)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.backupRepo.GetByID(id)
+}
+
+// RestoreBackupFromTrash takes a backup out of the trash before it is purged
+func (s *DatabaseService) RestoreBackupFromTrash(id string) (*models.BackupInfo, error) {
+	if err := s.backupRepo.Untrash(id); err != nil {
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+		if _, err := s.backupRepo.GetByID(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrBackupNotTrashed
+	}
+	return s.backupRepo.GetByID(id)
+}
+
 // GetBackupsByInstance returns backups for a specific PostgreSQL instance
 func (s *DatabaseService) GetBackupsByInstance(postgresID string) ([]*models.BackupInfo, error) {
 	return s.backupRepo.GetByPostgreSQLID(postgresID)
 }
 
+// GetLatestBackupsByInstance returns the last successful backup of every database
+// on an instance, including databases that have never been backed up
+func (s *DatabaseService) GetLatestBackupsByInstance(postgresID string) ([]map[string]interface{}, error) {
+	instance, err := s.postgresRepo.GetByID(postgresID)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := s.backupRepo.GetLatestCompleted(postgresID)
+	if err != nil {
+		return nil, err
+	}
+
+	byDatabase := make(map[string]*models.BackupInfo, len(latest))
+	for _, backup := range latest {
+		byDatabase[backup.DatabaseName] = backup
+	}
+
+	now := time.Now()
+	results := make([]map[string]interface{}, 0, len(instance.GetDatabases()))
+	for _, dbName := range instance.GetDatabases() {
+		entry := map[string]interface{}{
+			"database_name":  dbName,
+			"backup":         nil,
+			"last_backup_at": nil,
+			"age_seconds":    nil,
+		}
+		if backup, ok := byDatabase[dbName]; ok {
+			lastBackupAt := backup.CreatedAt
+			if backup.EndTime != nil {
+				lastBackupAt = *backup.EndTime
+			}
+			entry["backup"] = backup
+			entry["last_backup_at"] = lastBackupAt
+			entry["age_seconds"] = int64(now.Sub(lastBackupAt).Seconds())
+			delete(byDatabase, dbName)
+		}
+		results = append(results, entry)
+	}
+
+	// Databases removed from the instance config but still holding backups
+	for dbName, backup := range byDatabase {
+		lastBackupAt := backup.CreatedAt
+		if backup.EndTime != nil {
+			lastBackupAt = *backup.EndTime
+		}
+		results = append(results, map[string]interface{}{
+			"database_name":  dbName,
+			"backup":         backup,
+			"last_backup_at": lastBackupAt,
+			"age_seconds":    int64(now.Sub(lastBackupAt).Seconds()),
+		})
+	}
+
+	return results, nil
+}
+
+// GetStorageByInstance returns the storage used by each instance's completed backups
+func (s *DatabaseService) GetStorageByInstance() ([]*database.InstanceStorage, error) {
+	return s.backupRepo.GetStorageByInstance()
+}
+
+// GetRetentionReport reports, per instance and database, the oldest and
+// newest completed backup, counts by type and total bytes, together with the
+// retention policy cleanup applies to the instance
+func (s *DatabaseService) GetRetentionReport() ([]map[string]interface{}, error) {
+	instances, err := s.postgresRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := s.backupRepo.GetRetentionSummary()
+	if err != nil {
+		return nil, err
+	}
+
+	byInstance := make(map[string][]*database.BackupTypeSummary)
+	for _, summary := range summaries {
+		byInstance[summary.PostgreSQLID] = append(byInstance[summary.PostgreSQLID], summary)
+	}
+
+	// The same policy cleanup applies: the instance's over the global one
+	globalPolicy := s.db.GlobalRetentionPolicy()
+
+	report := make([]map[string]interface{}, 0, len(instances))
+	for _, instance := range instances {
+		policySource := "global"
+		if instance.RetentionPolicy != nil {
+			policySource = "instance"
+		}
+
+		// Rows are ordered by database, so each database's types are adjacent
+		databases := make([]map[string]interface{}, 0)
+		rows := byInstance[instance.ID]
+		for start := 0; start < len(rows); {
+			end := start
+			countByType := make(map[string]int)
+			var count, protected int
+			var totalBytes int64
+			oldest, newest := rows[start].Oldest, rows[start].Newest
+			for end < len(rows) && rows[end].DatabaseName == rows[start].DatabaseName {
+				row := rows[end]
+				countByType[string(row.BackupType)] = row.Count
+				count += row.Count
+				protected += row.Protected
+				totalBytes += row.TotalBytes
+				if row.Oldest.Before(oldest) {
+					oldest = row.Oldest
+				}
+				if row.Newest.After(newest) {
+					newest = row.Newest
+				}
+				end++
+			}
+
+			databases = append(databases, map[string]interface{}{
+				"database_name": rows[start].DatabaseName,
+				"oldest_backup": oldest,
+				"newest_backup": newest,
+				"backup_count":  count,
+				"count_by_type": countByType,
+				"protected":     protected,
+				"total_bytes":   totalBytes,
+			})
+			start = end
+		}
+
+		report = append(report, map[string]interface{}{
+			"postgres_id":      instance.ID,
+			"name":             instance.Name,
+			"enabled":          instance.Enabled,
+			"retention_policy": instance.GetRetentionPolicy(globalPolicy),
+			"policy_source":    policySource,
+			"databases":        databases,
+		})
+	}
+
+	return report, nil
+}
+
+// GetDuplicateBackups groups completed backups sharing a checksum into clusters.
+// reclaimable_bytes counts every copy except the newest of each cluster.
+func (s *DatabaseService) GetDuplicateBackups(postgresID string) (map[string]interface{}, error) {
+	backups, err := s.backupRepo.GetDuplicates(postgresID)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := make([]map[string]interface{}, 0)
+	var totalReclaimable int64
+	for start := 0; start < len(backups); {
+		end := start
+		var clusterSize int64
+		for end < len(backups) && backups[end].Checksum == backups[start].Checksum {
+			clusterSize += backups[end].FileSize
+			end++
+		}
+
+		group := backups[start:end]
+		newest := group[len(group)-1] // Rows are ordered oldest first
+		reclaimable := clusterSize - newest.FileSize
+		totalReclaimable += reclaimable
+
+		clusters = append(clusters, map[string]interface{}{
+			"checksum":          group[0].Checksum,
+			"count":             len(group),
+			"total_size":        clusterSize,
+			"reclaimable_bytes": reclaimable,
+			"newest_backup_id":  newest.ID,
+			"backups":           group,
+		})
+		start = end
+	}
+
+	return map[string]interface{}{
+		"clusters":          clusters,
+		"cluster_count":     len(clusters),
+		"reclaimable_bytes": totalReclaimable,
+	}, nil
+}
+
 // GetBackupsByStatus returns backups with a specific status
 func (s *DatabaseService) GetBackupsByStatus(status models.BackupStatus) ([]*models.BackupInfo, error) {
 	return s.backupRepo.GetByStatus(status)
@@ -129,6 +525,35 @@ func (s *DatabaseService) DeleteOldBackups(postgresID string, backupType models.
 	return s.backupRepo.DeleteOldBackups(postgresID, backupType, olderThan)
 }
 
+// ==================== Restore History ====================
+
+// GetRestores returns restore audit records, newest first
+func (s *DatabaseService) GetRestores(filters database.RestoreFilters) ([]*database.RestoreRecord, error) {
+	return database.NewRestoreRepository(s.db).GetFiltered(filters)
+}
+
+// ==================== One-off Schedules ====================
+
+// CreateOneOffSchedule saves a one-off backup schedule for the worker's
+// scheduler to fire. It returns sql.ErrNoRows when the instance doesn't exist.
+func (s *DatabaseService) CreateOneOffSchedule(schedule *database.Schedule) error {
+	if _, err := s.postgresRepo.GetByID(schedule.PostgreSQLID); err != nil {
+		return err
+	}
+	schedule.ID = config.NewID("schedule")
+	return database.NewScheduleRepository(s.db).CreateOneOff(schedule)
+}
+
+// GetOneOffSchedules returns one-off schedules, pending ones first
+func (s *DatabaseService) GetOneOffSchedules(pendingOnly bool) ([]*database.Schedule, error) {
+	return database.NewScheduleRepository(s.db).GetOneOff(pendingOnly)
+}
+
+// CancelOneOffSchedule deletes a one-off schedule that hasn't fired yet
+func (s *DatabaseService) CancelOneOffSchedule(id string) error {
+	return database.NewScheduleRepository(s.db).DeletePendingOneOff(id)
+}
+
 // ==================== Log Management ====================
 
 // GetLogs returns logs with filters
@@ -136,6 +561,11 @@ func (s *DatabaseService) GetLogs(filters database.LogFilters) ([]*database.LogE
 	return s.logRepo.GetFiltered(filters)
 }
 
+// StreamLogs calls fn for every log matching filters, oldest first
+func (s *DatabaseService) StreamLogs(filters database.LogFilters, fn func(*database.LogEntry) error) error {
+	return s.logRepo.StreamFiltered(filters, fn)
+}
+
 // GetLogsByJobID returns logs for a specific job
 func (s *DatabaseService) GetLogsByJobID(jobID string) ([]*database.LogEntry, error) {
 	return s.logRepo.GetByJobID(jobID)
@@ -195,9 +625,81 @@ func (s *DatabaseService) GetDashboardStats() (map[string]interface{}, error) {
 	}
 	stats["database"] = dbStats
 
+	// Backup age per enabled database
+	backupAge, err := s.GetBackupAges(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	stats["backup_age"] = backupAge
+
 	return stats, nil
 }
 
+// backupOverdueGrace is how long past its expected interval a backup may be
+// late, so a dump that is still running doesn't flag its database
+const backupOverdueGrace = time.Hour
+
+// DatabaseBackupAge is how long ago a database's newest completed backup finished
+type DatabaseBackupAge struct {
+	PostgresID       string     `json:"postgres_id"`
+	InstanceName     string     `json:"instance_name"`
+	Database         string     `json:"database"`
+	LastBackupAt     *time.Time `json:"last_backup_at"`              // nil when it was never backed up
+	AgeSeconds       *int64     `json:"age_seconds"`                 // nil when it was never backed up
+	ExpectedInterval string     `json:"expected_interval,omitempty"` // Empty when no scheduled backups run
+	Overdue          bool       `json:"overdue"`
+}
+
+// BackupAgeReport lists the backup age of every database of the enabled instances
+type BackupAgeReport struct {
+	Databases []*DatabaseBackupAge `json:"databases"`
+	Overdue   int                  `json:"overdue"`
+}
+
+// GetBackupAges reports when each database the scheduler backs up was last
+// backed up. A database is overdue when it has scheduled backups and none
+// completed within its expected interval plus backupOverdueGrace, or never.
+func (s *DatabaseService) GetBackupAges(now time.Time) (*BackupAgeReport, error) {
+	instances, err := s.postgresRepo.GetEnabled()
+	if err != nil {
+		return nil, err
+	}
+	lastCompleted, err := s.backupRepo.GetLastCompletedTimes()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BackupAgeReport{Databases: []*DatabaseBackupAge{}}
+	for _, instance := range instances {
+		for _, databaseName := range instance.ScheduledDatabases() {
+			age := &DatabaseBackupAge{
+				PostgresID:   instance.ID,
+				InstanceName: instance.Name,
+				Database:     databaseName,
+			}
+			interval := instance.ExpectedBackupInterval(databaseName)
+			if interval > 0 {
+				age.ExpectedInterval = interval.String()
+			}
+
+			if last, ok := lastCompleted[instance.ID][databaseName]; ok {
+				seconds := int64(now.Sub(last).Seconds())
+				age.LastBackupAt = &last
+				age.AgeSeconds = &seconds
+				age.Overdue = interval > 0 && now.Sub(last) > interval+backupOverdueGrace
+			} else {
+				age.Overdue = interval > 0
+			}
+
+			if age.Overdue {
+				report.Overdue++
+			}
+			report.Databases = append(report.Databases, age)
+		}
+	}
+	return report, nil
+}
+
 // GetBackupTrends returns backup trends over time
 func (s *DatabaseService) GetBackupTrends(days int) (map[string]interface{}, error) {
 	// This would be implemented with more complex SQL queries
@@ -223,6 +725,22 @@ func (s *DatabaseService) HealthCheck() map[string]interface{} {
 		}
 	}
 
+	// Object storage, when configured
+	if s.s3Client != nil {
+		if err := s.s3Client.CheckBucket(s3ProbeTimeout); err != nil {
+			health["s3"] = map[string]interface{}{
+				"status": "unhealthy",
+				"bucket": s.s3Client.Bucket(),
+				"error":  err.Error(),
+			}
+		} else {
+			health["s3"] = map[string]interface{}{
+				"status": "healthy",
+				"bucket": s.s3Client.Bucket(),
+			}
+		}
+	}
+
 	// PostgreSQL instances
 	instances, err := s.postgresRepo.GetEnabled()
 	if err != nil {
@@ -231,9 +749,25 @@ func (s *DatabaseService) HealthCheck() map[string]interface{} {
 			"error":  err.Error(),
 		}
 	} else {
+		results := s.probeInstances(instances)
+
+		reachable := 0
+		for _, result := range results {
+			if result["reachable"] == true {
+				reachable++
+			}
+		}
+
+		status := "healthy"
+		if reachable < len(instances) {
+			status = "degraded"
+		}
+
 		health["postgresql_instances"] = map[string]interface{}{
-			"status": "healthy",
-			"count":  len(instances),
+			"status":    status,
+			"count":     len(instances),
+			"reachable": reachable,
+			"instances": results,
 		}
 	}
 
@@ -254,18 +788,144 @@ func (s *DatabaseService) HealthCheck() map[string]interface{} {
 	return health
 }
 
+// probeInstances checks connectivity to each instance with bounded concurrency
+func (s *DatabaseService) probeInstances(instances []*config.PostgreSQLConfig) []map[string]interface{} {
+	results := make([]map[string]interface{}, len(instances))
+	sem := make(chan struct{}, instanceProbeConcurrency)
+	var wg sync.WaitGroup
+
+	for i, instance := range instances {
+		wg.Add(1)
+		go func(i int, instance *config.PostgreSQLConfig) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			err := probeInstance(instance)
+
+			result := map[string]interface{}{
+				"id":         instance.ID,
+				"name":       instance.Name,
+				"reachable":  err == nil,
+				"latency_ms": time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				result["error"] = err.Error()
+			}
+			results[i] = result
+		}(i, instance)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// probeInstance runs SELECT 1 against an instance, retrying with exponential backoff
+func probeInstance(instance *config.PostgreSQLConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), instanceProbeTimeout)
+	defer cancel()
+
+	instance, cleanupSSL, err := instance.WithSSLFiles(filepath.Join(os.TempDir(), "postgres-backup-ssl"))
+	if err != nil {
+		return err
+	}
+	defer cleanupSSL()
+
+	connStr := instance.ConnectionString(instance.GetDefaultDatabase(), int(instanceProbeTimeout.Seconds()))
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	backoff := instanceProbeBackoff
+	for attempt := 1; ; attempt++ {
+		var one int
+		err = db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+		if err == nil || attempt == instanceProbeAttempts {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
 // ==================== Migration Support ====================
 
+// MaintenanceReport is the outcome of RunMaintenance
+type MaintenanceReport struct {
+	LogRetentionDays int                          `json:"log_retention_days"` // 0 = logs were not pruned
+	PrunedLogs       int64                        `json:"pruned_logs"`
+	Tables           []*database.TableMaintenance `json:"tables"`
+	Success          bool                         `json:"success"`
+}
+
+// RunMaintenance deletes logs older than logRetentionDays (0 keeps them all),
+// then runs VACUUM ANALYZE on the service tables so the freed space is reused
+// and the planner statistics match the pruned tables
+func (s *DatabaseService) RunMaintenance(logRetentionDays int) (*MaintenanceReport, error) {
+	report := &MaintenanceReport{LogRetentionDays: logRetentionDays, Success: true}
+
+	if logRetentionDays > 0 {
+		pruned, err := s.logRepo.DeleteOldLogs(time.Duration(logRetentionDays) * 24 * time.Hour)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prune logs: %w", err)
+		}
+		report.PrunedLogs = pruned
+	}
+
+	for _, table := range database.MaintenanceTables {
+		result := s.db.VacuumAnalyze(table)
+		if result.Error != "" {
+			report.Success = false
+		}
+		report.Tables = append(report.Tables, result)
+	}
+
+	return report, nil
+}
+
 // GetMigrationStatus returns migration status
 func (s *DatabaseService) GetMigrationStatus() (map[string]interface{}, error) {
 	return s.migrationSvc.GetMigrationStatus()
 }
 
+// MigrationNeeded reports whether JSON data is waiting to be migrated
+func (s *DatabaseService) MigrationNeeded() (bool, error) {
+	return s.migrationSvc.MigrationNeeded()
+}
+
 // PerformMigration performs migration from JSON to SQLite
 func (s *DatabaseService) PerformMigration() error {
 	return s.migrationSvc.MigrateAll()
 }
 
+// StartMigration runs the migration in the background, see GetMigrationStatus for progress
+func (s *DatabaseService) StartMigration() (*database.MigrationRun, error) {
+	return s.migrationSvc.StartMigration()
+}
+
+// AbortMigration stops the background migration, keeping what it migrated
+func (s *DatabaseService) AbortMigration() (*database.MigrationRun, error) {
+	return s.migrationSvc.AbortMigration()
+}
+
+// ListJSONBackups returns the JSON files copied aside before migrations
+func (s *DatabaseService) ListJSONBackups() ([]*database.JSONBackup, error) {
+	return s.migrationSvc.ListJSONBackups()
+}
+
+// WriteJSONBackupArchive writes a pre-migration JSON backup to w as a .tar.gz
+func (s *DatabaseService) WriteJSONBackupArchive(name string, w io.Writer) error {
+	return s.migrationSvc.WriteJSONBackupArchive(name, w)
+}
+
 // ==================== Utility Functions ====================
 
 // GetDB returns the underlying database connection (for worker integration)
@@ -274,5 +934,5 @@ func (s *DatabaseService) GetDB() *database.DB {
 }
 
 func generateID() string {
-	return fmt.Sprintf("pg-%d", time.Now().Unix())
+	return config.NewID("pg")
 }
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"evolution-postgres-backup/internal/config"
+	"evolution-postgres-backup/internal/logger"
+	"evolution-postgres-backup/internal/models"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// replicaAttempts is how many times an upload to a secondary bucket is tried
+const replicaAttempts = 4
+
+// replicaRetryDelay is the wait before the first retry of a secondary upload,
+// doubled after each failure
+const replicaRetryDelay = time.Minute
+
+// replicaStore is a secondary bucket backups are copied to
+type replicaStore struct {
+	name   string
+	client *S3Client
+}
+
+// newReplicaStores connects to the replicas configured in cfg. A replica whose
+// bucket can't be reached at startup is kept, so each backup retries it and
+// records the failure instead of skipping it silently.
+func newReplicaStores(cfg *config.Config) []*replicaStore {
+	var stores []*replicaStore
+	for _, destination := range cfg.S3Config.Replicas {
+		client := NewS3Client()
+		if err := client.Initialize(&config.Config{S3Config: cfg.S3Config.ReplicaConfig(destination)}); err != nil {
+			if client.uploader == nil {
+				fmt.Printf("Warning: S3 replica %s disabled: %v\n", destination.DestinationName(), err)
+				continue
+			}
+			fmt.Printf("Warning: S3 replica %s: %v\n", destination.DestinationName(), err)
+		}
+		stores = append(stores, &replicaStore{name: destination.DestinationName(), client: client})
+	}
+	return stores
+}
+
+// pendingReplicas returns one pending upload per secondary bucket
+func (bs *BackupService) pendingReplicas() []models.ReplicaUpload {
+	replicas := make([]models.ReplicaUpload, len(bs.replicas))
+	for i, store := range bs.replicas {
+		replicas[i] = models.ReplicaUpload{
+			Destination: store.name,
+			Bucket:      store.client.Bucket(),
+			Status:      models.ReplicaStatusPending,
+		}
+	}
+	return replicas
+}
+
+// replicateBackup copies an uploaded backup to every secondary bucket whose
+// upload is still pending, in parallel, then releases the local file. A
+// failing bucket is retried on its own and never changes the backup's status.
+func (bs *BackupService) replicateBackup(jobID string, backupInfo *models.BackupInfo, localPath string) {
+	var wg sync.WaitGroup
+	for i := range backupInfo.Replicas {
+		if backupInfo.Replicas[i].Status != models.ReplicaStatusPending {
+			continue
+		}
+		store := bs.replicaStore(backupInfo.Replicas[i].Destination)
+		if store == nil {
+			bs.replicaMu.Lock()
+			backupInfo.Replicas[i].Status = models.ReplicaStatusFailed
+			backupInfo.Replicas[i].Error = "replica is no longer configured"
+			backupInfo.Replicas[i].NextAttemptAt = nil
+			bs.replicaMu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(i int, store *replicaStore) {
+			defer wg.Done()
+			bs.uploadReplica(jobID, backupInfo, i, store, localPath)
+		}(i, store)
+	}
+	wg.Wait()
+
+	bs.replicaMu.Lock()
+	defer bs.replicaMu.Unlock()
+	bs.releaseLocalFile(jobID, backupInfo, localPath)
+	if err := bs.persistence.SaveSingleBackup(bs.backups); err != nil {
+		logger.GetLogger().LogJobProgress(jobID, "Warning: failed to save replica status to disk: %v", err)
+	}
+}
+
+// replicaStore returns the configured secondary bucket with the given name
+func (bs *BackupService) replicaStore(name string) *replicaStore {
+	for _, store := range bs.replicas {
+		if store.name == name {
+			return store
+		}
+	}
+	return nil
+}
+
+// resumeReplication picks up the replica uploads a previous run left pending,
+// keeping their attempt count and next retry time. Uploads whose local file
+// is gone can't be resumed and are marked failed, which also lets
+// pruneLocalCopies manage the backup again.
+func (bs *BackupService) resumeReplication() {
+	log := logger.GetLogger()
+	for _, backupInfo := range bs.backups {
+		if !backupInfo.ReplicationPending() {
+			continue
+		}
+		jobID := "replica-" + backupInfo.ID
+		if backupInfo.FilePath == "" {
+			bs.failPendingReplicas(backupInfo, "local file was released before the upload finished")
+			continue
+		}
+		if _, err := os.Stat(backupInfo.FilePath); err != nil {
+			bs.failPendingReplicas(backupInfo, fmt.Sprintf("local file is no longer available: %v", err))
+			continue
+		}
+		log.LogJobProgress(jobID, "Resuming replica uploads of backup %s", backupInfo.ID)
+		go bs.replicateBackup(jobID, backupInfo, backupInfo.FilePath)
+	}
+
+	bs.replicaMu.Lock()
+	defer bs.replicaMu.Unlock()
+	if err := bs.persistence.SaveSingleBackup(bs.backups); err != nil {
+		fmt.Printf("Warning: failed to save replica status to disk: %v\n", err)
+	}
+}
+
+// failPendingReplicas gives up on every pending replica upload of a backup
+func (bs *BackupService) failPendingReplicas(backupInfo *models.BackupInfo, reason string) {
+	bs.replicaMu.Lock()
+	defer bs.replicaMu.Unlock()
+	for i := range backupInfo.Replicas {
+		if replica := &backupInfo.Replicas[i]; replica.Status == models.ReplicaStatusPending {
+			replica.Status = models.ReplicaStatusFailed
+			replica.Error = reason
+			replica.NextAttemptAt = nil
+		}
+	}
+}
+
+// uploadReplica uploads a backup to one secondary bucket, retrying with
+// backoff, and records each attempt in backupInfo.Replicas[i]. The next retry
+// time is saved with the backup, so a resumed upload waits out the remaining
+// delay and continues counting attempts where it stopped.
+func (bs *BackupService) uploadReplica(jobID string, backupInfo *models.BackupInfo, i int, store *replicaStore, localPath string) {
+	log := logger.GetLogger()
+
+	bs.replicaMu.Lock()
+	attempt := backupInfo.Replicas[i].Attempts
+	var wait time.Duration
+	if next := backupInfo.Replicas[i].NextAttemptAt; next != nil {
+		wait = time.Until(*next)
+	}
+	bs.replicaMu.Unlock()
+
+	for {
+		if wait > 0 {
+			time.Sleep(wait)
+		}
+		attempt++
+		err := store.client.UploadFile(localPath, backupInfo.S3Key)
+
+		bs.replicaMu.Lock()
+		replica := &backupInfo.Replicas[i]
+		replica.Attempts = attempt
+		replica.NextAttemptAt = nil
+		if err == nil {
+			uploadedAt := time.Now()
+			replica.Status = models.ReplicaStatusCompleted
+			replica.Error = ""
+			replica.UploadedAt = &uploadedAt
+		} else {
+			replica.Error = err.Error()
+			if attempt >= replicaAttempts {
+				replica.Status = models.ReplicaStatusFailed
+			} else {
+				wait = replicaRetryDelay << (attempt - 1)
+				next := time.Now().Add(wait)
+				replica.NextAttemptAt = &next
+			}
+		}
+		status := replica.Status
+		if saveErr := bs.persistence.SaveSingleBackup(bs.backups); saveErr != nil {
+			log.LogJobProgress(jobID, "Warning: failed to save replica status to disk: %v", saveErr)
+		}
+		bs.replicaMu.Unlock()
+
+		switch {
+		case err == nil:
+			log.LogJobProgress(jobID, "Replica %s upload completed", store.name)
+			return
+		case status == models.ReplicaStatusFailed:
+			log.LogJobError(jobID, "Replica %s upload failed after %d attempts: %v", store.name, attempt, err)
+			return
+		}
+
+		log.LogJobProgress(jobID, "Replica %s upload failed (attempt %d/%d), retrying in %v: %v", store.name, attempt, replicaAttempts, wait, err)
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"evolution-postgres-backup/internal/logger"
+	"evolution-postgres-backup/internal/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestBackupService(t *testing.T) *BackupService {
+	t.Helper()
+	if err := logger.InitLogger(t.TempDir()); err != nil {
+		t.Fatalf("InitLogger: %v", err)
+	}
+	return &BackupService{
+		backups:     make(map[string]*models.BackupInfo),
+		persistence: NewBackupPersistence(t.TempDir()),
+	}
+}
+
+func TestResumeReplicationFailsUploadsWithoutLocalFile(t *testing.T) {
+	bs := newTestBackupService(t)
+	next := time.Now().Add(time.Minute)
+	bs.backups["b1"] = &models.BackupInfo{
+		ID:       "b1",
+		Status:   models.BackupStatusCompleted,
+		FilePath: filepath.Join(t.TempDir(), "missing.sql"),
+		Replicas: []models.ReplicaUpload{{Destination: "dr", Status: models.ReplicaStatusPending, Attempts: 2, NextAttemptAt: &next}},
+	}
+
+	bs.resumeReplication()
+
+	backup := bs.backups["b1"]
+	if backup.ReplicationPending() {
+		t.Fatal("replica still pending after resume without a local file")
+	}
+	if replica := backup.Replicas[0]; replica.Status != models.ReplicaStatusFailed || replica.NextAttemptAt != nil {
+		t.Errorf("replica = %+v, want failed without a next attempt", replica)
+	}
+
+	saved, err := bs.persistence.LoadBackups()
+	if err != nil {
+		t.Fatalf("LoadBackups: %v", err)
+	}
+	if saved["b1"].ReplicationPending() {
+		t.Error("pending replica state was not saved")
+	}
+}
+
+func TestReplicateBackupReleasesFileOfRemovedReplica(t *testing.T) {
+	bs := newTestBackupService(t)
+	localPath := filepath.Join(t.TempDir(), "backup.sql")
+	if err := os.WriteFile(localPath, []byte("dump"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	backup := &models.BackupInfo{
+		ID:       "b1",
+		Status:   models.BackupStatusCompleted,
+		FilePath: localPath,
+		Replicas: []models.ReplicaUpload{{Destination: "removed", Status: models.ReplicaStatusPending}},
+	}
+	bs.backups["b1"] = backup
+
+	bs.replicateBackup("job", backup, localPath)
+
+	if backup.Replicas[0].Status != models.ReplicaStatusFailed {
+		t.Errorf("replica status = %s, want failed", backup.Replicas[0].Status)
+	}
+	if _, err := os.Stat(localPath); !os.IsNotExist(err) {
+		t.Errorf("local file not released: %v", err)
+	}
+}
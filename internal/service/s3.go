@@ -1,13 +1,17 @@
 package service
 
 import (
+	"context"
 	"evolution-postgres-backup/internal/config"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -19,6 +23,9 @@ type S3Client struct {
 	uploader *s3manager.Uploader
 	session  *session.Session
 	bucket   string
+
+	// uploadSlots limits concurrent uploads when MaxConcurrentUploads is set
+	uploadSlots chan struct{}
 }
 
 func NewS3Client() *S3Client {
@@ -50,11 +57,17 @@ func (s *S3Client) Initialize(cfg *config.Config) error {
 	s.uploader = s3manager.NewUploader(sess)
 	s.session = sess
 	s.bucket = cfg.S3Config.Bucket
+	if cfg.S3Config.MaxConcurrentUploads > 0 {
+		s.uploadSlots = make(chan struct{}, cfg.S3Config.MaxConcurrentUploads)
+	}
 
 	// Test connection
 	_, err = s.client.HeadBucket(&s3.HeadBucketInput{
 		Bucket: aws.String(s.bucket),
 	})
+	if err != nil && cfg.S3Config.CreateBucket && isBucketNotFound(err) {
+		err = s.createBucket(cfg.S3Config.Region)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to access S3 bucket '%s': %w", s.bucket, err)
 	}
@@ -63,7 +76,71 @@ func (s *S3Client) Initialize(cfg *config.Config) error {
 	return nil
 }
 
+// isBucketNotFound reports whether HeadBucket failed because the bucket doesn't exist
+func isBucketNotFound(err error) bool {
+	if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+		return true
+	}
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() == "NotFound" || awsErr.Code() == s3.ErrCodeNoSuchBucket
+	}
+	return false
+}
+
+// createBucket creates the configured bucket, for S3_CREATE_BUCKET
+func (s *S3Client) createBucket(region string) error {
+	input := &s3.CreateBucketInput{Bucket: aws.String(s.bucket)}
+	// us-east-1 is the default location and must not be named
+	if region != "" && region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{LocationConstraint: aws.String(region)}
+	}
+	if _, err := s.client.CreateBucket(input); err != nil {
+		return fmt.Errorf("bucket does not exist and creating it failed: %w", err)
+	}
+	log.Printf("Created S3 bucket %s (S3_CREATE_BUCKET)", s.bucket)
+	return nil
+}
+
+// CheckBucket checks that the bucket is reachable with the configured
+// credentials, with a HeadBucket request bounded by timeout
+func (s *S3Client) CheckBucket(timeout time.Duration) error {
+	if s.client == nil {
+		return fmt.Errorf("S3 client is not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err := s.client.HeadBucketWithContext(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
+	})
+	if err != nil && isBucketNotFound(err) {
+		return fmt.Errorf("S3 bucket '%s' does not exist (on MinIO or self-hosted storage, S3_CREATE_BUCKET=true creates it at startup): %w", s.bucket, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to access S3 bucket '%s': %w", s.bucket, err)
+	}
+	return nil
+}
+
+// Bucket returns the configured bucket name
+func (s *S3Client) Bucket() string {
+	return s.bucket
+}
+
+// UploadFile uploads a local file, first waiting for an upload slot when
+// concurrent uploads are limited
 func (s *S3Client) UploadFile(filePath, s3Key string) error {
+	if s.uploadSlots != nil {
+		select {
+		case s.uploadSlots <- struct{}{}:
+		default:
+			log.Printf("Waiting for an upload slot (%d in use) to upload %s", cap(s.uploadSlots), s3Key)
+			s.uploadSlots <- struct{}{}
+		}
+		defer func() { <-s.uploadSlots }()
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file %s: %w", filePath, err)
@@ -117,15 +194,20 @@ func (s *S3Client) DeleteFile(s3Key string) error {
 }
 
 func (s *S3Client) ListFiles(prefix string) ([]*s3.Object, error) {
-	result, err := s.client.ListObjectsV2(&s3.ListObjectsV2Input{
+	// Follow continuation tokens; a single response stops at 1000 keys
+	var objects []*s3.Object
+	err := s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
 		Bucket: aws.String(s.bucket),
 		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		objects = append(objects, page.Contents...)
+		return true
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list files from S3: %w", err)
 	}
 
-	return result.Contents, nil
+	return objects, nil
 }
 
 func (s *S3Client) GetFileSize(s3Key string) (int64, error) {
@@ -148,21 +230,57 @@ func (s *S3Client) FileExists(s3Key string) bool {
 	return err == nil
 }
 
-func (s *S3Client) CleanupOldBackups(prefix string, retentionCount int) error {
-	objects, err := s.ListFiles(prefix)
+// oldBackups returns the objects under prefix beyond the newest retentionCount.
+// Keys in protected are never returned and don't count towards retentionCount.
+func (s *S3Client) oldBackups(prefix string, retentionCount int, protected map[string]bool) ([]*s3.Object, error) {
+	listed, err := s.ListFiles(prefix)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var objects []*s3.Object
+	for _, obj := range listed {
+		if !protected[*obj.Key] {
+			objects = append(objects, obj)
+		}
 	}
 
 	if len(objects) <= retentionCount {
-		return nil
+		return nil, nil
 	}
 
 	// Sort by last modified (oldest first)
 	// AWS SDK already returns objects sorted by key name, which should be chronological
 	// for our backup naming scheme
 
-	objectsToDelete := objects[:len(objects)-retentionCount]
+	return objects[:len(objects)-retentionCount], nil
+}
+
+// backupsOlderThan returns the objects under prefix last modified before
+// cutoff, except keys in protected
+func (s *S3Client) backupsOlderThan(prefix string, cutoff time.Time, protected map[string]bool) ([]*s3.Object, error) {
+	listed, err := s.ListFiles(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*s3.Object
+	for _, obj := range listed {
+		if obj.LastModified == nil || !obj.LastModified.Before(cutoff) || protected[*obj.Key] {
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// CleanupOldBackups keeps the newest retentionCount objects under prefix. Keys
+// in protected are never deleted and don't count towards retentionCount.
+func (s *S3Client) CleanupOldBackups(prefix string, retentionCount int, protected map[string]bool) error {
+	objectsToDelete, err := s.oldBackups(prefix, retentionCount, protected)
+	if err != nil {
+		return err
+	}
 
 	for _, obj := range objectsToDelete {
 		if err := s.DeleteFile(*obj.Key); err != nil {
@@ -174,10 +292,218 @@ func (s *S3Client) CleanupOldBackups(prefix string, retentionCount int) error {
 	return nil
 }
 
-func GenerateS3Key(postgresID, backupType, timestamp, filename string) string {
-	// Format: backups/{postgres_id}/{backup_type}/{year}/{month}/{filename}
-	parts := strings.Split(timestamp, "-")
-	year, month := parts[0], parts[1]
+// CleanupBackupsOlderThan deletes every object under prefix last modified before
+// cutoff, except keys in protected
+func (s *S3Client) CleanupBackupsOlderThan(prefix string, cutoff time.Time, protected map[string]bool) error {
+	objects, err := s.backupsOlderThan(prefix, cutoff, protected)
+	if err != nil {
+		return err
+	}
+
+	deleted := 0
+	for _, obj := range objects {
+		if err := s.DeleteFile(*obj.Key); err != nil {
+			log.Printf("Failed to delete old backup %s: %v", *obj.Key, err)
+			continue
+		}
+		deleted++
+	}
+
+	log.Printf("Cleaned up %d backups older than %s with prefix %s", deleted, cutoff.Format(time.RFC3339), prefix)
+	return nil
+}
+
+// maxCopyObjectSize is the largest object a single CopyObject call copies;
+// larger objects are copied in parts
+const maxCopyObjectSize = 5 * 1024 * 1024 * 1024
+
+// copyPartSize is the size of each part of a multipart copy. At 512 MiB the
+// 10,000-part limit allows objects up to S3's 5 TB maximum.
+const copyPartSize = 512 * 1024 * 1024
+
+// SetStorageClass moves an object to another storage class by copying it
+// onto itself, keeping its metadata. Objects over 5 GB are copied in parts.
+func (s *S3Client) SetStorageClass(s3Key, storageClass string) error {
+	head, err := s.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s3Key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get object info from S3: %w", err)
+	}
+
+	if aws.Int64Value(head.ContentLength) > maxCopyObjectSize {
+		err = s.copyObjectInParts(s3Key, storageClass, head)
+	} else {
+		_, err = s.client.CopyObject(&s3.CopyObjectInput{
+			Bucket:            aws.String(s.bucket),
+			Key:               aws.String(s3Key),
+			CopySource:        aws.String(s.bucket + "/" + s3Key),
+			StorageClass:      aws.String(storageClass),
+			MetadataDirective: aws.String(s3.MetadataDirectiveCopy),
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set storage class of %s to %s: %w", s3Key, storageClass, err)
+	}
+
+	log.Printf("Moved %s to storage class %s", s3Key, storageClass)
+	return nil
+}
+
+// copyObjectInParts copies an object onto itself in storageClass with a
+// multipart upload of UploadPartCopy parts, carrying over the metadata in head.
+// A failed copy is aborted so its parts aren't billed.
+func (s *S3Client) copyObjectInParts(s3Key, storageClass string, head *s3.HeadObjectOutput) error {
+	upload, err := s.client.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(s.bucket),
+		Key:                aws.String(s3Key),
+		StorageClass:       aws.String(storageClass),
+		Metadata:           head.Metadata,
+		ContentType:        head.ContentType,
+		ContentEncoding:    head.ContentEncoding,
+		ContentDisposition: head.ContentDisposition,
+		CacheControl:       head.CacheControl,
+	})
+	if err != nil {
+		return err
+	}
+
+	var parts []*s3.CompletedPart
+	for i, byteRange := range copyPartRanges(aws.Int64Value(head.ContentLength), copyPartSize) {
+		partNumber := aws.Int64(int64(i + 1))
+		part, err := s.client.UploadPartCopy(&s3.UploadPartCopyInput{
+			Bucket:            aws.String(s.bucket),
+			Key:               aws.String(s3Key),
+			UploadId:          upload.UploadId,
+			PartNumber:        partNumber,
+			CopySource:        aws.String(s.bucket + "/" + s3Key),
+			CopySourceRange:   aws.String(byteRange),
+			CopySourceIfMatch: head.ETag,
+		})
+		if err != nil {
+			s.abortMultipartUpload(s3Key, upload.UploadId)
+			return fmt.Errorf("failed to copy part %d: %w", *partNumber, err)
+		}
+		parts = append(parts, &s3.CompletedPart{ETag: part.CopyPartResult.ETag, PartNumber: partNumber})
+	}
+
+	_, err = s.client.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(s3Key),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		s.abortMultipartUpload(s3Key, upload.UploadId)
+		return err
+	}
+	return nil
+}
+
+// abortMultipartUpload discards the parts of an unfinished multipart upload
+func (s *S3Client) abortMultipartUpload(s3Key string, uploadID *string) {
+	_, err := s.client.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(s3Key),
+		UploadId: uploadID,
+	})
+	if err != nil {
+		log.Printf("Failed to abort multipart copy of %s: %v", s3Key, err)
+	}
+}
+
+// copyPartRanges splits an object of size bytes into the CopySourceRange
+// values ("bytes=first-last") of parts of at most partSize bytes
+func copyPartRanges(size, partSize int64) []string {
+	var ranges []string
+	for start := int64(0); start < size; start += partSize {
+		end := start + partSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		ranges = append(ranges, fmt.Sprintf("bytes=%d-%d", start, end))
+	}
+	return ranges
+}
+
+// archiveObjects moves objects not yet in storageClass to it and returns the
+// keys that were moved
+func (s *S3Client) archiveObjects(objects []*s3.Object, storageClass string) []string {
+	var archived []string
+	for _, obj := range objects {
+		if aws.StringValue(obj.StorageClass) == storageClass {
+			continue
+		}
+		if err := s.SetStorageClass(*obj.Key, storageClass); err != nil {
+			log.Printf("Failed to archive old backup %s: %v", *obj.Key, err)
+			continue
+		}
+		archived = append(archived, *obj.Key)
+	}
+	return archived
+}
+
+// ArchiveOldBackups is CleanupOldBackups that moves the backups beyond
+// retentionCount to storageClass instead of deleting them. It returns the
+// keys whose storage class changed.
+func (s *S3Client) ArchiveOldBackups(prefix string, retentionCount int, protected map[string]bool, storageClass string) ([]string, error) {
+	objects, err := s.oldBackups(prefix, retentionCount, protected)
+	if err != nil {
+		return nil, err
+	}
+
+	archived := s.archiveObjects(objects, storageClass)
+	log.Printf("Archived %d old backups with prefix %s to %s", len(archived), prefix, storageClass)
+	return archived, nil
+}
+
+// ArchiveBackupsOlderThan is CleanupBackupsOlderThan that moves the backups to
+// storageClass instead of deleting them. It returns the keys whose storage
+// class changed.
+func (s *S3Client) ArchiveBackupsOlderThan(prefix string, cutoff time.Time, protected map[string]bool, storageClass string) ([]string, error) {
+	objects, err := s.backupsOlderThan(prefix, cutoff, protected)
+	if err != nil {
+		return nil, err
+	}
+
+	archived := s.archiveObjects(objects, storageClass)
+	log.Printf("Archived %d backups older than %s with prefix %s to %s", len(archived), cutoff.Format(time.RFC3339), prefix, storageClass)
+	return archived, nil
+}
+
+// RetrieveArchived makes an object in a Glacier storage class readable. It
+// starts a restore kept for days and reports whether a restored copy is
+// already available to download.
+func (s *S3Client) RetrieveArchived(s3Key string, days int) (bool, error) {
+	head, err := s.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s3Key),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get object info from S3: %w", err)
+	}
+
+	// x-amz-restore is set once a restore was requested, with ongoing-request="false" when done
+	restore := aws.StringValue(head.Restore)
+	if strings.Contains(restore, `ongoing-request="false"`) {
+		return true, nil
+	}
+	if strings.Contains(restore, `ongoing-request="true"`) {
+		return false, nil
+	}
+
+	_, err = s.client.RestoreObject(&s3.RestoreObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s3Key),
+		RestoreRequest: &s3.RestoreRequest{
+			Days: aws.Int64(int64(days)),
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to request retrieval of %s: %w", s3Key, err)
+	}
 
-	return fmt.Sprintf("backups/%s/%s/%s/%s/%s", postgresID, backupType, year, month, filename)
+	log.Printf("Requested retrieval of archived backup %s", s3Key)
+	return false, nil
 }
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCopyPartRanges(t *testing.T) {
+	tests := []struct {
+		size, partSize int64
+		want           []string
+	}{
+		{10, 4, []string{"bytes=0-3", "bytes=4-7", "bytes=8-9"}},
+		{8, 4, []string{"bytes=0-3", "bytes=4-7"}},
+		{3, 4, []string{"bytes=0-2"}},
+	}
+	for _, tt := range tests {
+		if got := copyPartRanges(tt.size, tt.partSize); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("copyPartRanges(%d, %d) = %v, want %v", tt.size, tt.partSize, got, tt.want)
+		}
+	}
+
+	// The largest S3 object must fit in the 10,000-part limit
+	if parts := len(copyPartRanges(5*1000*1000*1000*1000, copyPartSize)); parts > 10000 {
+		t.Errorf("a 5 TB object needs %d parts, over the 10,000 limit", parts)
+	}
+}
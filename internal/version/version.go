@@ -0,0 +1,18 @@
+package version
+
+import "runtime"
+
+// Build metadata, injected at build time with
+//
+//	-ldflags "-X evolution-postgres-backup/internal/version.GitCommit=$(git rev-parse --short HEAD)
+//	          -X evolution-postgres-backup/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "2.0.0"
+	GitCommit = "unknown"
+	BuildTime = "unknown"
+)
+
+// GoVersion returns the Go toolchain the binary was built with
+func GoVersion() string {
+	return runtime.Version()
+}
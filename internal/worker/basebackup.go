@@ -0,0 +1,195 @@
+package worker
+
+import (
+	"archive/tar"
+	"evolution-postgres-backup/internal/config"
+	"evolution-postgres-backup/internal/database"
+	"evolution-postgres-backup/internal/models"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// BaseBackupTag marks backup records holding a pg_basebackup archive of a
+// whole cluster rather than a pg_dump of one database
+const BaseBackupTag = "basebackup"
+
+// processBaseBackupJob takes a physical backup of an instance with
+// pg_basebackup, streaming the WAL needed to make it consistent. The output
+// (base.tar.gz, pg_wal.tar.gz and, on PostgreSQL 13+, backup_manifest) is
+// packed into a single tarball. The connecting role needs the REPLICATION
+// attribute and a free replication connection slot.
+func (w *Worker) processBaseBackupJob(job *Job) error {
+	w.logInfo(job, "Processing base backup job %s", job.ID)
+
+	postgresID, ok := job.Payload["postgres_id"].(string)
+	if !ok {
+		return fmt.Errorf("missing postgres_id in job payload")
+	}
+
+	pgRepo := database.NewPostgreSQLRepository(w.dbService)
+	pgInstance, err := pgRepo.GetByID(postgresID)
+	if err != nil {
+		return fmt.Errorf("failed to get postgres instance: %w", err)
+	}
+
+	backupRepo := database.NewBackupRepository(w.dbService)
+	backup := &models.BackupInfo{
+		ID:           generateBackupID(),
+		PostgreSQLID: postgresID,
+		BackupType:   models.BackupTypeManual,
+		Status:       models.BackupStatusInProgress,
+		StartTime:    time.Now(),
+		CreatedAt:    time.Now(),
+		JobID:        job.ID,
+		Tags:         append([]string{BaseBackupTag}, job.Tags()...),
+		CreatedBy:    job.CreatedBy(),
+	}
+	if err := backupRepo.Create(backup); err != nil {
+		return fmt.Errorf("failed to create backup record: %w", err)
+	}
+	job.Payload["backup_id"] = backup.ID
+
+	w.logJobProgress(job.ID, backup.ID, "Base backup started for %s", pgInstance.Name)
+
+	fail := func(err error) error {
+		backup.Fail(err.Error())
+		endTime := time.Now()
+		backup.EndTime = &endTime
+
+		if updateErr := backupRepo.Update(backup); updateErr != nil {
+			return fmt.Errorf("failed to update backup record: %w", updateErr)
+		}
+		w.logJobProgress(job.ID, backup.ID, "Base backup failed: %v", err)
+		return err
+	}
+
+	tempDir := backupTempDir(pgInstance)
+	name := fmt.Sprintf("%s_basebackup_%s", pgInstance.Name, backup.StartTime.Format("2006-01-02-15-04-05"))
+	outputDir := filepath.Join(tempDir, name)
+	archivePath := outputDir + ".tar"
+
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return fail(fmt.Errorf("failed to create temp directory: %w", err))
+	}
+	defer os.RemoveAll(outputDir)
+
+	pgInstance, cleanupSSL, err := pgInstance.WithSSLFiles(filepath.Join(tempDir, "ssl"))
+	if err != nil {
+		return fail(fmt.Errorf("failed to prepare SSL certificates: %w", err))
+	}
+	defer cleanupSSL()
+
+	// Replication connections can't go through a pooler
+	pgInstance = pgInstance.ForBackup()
+
+	cmd := exec.Command(config.ToolPath(config.ToolPgBasebackup),
+		"-h", pgInstance.Host,
+		"-p", fmt.Sprintf("%d", pgInstance.Port),
+		"-U", pgInstance.Username,
+		"-D", outputDir,
+		"--format=tar", "--gzip",
+		"--wal-method=stream",
+		"--checkpoint=fast",
+		"--verbose", "--no-password")
+	cmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", pgInstance.Password))
+	cmd.Env = append(cmd.Env, pgInstance.SSLEnv()...)
+	cmd.Env = append(cmd.Env, config.ConnectTimeoutEnv()...)
+
+	w.logJobProgress(job.ID, backup.ID, "Executing pg_basebackup: %s@%s:%d", pgInstance.Username, pgInstance.Host, pgInstance.Port)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if poolerErr := pgInstance.PoolerError("pg_basebackup", string(output)); poolerErr != nil {
+			return fail(poolerErr)
+		}
+		return fail(fmt.Errorf("pg_basebackup failed: %v: %s", err, lastLines(string(output), 5)))
+	}
+
+	size, err := packDirectory(outputDir, archivePath)
+	if err != nil {
+		os.Remove(archivePath)
+		return fail(fmt.Errorf("failed to pack base backup: %w", err))
+	}
+	if err := pgInstance.CheckBackupSize(size); err != nil {
+		os.Remove(archivePath)
+		return fail(err)
+	}
+
+	// The backup only counts once its archive is in the bucket
+	if err := w.uploadBackup(job, backup, archivePath); err != nil {
+		os.Remove(archivePath)
+		return fail(err)
+	}
+
+	backup.Status = models.BackupStatusCompleted
+	backup.FilePath = archivePath
+	backup.FileSize = size
+	endTime := time.Now()
+	backup.EndTime = &endTime
+	if err := backupRepo.Update(backup); err != nil {
+		return fmt.Errorf("failed to update backup record: %w", err)
+	}
+	job.Payload["file_size"] = size
+
+	w.logJobProgress(job.ID, backup.ID, "Base backup completed: %s (%d bytes)", archivePath, size)
+	return nil
+}
+
+// packDirectory writes the regular files of dir into an uncompressed tar
+// archive (the pg_basebackup files are already gzipped) and returns its size
+func packDirectory(dir, archivePath string) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	tw := tar.NewWriter(file)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if err := addFileToTar(tw, filepath.Join(dir, entry.Name())); err != nil {
+			return 0, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), file.Close()
+}
+
+// addFileToTar appends one file to a tar archive under its base name
+func addFileToTar(tw *tar.Writer, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, src)
+	return err
+}
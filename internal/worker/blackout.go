@@ -0,0 +1,102 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// blackoutJobTypes are the job types held back during the backup blackout
+var blackoutJobTypes = []string{string(JobTypeBackup), string(JobTypeBaseBackup)}
+
+// blackoutWindow is a daily period, in minutes after midnight, during which
+// new backups don't start. End before start wraps past midnight.
+type blackoutWindow struct {
+	start, end int
+	location   *time.Location
+	spec       string
+}
+
+// parseBlackoutWindow parses "HH:MM-HH:MM" in the named time zone ("" = local)
+func parseBlackoutWindow(spec, timezone string) (*blackoutWindow, error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid blackout %q: use HH:MM-HH:MM", spec)
+	}
+
+	window := &blackoutWindow{location: time.Local, spec: spec}
+	for _, part := range []struct {
+		value  string
+		minute *int
+	}{{from, &window.start}, {to, &window.end}} {
+		t, err := time.Parse("15:04", strings.TrimSpace(part.value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid blackout %q: use HH:MM-HH:MM", spec)
+		}
+		*part.minute = t.Hour()*60 + t.Minute()
+	}
+	if window.start == window.end {
+		return nil, fmt.Errorf("invalid blackout %q: start and end are the same", spec)
+	}
+
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blackout time zone %q: %w", timezone, err)
+		}
+		window.location = location
+	}
+	return window, nil
+}
+
+// blackoutFromEnv reads BACKUP_BLACKOUT (e.g. "09:00-18:00") and
+// BACKUP_BLACKOUT_TZ (e.g. "Europe/Berlin", default local time). It returns
+// nil when no blackout is configured.
+func blackoutFromEnv() (*blackoutWindow, error) {
+	spec := os.Getenv("BACKUP_BLACKOUT")
+	if spec == "" {
+		return nil, nil
+	}
+	return parseBlackoutWindow(spec, os.Getenv("BACKUP_BLACKOUT_TZ"))
+}
+
+// Active reports whether now falls inside the window
+func (b *blackoutWindow) Active(now time.Time) bool {
+	if b == nil {
+		return false
+	}
+	local := now.In(b.location)
+	minute := local.Hour()*60 + local.Minute()
+	if b.start < b.end {
+		return minute >= b.start && minute < b.end
+	}
+	return minute >= b.start || minute < b.end
+}
+
+func (b *blackoutWindow) String() string {
+	return fmt.Sprintf("%s %s", b.spec, b.location)
+}
+
+// inBlackout reports whether job must wait for the backup blackout to end
+func (q *JobQueue) inBlackout(job *Job) bool {
+	if !q.blackout.Active(time.Now()) {
+		return false
+	}
+	for _, jobType := range blackoutJobTypes {
+		if string(job.Type) == jobType {
+			return true
+		}
+	}
+	return false
+}
+
+// deferJob puts a job this process had taken back to pending in the database,
+// for the loader to pick up once the blackout is over
+func (q *JobQueue) deferJob(job *Job) {
+	if _, err := q.dbService.Exec(`UPDATE jobs SET status = 'pending', started_at = NULL WHERE id = $1 AND status IN ('pending', 'running')`, job.ID); err != nil {
+		q.logError(job, "Failed to defer job %s: %v", job.ID, err)
+		return
+	}
+	q.logInfo(job, "Job %s (%s) deferred until the backup blackout (%s) ends", job.ID, job.Type, q.blackout)
+}
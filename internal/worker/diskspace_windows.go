@@ -0,0 +1,10 @@
+//go:build windows
+
+package worker
+
+import "errors"
+
+// availableDiskSpace is not implemented on Windows; the preflight is skipped
+func availableDiskSpace(path string) (int64, error) {
+	return 0, errors.New("disk space check not supported on windows")
+}
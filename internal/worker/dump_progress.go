@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"bytes"
+	"strings"
+)
+
+// dumpProgressMarkers are the pg_dump --verbose messages worth reporting while
+// a dump runs; the rest (catalog reads, SET commands) is only kept for errors
+var dumpProgressMarkers = []string{
+	"dumping contents of table",
+	"saving ",
+	"warning:",
+}
+
+// dumpProgressWriter collects pg_dump's stderr and calls onProgress for each
+// complete line matching dumpProgressMarkers, as it is written
+type dumpProgressWriter struct {
+	output     bytes.Buffer
+	partial    []byte
+	onProgress func(line string)
+}
+
+func (p *dumpProgressWriter) Write(data []byte) (int, error) {
+	p.output.Write(data)
+
+	p.partial = append(p.partial, data...)
+	for {
+		i := bytes.IndexByte(p.partial, '\n')
+		if i < 0 {
+			break
+		}
+		p.report(string(p.partial[:i]))
+		p.partial = p.partial[i+1:]
+	}
+	return len(data), nil
+}
+
+// report passes a line to onProgress if it is a progress message
+func (p *dumpProgressWriter) report(line string) {
+	line = strings.TrimSpace(strings.TrimPrefix(line, "pg_dump: "))
+	for _, marker := range dumpProgressMarkers {
+		if strings.HasPrefix(line, marker) {
+			p.onProgress(line)
+			return
+		}
+	}
+}
+
+// Bytes returns everything written so far
+func (p *dumpProgressWriter) Bytes() []byte {
+	return p.output.Bytes()
+}
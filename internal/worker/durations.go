@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"sort"
+	"time"
+)
+
+// durationWindowSize is how many recent completions per job type the duration
+// metrics are computed over
+const durationWindowSize = 200
+
+// JobDurationStats summarizes the recent durations of completed jobs of a type
+type JobDurationStats struct {
+	Samples        int     `json:"samples"`
+	AverageSeconds float64 `json:"average_seconds"`
+	P95Seconds     float64 `json:"p95_seconds"`
+}
+
+// durationWindow is a ring buffer of the most recent job durations
+type durationWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+// add records a duration, overwriting the oldest once the window is full
+func (d *durationWindow) add(duration time.Duration) {
+	if len(d.samples) < durationWindowSize {
+		d.samples = append(d.samples, duration)
+		return
+	}
+	d.samples[d.next] = duration
+	d.next = (d.next + 1) % durationWindowSize
+}
+
+// stats computes the average and nearest-rank 95th percentile of the window
+func (d *durationWindow) stats() JobDurationStats {
+	if len(d.samples) == 0 {
+		return JobDurationStats{}
+	}
+
+	sorted := make([]time.Duration, len(d.samples))
+	copy(sorted, d.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, duration := range sorted {
+		total += duration
+	}
+
+	rank := (len(sorted)*95 + 99) / 100 // ceil(0.95 * n)
+	return JobDurationStats{
+		Samples:        len(sorted),
+		AverageSeconds: (total / time.Duration(len(sorted))).Seconds(),
+		P95Seconds:     sorted[rank-1].Seconds(),
+	}
+}
+
+// recordJobFinished updates the completion counters and, for completed jobs,
+// the duration window of the job's type
+func (q *JobQueue) recordJobFinished(job *Job) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch job.Status {
+	case JobStatusCompleted:
+		q.stats.CompletedJobs++
+	case JobStatusFailed:
+		q.stats.FailedJobs++
+	}
+
+	if job.Status != JobStatusCompleted || job.StartedAt == nil || job.CompletedAt == nil {
+		return
+	}
+
+	window, ok := q.durations[job.Type]
+	if !ok {
+		window = &durationWindow{}
+		q.durations[job.Type] = window
+	}
+	window.add(job.CompletedAt.Sub(*job.StartedAt))
+}
+
+// GetJobDurations returns the rolling duration metrics of completed jobs by type
+func (q *JobQueue) GetJobDurations() map[JobType]JobDurationStats {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	durations := make(map[JobType]JobDurationStats, len(q.durations))
+	for jobType, window := range q.durations {
+		durations[jobType] = window.stats()
+	}
+	return durations
+}
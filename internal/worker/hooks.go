@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"evolution-postgres-backup/internal/config"
+	"evolution-postgres-backup/internal/models"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// preBackupSQLTimeout bounds the pre-backup SQL, which is meant to be quick
+const preBackupSQLTimeout = 5 * time.Minute
+
+// hookClient posts post-backup webhooks; a slow endpoint must not hold up the worker
+var hookClient = &http.Client{Timeout: 10 * time.Second}
+
+// runPreBackupSQL runs the instance's pre_backup_sql against databaseName
+// with psql, stopping at the first error. pgInstance is the config the dump
+// uses, so the SQL goes through the same endpoint, SSL files and session settings.
+func runPreBackupSQL(pgInstance *config.PostgreSQLConfig, databaseName string) error {
+	// Re-check here too: the API may allow it while this worker doesn't
+	if !config.PreBackupSQLAllowed() {
+		return config.ErrPreBackupSQLDisabled
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), preBackupSQLTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, config.ToolPath(config.ToolPsql),
+		"-h", pgInstance.Host,
+		"-p", fmt.Sprintf("%d", pgInstance.Port),
+		"-U", pgInstance.Username,
+		"-d", databaseName,
+		"-v", "ON_ERROR_STOP=1", "--quiet", "--no-password",
+		"-c", pgInstance.PreBackupSQL,
+	)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", pgInstance.Password))
+	cmd.Env = append(cmd.Env, pgInstance.SSLEnv()...)
+	cmd.Env = append(cmd.Env, config.ConnectTimeoutEnv()...)
+	cmd.Env = append(cmd.Env, pgInstance.PGOptionsEnv()...)
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("pre-backup SQL did not finish within %s", preBackupSQLTimeout)
+	}
+	if err != nil {
+		if poolerErr := pgInstance.PoolerError("psql", string(output)); poolerErr != nil {
+			return poolerErr
+		}
+		return fmt.Errorf("pre-backup SQL failed: %v: %s", err, lastLines(string(output), 5))
+	}
+	return nil
+}
+
+// sendPostBackupWebhook posts a completed backup to webhookURL. The "text"
+// field makes the payload usable as a Slack incoming webhook, like job alerts.
+func sendPostBackupWebhook(webhookURL, instanceName string, backup *models.BackupInfo) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"text": fmt.Sprintf("✅ %s backup of %s/%s completed (%d bytes)",
+			backup.BackupType, instanceName, backup.DatabaseName, backup.FileSize),
+		"event":         "backup_completed",
+		"backup_id":     backup.ID,
+		"job_id":        backup.JobID,
+		"postgres_id":   backup.PostgreSQLID,
+		"database_name": backup.DatabaseName,
+		"backup_type":   backup.BackupType,
+		"file_path":     backup.FilePath,
+		"file_size":     backup.FileSize,
+		"format":        backup.Format,
+		"checksum":      backup.Checksum,
+		"tags":          backup.Tags,
+		"started_at":    backup.StartTime,
+		"completed_at":  backup.EndTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := hookClient.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
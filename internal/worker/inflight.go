@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"database/sql"
+	"errors"
+	"evolution-postgres-backup/internal/database"
+	"evolution-postgres-backup/internal/models"
+	"fmt"
+)
+
+// ErrDuplicateBackup is returned when a backup of the same database and type
+// is already pending, running or waiting for a retry
+var ErrDuplicateBackup = errors.New("a backup of this database is already pending, running or retrying")
+
+// DuplicateBackupError identifies the in-flight backup a new one duplicates
+type DuplicateBackupError struct {
+	JobID  string
+	Backup *models.BackupInfo // nil while the worker hasn't created the record yet
+}
+
+func (e *DuplicateBackupError) Error() string {
+	return fmt.Sprintf("%v (job %s)", ErrDuplicateBackup, e.JobID)
+}
+
+func (e *DuplicateBackupError) Unwrap() error {
+	return ErrDuplicateBackup
+}
+
+// Force queues a backup even when one of the same database and type is
+// already pending, running or retrying
+func Force() JobOption {
+	return func(job *Job) {
+		job.force = true
+	}
+}
+
+// WithTx runs fn in the transaction that saves a backup and its job (see
+// SaveBackupJob), so that a related change commits with them. An error from
+// fn saves nothing.
+func WithTx(fn func(tx *sql.Tx) error) JobOption {
+	return func(job *Job) {
+		job.withTx = fn
+	}
+}
+
+// checkInFlightBackup returns a DuplicateBackupError when a backup job for the
+// same instance, database and type is pending, running or retrying. Callers
+// run it inside the transaction that inserts the job: it first takes a lock on
+// that key, so concurrent requests can't both pass the check.
+func (q *JobQueue) checkInFlightBackup(tx *sql.Tx, job *Job) error {
+	if job.force {
+		return nil
+	}
+	payload, err := job.BackupPayload()
+	if err != nil {
+		return err
+	}
+
+	key := payload.PostgresID + "/" + payload.DatabaseName + "/" + string(payload.BackupType)
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", key); err != nil {
+		return fmt.Errorf("failed to lock backup key: %w", err)
+	}
+
+	var jobID, backupID string
+	err = tx.QueryRow(`
+		SELECT id, COALESCE(NULLIF(backup_id, ''), payload->>'backup_id', '')
+		FROM jobs
+		WHERE type = 'backup' AND status IN ('pending', 'running', 'retrying')
+		  AND postgres_id = $1 AND database_name = $2 AND payload->>'backup_type' = $3
+		ORDER BY created_at
+		LIMIT 1`,
+		payload.PostgresID, payload.DatabaseName, string(payload.BackupType)).Scan(&jobID, &backupID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check for in-flight backups: %w", err)
+	}
+
+	duplicate := &DuplicateBackupError{JobID: jobID}
+	if backupID != "" {
+		if backup, err := database.NewBackupRepository(q.dbService).GetByID(backupID); err == nil {
+			duplicate.Backup = backup
+		}
+	}
+	return duplicate
+}
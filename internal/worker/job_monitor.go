@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jobMonitorInterval is how often running jobs are checked against their thresholds
+const jobMonitorInterval = time.Minute
+
+// alertClient sends alert webhooks; a slow endpoint must not stall the monitor
+var alertClient = &http.Client{Timeout: 10 * time.Second}
+
+// runningAlertThreshold returns how long a job of jobType may run before an
+// alert fires, configurable via ALERT_<TYPE>_RUNNING_MINUTES (e.g.
+// ALERT_BACKUP_RUNNING_MINUTES). 0 disables alerts for the type.
+func runningAlertThreshold(jobType JobType) time.Duration {
+	name := "ALERT_" + strings.ToUpper(string(jobType)) + "_RUNNING_MINUTES"
+	if minutes, err := strconv.Atoi(os.Getenv(name)); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 0
+}
+
+// monitorLongRunningJobs alerts once per job when a job of this process runs
+// longer than its type's threshold. Jobs are left running.
+func (q *JobQueue) monitorLongRunningJobs(ctx context.Context) {
+	webhookURL := os.Getenv("ALERT_WEBHOOK_URL")
+	if webhookURL == "" {
+		return
+	}
+
+	ticker := time.NewTicker(jobMonitorInterval)
+	defer ticker.Stop()
+
+	alerted := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		running := make(map[string]bool)
+		for _, job := range q.GetRunningJobs() {
+			running[job.ID] = true
+			if alerted[job.ID] || job.StartedAt == nil {
+				continue
+			}
+			threshold := runningAlertThreshold(job.Type)
+			if threshold == 0 {
+				continue
+			}
+			elapsed := time.Since(*job.StartedAt)
+			if elapsed < threshold {
+				continue
+			}
+
+			alerted[job.ID] = true
+			q.logInfo(job, "Job %s (%s) has been running for %s, over the %s alert threshold", job.ID, job.Type, elapsed.Round(time.Second), threshold)
+			if err := q.sendJobAlert(webhookURL, job, elapsed, threshold); err != nil {
+				q.logError(job, "Failed to send long-running alert for job %s: %v", job.ID, err)
+			}
+		}
+
+		// Forget finished jobs
+		for id := range alerted {
+			if !running[id] {
+				delete(alerted, id)
+			}
+		}
+	}
+}
+
+// sendJobAlert posts a long-running job alert. The "text" field makes the
+// payload usable as a Slack incoming webhook; other receivers get the details.
+func (q *JobQueue) sendJobAlert(webhookURL string, job *Job, elapsed, threshold time.Duration) error {
+	// Read the target from the jobs table: the running worker may be writing to the payload
+	var postgresID, databaseName string
+	err := q.dbService.QueryRow("SELECT postgres_id, database_name FROM jobs WHERE id = $1", job.ID).Scan(&postgresID, &databaseName)
+	if err != nil {
+		return fmt.Errorf("failed to read job %s: %w", job.ID, err)
+	}
+
+	text := fmt.Sprintf("⏳ %s job %s has been running for %s (threshold %s)",
+		job.Type, job.ID, elapsed.Round(time.Minute), threshold)
+	if databaseName != "" {
+		text += fmt.Sprintf(" on %s/%s", postgresID, databaseName)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"text":              text,
+		"event":             "job_long_running",
+		"job_id":            job.ID,
+		"job_type":          job.Type,
+		"worker_id":         job.WorkerID,
+		"postgres_id":       postgresID,
+		"database_name":     databaseName,
+		"started_at":        job.StartedAt,
+		"running_seconds":   int64(elapsed.Seconds()),
+		"threshold_seconds": int64(threshold.Seconds()),
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := alertClient.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"evolution-postgres-backup/internal/models"
+	"fmt"
+)
+
+// ErrInvalidPayload is returned when a job's payload doesn't match its type
+var ErrInvalidPayload = errors.New("invalid job payload")
+
+// BackupPayload is the payload of a backup job
+type BackupPayload struct {
+	PostgresID   string            `json:"postgres_id"`
+	DatabaseName string            `json:"database_name"`
+	BackupType   models.BackupType `json:"backup_type"`
+	BackupID     string            `json:"backup_id,omitempty"` // Set when the backup record already exists
+	Tags         []string          `json:"tags,omitempty"`
+	NoBlobs      bool              `json:"no_blobs,omitempty"` // Dump without large objects, see NoBlobs
+}
+
+// Validate checks the required fields and the backup type
+func (p *BackupPayload) Validate() error {
+	switch {
+	case p.PostgresID == "":
+		return fmt.Errorf("missing postgres_id")
+	case p.DatabaseName == "":
+		return fmt.Errorf("missing database_name")
+	case p.BackupType == "":
+		return fmt.Errorf("missing backup_type")
+	}
+	switch p.BackupType {
+	case models.BackupTypeHourly, models.BackupTypeDaily, models.BackupTypeWeekly, models.BackupTypeMonthly, models.BackupTypeManual:
+		return nil
+	}
+	return fmt.Errorf("unknown backup_type %q", p.BackupType)
+}
+
+// RestorePayload is the payload of a restore job
+type RestorePayload struct {
+	BackupID     string `json:"backup_id"`
+	PostgresID   string `json:"postgres_id"`
+	DatabaseName string `json:"database_name"`
+
+	// See ValidateOnly
+	ValidateOnly   bool `json:"validate_only,omitempty"`
+	ScratchRestore bool `json:"scratch_restore,omitempty"`
+
+	// See RestoreTables
+	IncludeTables []string `json:"include_tables,omitempty"`
+	ExcludeTables []string `json:"exclude_tables,omitempty"`
+	DataOnly      bool     `json:"data_only,omitempty"`
+}
+
+// Validate checks the required fields and the table options
+func (p *RestorePayload) Validate() error {
+	switch {
+	case p.BackupID == "":
+		return fmt.Errorf("missing backup_id")
+	case p.PostgresID == "":
+		return fmt.Errorf("missing postgres_id")
+	case p.DatabaseName == "":
+		return fmt.Errorf("missing database_name")
+	case p.ScratchRestore && !p.ValidateOnly:
+		return fmt.Errorf("scratch_restore requires validate_only")
+	}
+	if err := ValidateRestoreTables(p.IncludeTables); err != nil {
+		return err
+	}
+	return ValidateRestoreTables(p.ExcludeTables)
+}
+
+// TableLevel reports whether only some tables are restored
+func (p *RestorePayload) TableLevel() bool {
+	return len(p.IncludeTables) > 0 || len(p.ExcludeTables) > 0
+}
+
+// CleanupPayload is the payload of a cleanup job: retention cleanup of one
+// backup type, with Purge the removal of the whole instance, or with
+// EmptyTrash the removal of trashed backups past their grace period
+type CleanupPayload struct {
+	PostgresID string            `json:"postgres_id,omitempty"` // Required unless EmptyTrash
+	BackupType models.BackupType `json:"backup_type,omitempty"` // Required unless Purge or EmptyTrash
+	Purge      bool              `json:"purge,omitempty"`
+	EmptyTrash bool              `json:"empty_trash,omitempty"`
+	DryRun     bool              `json:"dry_run,omitempty"`
+}
+
+// Validate checks the required fields
+func (p *CleanupPayload) Validate() error {
+	switch {
+	case p.EmptyTrash && (p.Purge || p.DryRun):
+		return fmt.Errorf("empty_trash can't be combined with purge or dry_run")
+	case p.EmptyTrash:
+		return nil
+	case p.PostgresID == "":
+		return fmt.Errorf("missing postgres_id")
+	case !p.Purge && p.BackupType == "":
+		return fmt.Errorf("missing backup_type")
+	case p.Purge && p.DryRun:
+		return fmt.Errorf("dry_run is not supported for purge jobs")
+	}
+	return nil
+}
+
+// decodePayload converts a job payload into a typed payload and validates it.
+// Keys the struct doesn't know, such as worker results, are ignored.
+func decodePayload(payload map[string]interface{}, typed interface{ Validate() error }) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if err := json.Unmarshal(data, typed); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if err := typed.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	return nil
+}
+
+// BackupPayload decodes the payload of a backup job
+func (j *Job) BackupPayload() (*BackupPayload, error) {
+	payload := &BackupPayload{}
+	return payload, decodePayload(j.Payload, payload)
+}
+
+// RestorePayload decodes the payload of a restore job
+func (j *Job) RestorePayload() (*RestorePayload, error) {
+	payload := &RestorePayload{}
+	return payload, decodePayload(j.Payload, payload)
+}
+
+// CleanupPayload decodes the payload of a cleanup job
+func (j *Job) CleanupPayload() (*CleanupPayload, error) {
+	payload := &CleanupPayload{}
+	return payload, decodePayload(j.Payload, payload)
+}
+
+// validatePayload checks the payload of a job against the payload struct of
+// its type, so malformed jobs are rejected before they are queued
+func (j *Job) validatePayload() error {
+	var err error
+	switch j.Type {
+	case JobTypeBackup:
+		_, err = j.BackupPayload()
+	case JobTypeRestore:
+		_, err = j.RestorePayload()
+	case JobTypeCleanup:
+		_, err = j.CleanupPayload()
+	}
+	return err
+}
@@ -0,0 +1,102 @@
+package worker
+
+import (
+	"database/sql"
+	"evolution-postgres-backup/internal/config"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ClientTool is the result of probing one PostgreSQL client binary
+type ClientTool struct {
+	Name     string
+	Path     string
+	Version  string
+	Required bool
+	Err      error
+}
+
+// CheckClientTools probes pg_dump, psql, pg_restore and pg_basebackup. pg_dump and psql are
+// needed for every backup and restore, so a missing one makes the returned
+// error non-nil; pg_restore is only used to validate custom-format archives and
+// pg_basebackup only for base backup jobs.
+func CheckClientTools() ([]ClientTool, error) {
+	tools := []ClientTool{
+		{Name: config.ToolPgDump, Required: true},
+		{Name: config.ToolPsql, Required: true},
+		{Name: config.ToolPgRestore},
+		{Name: config.ToolPgBasebackup},
+	}
+
+	var missing []string
+	for i := range tools {
+		tool := &tools[i]
+		tool.Path = config.ToolPath(tool.Name)
+		tool.Version, tool.Err = config.ToolVersion(tool.Name)
+		if tool.Err != nil && tool.Required {
+			missing = append(missing, tool.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return tools, fmt.Errorf("required PostgreSQL client tools unavailable: %v", missing)
+	}
+	return tools, nil
+}
+
+// VersionMismatchError means pg_dump is older than the server it should dump.
+// pg_dump refuses to dump from a newer server major version.
+type VersionMismatchError struct {
+	ClientVersion string
+	ServerVersion string
+}
+
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("pg_dump version mismatch: client %q is older than server %s; install pg_dump %d or newer (or set PG_DUMP_PATH)",
+		e.ClientVersion, e.ServerVersion, majorVersion(e.ServerVersion))
+}
+
+// checkServerVersion compares the major version of the target server with the
+// local pg_dump and returns a *VersionMismatchError when the server is newer.
+// Versions that can't be determined are not treated as a mismatch; pg_dump
+// reports connection problems itself.
+func checkServerVersion(pgInstance *config.PostgreSQLConfig, databaseName string) error {
+	clientVersion, err := config.ToolVersion(config.ToolPgDump)
+	if err != nil {
+		return nil
+	}
+
+	db, err := sql.Open("postgres", pgInstance.ConnectionString(databaseName, config.ConnectTimeout()))
+	if err != nil {
+		return nil
+	}
+	defer db.Close()
+
+	var serverVersion string
+	if err := db.QueryRow("SHOW server_version").Scan(&serverVersion); err != nil {
+		return nil
+	}
+
+	client, server := majorVersion(clientVersion), majorVersion(serverVersion)
+	if client > 0 && server > client {
+		return &VersionMismatchError{ClientVersion: clientVersion, ServerVersion: serverVersion}
+	}
+	return nil
+}
+
+// majorVersion extracts the major version from output like
+// "pg_dump (PostgreSQL) 16.2" or "15.4 (Debian 15.4-1.pgdg120+1)", or 0
+func majorVersion(version string) int {
+	for _, field := range strings.Fields(version) {
+		if field[0] < '0' || field[0] > '9' {
+			continue
+		}
+		major, err := strconv.Atoi(strings.SplitN(field, ".", 2)[0])
+		if err != nil {
+			return 0
+		}
+		return major
+	}
+	return 0
+}
@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"evolution-postgres-backup/internal/models"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Job priorities run from MinPriority to MaxPriority. Workers take pending jobs
+// highest priority first, oldest first within a priority. A priority given in a
+// request wins; otherwise the default of the job kind below applies, which
+// JOB_PRIORITY_<KIND> overrides (e.g. JOB_PRIORITY_MANUAL=9 lets manual backups
+// go ahead of scheduled ones).
+const (
+	MinPriority = 1
+	MaxPriority = 10
+)
+
+// Default priorities by backup type; scheduled backups go ahead of manual ones
+var defaultBackupPriorities = map[models.BackupType]int{
+	models.BackupTypeHourly:  7,
+	models.BackupTypeDaily:   7,
+	models.BackupTypeWeekly:  7,
+	models.BackupTypeMonthly: 7,
+	models.BackupTypeManual:  5,
+}
+
+// Default priorities of the other job kinds
+const (
+	defaultRestorePriority    = 8 // Someone is usually waiting for a restore
+	defaultBaseBackupPriority = 5
+	defaultSchemaDiffPriority = 5
+	defaultPurgePriority      = 5
+	defaultCleanupPriority    = 3 // Retention cleanup and trash purges can wait
+)
+
+// priorityFromEnv returns JOB_PRIORITY_<kind> when it is on the priority
+// scale, and fallback otherwise
+func priorityFromEnv(kind string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv("JOB_PRIORITY_" + strings.ToUpper(kind)))
+	if err != nil || value < MinPriority || value > MaxPriority {
+		return fallback
+	}
+	return value
+}
+
+// BackupPriority returns the default priority of a backup of the given type
+func BackupPriority(backupType models.BackupType) int {
+	fallback, ok := defaultBackupPriorities[backupType]
+	if !ok {
+		fallback = defaultBackupPriorities[models.BackupTypeManual]
+	}
+	return priorityFromEnv(string(backupType), fallback)
+}
+
+// RestorePriority returns the default priority of restore jobs
+func RestorePriority() int {
+	return priorityFromEnv("restore", defaultRestorePriority)
+}
+
+// BaseBackupPriority returns the default priority of pg_basebackup jobs
+func BaseBackupPriority() int {
+	return priorityFromEnv("basebackup", defaultBaseBackupPriority)
+}
+
+// SchemaDiffPriority returns the default priority of schema diff jobs
+func SchemaDiffPriority() int {
+	return priorityFromEnv("schema_diff", defaultSchemaDiffPriority)
+}
+
+// PurgePriority returns the default priority of instance purge jobs
+func PurgePriority() int {
+	return priorityFromEnv("purge", defaultPurgePriority)
+}
+
+// CleanupPriority returns the default priority of retention cleanup and trash purge jobs
+func CleanupPriority() int {
+	return priorityFromEnv("cleanup", defaultCleanupPriority)
+}
+
+// Priorities returns the effective default priority of every job kind, keyed
+// by the suffix of its JOB_PRIORITY_ variable
+func Priorities() map[string]int {
+	priorities := map[string]int{
+		"restore":     RestorePriority(),
+		"basebackup":  BaseBackupPriority(),
+		"schema_diff": SchemaDiffPriority(),
+		"purge":       PurgePriority(),
+		"cleanup":     CleanupPriority(),
+	}
+	for backupType := range defaultBackupPriorities {
+		priorities[string(backupType)] = BackupPriority(backupType)
+	}
+	return priorities
+}
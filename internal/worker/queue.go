@@ -3,12 +3,18 @@ package worker
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"evolution-postgres-backup/internal/config"
 	"evolution-postgres-backup/internal/database"
+	"evolution-postgres-backup/internal/logger"
 	"evolution-postgres-backup/internal/models"
 	"fmt"
-	"log"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 // JobType represents different types of jobs
@@ -18,6 +24,12 @@ const (
 	JobTypeBackup  JobType = "backup"
 	JobTypeRestore JobType = "restore"
 	JobTypeCleanup JobType = "cleanup"
+
+	// JobTypeBaseBackup is a physical pg_basebackup of a whole instance
+	JobTypeBaseBackup JobType = "basebackup"
+
+	// JobTypeSchemaDiff compares the schema of two backups
+	JobTypeSchemaDiff JobType = "schema_diff"
 )
 
 // JobStatus represents job execution status
@@ -29,8 +41,18 @@ const (
 	JobStatusCompleted JobStatus = "completed"
 	JobStatusFailed    JobStatus = "failed"
 	JobStatusRetrying  JobStatus = "retrying"
+	JobStatusCancelled JobStatus = "cancelled"
 )
 
+// IsTerminal reports whether a job in this status will not run again
+func (s JobStatus) IsTerminal() bool {
+	switch s {
+	case JobStatusPending, JobStatusRunning, JobStatusRetrying:
+		return false
+	}
+	return true
+}
+
 // Job represents a work item in the queue
 type Job struct {
 	ID          string                 `json:"id"`
@@ -45,20 +67,184 @@ type Job struct {
 	CompletedAt *time.Time             `json:"completed_at,omitempty"`
 	Error       string                 `json:"error,omitempty"`
 	WorkerID    string                 `json:"worker_id,omitempty"`
+
+	force   bool                // See Force; only used while the job is created
+	withTx  func(*sql.Tx) error // See WithTx; only used while the job is created
+	claimed bool                // Marked running in the database, see claimJob
+}
+
+// JobOption customizes a job before it is queued
+type JobOption func(*Job)
+
+// WithRequestID records the API request that created the job, so the worker
+// can attach it to the job's logs
+func WithRequestID(requestID string) JobOption {
+	return func(job *Job) {
+		if requestID != "" {
+			job.Payload["request_id"] = requestID
+		}
+	}
+}
+
+// WithCreatedBy records who submitted the job, e.g. the API key name, so the
+// backup it produces can be traced back to them
+func WithCreatedBy(createdBy string) JobOption {
+	return func(job *Job) {
+		if createdBy != "" {
+			job.Payload["created_by"] = createdBy
+		}
+	}
+}
+
+// ValidateOnly turns a restore job into a dry run that checks the archive
+// without touching the target database. With scratchRestore the archive is
+// also restored into a temporary database that is dropped afterwards.
+func ValidateOnly(scratchRestore bool) JobOption {
+	return func(job *Job) {
+		job.Payload["validate_only"] = true
+		job.Payload["scratch_restore"] = scratchRestore
+	}
+}
+
+// WithTags labels the backup a worker creates for a backup job that has no
+// backup record yet
+func WithTags(tags []string) JobOption {
+	return func(job *Job) {
+		if len(tags) > 0 {
+			job.Payload["tags"] = tags
+		}
+	}
+}
+
+// NoBlobs makes a backup job dump without large objects (pg_dump --no-blobs).
+// The backup is marked BlobsExcluded so it isn't mistaken for a full one.
+func NoBlobs(noBlobs bool) JobOption {
+	return func(job *Job) {
+		if noBlobs {
+			job.Payload["no_blobs"] = true
+		}
+	}
+}
+
+// DryRun makes a cleanup job report the backups retention would delete or
+// archive, without touching them
+func DryRun() JobOption {
+	return func(job *Job) {
+		job.Payload["dry_run"] = true
+	}
+}
+
+// RestoreTables limits a restore job to the given tables ("name" or
+// "schema.name") of a custom-format archive, or restores all but the excluded
+// ones. With dataOnly only table contents are restored.
+func RestoreTables(include, exclude []string, dataOnly bool) JobOption {
+	return func(job *Job) {
+		if dataOnly {
+			job.Payload["data_only"] = true
+		}
+		if len(include) > 0 {
+			job.Payload["include_tables"] = include
+		}
+		if len(exclude) > 0 {
+			job.Payload["exclude_tables"] = exclude
+		}
+	}
+}
+
+// Tags returns the backup tags requested for the job
+func (j *Job) Tags() []string {
+	return j.payloadStrings("tags")
+}
+
+// payloadStrings returns a string list from the payload. The payload holds a
+// []interface{} once it has been loaded back from the database.
+func (j *Job) payloadStrings(key string) []string {
+	switch values := j.Payload[key].(type) {
+	case []string:
+		return values
+	case []interface{}:
+		result := make([]string, 0, len(values))
+		for _, value := range values {
+			if s, ok := value.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// RequestID returns the ID of the API request that created the job, if any
+func (j *Job) RequestID() string {
+	requestID, _ := j.Payload["request_id"].(string)
+	return requestID
+}
+
+// CreatedBy returns who submitted the job, or "" if unknown
+func (j *Job) CreatedBy() string {
+	createdBy, _ := j.Payload["created_by"].(string)
+	return createdBy
+}
+
+// applyOptions applies job options, making sure the payload exists
+func (j *Job) applyOptions(opts []JobOption) {
+	if j.Payload == nil {
+		j.Payload = make(map[string]interface{})
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
 }
 
+// MaxWorkers is the upper bound accepted by Scale
+const MaxWorkers = 64
+
 // JobQueue manages the job queue and workers
 type JobQueue struct {
-	ctx         context.Context
-	cancel      context.CancelFunc
-	jobs        chan *Job
-	workers     []*Worker
-	workerCount int
-	dbService   *database.DB
-	logRepo     *database.LogRepository
-	mu          sync.RWMutex
-	running     bool
-	stats       *QueueStats
+	ctx          context.Context
+	cancel       context.CancelFunc
+	loaderCancel context.CancelFunc
+	jobs         chan *Job
+	workers      []*Worker
+	workerCount  int
+	workerSeq    int    // Last number used for a worker ID
+	processID    string // Prefix of worker IDs, see ProcessID
+	dbService    *database.DB
+	logRepo      *database.LogRepository
+	mu           sync.RWMutex
+	loaderWg     sync.WaitGroup // Tracks the database job loader goroutine
+	running      bool
+	started      bool // Start was called at least once; false in API-only processes
+	draining     bool
+	stats        *QueueStats
+	durations    map[JobType]*durationWindow // Recent completed-job durations
+	objectStore  ObjectStore                 // Optional; holds uploaded copies of backups
+	blackout     *blackoutWindow             // Optional; backups don't start inside it
+	paused       atomic.Bool                 // Cached config flag, see Pause
+
+	// maxRestores caps concurrent restores across worker processes when
+	// MAX_CONCURRENT_RESTORES is set, see acquireRestoreSlot
+	maxRestores int
+}
+
+// ObjectStore removes backup objects from remote storage such as S3
+type ObjectStore interface {
+	DeleteFile(key string) error
+}
+
+// SetObjectStore lets backup jobs upload their files to remote storage and
+// cleanup jobs delete the remote copies (S3Key) of backups
+func (q *JobQueue) SetObjectStore(store ObjectStore) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.objectStore = store
+}
+
+// getObjectStore returns the configured object store, or nil
+func (q *JobQueue) getObjectStore() ObjectStore {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.objectStore
 }
 
 // QueueStats tracks queue statistics
@@ -77,7 +263,7 @@ func NewJobQueue(workerCount int, dbService *database.DB) *JobQueue {
 
 	logRepo := database.NewLogRepository(dbService)
 
-	return &JobQueue{
+	q := &JobQueue{
 		ctx:         ctx,
 		cancel:      cancel,
 		jobs:        make(chan *Job, 1000), // Buffer for 1000 jobs
@@ -86,7 +272,26 @@ func NewJobQueue(workerCount int, dbService *database.DB) *JobQueue {
 		dbService:   dbService,
 		logRepo:     logRepo,
 		stats:       &QueueStats{},
+		durations:   make(map[JobType]*durationWindow),
+		processID:   ProcessID(),
+	}
+
+	blackout, err := blackoutFromEnv()
+	if err != nil {
+		q.logError(nil, "Ignoring BACKUP_BLACKOUT: %v", err)
+	} else if blackout != nil {
+		q.blackout = blackout
+		q.logInfo(nil, "Backups are held back during the blackout window %s", blackout)
+	}
+
+	maxRestores, err := maxRestoresFromEnv()
+	if err != nil {
+		q.logError(nil, "Ignoring MAX_CONCURRENT_RESTORES: %v", err)
+	} else if maxRestores > 0 {
+		q.maxRestores = maxRestores
+		q.logInfo(nil, "At most %d restores run at once", maxRestores)
 	}
+	return q
 }
 
 // GetDB returns the database connection
@@ -103,26 +308,117 @@ func (q *JobQueue) Start() error {
 		return fmt.Errorf("queue is already running")
 	}
 
+	// A stopped queue can't reuse its cancelled context or closed channel
+	if q.ctx.Err() != nil {
+		q.ctx, q.cancel = context.WithCancel(context.Background())
+		q.jobs = make(chan *Job, cap(q.jobs))
+		q.workers = make([]*Worker, 0, q.workerCount)
+		q.workerSeq = 0
+	}
+
+	// A pause outlives restarts of the worker process
+	q.refreshPaused()
+
 	// Create and start workers
 	for i := 0; i < q.workerCount; i++ {
-		worker := NewWorker(fmt.Sprintf("worker-%d", i+1), q.jobs, q.dbService, q.logRepo, q)
-		q.workers = append(q.workers, worker)
-
-		go worker.Start(q.ctx)
+		q.spawnWorker()
 	}
 
 	// Start statistics updater
-	go q.updateStats()
+	go q.updateStats(q.ctx)
+
+	// Alert on jobs running past their threshold
+	go q.monitorLongRunningJobs(q.ctx)
 
 	// Start job loader from database
-	go q.loadJobsFromDatabase()
+	loaderCtx, loaderCancel := context.WithCancel(q.ctx)
+	q.loaderCancel = loaderCancel
+	q.loaderWg.Add(1)
+	go func() {
+		defer q.loaderWg.Done()
+		q.loadJobsFromDatabase(loaderCtx)
+	}()
 
 	q.running = true
-	q.logInfo("Queue started with %d workers", q.workerCount)
+	q.started = true
+	q.logInfo(nil, "Queue started with %d workers", q.workerCount)
 
 	return nil
 }
 
+// spawnWorker creates and starts a new worker (caller must hold q.mu)
+func (q *JobQueue) spawnWorker() {
+	q.workerSeq++
+	worker := NewWorker(fmt.Sprintf("%s-worker-%d", q.processID, q.workerSeq), q.jobs, q.dbService, q.logRepo, q)
+	q.workers = append(q.workers, worker)
+
+	go worker.Start(q.ctx)
+}
+
+// Scale changes the number of workers. New workers start immediately; removed
+// workers finish their current job before exiting. Idle workers are removed first.
+func (q *JobQueue) Scale(target int) (int, error) {
+	if target < 1 || target > MaxWorkers {
+		return 0, fmt.Errorf("worker count must be between 1 and %d", MaxWorkers)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	previous := q.workerCount
+	q.workerCount = target
+
+	// Workers are created on Start when the queue isn't running
+	if !q.running {
+		q.logInfo(nil, "Worker count changed from %d to %d (queue not running)", previous, target)
+		return target, nil
+	}
+
+	if q.draining {
+		q.workerCount = previous
+		return 0, fmt.Errorf("queue is draining")
+	}
+
+	for len(q.workers) < target {
+		q.spawnWorker()
+	}
+
+	if excess := len(q.workers) - target; excess > 0 {
+		// Prefer idle workers, then take the most recently started ones
+		kept := make([]*Worker, 0, target)
+		removed := make([]*Worker, 0, excess)
+		for i := len(q.workers) - 1; i >= 0; i-- {
+			worker := q.workers[i]
+			if len(removed) < excess && !worker.IsActive() {
+				removed = append(removed, worker)
+			} else {
+				kept = append(kept, worker)
+			}
+		}
+		for len(removed) < excess {
+			removed = append(removed, kept[0])
+			kept = kept[1:]
+		}
+
+		// Restore the original order of the remaining workers
+		for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+			kept[i], kept[j] = kept[j], kept[i]
+		}
+		q.workers = kept
+
+		for _, worker := range removed {
+			worker.Quit()
+			go func(w *Worker) {
+				<-w.Done()
+				w.Stop()
+			}(worker)
+		}
+	}
+
+	q.logInfo(nil, "Scaled workers from %d to %d", previous, target)
+	return target, nil
+}
+
 // Stop stops the job queue and all workers
 func (q *JobQueue) Stop() {
 	q.mu.Lock()
@@ -134,6 +430,9 @@ func (q *JobQueue) Stop() {
 
 	q.cancel()
 
+	// The loader sends on q.jobs, so it must be gone before the channel is closed
+	q.loaderWg.Wait()
+
 	// Wait for workers to finish current jobs
 	for _, worker := range q.workers {
 		worker.Stop()
@@ -141,43 +440,232 @@ func (q *JobQueue) Stop() {
 
 	close(q.jobs)
 	q.running = false
-	q.logInfo("Queue stopped")
+	q.draining = false
+	q.logInfo(nil, "Queue stopped")
+}
+
+// Drain stops accepting new jobs and waits for running jobs to finish before
+// stopping the queue. Jobs still waiting in the channel are handed back to the
+// database as pending. If ctx expires first, the queue is stopped anyway and
+// the context error is returned.
+func (q *JobQueue) Drain(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.running {
+		q.mu.Unlock()
+		return nil
+	}
+	q.draining = true
+	q.loaderCancel()
+	workers := make([]*Worker, len(q.workers))
+	copy(workers, q.workers)
+	q.mu.Unlock()
+
+	// Make sure the loader isn't still pushing jobs we're about to requeue
+	q.loaderWg.Wait()
+
+	q.logInfo(nil, "Draining queue, waiting for %d workers to finish running jobs", len(workers))
+
+	for _, worker := range workers {
+		worker.Quit()
+	}
+
+	var err error
+wait:
+	for _, worker := range workers {
+		select {
+		case <-worker.Done():
+		case <-ctx.Done():
+			err = fmt.Errorf("drain timed out with jobs still running: %w", ctx.Err())
+			break wait
+		}
+	}
+
+	q.requeueBufferedJobs()
+	q.Stop()
+
+	if err != nil {
+		q.logError(nil, "%v", err)
+		return err
+	}
+
+	q.logInfo(nil, "Queue drained")
+	return nil
+}
+
+// IsDraining returns whether the queue is draining
+func (q *JobQueue) IsDraining() bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.draining
+}
+
+// requeueBufferedJobs marks jobs still sitting in the channel as pending again
+// so that they are picked up from the database after a restart
+func (q *JobQueue) requeueBufferedJobs() {
+	requeued := 0
+	for {
+		select {
+		case job := <-q.jobs:
+			query := `UPDATE jobs SET status = 'pending', started_at = NULL WHERE id = $1 AND status <> 'cancelled'`
+			if _, err := q.dbService.Exec(query, job.ID); err != nil {
+				q.logError(job, "Failed to requeue job %s: %v", job.ID, err)
+				continue
+			}
+			requeued++
+		default:
+			if requeued > 0 {
+				q.logInfo(nil, "Requeued %d buffered jobs as pending", requeued)
+			}
+			return
+		}
+	}
+}
+
+// ErrQueueFull is returned when the job buffer has no room left
+var ErrQueueFull = errors.New("queue is full")
+
+// capacityPollInterval is how often WaitForCapacity rechecks the buffer
+const capacityPollInterval = 100 * time.Millisecond
+
+// QueueCapacity describes how full the job buffer is
+type QueueCapacity struct {
+	Capacity    int     `json:"capacity"`
+	Buffered    int     `json:"buffered"`
+	Utilization float64 `json:"utilization"` // Percentage of Capacity in use
+}
+
+// Capacity returns the size and fill level of the job buffer. A queue that was
+// never started only persists jobs for worker processes, so nothing is buffered.
+func (q *JobQueue) Capacity() QueueCapacity {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	capacity := QueueCapacity{Capacity: cap(q.jobs)}
+	if q.started {
+		capacity.Buffered = len(q.jobs)
+	}
+	if capacity.Capacity > 0 {
+		capacity.Utilization = float64(capacity.Buffered) / float64(capacity.Capacity) * 100
+	}
+	return capacity
+}
+
+// hasRoom reports whether a job can be buffered without blocking
+func (q *JobQueue) hasRoom() bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return !q.started || len(q.jobs) < cap(q.jobs)
+}
+
+// WaitForCapacity blocks until the job buffer has room or ctx is done, so
+// callers submitting many jobs can throttle instead of failing
+func (q *JobQueue) WaitForCapacity(ctx context.Context) error {
+	ticker := time.NewTicker(capacityPollInterval)
+	defer ticker.Stop()
+
+	for !q.hasRoom() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrQueueFull, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
 }
 
-// AddJob adds a new job to the queue
+// AddJob adds a new job to the queue, failing with ErrQueueFull right away if
+// the buffer has no room
 func (q *JobQueue) AddJob(job *Job) error {
-	if job.ID == "" {
-		job.ID = generateJobID()
+	if !q.hasRoom() {
+		return ErrQueueFull
 	}
+	return q.enqueue(job)
+}
 
-	if job.CreatedAt.IsZero() {
-		job.CreatedAt = time.Now()
+// AddJobContext adds a new job to the queue, waiting for room in the buffer
+// until ctx is done
+func (q *JobQueue) AddJobContext(ctx context.Context, job *Job) error {
+	if err := q.WaitForCapacity(ctx); err != nil {
+		return err
 	}
+	return q.enqueue(job)
+}
 
-	if job.MaxRetries == 0 {
-		job.MaxRetries = 3 // Default max retries
+// enqueue persists a job and hands it to the workers. Jobs are persisted first
+// so that nothing is lost; the database loader picks up any job that doesn't
+// fit in the buffer, and is the only way jobs reach worker processes when this
+// queue was never started.
+func (q *JobQueue) enqueue(job *Job) error {
+	if err := job.validatePayload(); err != nil {
+		return err
 	}
+	prepareJob(job)
 
-	job.Status = JobStatusPending
+	if q.IsDraining() {
+		return fmt.Errorf("queue is draining")
+	}
 
 	// Store job in database for persistence
 	if err := q.persistJob(job); err != nil {
 		return fmt.Errorf("failed to persist job: %w", err)
 	}
 
-	select {
-	case q.jobs <- job:
-		q.logInfo("Job %s (%s) added to queue", job.ID, job.Type)
+	return q.dispatch(job)
+}
+
+// dispatch hands a persisted job to the workers of this process if the queue
+// runs here and the buffer has room; otherwise it stays pending in the
+// database for the loader
+func (q *JobQueue) dispatch(job *Job) error {
+	// Hold the read lock so Stop can't close the channel while we send
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if !q.started {
+		q.logInfo(job, "Job %s (%s) saved for the worker service", job.ID, job.Type)
 		return nil
-	case <-q.ctx.Done():
+	}
+
+	if q.ctx.Err() != nil {
 		return fmt.Errorf("queue is shutting down")
+	}
+
+	if q.inBlackout(job) {
+		q.logInfo(job, "Job %s (%s) left pending in database until the backup blackout (%s) ends", job.ID, job.Type, q.blackout)
+		return nil
+	}
+
+	select {
+	case q.jobs <- job:
+		q.logInfo(job, "Job %s (%s) added to queue", job.ID, job.Type)
 	default:
-		return fmt.Errorf("queue is full")
+		// Lost a race for the last slot; the job is pending in the database
+		q.logInfo(job, "Job %s (%s) left pending in database, buffer is full", job.ID, job.Type)
+	}
+	return nil
+}
+
+// prepareJob fills in the defaults of a new job
+func prepareJob(job *Job) {
+	if job.ID == "" {
+		job.ID = generateJobID()
+	}
+
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
 	}
+
+	if job.MaxRetries == 0 {
+		job.MaxRetries = 3 // Default max retries
+	}
+
+	job.Status = JobStatusPending
 }
 
-// AddBackupJob creates and adds a backup job
-func (q *JobQueue) AddBackupJob(postgresID, databaseName string, backupType models.BackupType, priority int) (*Job, error) {
+// AddBackupJob creates and adds a backup job. It fails with a
+// DuplicateBackupError while a backup of the same database and type is
+// pending, running or waiting for a retry, unless the Force option is given.
+func (q *JobQueue) AddBackupJob(postgresID, databaseName string, backupType models.BackupType, priority int, opts ...JobOption) (*Job, error) {
 	// Create job without creating backup record (backup should be created by API)
 	job := &Job{
 		Type:     JobTypeBackup,
@@ -191,15 +679,145 @@ func (q *JobQueue) AddBackupJob(postgresID, databaseName string, backupType mode
 		MaxRetries: 3,
 	}
 
-	if err := q.AddJob(job); err != nil {
+	job.applyOptions(opts)
+
+	if !q.hasRoom() {
+		return nil, ErrQueueFull
+	}
+	if err := job.validatePayload(); err != nil {
+		return nil, err
+	}
+	prepareJob(job)
+	if q.IsDraining() {
+		return nil, fmt.Errorf("queue is draining")
+	}
+
+	// Check and insert under the same lock as saveBackupJob
+	tx, err := q.dbService.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := q.checkInFlightBackup(tx, job); err != nil {
+		return nil, err
+	}
+	if err := insertJob(tx, job); err != nil {
+		return nil, fmt.Errorf("failed to persist job: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to persist job: %w", err)
+	}
+	q.logInfo(job, "Job %s (%s) persisted to database", job.ID, job.Type)
+
+	if err := q.dispatch(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// EnqueueBackup saves a backup record and the job that performs it in one
+// transaction, linking the two through backup_id and job_id, and adds the job
+// to the queue
+func (q *JobQueue) EnqueueBackup(backup *models.BackupInfo, priority int, opts ...JobOption) (*Job, error) {
+	// Don't leave a pending backup record behind for a job that can't be queued
+	if !q.hasRoom() {
+		return nil, ErrQueueFull
+	}
+
+	job, err := q.saveBackupJob(backup, priority, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.dispatch(job); err != nil {
+		return nil, fmt.Errorf("failed to add job to queue: %w", err)
+	}
+	return job, nil
+}
+
+// SaveBackupJob saves a backup record and the job that performs it in one
+// transaction, without handing the job to the buffer: the database loader
+// picks it up. Unlike EnqueueBackup it doesn't fail when the buffer is full.
+func (q *JobQueue) SaveBackupJob(backup *models.BackupInfo, priority int, opts ...JobOption) (*Job, error) {
+	job, err := q.saveBackupJob(backup, priority, opts)
+	if err != nil {
 		return nil, err
 	}
 
+	q.logInfo(job, "Job %s (%s) saved for the database loader", job.ID, job.Type)
 	return job, nil
 }
 
+// saveBackupJob inserts a backup record and its job together, so that either
+// both exist or neither does. Like AddBackupJob it refuses duplicates of an
+// in-flight backup unless forced.
+func (q *JobQueue) saveBackupJob(backup *models.BackupInfo, priority int, opts []JobOption) (*Job, error) {
+	if q.IsDraining() {
+		return nil, fmt.Errorf("queue is draining")
+	}
+
+	job := newBackupJob(backup, priority, opts)
+	if err := job.validatePayload(); err != nil {
+		return nil, err
+	}
+	prepareJob(job)
+	backup.JobID = job.ID
+
+	tx, err := q.dbService.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := q.checkInFlightBackup(tx, job); err != nil {
+		return nil, err
+	}
+	if err := database.NewBackupRepository(q.dbService).CreateTx(tx, backup); err != nil {
+		return nil, fmt.Errorf("failed to create backup record: %w", err)
+	}
+	if err := insertJob(tx, job); err != nil {
+		return nil, fmt.Errorf("failed to persist job: %w", err)
+	}
+	if job.withTx != nil {
+		if err := job.withTx(tx); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to save backup and job: %w", err)
+	}
+	return job, nil
+}
+
+// newBackupJob builds the job performing backup, defaulting the backup's
+// creator to the job's
+func newBackupJob(backup *models.BackupInfo, priority int, opts []JobOption) *Job {
+	job := &Job{
+		Type:     JobTypeBackup,
+		Priority: priority,
+		Payload: map[string]interface{}{
+			"postgres_id":   backup.PostgreSQLID,
+			"database_name": backup.DatabaseName,
+			"backup_type":   string(backup.BackupType),
+			"backup_id":     backup.ID, // Include backup_id for worker
+		},
+		MaxRetries: 3,
+	}
+
+	job.applyOptions(opts)
+
+	if backup.CreatedBy == "" {
+		backup.CreatedBy = job.CreatedBy()
+	}
+	if noBlobs, _ := job.Payload["no_blobs"].(bool); noBlobs {
+		backup.BlobsExcluded = true
+	}
+	return job
+}
+
 // AddRestoreJob creates and adds a restore job
-func (q *JobQueue) AddRestoreJob(backupID, postgresID, databaseName string, priority int) (*Job, error) {
+func (q *JobQueue) AddRestoreJob(backupID, postgresID, databaseName string, priority int, opts ...JobOption) (*Job, error) {
 	job := &Job{
 		Type:     JobTypeRestore,
 		Priority: priority,
@@ -211,6 +829,8 @@ func (q *JobQueue) AddRestoreJob(backupID, postgresID, databaseName string, prio
 		MaxRetries: 1, // Restore jobs should not retry automatically
 	}
 
+	job.applyOptions(opts)
+
 	if err := q.AddJob(job); err != nil {
 		return nil, err
 	}
@@ -219,7 +839,7 @@ func (q *JobQueue) AddRestoreJob(backupID, postgresID, databaseName string, prio
 }
 
 // AddCleanupJob creates and adds a cleanup job
-func (q *JobQueue) AddCleanupJob(postgresID string, backupType models.BackupType, priority int) (*Job, error) {
+func (q *JobQueue) AddCleanupJob(postgresID string, backupType models.BackupType, priority int, opts ...JobOption) (*Job, error) {
 	job := &Job{
 		Type:     JobTypeCleanup,
 		Priority: priority,
@@ -230,6 +850,95 @@ func (q *JobQueue) AddCleanupJob(postgresID string, backupType models.BackupType
 		MaxRetries: 2,
 	}
 
+	job.applyOptions(opts)
+
+	if err := q.AddJob(job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// AddPurgeJob creates a cleanup job that deletes every backup of an instance,
+// protected ones included, and then the instance itself
+func (q *JobQueue) AddPurgeJob(postgresID string, priority int, opts ...JobOption) (*Job, error) {
+	job := &Job{
+		Type:     JobTypeCleanup,
+		Priority: priority,
+		Payload: map[string]interface{}{
+			"postgres_id": postgresID,
+			"purge":       true,
+		},
+		MaxRetries: 2,
+	}
+
+	job.applyOptions(opts)
+
+	if err := q.AddJob(job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// AddEmptyTrashJob creates a cleanup job that permanently deletes trashed
+// backups whose grace period has ended
+func (q *JobQueue) AddEmptyTrashJob(priority int, opts ...JobOption) (*Job, error) {
+	job := &Job{
+		Type:     JobTypeCleanup,
+		Priority: priority,
+		Payload: map[string]interface{}{
+			"empty_trash": true,
+		},
+		MaxRetries: 2,
+	}
+
+	job.applyOptions(opts)
+
+	if err := q.AddJob(job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// AddBaseBackupJob creates and adds a pg_basebackup job for an instance
+func (q *JobQueue) AddBaseBackupJob(postgresID string, priority int, opts ...JobOption) (*Job, error) {
+	job := &Job{
+		Type:     JobTypeBaseBackup,
+		Priority: priority,
+		Payload: map[string]interface{}{
+			"postgres_id": postgresID,
+		},
+		MaxRetries: 1, // Base backups are expensive; don't retry automatically
+	}
+
+	job.applyOptions(opts)
+
+	if err := q.AddJob(job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// AddSchemaDiffJob creates a job comparing the schema of two backups. The
+// instance and database of the base backup fill the job's required columns.
+func (q *JobQueue) AddSchemaDiffJob(baseBackup *models.BackupInfo, targetBackupID string, priority int, opts ...JobOption) (*Job, error) {
+	job := &Job{
+		Type:     JobTypeSchemaDiff,
+		Priority: priority,
+		Payload: map[string]interface{}{
+			"backup_id":        baseBackup.ID,
+			"target_backup_id": targetBackupID,
+			"postgres_id":      baseBackup.PostgreSQLID,
+			"database_name":    baseBackup.DatabaseName,
+		},
+		MaxRetries: 1,
+	}
+
+	job.applyOptions(opts)
+
 	if err := q.AddJob(job); err != nil {
 		return nil, err
 	}
@@ -277,15 +986,23 @@ func (q *JobQueue) GetRunningJobs() []*Job {
 	return runningJobs
 }
 
-// GetWorkerStatus returns status of all workers
+// GetWorkerStatus returns status of all workers, with their lifetime counters
+// when they can be read from the database
 func (q *JobQueue) GetWorkerStatus() []WorkerStatus {
 	q.mu.RLock()
-	defer q.mu.RUnlock()
-
 	status := make([]WorkerStatus, len(q.workers))
 	for i, worker := range q.workers {
 		status[i] = worker.GetStatus()
 	}
+	q.mu.RUnlock()
+
+	lifetime, err := database.NewWorkerStatsRepository(q.dbService).GetAll()
+	if err != nil {
+		return status
+	}
+	for i := range status {
+		status[i].Lifetime = lifetime[status[i].ID]
+	}
 
 	return status
 }
@@ -297,8 +1014,28 @@ func (q *JobQueue) IsRunning() bool {
 	return q.running
 }
 
+// Started returns whether this process runs workers, i.e. whether Start was
+// ever called. API-only processes only enqueue jobs and never start the queue.
+func (q *JobQueue) Started() bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.started
+}
+
 // persistJob saves job to database
 func (q *JobQueue) persistJob(job *Job) error {
+	if err := insertJob(q.dbService, job); err != nil {
+		return err
+	}
+
+	q.logInfo(job, "Job %s (%s) persisted to database", job.ID, job.Type)
+	return nil
+}
+
+// insertJob inserts a job row through a database or a transaction
+func insertJob(db interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}, job *Job) error {
 	query := `
 		INSERT INTO jobs (id, type, postgres_id, database_name, backup_id, priority, payload, status, retry_count, max_retries, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
@@ -309,10 +1046,17 @@ func (q *JobQueue) persistJob(job *Job) error {
 	databaseName, _ := job.Payload["database_name"].(string)
 	backupID, _ := job.Payload["backup_id"].(string)
 
-	// Use NULL for payload instead of empty string
+	// Store the full payload so options survive a round trip through the database
 	var payloadJSON interface{} = nil
+	if len(job.Payload) > 0 {
+		data, err := json.Marshal(job.Payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode job payload: %w", err)
+		}
+		payloadJSON = string(data)
+	}
 
-	_, err := q.dbService.Exec(query,
+	_, err := db.Exec(query,
 		job.ID,
 		string(job.Type),
 		postgresID,
@@ -329,8 +1073,6 @@ func (q *JobQueue) persistJob(job *Job) error {
 	if err != nil {
 		return fmt.Errorf("failed to insert job into database: %w", err)
 	}
-
-	q.logInfo("Job %s (%s) persisted to database", job.ID, job.Type)
 	return nil
 }
 
@@ -338,16 +1080,25 @@ func (q *JobQueue) persistJob(job *Job) error {
 func (q *JobQueue) updateJobStatus(job *Job) error {
 	query := `
 		UPDATE jobs 
-		SET status = $1, retry_count = $2, started_at = $3, completed_at = $4, error_message = $5
-		WHERE id = $6
+		SET status = $1, retry_count = $2, started_at = $3, completed_at = $4, error_message = $5, payload = COALESCE($6::jsonb, payload)
+		WHERE id = $7 AND status <> 'cancelled'
 	`
 
-	_, err := q.dbService.Exec(query,
+	// Workers may add results to the payload, e.g. a restore validation report
+	var payloadJSON interface{} = nil
+	if len(job.Payload) > 0 {
+		if data, err := json.Marshal(job.Payload); err == nil {
+			payloadJSON = string(data)
+		}
+	}
+
+	result, err := q.dbService.Exec(query,
 		string(job.Status),
 		job.RetryCount,
 		job.StartedAt,
 		job.CompletedAt,
 		job.Error,
+		payloadJSON,
 		job.ID,
 	)
 
@@ -355,17 +1106,151 @@ func (q *JobQueue) updateJobStatus(job *Job) error {
 		return fmt.Errorf("failed to update job status in database: %w", err)
 	}
 
-	q.logInfo("Job %s status updated to %s", job.ID, job.Status)
+	// A cancel stands: the job was cancelled before a worker claimed it
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		q.logInfo(job, "Job %s was cancelled, keeping that over status %s", job.ID, job.Status)
+		return nil
+	}
+
+	if err := q.dbService.NotifyJobActivity(job.ID); err != nil {
+		q.logError(job, "Failed to notify activity of job %s: %v", job.ID, err)
+	}
+
+	q.logInfo(job, "Job %s status updated to %s", job.ID, job.Status)
 	return nil
 }
 
+// claimJob marks a job handed over by dispatch as running in the database, so
+// that the loader doesn't run it a second time, cancelling it is refused and
+// restore slots count it. It reports false when the job is no longer pending:
+// cancelled while buffered, or taken by a loader first.
+func (q *JobQueue) claimJob(job *Job) (bool, error) {
+	if job.claimed {
+		return true, nil
+	}
+	result, err := q.dbService.Exec(`UPDATE jobs SET status = 'running', started_at = $1 WHERE id = $2 AND status = 'pending'`, time.Now(), job.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark job as running: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return false, nil
+	}
+	job.claimed = true
+	return true, nil
+}
+
+// RecentFailures counts the jobs that finished within window and how many of
+// them failed, across all processes
+func (q *JobQueue) RecentFailures(window time.Duration) (failed, finished int, err error) {
+	query := `
+		SELECT COUNT(*) FILTER (WHERE status = 'failed'), COUNT(*)
+		FROM jobs
+		WHERE status IN ('completed', 'failed') AND completed_at >= $1`
+	err = q.dbService.QueryRow(query, time.Now().Add(-window)).Scan(&failed, &finished)
+	return failed, finished, err
+}
+
+// GetJobStatus reads a job's status and error message from the database, which
+// sees jobs of every process. It returns sql.ErrNoRows for unknown jobs.
+func (q *JobQueue) GetJobStatus(jobID string) (JobStatus, string, error) {
+	var status string
+	var errorMessage sql.NullString
+	err := q.dbService.QueryRow("SELECT status, error_message FROM jobs WHERE id = $1", jobID).Scan(&status, &errorMessage)
+	if err != nil {
+		return "", "", err
+	}
+	return JobStatus(status), errorMessage.String, nil
+}
+
+// GetJobPayload reads a job's payload, including the results workers stored
+// in it, from the database. It returns sql.ErrNoRows for unknown jobs.
+func (q *JobQueue) GetJobPayload(jobID string) (map[string]interface{}, error) {
+	var payload sql.NullString
+	if err := q.dbService.QueryRow("SELECT payload FROM jobs WHERE id = $1", jobID).Scan(&payload); err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{})
+	if payload.Valid && payload.String != "" {
+		if err := json.Unmarshal([]byte(payload.String), &result); err != nil {
+			return nil, fmt.Errorf("failed to parse payload of job %s: %w", jobID, err)
+		}
+	}
+	return result, nil
+}
+
+// CancelPendingJobs marks the pending and retrying jobs matching jobType and
+// postgresID (empty matches any) as cancelled, so neither the loader nor a
+// worker holding them in its buffer runs them. Jobs a worker has claimed are
+// running and left alone. Backups waiting on a cancelled
+// job are failed. It returns the number of cancelled jobs.
+func (q *JobQueue) CancelPendingJobs(jobType JobType, postgresID string) (int, error) {
+	query := `
+		UPDATE jobs SET status = 'cancelled', completed_at = $1, error_message = 'cancelled'
+		WHERE status IN ('pending', 'retrying')`
+	args := []interface{}{time.Now()}
+	if jobType != "" {
+		args = append(args, string(jobType))
+		query += fmt.Sprintf(" AND type = $%d", len(args))
+	}
+	if postgresID != "" {
+		args = append(args, postgresID)
+		query += fmt.Sprintf(" AND postgres_id = $%d", len(args))
+	}
+	query += " RETURNING id"
+
+	tx, err := q.dbService.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cancel jobs: %w", err)
+	}
+	var jobIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		jobIDs = append(jobIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(jobIDs) > 0 {
+		_, err = tx.Exec(`
+			UPDATE backups SET status = 'failed', error_message = 'job cancelled', end_time = $1
+			WHERE job_id = ANY($2) AND status = 'pending'`, time.Now(), pq.Array(jobIDs))
+		if err != nil {
+			return 0, fmt.Errorf("failed to fail backups of cancelled jobs: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if len(jobIDs) > 0 {
+		// An empty job ID wakes every job stream
+		if err := q.dbService.NotifyJobActivity(""); err != nil {
+			q.logError(nil, "Failed to notify job activity: %v", err)
+		}
+		q.logInfo(nil, "Cancelled %d pending jobs (type=%q, postgres_id=%q)", len(jobIDs), jobType, postgresID)
+	}
+	return len(jobIDs), nil
+}
+
 // UpdateJobStatus is a public method to update job status (used by workers)
 func (q *JobQueue) UpdateJobStatus(job *Job) error {
 	return q.updateJobStatus(job)
 }
 
 // updateStats periodically updates queue statistics
-func (q *JobQueue) updateStats() {
+func (q *JobQueue) updateStats(ctx context.Context) {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
@@ -373,7 +1258,7 @@ func (q *JobQueue) updateStats() {
 		select {
 		case <-ticker.C:
 			q.refreshStats()
-		case <-q.ctx.Done():
+		case <-ctx.Done():
 			return
 		}
 	}
@@ -396,69 +1281,89 @@ func (q *JobQueue) refreshStats() {
 	q.stats.PendingJobs = int64(len(q.jobs))
 }
 
-// logInfo logs informational messages
-func (q *JobQueue) logInfo(format string, args ...interface{}) {
-	message := fmt.Sprintf(format, args...)
-	log.Printf("[QUEUE] %s", message)
+// logInfo logs queue messages. Messages about a job carry its ID and the
+// ID of the API request that created it; job is nil for the others.
+func (q *JobQueue) logInfo(job *Job, format string, args ...interface{}) {
+	q.writeLog(logger.LogLevelInfo, job, fmt.Sprintf(format, args...))
+}
 
-	// Also log to database
-	entry := &database.LogEntry{
-		Timestamp: time.Now(),
-		Level:     "INFO",
-		Component: "QUEUE",
-		Message:   message,
-	}
-	q.logRepo.Create(entry)
+// logError logs error messages, like logInfo
+func (q *JobQueue) logError(job *Job, format string, args ...interface{}) {
+	q.writeLog(logger.LogLevelError, job, fmt.Sprintf(format, args...))
 }
 
-// logError logs error messages
-func (q *JobQueue) logError(format string, args ...interface{}) {
-	message := fmt.Sprintf(format, args...)
-	log.Printf("[QUEUE] ERROR: %s", message)
+// writeLog emits a message and saves it to the logs table
+func (q *JobQueue) writeLog(level logger.LogLevel, job *Job, message string) {
+	var jobID, requestID string
+	if job != nil {
+		jobID, requestID = job.ID, job.RequestID()
+	}
+	logger.Emit(logger.Entry{Level: level, Component: "QUEUE", JobID: jobID, RequestID: requestID, Message: message})
 
 	// Also log to database
 	entry := &database.LogEntry{
 		Timestamp: time.Now(),
-		Level:     "ERROR",
+		Level:     string(level),
 		Component: "QUEUE",
+		JobID:     jobID,
 		Message:   message,
 	}
+	if requestID != "" {
+		if details, err := json.Marshal(map[string]string{"request_id": requestID}); err == nil {
+			entry.Details = string(details)
+		}
+	}
 	q.logRepo.Create(entry)
 }
 
+// jobLoaderInterval is how often workers poll the database for pending jobs
+const jobLoaderInterval = 5 * time.Second
+
 // loadJobsFromDatabase periodically loads pending jobs from database
-func (q *JobQueue) loadJobsFromDatabase() {
-	ticker := time.NewTicker(5 * time.Second) // Check every 5 seconds
+func (q *JobQueue) loadJobsFromDatabase(ctx context.Context) {
+	ticker := time.NewTicker(jobLoaderInterval)
 	defer ticker.Stop()
 
-	q.logInfo("Database job loader started - checking every 5 seconds")
+	q.logInfo(nil, "Database job loader started - checking every %v", jobLoaderInterval)
 
 	for {
 		select {
-		case <-q.ctx.Done():
-			q.logInfo("Database job loader stopping...")
+		case <-ctx.Done():
+			q.logInfo(nil, "Database job loader stopping...")
 			return
 		case <-ticker.C:
-			q.logInfo("Checking for pending jobs in database...")
+			// Also picks up a pause requested through another process
+			if q.refreshPaused() {
+				continue
+			}
+
+			q.logInfo(nil, "Checking for pending jobs in database...")
 			// Load pending jobs from database
 			query := `
 				SELECT id, type, postgres_id, database_name, backup_id, priority, payload, retry_count, max_retries, created_at
 				FROM jobs 
-				WHERE status = 'pending' 
+				WHERE (status = 'pending' 
 				   OR status = 'retrying'
-				   OR (status = 'running' AND started_at < NOW() - INTERVAL '5 minutes')
+				   OR (status = 'running' AND started_at < NOW() - INTERVAL '5 minutes'))
+				  AND NOT (type = ANY($1))
 				ORDER BY priority DESC, created_at ASC
 				LIMIT 10
 			`
 
-			rows, err := q.dbService.Query(query)
+			// Backups wait in pending while the blackout window is open
+			heldTypes := []string{}
+			if q.blackout.Active(time.Now()) {
+				heldTypes = blackoutJobTypes
+			}
+
+			rows, err := q.dbService.Query(query, pq.Array(heldTypes))
 			if err != nil {
-				q.logError("Failed to query pending jobs: %v", err)
+				q.logError(nil, "Failed to query pending jobs: %v", err)
 				continue
 			}
 
 			var jobsLoaded int
-			q.logInfo("Scanning jobs from query result...")
+			q.logInfo(nil, "Scanning jobs from query result...")
 			for rows.Next() {
 				var job Job
 				var payload sql.NullString
@@ -471,7 +1376,7 @@ func (q *JobQueue) loadJobsFromDatabase() {
 					&job.MaxRetries, &createdAtStr,
 				)
 				if err != nil {
-					q.logError("Failed to scan job row: %v", err)
+					q.logError(nil, "Failed to scan job row: %v", err)
 					continue
 				}
 
@@ -492,19 +1397,26 @@ func (q *JobQueue) loadJobsFromDatabase() {
 					"backup_type":   "manual", // Valid backup type from CHECK constraint
 				}
 
-				// If payload from database exists, try to parse it (for future use)
+				// The stored payload carries everything else (backup_type, options, request_id)
 				if payload.Valid && payload.String != "" {
-					// For now, we use the fields from database directly
-					// In the future, we could parse JSON payload here
+					var stored map[string]interface{}
+					if err := json.Unmarshal([]byte(payload.String), &stored); err != nil {
+						q.logError(&job, "Failed to parse payload of job %s: %v", job.ID, err)
+					} else {
+						for key, value := range stored {
+							job.Payload[key] = value
+						}
+					}
 				}
 
 				job.Status = JobStatusPending
 
-				// Mark job as running in database to avoid duplicate processing
-				updateQuery := `UPDATE jobs SET status = 'running', started_at = $1 WHERE id = $2 AND status = 'pending'`
+				// Mark job as running in database to avoid duplicate processing;
+				// failed jobs left retrying by a worker run again here
+				updateQuery := `UPDATE jobs SET status = 'running', started_at = $1 WHERE id = $2 AND status IN ('pending', 'retrying')`
 				result, err := q.dbService.Exec(updateQuery, time.Now(), job.ID)
 				if err != nil {
-					q.logError("Failed to mark job as running: %v", err)
+					q.logError(&job, "Failed to mark job as running: %v", err)
 					continue
 				}
 
@@ -513,25 +1425,26 @@ func (q *JobQueue) loadJobsFromDatabase() {
 					// Job was already picked up by another worker
 					continue
 				}
+				job.claimed = true
 
 				// Try to add job to queue (non-blocking)
 				select {
 				case q.jobs <- &job:
 					jobsLoaded++
-					q.logInfo("Loaded job %s from database (%s)", job.ID, job.Type)
+					q.logInfo(&job, "Loaded job %s from database (%s)", job.ID, job.Type)
 				default:
 					// Queue is full, mark job back as pending
 					rollbackQuery := `UPDATE jobs SET status = 'pending', started_at = NULL WHERE id = $1`
 					q.dbService.Exec(rollbackQuery, job.ID)
-					q.logInfo("Job queue full, job %s rolled back to pending", job.ID)
+					q.logInfo(&job, "Job queue full, job %s rolled back to pending", job.ID)
 				}
 			}
 			rows.Close()
 
 			if jobsLoaded > 0 {
-				q.logInfo("Loaded %d jobs from database", jobsLoaded)
+				q.logInfo(nil, "Loaded %d jobs from database", jobsLoaded)
 			} else {
-				q.logInfo("No pending jobs found in database")
+				q.logInfo(nil, "No pending jobs found in database")
 			}
 		}
 	}
@@ -539,5 +1452,5 @@ func (q *JobQueue) loadJobsFromDatabase() {
 
 // generateJobID generates a unique job ID
 func generateJobID() string {
-	return fmt.Sprintf("job_%d", time.Now().UnixNano())
+	return config.NewID("job")
 }
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"database/sql"
+	"time"
+)
+
+// queuePausedKey is the config table key holding the pause flag, shared by the
+// API and worker processes
+const queuePausedKey = "queue_paused"
+
+// pausePollInterval is how often an idle worker rechecks a paused queue
+const pausePollInterval = time.Second
+
+// Pause stops workers from taking new jobs and the loader from loading them;
+// running jobs finish normally. Worker processes pick it up on their next
+// loader tick.
+func (q *JobQueue) Pause() error {
+	return q.setPaused(true)
+}
+
+// Resume lets workers take jobs again after Pause
+func (q *JobQueue) Resume() error {
+	return q.setPaused(false)
+}
+
+func (q *JobQueue) setPaused(paused bool) error {
+	value := "false"
+	if paused {
+		value = "true"
+	}
+	_, err := q.dbService.Exec(`
+		INSERT INTO config (key, value, description, updated_at)
+		VALUES ($1, $2, 'Workers take no new jobs while true', NOW())
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_at = NOW()`,
+		queuePausedKey, value)
+	if err != nil {
+		return err
+	}
+
+	q.paused.Store(paused)
+	if paused {
+		q.logInfo(nil, "Queue paused: no new jobs will start")
+	} else {
+		q.logInfo(nil, "Queue resumed")
+	}
+	return nil
+}
+
+// Paused reads the pause flag from the database
+func (q *JobQueue) Paused() (bool, error) {
+	var value string
+	err := q.dbService.QueryRow("SELECT value FROM config WHERE key = $1", queuePausedKey).Scan(&value)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return value == "true", nil
+}
+
+// refreshPaused updates the cached pause flag, keeping the last known state
+// when the database can't be read
+func (q *JobQueue) refreshPaused() bool {
+	paused, err := q.Paused()
+	if err != nil {
+		q.logError(nil, "Failed to read queue pause flag: %v", err)
+		return q.paused.Load()
+	}
+	if paused != q.paused.Swap(paused) {
+		if paused {
+			q.logInfo(nil, "Queue paused: no new jobs will start")
+		} else {
+			q.logInfo(nil, "Queue resumed")
+		}
+	}
+	return paused
+}
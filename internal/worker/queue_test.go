@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"database/sql"
+	"evolution-postgres-backup/internal/database"
+	"sync"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+// newOfflineQueue returns a queue whose database refuses connections, so
+// every query fails fast the way it does while PostgreSQL is down
+func newOfflineQueue(t *testing.T, workers int) *JobQueue {
+	t.Helper()
+	sqlDB, err := sql.Open("postgres", "host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return NewJobQueue(workers, &database.DB{DB: sqlDB})
+}
+
+func TestStartStopUnderLoadDoesNotPanic(t *testing.T) {
+	q := newOfflineQueue(t, 2)
+
+	// Producers keep handing jobs to the queue while it is stopped and
+	// started again; a send on the closed channel would panic
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				q.dispatch(&Job{ID: generateJobID(), Type: JobTypeCleanup, Payload: map[string]interface{}{}})
+			}
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		if err := q.Start(); err != nil {
+			t.Fatalf("start %d: %v", i, err)
+		}
+		q.Stop()
+	}
+	close(stop)
+	wg.Wait()
+
+	if q.IsRunning() {
+		t.Error("queue still running after Stop")
+	}
+}
@@ -0,0 +1,177 @@
+package worker
+
+import (
+	"compress/gzip"
+	"evolution-postgres-backup/internal/config"
+	"evolution-postgres-backup/internal/database"
+	"evolution-postgres-backup/internal/models"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// backupDumpFormat returns the format recorded on the backup, and otherwise
+// identifies the file at path (the backup's dump) by its leading bytes, e.g.
+// for backups migrated from the JSON store or taken before formats were recorded
+func backupDumpFormat(backup *models.BackupInfo, path string) (string, error) {
+	switch backup.Format {
+	case DumpFormatPlain, DumpFormatPlainGzip, DumpFormatCustom:
+		return backup.Format, nil
+	}
+	return detectDumpFormat(path)
+}
+
+// fileDownloader is implemented by object stores that can fetch objects
+type fileDownloader interface {
+	DownloadFile(key, localPath string) error
+}
+
+// fetchDumpFile returns the path of a backup's dump. When the local file is
+// gone but the backup was uploaded, the S3 copy is downloaded into dir; the
+// returned function removes that download.
+func (w *Worker) fetchDumpFile(job *Job, backup *models.BackupInfo, dir string) (string, func(), error) {
+	if err := checkDumpBackup(backup); err != nil {
+		return "", nil, err
+	}
+	if backup.FilePath != "" {
+		if _, err := os.Stat(backup.FilePath); err == nil {
+			return backup.FilePath, func() {}, nil
+		}
+	}
+	if backup.S3Key == "" {
+		return "", nil, checkDumpFile(backup)
+	}
+	if backup.NeedsRetrieval() {
+		return "", nil, fmt.Errorf("backup %s is archived in %s; retrieve %s from object storage first", backup.ID, backup.StorageClass, backup.S3Key)
+	}
+
+	downloader, ok := w.jobQueue.getObjectStore().(fileDownloader)
+	if !ok {
+		return "", nil, fmt.Errorf("backup %s has no local file and no object storage is configured to download %s", backup.ID, backup.S3Key)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	file, err := os.CreateTemp(dir, "download-*-"+filepath.Base(backup.S3Key))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create download file: %w", err)
+	}
+	path := file.Name()
+	file.Close()
+
+	w.logJobProgress(job.ID, backup.ID, "No local file, downloading %s from S3", backup.S3Key)
+	if err := downloader.DownloadFile(backup.S3Key, path); err != nil {
+		os.Remove(path)
+		return "", nil, fmt.Errorf("failed to download backup %s: %w", backup.ID, err)
+	}
+	return path, func() { os.Remove(path) }, nil
+}
+
+// restoreCommand builds the command restoring an archive of the given format
+// into databaseName: pg_restore for custom archives, psql fed the (gunzipped)
+// script otherwise. pgRestoreArgs only apply to pg_restore. The returned
+// function closes the script the command reads.
+func restoreCommand(pgInstance *config.PostgreSQLConfig, path, format, databaseName string, pgRestoreArgs ...string) (*exec.Cmd, func(), error) {
+	connArgs := []string{"-h", pgInstance.Host, "-p", fmt.Sprintf("%d", pgInstance.Port), "-U", pgInstance.Username, "-d", databaseName}
+	closeInput := func() {}
+
+	var cmd *exec.Cmd
+	switch format {
+	case DumpFormatCustom:
+		args := append(connArgs, pgRestoreArgs...)
+		args = append(args, "--exit-on-error", "--no-password", path)
+		cmd = exec.Command(config.ToolPath(config.ToolPgRestore), args...)
+	default:
+		cmd = exec.Command(config.ToolPath(config.ToolPsql),
+			append(connArgs, "-v", "ON_ERROR_STOP=1", "--quiet", "--no-password")...)
+
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		closeInput = func() { file.Close() }
+
+		if format == DumpFormatPlainGzip {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				file.Close()
+				return nil, nil, err
+			}
+			closeInput = func() {
+				gz.Close()
+				file.Close()
+			}
+			cmd.Stdin = gz
+		} else {
+			cmd.Stdin = file
+		}
+	}
+
+	cmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", pgInstance.Password))
+	cmd.Env = append(cmd.Env, pgInstance.SSLEnv()...)
+	cmd.Env = append(cmd.Env, config.ConnectTimeoutEnv()...)
+	return cmd, closeInput, nil
+}
+
+// restoreDatabase restores a whole backup into the target database, choosing
+// the tool and decompression from the backup's format
+func (w *Worker) restoreDatabase(job *Job, payload *RestorePayload) error {
+	backupID, postgresID, databaseName := payload.BackupID, payload.PostgresID, payload.DatabaseName
+
+	backup, err := database.NewBackupRepository(w.dbService).GetByID(backupID)
+	if err != nil {
+		return fmt.Errorf("failed to get backup %s: %w", backupID, err)
+	}
+	if err := checkDumpBackup(backup); err != nil {
+		return err
+	}
+
+	pgInstance, err := database.NewPostgreSQLRepository(w.dbService).GetByID(postgresID)
+	if err != nil {
+		return fmt.Errorf("failed to get postgres instance: %w", err)
+	}
+	tempDir := backupTempDir(pgInstance)
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	dumpPath, cleanupDump, err := w.fetchDumpFile(job, backup, tempDir)
+	if err != nil {
+		return err
+	}
+	defer cleanupDump()
+
+	format, err := backupDumpFormat(backup, dumpPath)
+	if err != nil {
+		return fmt.Errorf("failed to read backup file: %w", err)
+	}
+	if backup.Format == "" {
+		w.logJobProgress(job.ID, backupID, "Backup has no recorded format; detected %s from the file", format)
+	}
+	if backup.BlobsExcluded {
+		w.logJobProgress(job.ID, backupID, "⚠️ Backup was taken with no_blobs; large objects will not be restored")
+	}
+
+	pgInstance, cleanupSSL, err := pgInstance.WithSSLFiles(filepath.Join(tempDir, "ssl"))
+	if err != nil {
+		return fmt.Errorf("failed to prepare SSL certificates: %w", err)
+	}
+	defer cleanupSSL()
+	pgInstance = pgInstance.ForBackup()
+
+	cmd, closeInput, err := restoreCommand(pgInstance, dumpPath, format, databaseName)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer closeInput()
+
+	w.logJobProgress(job.ID, backupID, "Restoring %s archive %s with %s", format, dumpPath, filepath.Base(cmd.Path))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if poolerErr := pgInstance.PoolerError(filepath.Base(cmd.Path), string(output)); poolerErr != nil {
+			return poolerErr
+		}
+		return fmt.Errorf("%s failed: %v: %s", filepath.Base(cmd.Path), err, lastLines(string(output), 5))
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// RestoreLimitStats shows how busy the restore limit is across all worker
+// processes
+type RestoreLimitStats struct {
+	MaxConcurrent int   `json:"max_concurrent"` // 0 = unlimited
+	Running       int64 `json:"running"`
+	Waiting       int64 `json:"waiting"` // Restore jobs queued behind the limit
+}
+
+// restoreSlotPollInterval is how often a restore waiting for a slot checks
+// whether one was freed
+const restoreSlotPollInterval = 5 * time.Second
+
+// restoreSlotLock is the advisory lock key serializing slot claims
+const restoreSlotLock = "restore-slots"
+
+// maxRestoresFromEnv reads MAX_CONCURRENT_RESTORES, the number of restores
+// all worker processes run at once. 0, the default, means no limit.
+func maxRestoresFromEnv() (int, error) {
+	value := os.Getenv("MAX_CONCURRENT_RESTORES")
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("invalid MAX_CONCURRENT_RESTORES %q: use a number >= 0", value)
+	}
+	return limit, nil
+}
+
+// acquireRestoreSlot blocks until job may start restoring or the queue stops.
+// Slots are the running jobs with restore_slot = 'held', counted under an
+// advisory lock so the limit holds across worker processes. The returned
+// function releases the slot.
+func (q *JobQueue) acquireRestoreSlot(job *Job) (func(), error) {
+	if _, err := q.dbService.Exec("UPDATE jobs SET restore_slot = 'waiting' WHERE id = $1", job.ID); err != nil {
+		return nil, fmt.Errorf("failed to queue for a restore slot: %w", err)
+	}
+
+	logged := false
+	for {
+		acquired, err := q.claimRestoreSlot(job.ID)
+		if err != nil {
+			q.logError(job, "Job %s failed to claim a restore slot: %v", job.ID, err)
+		}
+		if acquired {
+			break
+		}
+		if !logged {
+			q.logInfo(job, "Job %s waiting for a restore slot (%d in use)", job.ID, q.maxRestores)
+			logged = true
+		}
+		select {
+		case <-time.After(restoreSlotPollInterval):
+		case <-q.ctx.Done():
+			q.releaseRestoreSlot(job.ID)
+			return nil, fmt.Errorf("queue stopped before a restore slot was free")
+		}
+	}
+
+	return func() { q.releaseRestoreSlot(job.ID) }, nil
+}
+
+// claimRestoreSlot marks job as holding a restore slot if fewer than
+// maxRestores other running jobs hold one
+func (q *JobQueue) claimRestoreSlot(jobID string) (bool, error) {
+	tx, err := q.dbService.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", restoreSlotLock); err != nil {
+		return false, fmt.Errorf("failed to lock restore slots: %w", err)
+	}
+	if q.maxRestores > 0 {
+		var held int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM jobs
+			WHERE status = 'running' AND restore_slot = 'held' AND id <> $1`, jobID).Scan(&held)
+		if err != nil {
+			return false, fmt.Errorf("failed to count restore slots: %w", err)
+		}
+		if held >= q.maxRestores {
+			return false, nil
+		}
+	}
+	if _, err := tx.Exec("UPDATE jobs SET restore_slot = 'held' WHERE id = $1", jobID); err != nil {
+		return false, fmt.Errorf("failed to claim restore slot: %w", err)
+	}
+	return true, tx.Commit()
+}
+
+// releaseRestoreSlot frees the slot held, or the place in line taken, by job
+func (q *JobQueue) releaseRestoreSlot(jobID string) {
+	if _, err := q.dbService.Exec("UPDATE jobs SET restore_slot = NULL WHERE id = $1", jobID); err != nil {
+		q.logError(nil, "Failed to release the restore slot of job %s: %v", jobID, err)
+	}
+}
+
+// GetRestoreLimit returns the restore limit and how many restores run or wait
+// in all worker processes
+func (q *JobQueue) GetRestoreLimit() (RestoreLimitStats, error) {
+	stats := RestoreLimitStats{MaxConcurrent: q.maxRestores}
+	err := q.dbService.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE restore_slot = 'held'),
+		       COUNT(*) FILTER (WHERE restore_slot = 'waiting')
+		FROM jobs
+		WHERE status = 'running'`).Scan(&stats.Running, &stats.Waiting)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count restores: %w", err)
+	}
+	return stats, nil
+}
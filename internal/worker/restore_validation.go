@@ -0,0 +1,280 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"evolution-postgres-backup/internal/config"
+	"evolution-postgres-backup/internal/database"
+	"evolution-postgres-backup/internal/models"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Dump archive formats
+const (
+	DumpFormatPlain     = "plain"      // SQL script
+	DumpFormatPlainGzip = "plain_gzip" // gzip-compressed SQL script
+	DumpFormatCustom    = "custom"     // pg_dump -Fc archive
+)
+
+// plainDumpTrailer is the last comment pg_dump writes to a complete SQL script
+const plainDumpTrailer = "-- PostgreSQL database dump complete"
+
+// RestoreValidation is the report of a restore dry run
+type RestoreValidation struct {
+	Format         string         `json:"format"`
+	Valid          bool           `json:"valid"`
+	ObjectCount    int            `json:"object_count"`
+	ObjectTypes    map[string]int `json:"object_types"`
+	ScratchRestore bool           `json:"scratch_restore"`
+	ScratchResult  string         `json:"scratch_result,omitempty"`
+	Error          string         `json:"error,omitempty"`
+}
+
+// validateRestore checks that a backup archive is restorable without touching
+// the target database, and stores the report in the job payload
+func (w *Worker) validateRestore(job *Job, payload *RestorePayload) error {
+	backupID, postgresID, scratch := payload.BackupID, payload.PostgresID, payload.ScratchRestore
+
+	backupRepo := database.NewBackupRepository(w.dbService)
+	backup, err := backupRepo.GetByID(backupID)
+	if err != nil {
+		return fmt.Errorf("failed to get backup %s: %w", backupID, err)
+	}
+	if backup.Status != models.BackupStatusCompleted {
+		return fmt.Errorf("backup %s is not completed", backupID)
+	}
+	if backup.Trashed() {
+		return fmt.Errorf("backup %s is in the trash; restore it from the trash first", backupID)
+	}
+	if backup.StorageClass != "" {
+		// Archiving removes the local file; Glacier classes also need a retrieval first
+		if _, err := os.Stat(backup.FilePath); err != nil {
+			if backup.NeedsRetrieval() {
+				return fmt.Errorf("backup %s is archived in %s; retrieve %s from object storage before restoring", backupID, backup.StorageClass, backup.S3Key)
+			}
+			return fmt.Errorf("backup %s was archived to %s and has no local file; download %s first", backupID, backup.StorageClass, backup.S3Key)
+		}
+	}
+	if backup.FilePath == "" {
+		return fmt.Errorf("backup %s has no local file", backupID)
+	}
+	for _, tag := range backup.Tags {
+		if tag == BaseBackupTag {
+			return fmt.Errorf("backup %s is a base backup; restore it with a PostgreSQL data directory, not pg_restore", backupID)
+		}
+	}
+
+	format, err := backupDumpFormat(backup, backup.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read backup file: %w", err)
+	}
+	w.logJobProgress(job.ID, backupID, "Validating %s archive %s", format, backup.FilePath)
+
+	report := &RestoreValidation{Format: format, ScratchRestore: scratch}
+	if format == DumpFormatCustom {
+		err = listCustomArchive(backup.FilePath, report)
+	} else {
+		err = scanPlainDump(backup.FilePath, format == DumpFormatPlainGzip, report)
+	}
+
+	if err == nil && scratch {
+		pgRepo := database.NewPostgreSQLRepository(w.dbService)
+		pgInstance, getErr := pgRepo.GetByID(postgresID)
+		if getErr != nil {
+			return fmt.Errorf("failed to get postgres instance: %w", getErr)
+		}
+		w.logJobProgress(job.ID, backupID, "Restoring into a temporary database")
+		err = scratchRestore(pgInstance, backup.FilePath, format)
+		if err == nil {
+			report.ScratchResult = "restored and dropped successfully"
+		}
+	}
+
+	report.Valid = err == nil
+	if err != nil {
+		report.Error = err.Error()
+	}
+	job.Payload["validation"] = report
+
+	if err != nil {
+		w.logJobProgress(job.ID, backupID, "Validation failed: %v", err)
+		return fmt.Errorf("backup %s is not restorable: %w", backupID, err)
+	}
+
+	w.logJobProgress(job.ID, backupID, "Validation passed: %d objects %v", report.ObjectCount, report.ObjectTypes)
+	return nil
+}
+
+// detectDumpFormat identifies an archive by its leading bytes
+func detectDumpFormat(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	header := make([]byte, 5)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("PGDMP")):
+		return DumpFormatCustom, nil
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return DumpFormatPlainGzip, nil
+	default:
+		return DumpFormatPlain, nil
+	}
+}
+
+// listCustomArchive runs pg_restore --list and counts the archive's objects by type
+func listCustomArchive(path string, report *RestoreValidation) error {
+	output, err := exec.Command(config.ToolPath(config.ToolPgRestore), "--list", path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("pg_restore --list failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	report.ObjectTypes = make(map[string]int)
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		// TOC lines look like "215; 1259 16386 TABLE public users postgres"
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		objectType := fields[3]
+		if objectType == "TABLE" && len(fields) > 4 && fields[4] == "DATA" {
+			objectType = "TABLE DATA"
+		}
+		report.ObjectTypes[objectType]++
+		report.ObjectCount++
+	}
+	return nil
+}
+
+// scanPlainDump reads a SQL script end to end, counting CREATE statements and
+// checking for the trailer pg_dump writes when a dump completes
+func scanPlainDump(path string, gzipped bool, report *RestoreValidation) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if gzipped {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("invalid gzip stream: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	report.ObjectTypes = make(map[string]int)
+	complete := false
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024) // COPY rows can be long
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, plainDumpTrailer) {
+			complete = true
+			continue
+		}
+		if objectType := createdObjectType(line); objectType != "" {
+			report.ObjectTypes[objectType]++
+			report.ObjectCount++
+		}
+		if strings.HasPrefix(line, "COPY ") {
+			report.ObjectTypes["TABLE DATA"]++
+			report.ObjectCount++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read dump: %w", err)
+	}
+	if !complete {
+		return fmt.Errorf("dump is truncated: %q trailer not found", plainDumpTrailer)
+	}
+	return nil
+}
+
+// createdObjectType returns e.g. "TABLE" for "CREATE TABLE public.users (", or ""
+func createdObjectType(line string) string {
+	if !strings.HasPrefix(line, "CREATE ") {
+		return ""
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, "CREATE "))
+	for len(fields) > 0 {
+		switch fields[0] {
+		case "OR", "REPLACE", "UNIQUE", "UNLOGGED", "TEMPORARY", "MATERIALIZED", "DEFAULT", "TRUSTED", "PROCEDURAL":
+			if fields[0] == "MATERIALIZED" && len(fields) > 1 {
+				return "MATERIALIZED VIEW"
+			}
+			fields = fields[1:]
+			continue
+		}
+		return fields[0]
+	}
+	return ""
+}
+
+// scratchRestore restores an archive into a temporary database and drops it
+func scratchRestore(pgInstance *config.PostgreSQLConfig, path, format string) error {
+	sslDir := filepath.Join(os.TempDir(), "postgres-backup-ssl")
+	pgInstance, cleanupSSL, err := pgInstance.WithSSLFiles(sslDir)
+	if err != nil {
+		return fmt.Errorf("failed to prepare SSL certificates: %w", err)
+	}
+	defer cleanupSSL()
+	pgInstance = pgInstance.ForBackup()
+
+	admin, err := sql.Open("postgres", pgInstance.ConnectionString("postgres", config.ConnectTimeout()))
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	scratchDB := fmt.Sprintf("restore_check_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE DATABASE " + scratchDB); err != nil {
+		return fmt.Errorf("failed to create temporary database: %w", err)
+	}
+	defer admin.Exec("DROP DATABASE IF EXISTS " + scratchDB)
+
+	cmd, closeInput, err := restoreCommand(pgInstance, path, format, scratchDB, "--no-owner", "--no-privileges")
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if poolerErr := pgInstance.PoolerError(filepath.Base(cmd.Path), string(output)); poolerErr != nil {
+			return poolerErr
+		}
+		return fmt.Errorf("restore into temporary database failed: %v: %s", err, lastLines(string(output), 5))
+	}
+	return nil
+}
+
+// lastLines returns at most n trailing lines of output
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
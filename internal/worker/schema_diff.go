@@ -0,0 +1,304 @@
+package worker
+
+import (
+	"bufio"
+	"compress/gzip"
+	"evolution-postgres-backup/internal/config"
+	"evolution-postgres-backup/internal/database"
+	"evolution-postgres-backup/internal/models"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxDiffCells bounds the line diff of one object; larger definitions are
+// shown as fully removed and re-added
+const maxDiffCells = 1000000
+
+// dumpObjectHeader matches the comment pg_dump writes before each object, e.g.
+// "-- Name: users; Type: TABLE; Schema: public; Owner: postgres"
+var dumpObjectHeader = regexp.MustCompile(`^-- (Data for )?Name: (.*?); Type: (.*?); Schema: (.*?);`)
+
+// SchemaDiff is the result of comparing the schema of two backups
+type SchemaDiff struct {
+	BaseBackupID   string         `json:"base_backup_id"`
+	TargetBackupID string         `json:"target_backup_id"`
+	Added          []string       `json:"added"`   // Objects only in the target
+	Removed        []string       `json:"removed"` // Objects only in the base
+	Changed        []SchemaChange `json:"changed"`
+	Unchanged      int            `json:"unchanged"`
+}
+
+// SchemaChange is an object whose definition differs between two backups.
+// Diff has the definition lines prefixed with "  ", "- " or "+ ".
+type SchemaChange struct {
+	Object string `json:"object"`
+	Diff   string `json:"diff"`
+}
+
+// diffSchemas compares the schema of two backups, and stores the result in the
+// job payload
+func (w *Worker) diffSchemas(job *Job) error {
+	baseID, _ := job.Payload["backup_id"].(string)
+	targetID, _ := job.Payload["target_backup_id"].(string)
+	if baseID == "" || targetID == "" {
+		return fmt.Errorf("missing backup_id or target_backup_id in job payload")
+	}
+
+	backupRepo := database.NewBackupRepository(w.dbService)
+	postgresRepo := database.NewPostgreSQLRepository(w.dbService)
+	schemas := make([]map[string]string, 2)
+	for i, backupID := range []string{baseID, targetID} {
+		backup, err := backupRepo.GetByID(backupID)
+		if err != nil {
+			return fmt.Errorf("failed to get backup %s: %w", backupID, err)
+		}
+
+		// Backups only kept in S3 are downloaded next to the instance's dumps
+		tempDir := backupTempDir(&config.PostgreSQLConfig{})
+		if pgInstance, err := postgresRepo.GetByID(backup.PostgreSQLID); err == nil {
+			tempDir = backupTempDir(pgInstance)
+		}
+		dumpPath, cleanupDump, err := w.fetchDumpFile(job, backup, tempDir)
+		if err != nil {
+			return err
+		}
+
+		w.logJobProgress(job.ID, backupID, "Extracting schema from %s", dumpPath)
+		schemas[i], err = extractSchema(dumpPath)
+		cleanupDump()
+		if err != nil {
+			return fmt.Errorf("failed to extract schema of backup %s: %w", backupID, err)
+		}
+	}
+
+	diff := compareSchemas(schemas[0], schemas[1])
+	diff.BaseBackupID = baseID
+	diff.TargetBackupID = targetID
+	job.Payload["schema_diff"] = diff
+
+	w.logJobProgress(job.ID, baseID, "Schema diff against %s: %d added, %d removed, %d changed",
+		targetID, len(diff.Added), len(diff.Removed), len(diff.Changed))
+	return nil
+}
+
+// checkDumpFile checks that a backup is a completed logical dump with a local file
+func checkDumpFile(backup *models.BackupInfo) error {
+	if err := checkDumpBackup(backup); err != nil {
+		return err
+	}
+	if backup.FilePath == "" {
+		return fmt.Errorf("backup %s has no local file", backup.ID)
+	}
+	if _, err := os.Stat(backup.FilePath); err != nil {
+		if backup.StorageClass != "" {
+			return fmt.Errorf("backup %s was archived to %s and has no local file; download %s first", backup.ID, backup.StorageClass, backup.S3Key)
+		}
+		return fmt.Errorf("backup %s file is missing: %w", backup.ID, err)
+	}
+	return nil
+}
+
+// checkDumpBackup checks that a backup is a completed logical dump that isn't
+// in the trash, wherever its file is stored
+func checkDumpBackup(backup *models.BackupInfo) error {
+	if backup.Status != models.BackupStatusCompleted {
+		return fmt.Errorf("backup %s is not completed", backup.ID)
+	}
+	if backup.Trashed() {
+		return fmt.Errorf("backup %s is in the trash; restore it from the trash first", backup.ID)
+	}
+	for _, tag := range backup.Tags {
+		if tag == BaseBackupTag {
+			return fmt.Errorf("backup %s is a base backup and has no SQL schema", backup.ID)
+		}
+	}
+	return nil
+}
+
+// extractSchema returns the definitions of a dump's objects keyed by
+// "TYPE schema.name". Custom archives go through pg_restore --schema-only;
+// table data is skipped.
+func extractSchema(path string) (map[string]string, error) {
+	format, err := detectDumpFormat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case DumpFormatCustom:
+		cmd := exec.Command(config.ToolPath(config.ToolPgRestore), "--schema-only", "--file", "-", path)
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		objects, parseErr := parseSchemaObjects(stdout)
+		// Drain what the parser left unread, or pg_restore blocks writing
+		// to the pipe and Wait never returns
+		io.Copy(io.Discard, stdout)
+		if err := cmd.Wait(); err != nil {
+			return nil, fmt.Errorf("pg_restore --schema-only failed: %v: %s", err, lastLines(stderr.String(), 5))
+		}
+		return objects, parseErr
+	case DumpFormatPlainGzip:
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip stream: %w", err)
+		}
+		defer gz.Close()
+		return parseSchemaObjects(gz)
+	default:
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return parseSchemaObjects(file)
+	}
+}
+
+// parseSchemaObjects splits a SQL script into object definitions using the
+// header comments pg_dump writes, dropping COPY data, sequence values and comments
+func parseSchemaObjects(reader io.Reader) (map[string]string, error) {
+	objects := make(map[string]string)
+	var current string
+	var definition []string
+	inCopy := false
+
+	flush := func() {
+		if current != "" && len(definition) > 0 {
+			if objects[current] != "" {
+				objects[current] += "\n"
+			}
+			objects[current] += strings.Join(definition, "\n")
+		}
+		definition = nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024) // COPY rows can be long
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inCopy {
+			inCopy = line != `\.`
+			continue
+		}
+		if match := dumpObjectHeader.FindStringSubmatch(line); match != nil {
+			flush()
+			objectType := match[3]
+			if match[1] != "" || strings.HasSuffix(objectType, " DATA") || objectType == "SEQUENCE SET" {
+				current = ""
+			} else {
+				current = objectType + " " + match[4] + "." + match[2]
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "COPY ") && strings.HasSuffix(line, "FROM stdin;") {
+			inCopy = true
+			continue
+		}
+		if current == "" || strings.TrimSpace(line) == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+		definition = append(definition, line)
+	}
+	flush()
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dump: %w", err)
+	}
+	return objects, nil
+}
+
+// compareSchemas lists the objects added, removed and changed from base to target
+func compareSchemas(base, target map[string]string) *SchemaDiff {
+	diff := &SchemaDiff{Added: []string{}, Removed: []string{}, Changed: []SchemaChange{}}
+
+	for object, definition := range base {
+		targetDefinition, ok := target[object]
+		switch {
+		case !ok:
+			diff.Removed = append(diff.Removed, object)
+		case targetDefinition != definition:
+			diff.Changed = append(diff.Changed, SchemaChange{
+				Object: object,
+				Diff:   diffLines(strings.Split(definition, "\n"), strings.Split(targetDefinition, "\n")),
+			})
+		default:
+			diff.Unchanged++
+		}
+	}
+	for object := range target {
+		if _, ok := base[object]; !ok {
+			diff.Added = append(diff.Added, object)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Object < diff.Changed[j].Object })
+	return diff
+}
+
+// diffLines returns a line diff of two definitions based on their longest
+// common subsequence
+func diffLines(a, b []string) string {
+	var out strings.Builder
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			out.WriteString("- " + line + "\n")
+		}
+		for _, line := range b {
+			out.WriteString("+ " + line + "\n")
+		}
+		return out.String()
+	}
+
+	// lcs[i][j] is the common subsequence length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		default:
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		}
+	}
+	return out.String()
+}
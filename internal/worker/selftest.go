@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"evolution-postgres-backup/internal/config"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// SelfTestDump is a dump taken for the pipeline self-test
+type SelfTestDump struct {
+	Path     string `json:"-"`
+	Size     int64  `json:"size"`
+	Gzipped  bool   `json:"gzipped"`
+	Checksum string `json:"checksum"` // sha256 of the uncompressed dump, as stored on backups
+}
+
+// DumpForSelfTest dumps databaseName the way backup jobs do, with the
+// instance's compression, SSL and dump options, but without a backup record.
+// The caller removes the returned file. ctx bounds pg_dump.
+func DumpForSelfTest(ctx context.Context, pgInstance *config.PostgreSQLConfig, databaseName string) (*SelfTestDump, error) {
+	tempDir := backupTempDir(pgInstance)
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	dump := &SelfTestDump{Gzipped: pgInstance.Compression == config.CompressionGzip}
+	filename := fmt.Sprintf("selftest_%s_%s_%s.sql", pgInstance.ID, databaseName, time.Now().Format("2006-01-02-15-04-05"))
+	if dump.Gzipped {
+		filename += ".gz"
+	}
+	dump.Path = filepath.Join(tempDir, filename)
+
+	pgInstance, cleanupSSL, err := pgInstance.WithSSLFiles(filepath.Join(tempDir, "ssl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare SSL certificates: %w", err)
+	}
+	defer cleanupSSL()
+
+	pgInstance = pgInstance.ForBackup()
+	if err := checkServerVersion(pgInstance, databaseName); err != nil {
+		return nil, err
+	}
+
+	outputPath := dump.Path
+	if dump.Gzipped {
+		outputPath = ""
+	}
+	args, err := pgInstance.DumpArgs(databaseName, outputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, config.ToolPath(config.ToolPgDump), args...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", pgInstance.Password))
+	cmd.Env = append(cmd.Env, pgInstance.SSLEnv()...)
+	cmd.Env = append(cmd.Env, config.ConnectTimeoutEnv()...)
+	cmd.Env = append(cmd.Env, pgInstance.PGOptionsEnv()...)
+
+	output := &dumpProgressWriter{onProgress: func(string) {}}
+	cmd.Stderr = output
+	if dump.Gzipped {
+		_, err = runGzipDump(cmd, dump.Path, pgInstance.GetCompressionLevel(), pgInstance.MaxBackupSizeBytes)
+	} else {
+		cmd.Stdout = output
+		err = cmd.Run()
+	}
+	if err != nil {
+		os.Remove(dump.Path)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("pg_dump did not finish in time: %w", ctx.Err())
+		}
+		if poolerErr := pgInstance.PoolerError("pg_dump", string(output.Bytes())); poolerErr != nil {
+			return nil, poolerErr
+		}
+		return nil, fmt.Errorf("pg_dump failed: %w\nOutput: %s", err, string(output.Bytes()))
+	}
+
+	fileInfo, err := os.Stat(dump.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info: %w", err)
+	}
+	dump.Size = fileInfo.Size()
+
+	if dump.Checksum, err = dumpChecksum(dump.Path, dump.Gzipped); err != nil {
+		os.Remove(dump.Path)
+		return nil, fmt.Errorf("failed to compute checksum: %w", err)
+	}
+	return dump, nil
+}
+
+// SelfTestChecksum returns the checksum DumpForSelfTest would report for a
+// copy of its dump, e.g. one downloaded back from storage
+func SelfTestChecksum(path string, gzipped bool) (string, error) {
+	return dumpChecksum(path, gzipped)
+}
@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"evolution-postgres-backup/internal/config"
+	"os"
+	"strconv"
+)
+
+// WorkerCountFromEnv returns WORKER_COUNT, or fallback when it is unset or invalid
+func WorkerCountFromEnv(fallback int) int {
+	if count, err := strconv.Atoi(os.Getenv("WORKER_COUNT")); err == nil {
+		return count
+	}
+	return fallback
+}
+
+// ProcessID tells this worker process apart from the others sharing the
+// database; worker IDs, and so the worker_stats rows, start with it. It is
+// WORKER_ID when set and the host name otherwise, so lifetime counters add up
+// across restarts. Processes sharing a host need distinct WORKER_IDs.
+func ProcessID() string {
+	if id := os.Getenv("WORKER_ID"); id != "" {
+		return id
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	return host
+}
+
+// QueueSettings is the resolved configuration of the queue and its workers
+type QueueSettings struct {
+	WorkerCount           int     `json:"worker_count"`
+	JobLoaderInterval     string  `json:"job_loader_interval"`
+	PausePollInterval     string  `json:"pause_poll_interval"`
+	Blackout              string  `json:"blackout,omitempty"` // Empty when backups may start at any time
+	BlackoutTimezone      string  `json:"blackout_timezone,omitempty"`
+	MaxConcurrentRestores int     `json:"max_concurrent_restores"` // 0 = unlimited
+	TempDir               string  `json:"temp_dir"`
+	MinBackupSizeBytes    int64   `json:"min_backup_size_bytes"`
+	DiskSpaceFactor       float64 `json:"disk_space_factor"`
+	MinFreeSpaceMB        int64   `json:"min_free_space_mb"`
+	PGConnectTimeout      int     `json:"pg_connect_timeout_seconds"`
+
+	Priorities map[string]int `json:"priorities"` // Default priority per job kind, see Priorities
+}
+
+// Settings returns the queue configuration. A process that only queues jobs
+// reports the worker count WORKER_COUNT would give a worker process.
+func (q *JobQueue) Settings() QueueSettings {
+	q.mu.RLock()
+	workerCount := q.workerCount
+	if !q.started {
+		workerCount = WorkerCountFromEnv(workerCount)
+	}
+	blackout := q.blackout
+	q.mu.RUnlock()
+
+	settings := QueueSettings{
+		WorkerCount:           workerCount,
+		JobLoaderInterval:     jobLoaderInterval.String(),
+		PausePollInterval:     pausePollInterval.String(),
+		MaxConcurrentRestores: q.maxRestores,
+		TempDir:               backupTempDir(&config.PostgreSQLConfig{}),
+		MinBackupSizeBytes:    minBackupSize(),
+		DiskSpaceFactor:       diskSpaceFactor(),
+		MinFreeSpaceMB:        minFreeSpaceMB(),
+		PGConnectTimeout:      config.ConnectTimeout(),
+		Priorities:            Priorities(),
+	}
+	if blackout != nil {
+		settings.Blackout = blackout.spec
+		settings.BlackoutTimezone = blackout.location.String()
+	}
+	return settings
+}
@@ -0,0 +1,319 @@
+package worker
+
+import (
+	"evolution-postgres-backup/internal/config"
+	"evolution-postgres-backup/internal/database"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// tocEntry is one line of pg_restore --list output, e.g.
+// "215; 1259 16386 TABLE public users postgres"
+type tocEntry struct {
+	Line   string
+	Type   string // Empty for comments and blank lines
+	Schema string
+	Name   string // First word of the tag: the table for constraints and triggers
+	Object string // Second word of the tag, e.g. the constraint name
+}
+
+// multiWordTOCTypes are the object types of pg_restore --list spanning more
+// than one word, longest first.
+var multiWordTOCTypes = [][]string{
+	{"MATERIALIZED", "VIEW", "DATA"}, {"SEQUENCE", "OWNED", "BY"},
+	{"TABLE", "DATA"}, {"FK", "CONSTRAINT"}, {"CHECK", "CONSTRAINT"}, {"SEQUENCE", "SET"},
+	{"MATERIALIZED", "VIEW"}, {"FOREIGN", "TABLE"}, {"DEFAULT", "ACL"}, {"EVENT", "TRIGGER"},
+	{"ROW", "SECURITY"}, {"LARGE", "OBJECT"}, {"PUBLICATION", "TABLE"},
+}
+
+// parseTOC parses pg_restore --list output. Names containing spaces are not
+// supported.
+func parseTOC(output string) []tocEntry {
+	var entries []tocEntry
+	for _, line := range strings.Split(output, "\n") {
+		entry := tocEntry{Line: line}
+		fields := strings.Fields(line)
+		if len(fields) >= 6 && !strings.HasPrefix(fields[0], ";") {
+			rest := fields[3:]
+			words := 1
+			for _, typ := range multiWordTOCTypes {
+				if len(rest) > len(typ)+1 && strings.Join(rest[:len(typ)], " ") == strings.Join(typ, " ") {
+					words = len(typ)
+					break
+				}
+			}
+			entry.Type = strings.Join(rest[:words], " ")
+			entry.Schema, entry.Name = rest[words], rest[words+1]
+			if len(rest) > words+3 {
+				entry.Object = rest[words+2]
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+var (
+	indexTable      = regexp.MustCompile(`(?i)CREATE (?:UNIQUE )?INDEX .*? ON (?:ONLY )?([^\s(]+)`)
+	sequenceOwnedBy = regexp.MustCompile(`(?i)OWNED BY ([^\s;]+)\.[^.\s;]+;`)
+	foreignKeyTable = regexp.MustCompile(`(?i)REFERENCES ([^\s(]+)`)
+	identityTable   = regexp.MustCompile(`(?i)ALTER TABLE (?:ONLY )?(\S+)\s+ALTER COLUMN \S+ ADD GENERATED`)
+)
+
+// entryTables returns the tables (schema.name) a TOC entry belongs to or
+// depends on. Constraints, triggers, defaults, rules and policies carry their
+// table in the TOC tag; indexes, owned and identity sequences and the tables
+// referenced by foreign keys come from the object definitions of the archive.
+func entryTables(entry tocEntry, definitions map[string]string) []string {
+	switch entry.Type {
+	case "TABLE", "TABLE DATA":
+		return []string{entry.Schema + "." + entry.Name}
+	case "CONSTRAINT", "CHECK CONSTRAINT", "TRIGGER", "DEFAULT", "RULE", "POLICY", "ROW SECURITY":
+		return []string{entry.Schema + "." + entry.Name}
+	case "FK CONSTRAINT":
+		tables := []string{entry.Schema + "." + entry.Name}
+		definition := definitions[entry.Type+" "+entry.Schema+"."+entry.Name+" "+entry.Object]
+		if match := foreignKeyTable.FindStringSubmatch(definition); match != nil {
+			tables = append(tables, qualifiedTable(match[1], entry.Schema))
+		}
+		return tables
+	case "INDEX":
+		if match := indexTable.FindStringSubmatch(definitions["INDEX "+entry.Schema+"."+entry.Name]); match != nil {
+			return []string{qualifiedTable(match[1], entry.Schema)}
+		}
+	case "SEQUENCE", "SEQUENCE SET", "SEQUENCE OWNED BY":
+		sequence := entry.Schema + "." + entry.Name
+		if match := sequenceOwnedBy.FindStringSubmatch(definitions["SEQUENCE OWNED BY "+sequence]); match != nil {
+			return []string{qualifiedTable(match[1], entry.Schema)}
+		}
+		if match := identityTable.FindStringSubmatch(definitions["SEQUENCE "+sequence]); match != nil {
+			return []string{qualifiedTable(match[1], entry.Schema)}
+		}
+	}
+	return nil
+}
+
+// qualifiedTable turns a table reference of a dump statement into schema.name
+func qualifiedTable(reference, schema string) string {
+	reference = strings.ReplaceAll(reference, `"`, "")
+	if !strings.Contains(reference, ".") {
+		return schema + "." + reference
+	}
+	return reference
+}
+
+// ValidateRestoreTables checks table names given as "name" or "schema.name"
+func ValidateRestoreTables(tables []string) error {
+	for _, table := range tables {
+		parts := strings.Split(table, ".")
+		if len(parts) > 2 || strings.ContainsAny(table, " \t\n") {
+			return fmt.Errorf("invalid table %q: use name or schema.name", table)
+		}
+		for _, part := range parts {
+			if part == "" {
+				return fmt.Errorf("invalid table %q: use name or schema.name", table)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveTables maps requested names to the schema-qualified tables of the
+// archive. Unqualified names must match a table in exactly one schema.
+func resolveTables(entries []tocEntry, requested []string) (map[string]bool, error) {
+	bySchema := make(map[string][]string) // table name -> schemas
+	for _, entry := range entries {
+		if entry.Type == "TABLE" {
+			bySchema[entry.Name] = append(bySchema[entry.Name], entry.Schema)
+		}
+	}
+
+	resolved := make(map[string]bool)
+	var missing []string
+	for _, table := range requested {
+		if schema, name, qualified := strings.Cut(table, "."); qualified {
+			found := false
+			for _, s := range bySchema[name] {
+				if s == schema {
+					found = true
+					break
+				}
+			}
+			if !found {
+				missing = append(missing, table)
+				continue
+			}
+			resolved[table] = true
+			continue
+		}
+
+		switch schemas := bySchema[table]; len(schemas) {
+		case 0:
+			missing = append(missing, table)
+		case 1:
+			resolved[schemas[0]+"."+table] = true
+		default:
+			return nil, fmt.Errorf("table %q exists in schemas %s; qualify it as schema.%s", table, strings.Join(schemas, ", "), table)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("tables not found in the archive: %s", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+// selectTables builds a pg_restore -L list restoring the definition and data of
+// the included tables, or everything except the excluded ones. Indexes,
+// constraints, triggers and sequence ownership follow their table, and foreign
+// keys are dropped when they reference a table that is not restored.
+// definitions are the schema objects of the archive (see extractSchema).
+// dataOnly keeps only TABLE DATA entries, for refilling tables that still exist.
+func selectTables(entries []tocEntry, definitions map[string]string, include, exclude map[string]bool, dataOnly bool) (string, []string) {
+	var list strings.Builder
+	selected := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Type == "" {
+			list.WriteString(entry.Line + "\n")
+			continue
+		}
+		isTable := entry.Type == "TABLE" || entry.Type == "TABLE DATA"
+		tables := entryTables(entry, definitions)
+		switch {
+		case dataOnly && entry.Type != "TABLE DATA":
+			continue
+		case len(include) > 0 && !allTables(tables, include):
+			continue
+		case anyTable(tables, exclude):
+			continue
+		}
+		if isTable {
+			selected[tables[0]] = true
+		}
+		list.WriteString(entry.Line + "\n")
+	}
+
+	tables := make([]string, 0, len(selected))
+	for table := range selected {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return list.String(), tables
+}
+
+// allTables reports whether tables is non-empty and every table is in set
+func allTables(tables []string, set map[string]bool) bool {
+	for _, table := range tables {
+		if !set[table] {
+			return false
+		}
+	}
+	return len(tables) > 0
+}
+
+// anyTable reports whether any of tables is in set
+func anyTable(tables []string, set map[string]bool) bool {
+	for _, table := range tables {
+		if set[table] {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreTables restores selected tables of a custom-format backup into the
+// target database, checking first that every requested table is in the archive
+func (w *Worker) restoreTables(job *Job, payload *RestorePayload) error {
+	backupID, postgresID, databaseName := payload.BackupID, payload.PostgresID, payload.DatabaseName
+	include, exclude, dataOnly := payload.IncludeTables, payload.ExcludeTables, payload.DataOnly
+
+	backup, err := database.NewBackupRepository(w.dbService).GetByID(backupID)
+	if err != nil {
+		return fmt.Errorf("failed to get backup %s: %w", backupID, err)
+	}
+	if err := checkDumpFile(backup); err != nil {
+		return err
+	}
+	format, err := backupDumpFormat(backup, backup.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read backup file: %w", err)
+	}
+	if format != DumpFormatCustom {
+		return fmt.Errorf("restoring specific tables needs a custom-format archive (pg_dump -Fc); backup %s is %s", backupID, format)
+	}
+
+	output, err := exec.Command(config.ToolPath(config.ToolPgRestore), "--list", backup.FilePath).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("pg_restore --list failed: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	entries := parseTOC(string(output))
+	included, err := resolveTables(entries, include)
+	if err != nil {
+		return fmt.Errorf("backup %s: %w", backupID, err)
+	}
+	excluded, err := resolveTables(entries, exclude)
+	if err != nil {
+		return fmt.Errorf("backup %s: %w", backupID, err)
+	}
+
+	var definitions map[string]string
+	if !dataOnly {
+		if definitions, err = extractSchema(backup.FilePath); err != nil {
+			return fmt.Errorf("failed to read the schema of backup %s: %w", backupID, err)
+		}
+	}
+
+	list, tables := selectTables(entries, definitions, included, excluded, dataOnly)
+	if len(tables) == 0 {
+		return fmt.Errorf("no tables of backup %s are left to restore", backupID)
+	}
+	w.logJobProgress(job.ID, backupID, "Restoring %d tables: %s", len(tables), strings.Join(tables, ", "))
+
+	pgInstance, err := database.NewPostgreSQLRepository(w.dbService).GetByID(postgresID)
+	if err != nil {
+		return fmt.Errorf("failed to get postgres instance: %w", err)
+	}
+	tempDir := backupTempDir(pgInstance)
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	pgInstance, cleanupSSL, err := pgInstance.WithSSLFiles(filepath.Join(tempDir, "ssl"))
+	if err != nil {
+		return fmt.Errorf("failed to prepare SSL certificates: %w", err)
+	}
+	defer cleanupSSL()
+	pgInstance = pgInstance.ForBackup()
+
+	listFile, err := os.CreateTemp(tempDir, "restore-list-*.txt")
+	if err != nil {
+		return fmt.Errorf("failed to create restore list: %w", err)
+	}
+	defer os.Remove(listFile.Name())
+	if _, err := listFile.WriteString(list); err != nil {
+		listFile.Close()
+		return fmt.Errorf("failed to write restore list: %w", err)
+	}
+	listFile.Close()
+
+	cmd := exec.Command(config.ToolPath(config.ToolPgRestore),
+		"-h", pgInstance.Host, "-p", fmt.Sprintf("%d", pgInstance.Port), "-U", pgInstance.Username,
+		"-d", databaseName, "-L", listFile.Name(), "--exit-on-error", "--no-password", backup.FilePath)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", pgInstance.Password))
+	cmd.Env = append(cmd.Env, pgInstance.SSLEnv()...)
+	cmd.Env = append(cmd.Env, config.ConnectTimeoutEnv()...)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if poolerErr := pgInstance.PoolerError("pg_restore", string(output)); poolerErr != nil {
+			return poolerErr
+		}
+		return fmt.Errorf("pg_restore failed: %v: %s", err, lastLines(string(output), 5))
+	}
+
+	job.Payload["restored_tables"] = tables
+	return nil
+}
@@ -0,0 +1,102 @@
+package worker
+
+import (
+	"strings"
+	"testing"
+)
+
+const testTOC = `;
+; Archive created at 2026-01-01 00:00:00 UTC
+;
+215; 1259 16386 TABLE public users postgres
+216; 1259 16387 SEQUENCE public users_id_seq postgres
+217; 0 0 SEQUENCE OWNED BY public users_id_seq postgres
+218; 1259 16390 TABLE public orders postgres
+219; 2604 16391 DEFAULT public users id postgres
+3300; 0 16386 TABLE DATA public users postgres
+3301; 0 16390 TABLE DATA public orders postgres
+3302; 0 0 SEQUENCE SET public users_id_seq postgres
+3150; 2606 16392 CONSTRAINT public users users_pkey postgres
+3151; 2606 16393 CONSTRAINT public orders orders_pkey postgres
+3152; 1259 16394 INDEX public users_email_idx postgres
+3153; 1259 16395 INDEX public orders_user_id_idx postgres
+3154; 2620 16396 TRIGGER public users users_audit postgres
+3155; 2606 16397 FK CONSTRAINT public orders orders_user_id_fkey postgres
+`
+
+var testDefinitions = map[string]string{
+	"SEQUENCE OWNED BY public.users_id_seq":           "ALTER SEQUENCE public.users_id_seq OWNED BY public.users.id;",
+	"INDEX public.users_email_idx":                    "CREATE UNIQUE INDEX users_email_idx ON public.users USING btree (email);",
+	"INDEX public.orders_user_id_idx":                 "CREATE INDEX orders_user_id_idx ON public.orders USING btree (user_id);",
+	"FK CONSTRAINT public.orders orders_user_id_fkey": "ALTER TABLE ONLY public.orders\n    ADD CONSTRAINT orders_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id);",
+}
+
+func selectedIDs(list string) []string {
+	var ids []string
+	for _, line := range strings.Split(list, "\n") {
+		if id, _, ok := strings.Cut(line, ";"); ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func TestParseTOCMultiWordTypes(t *testing.T) {
+	types := make(map[string]tocEntry)
+	for _, entry := range parseTOC(testTOC) {
+		if entry.Type != "" {
+			types[entry.Type] = entry
+		}
+	}
+	for _, typ := range []string{"TABLE DATA", "SEQUENCE OWNED BY", "SEQUENCE SET", "FK CONSTRAINT"} {
+		if _, ok := types[typ]; !ok {
+			t.Errorf("type %q not parsed", typ)
+		}
+	}
+	if fk := types["FK CONSTRAINT"]; fk.Schema != "public" || fk.Name != "orders" || fk.Object != "orders_user_id_fkey" {
+		t.Errorf("FK CONSTRAINT parsed as %+v", fk)
+	}
+}
+
+func TestSelectTablesDropsDependentsOfExcludedTables(t *testing.T) {
+	list, tables := selectTables(parseTOC(testTOC), testDefinitions, nil, map[string]bool{"public.users": true}, false)
+
+	if got := strings.Join(tables, ","); got != "public.orders" {
+		t.Errorf("tables = %s, want public.orders", got)
+	}
+	got := strings.Join(selectedIDs(list), ",")
+	if want := "218,3301,3151,3153"; got != want {
+		t.Errorf("selected entries %s, want %s", got, want)
+	}
+}
+
+func TestSelectTablesKeepsDependentsOfIncludedTables(t *testing.T) {
+	list, _ := selectTables(parseTOC(testTOC), testDefinitions, map[string]bool{"public.users": true}, nil, false)
+
+	got := strings.Join(selectedIDs(list), ",")
+	if want := "215,216,217,219,3300,3302,3150,3152,3154"; got != want {
+		t.Errorf("selected entries %s, want %s", got, want)
+	}
+}
+
+func TestSelectTablesKeepsIdentitySequences(t *testing.T) {
+	toc := "215; 1259 16386 TABLE public users postgres\n" +
+		"216; 1259 16387 SEQUENCE public users_id_seq postgres\n" +
+		"217; 1259 16388 SEQUENCE public unrelated_seq postgres\n"
+	definitions := map[string]string{
+		"SEQUENCE public.users_id_seq": "ALTER TABLE public.users ALTER COLUMN id ADD GENERATED ALWAYS AS IDENTITY (\n    SEQUENCE NAME public.users_id_seq\n);",
+	}
+	list, _ := selectTables(parseTOC(toc), definitions, map[string]bool{"public.users": true}, nil, false)
+
+	if got := strings.Join(selectedIDs(list), ","); got != "215,216" {
+		t.Errorf("selected entries %s, want 215,216", got)
+	}
+}
+
+func TestSelectTablesDataOnly(t *testing.T) {
+	list, _ := selectTables(parseTOC(testTOC), nil, map[string]bool{"public.orders": true}, nil, true)
+
+	if got := strings.Join(selectedIDs(list), ","); got != "3301" {
+		t.Errorf("selected entries %s, want 3301", got)
+	}
+}
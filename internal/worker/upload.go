@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"evolution-postgres-backup/internal/config"
+	"evolution-postgres-backup/internal/models"
+	"fmt"
+	"path/filepath"
+)
+
+// fileUploader is implemented by object stores that accept new objects
+type fileUploader interface {
+	UploadFile(filePath, key string) error
+}
+
+// uploadBackup copies a finished backup file to the object store under the
+// configured S3 key layout and records the key on backup. Without an object
+// store the backup stays local-only and nothing is uploaded.
+func (w *Worker) uploadBackup(job *Job, backup *models.BackupInfo, localPath string) error {
+	uploader, ok := w.jobQueue.getObjectStore().(fileUploader)
+	if !ok {
+		return nil
+	}
+
+	s3Config := config.S3Config{}
+	s3Config.LoadEnv()
+	key := s3Config.BackupKey(backup.PostgreSQLID, string(backup.BackupType),
+		backup.StartTime.Format("2006-01-02-15-04-05"), filepath.Base(localPath))
+
+	w.logJobProgress(job.ID, backup.ID, "Uploading to S3: %s", key)
+	if err := uploader.UploadFile(localPath, key); err != nil {
+		return fmt.Errorf("S3 upload failed: %w", err)
+	}
+	backup.S3Key = key
+	w.logJobProgress(job.ID, backup.ID, "S3 upload completed successfully")
+	return nil
+}
@@ -1,14 +1,26 @@
 package worker
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"evolution-postgres-backup/internal/config"
 	"evolution-postgres-backup/internal/database"
+	"evolution-postgres-backup/internal/logger"
 	"evolution-postgres-backup/internal/models"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -21,6 +33,10 @@ type WorkerStatus struct {
 	JobsHandled int64      `json:"jobs_handled"`
 	StartedAt   time.Time  `json:"started_at"`
 	LastJobAt   *time.Time `json:"last_job_at,omitempty"`
+
+	// Session counters above reset on restart; Lifetime is read from worker_stats
+	BytesProcessed int64                 `json:"bytes_processed"`
+	Lifetime       *database.WorkerStats `json:"lifetime,omitempty"`
 }
 
 // Worker processes jobs from the queue
@@ -29,14 +45,19 @@ type Worker struct {
 	jobs        <-chan *Job
 	dbService   *database.DB
 	logRepo     *database.LogRepository
+	statsRepo   *database.WorkerStatsRepository
 	jobQueue    *JobQueue // Add reference to JobQueue
 	mu          sync.RWMutex
 	currentJob  *Job
 	status      string
 	jobsHandled int64
+	bytesDone   int64
 	startedAt   time.Time
 	lastJobAt   *time.Time
 	stopped     bool
+	quit        chan struct{} // Closed to stop picking up new jobs
+	quitOnce    sync.Once
+	done        chan struct{} // Closed when Start returns
 }
 
 // NewWorker creates a new worker
@@ -46,28 +67,73 @@ func NewWorker(id string, jobs <-chan *Job, dbService *database.DB, logRepo *dat
 		jobs:      jobs,
 		dbService: dbService,
 		logRepo:   logRepo,
+		statsRepo: database.NewWorkerStatsRepository(dbService),
 		jobQueue:  jobQueue,
 		status:    "idle",
 		startedAt: time.Now(),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
 	}
 }
 
 // Start starts the worker to process jobs
 func (w *Worker) Start(ctx context.Context) {
-	w.logInfo("Worker %s started", w.id)
+	defer close(w.done)
+	w.logInfo(nil, "Worker %s started", w.id)
 
 	for {
+		// Don't pick up another job once the worker has been asked to quit
+		select {
+		case <-w.quit:
+			w.logInfo(nil, "Worker %s: finished current work, exiting", w.id)
+			return
+		default:
+		}
+
+		// A paused queue keeps its jobs; running ones were allowed to finish
+		if w.jobQueue.paused.Load() {
+			select {
+			case <-w.quit:
+				w.logInfo(nil, "Worker %s: finished current work, exiting", w.id)
+				return
+			case <-ctx.Done():
+				w.logInfo(nil, "Worker %s: context cancelled", w.id)
+				return
+			case <-time.After(pausePollInterval):
+			}
+			continue
+		}
+
 		select {
 		case job, ok := <-w.jobs:
 			if !ok {
-				w.logInfo("Worker %s: job channel closed", w.id)
+				w.logInfo(nil, "Worker %s: job channel closed", w.id)
 				return
 			}
 
+			// Jobs handed over by enqueue are still pending in the database
+			// and may have been cancelled, or loaded, while buffered
+			if claimed, err := w.jobQueue.claimJob(job); err != nil {
+				w.logError(job, "Worker %s: %v", w.id, err)
+			} else if !claimed {
+				w.logInfo(job, "Worker %s: skipping job %s, no longer pending", w.id, job.ID)
+				continue
+			}
+
+			// The blackout may have opened while the job was buffered
+			if w.jobQueue.inBlackout(job) {
+				w.jobQueue.deferJob(job)
+				continue
+			}
+
 			w.processJob(job)
 
+		case <-w.quit:
+			w.logInfo(nil, "Worker %s: finished current work, exiting", w.id)
+			return
+
 		case <-ctx.Done():
-			w.logInfo("Worker %s: context cancelled", w.id)
+			w.logInfo(nil, "Worker %s: context cancelled", w.id)
 			return
 		}
 	}
@@ -80,7 +146,19 @@ func (w *Worker) Stop() {
 
 	w.stopped = true
 	w.status = "stopped"
-	w.logInfo("Worker %s stopped", w.id)
+	w.logInfo(nil, "Worker %s stopped", w.id)
+}
+
+// Quit asks the worker to exit after its current job, without picking up new ones
+func (w *Worker) Quit() {
+	w.quitOnce.Do(func() {
+		close(w.quit)
+	})
+}
+
+// Done returns a channel that is closed once the worker has exited
+func (w *Worker) Done() <-chan struct{} {
+	return w.done
 }
 
 // IsActive returns whether the worker is currently processing a job
@@ -109,6 +187,8 @@ func (w *Worker) GetStatus() WorkerStatus {
 		JobsHandled: w.jobsHandled,
 		StartedAt:   w.startedAt,
 		LastJobAt:   w.lastJobAt,
+
+		BytesProcessed: w.bytesDone,
 	}
 }
 
@@ -123,20 +203,9 @@ func (w *Worker) processJob(job *Job) {
 	job.WorkerID = w.id
 	w.mu.Unlock()
 
-	w.logInfo("Worker %s processing job %s (%s)", w.id, job.ID, job.Type)
+	w.logInfo(job, "Worker %s processing job %s (%s)", w.id, job.ID, job.Type)
 
-	// Process the job based on its type
-	var err error
-	switch job.Type {
-	case JobTypeBackup:
-		err = w.processBackupJob(job)
-	case JobTypeRestore:
-		err = w.processRestoreJob(job)
-	case JobTypeCleanup:
-		err = w.processCleanupJob(job)
-	default:
-		err = fmt.Errorf("unknown job type: %s", job.Type)
-	}
+	err := w.runJob(job)
 
 	// Update job status
 	w.mu.Lock()
@@ -144,63 +213,107 @@ func (w *Worker) processJob(job *Job) {
 	job.CompletedAt = &completedAt
 	w.lastJobAt = &completedAt
 	w.jobsHandled++
+	bytes := jobBytes(job)
+	w.bytesDone += bytes
 
 	if err != nil {
 		job.Error = err.Error()
 		job.RetryCount++
 
-		if job.RetryCount < job.MaxRetries {
+		var panicErr *jobPanicError
+		if job.RetryCount < job.MaxRetries && !errors.As(err, &panicErr) {
 			job.Status = JobStatusRetrying
-			w.logInfo("Worker %s: job %s failed, will retry (%d/%d): %v", w.id, job.ID, job.RetryCount, job.MaxRetries, err)
-			// TODO: Re-queue the job for retry
+			w.logInfo(job, "Worker %s: job %s failed, will retry (%d/%d): %v", w.id, job.ID, job.RetryCount, job.MaxRetries, err)
+			// The database loader picks the job up again
 		} else {
 			job.Status = JobStatusFailed
-			w.logError("Worker %s: job %s failed permanently after %d retries: %v", w.id, job.ID, job.RetryCount, err)
+			w.logError(job, "Worker %s: job %s failed permanently after %d retries: %v", w.id, job.ID, job.RetryCount, err)
 		}
 	} else {
 		job.Status = JobStatusCompleted
-		w.logInfo("Worker %s: job %s completed successfully", w.id, job.ID)
+		w.logInfo(job, "Worker %s: job %s completed successfully", w.id, job.ID)
 	}
 
 	w.currentJob = nil
 	w.status = "idle"
 	w.mu.Unlock()
 
+	w.jobQueue.recordJobFinished(job)
+
 	// Update job status in database
 	if updateErr := w.jobQueue.UpdateJobStatus(job); updateErr != nil {
-		w.logError("Failed to update job status in database: %v", updateErr)
+		w.logError(job, "Failed to update job status in database: %v", updateErr)
+	}
+
+	if statsErr := w.statsRepo.RecordJob(w.id, err != nil, bytes, completedAt); statsErr != nil {
+		w.logError(job, "Failed to record worker stats: %v", statsErr)
 	}
 }
 
-// processBackupJob processes a backup job
-func (w *Worker) processBackupJob(job *Job) error {
-	w.logInfo("Processing backup job %s", job.ID)
+// jobPanicError is the error of a job whose handler panicked. Such jobs are
+// failed without retrying: the same payload would panic again.
+type jobPanicError struct {
+	value interface{}
+}
 
-	// Extract parameters from job payload
-	postgresID, ok := job.Payload["postgres_id"].(string)
-	if !ok {
-		return fmt.Errorf("missing postgres_id in job payload")
-	}
+func (e *jobPanicError) Error() string {
+	return fmt.Sprintf("job panicked: %v", e.value)
+}
 
-	databaseName, ok := job.Payload["database_name"].(string)
-	if !ok {
-		return fmt.Errorf("missing database_name in job payload")
+// runJob processes the job based on its type. A panic in the handler, e.g. on
+// a malformed payload, becomes a jobPanicError so the worker keeps going.
+func (w *Worker) runJob(job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.logError(job, "Worker %s: job %s panicked: %v\n%s", w.id, job.ID, r, debug.Stack())
+			err = &jobPanicError{value: r}
+		}
+	}()
+
+	switch job.Type {
+	case JobTypeBackup:
+		return w.processBackupJob(job)
+	case JobTypeRestore:
+		return w.processRestoreJob(job)
+	case JobTypeCleanup:
+		return w.processCleanupJob(job)
+	case JobTypeBaseBackup:
+		return w.processBaseBackupJob(job)
+	case JobTypeSchemaDiff:
+		return w.diffSchemas(job)
+	default:
+		return fmt.Errorf("unknown job type: %s", job.Type)
 	}
+}
 
-	backupTypeStr, ok := job.Payload["backup_type"].(string)
-	if !ok {
-		return fmt.Errorf("missing backup_type in job payload")
+// jobBytes returns the size of the file a job wrote, as recorded in its payload
+func jobBytes(job *Job) int64 {
+	switch size := job.Payload["file_size"].(type) {
+	case int64:
+		return size
+	case float64:
+		return int64(size)
 	}
+	return 0
+}
+
+// processBackupJob processes a backup job
+func (w *Worker) processBackupJob(job *Job) error {
+	w.logInfo(job, "Processing backup job %s", job.ID)
 
-	backupType := models.BackupType(backupTypeStr)
+	payload, err := job.BackupPayload()
+	if err != nil {
+		return err
+	}
+	postgresID, databaseName, backupType := payload.PostgresID, payload.DatabaseName, payload.BackupType
 
 	backupRepo := database.NewBackupRepository(w.dbService)
 	var backup *models.BackupInfo
 
 	// Check if backup_id is provided in payload (meaning backup already exists)
-	if backupIDStr, exists := job.Payload["backup_id"].(string); exists && backupIDStr != "" {
+	if payload.BackupID != "" {
 		// Use existing backup
-		existingBackup, err := backupRepo.GetByID(backupIDStr)
+		existingBackup, err := backupRepo.GetByID(payload.BackupID)
 		if err != nil {
 			return fmt.Errorf("failed to get existing backup record: %w", err)
 		}
@@ -219,6 +332,8 @@ func (w *Worker) processBackupJob(job *Job) error {
 			StartTime:    time.Now(),
 			CreatedAt:    time.Now(),
 			JobID:        job.ID,
+			Tags:         payload.Tags,
+			CreatedBy:    job.CreatedBy(),
 		}
 
 		// Save backup record
@@ -232,12 +347,19 @@ func (w *Worker) processBackupJob(job *Job) error {
 		w.logJobProgress(job.ID, backup.ID, "Created new backup record %s", backup.ID)
 	}
 
+	// Record the exclusion on records created before the job asked for it
+	if payload.NoBlobs {
+		backup.BlobsExcluded = true
+	}
+
 	// Log backup start
 	w.logJobProgress(job.ID, backup.ID, "Backup started for %s/%s", postgresID, databaseName)
 
 	// Update backup status to in_progress
 	backup.Status = models.BackupStatusInProgress
 	backup.StartTime = time.Now() // Update start time when actually starting
+	backup.EndTime = nil          // A retry starts over from a failed attempt
+	backup.ErrorMessage = ""
 	if err := backupRepo.Update(backup); err != nil {
 		return fmt.Errorf("failed to update backup status: %w", err)
 	}
@@ -247,74 +369,152 @@ func (w *Worker) processBackupJob(job *Job) error {
 	pgRepo := database.NewPostgreSQLRepository(w.dbService)
 	pgInstance, err := pgRepo.GetByID(postgresID)
 	if err != nil {
-		return fmt.Errorf("failed to get postgres instance: %w", err)
+		return w.failBackup(job, backupRepo, backup, fmt.Errorf("failed to get postgres instance: %w", err))
 	}
 
 	// Create backup filename
 	timestamp := backup.StartTime.Format("2006-01-02-15-04-05")
 	filename := fmt.Sprintf("%s_Postgres_1_%s_%s_%s.sql",
 		pgInstance.Name, databaseName, string(backupType), timestamp)
+	if pgInstance.Compression == config.CompressionGzip {
+		filename += ".gz"
+	}
 
 	// Create local backup file path
-	tempDir := os.Getenv("BACKUP_TEMP_DIR")
-	if tempDir == "" {
-		tempDir = "/tmp/postgres-backups"
-	}
+	tempDir := backupTempDir(pgInstance)
 	localPath := filepath.Join(tempDir, filename)
 
 	// Ensure temp directory exists
 	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		return fmt.Errorf("failed to create temp directory: %w", err)
+		return w.failBackup(job, backupRepo, backup, fmt.Errorf("failed to create temp directory: %w", err))
 	}
 
 	w.logJobProgress(job.ID, backup.ID, "Local file: %s", localPath)
 
-	// Build pg_dump command
-	cmd := exec.Command("pg_dump",
-		"-h", pgInstance.Host,
-		"-p", fmt.Sprintf("%d", pgInstance.Port),
-		"-U", pgInstance.Username,
-		"-d", databaseName,
-		"-f", localPath,
-		"--verbose",
-		"--no-password",
-	)
+	// Fail fast instead of letting pg_dump die halfway through a full disk
+	if err := checkDiskSpace(backupRepo, tempDir, postgresID, databaseName); err != nil {
+		return w.failBackup(job, backupRepo, backup, err)
+	}
+
+	// Write inline SSL certificates to files libpq can read
+	pgInstance, cleanupSSL, err := pgInstance.WithSSLFiles(filepath.Join(tempDir, "ssl"))
+	if err != nil {
+		return w.failBackup(job, backupRepo, backup, fmt.Errorf("failed to prepare SSL certificates: %w", err))
+	}
+	defer cleanupSSL()
+
+	// Pooled instances are dumped through their direct endpoint
+	pgInstance = pgInstance.ForBackup()
+
+	// Build pg_dump command; without -f the dump is written to stdout for compression
+	outputPath := localPath
+	if pgInstance.Compression == config.CompressionGzip {
+		outputPath = ""
+	}
+	var extra []string
+	if payload.NoBlobs {
+		extra = append(extra, "--no-blobs")
+		w.logJobProgress(job.ID, backup.ID, "Large objects are excluded (no_blobs)")
+	}
+	args, err := pgInstance.DumpArgs(databaseName, outputPath, extra...)
+	if err != nil {
+		return w.failBackup(job, backupRepo, backup, err)
+	}
+	// pg_dump clears statement_timeout on its session; dump_timeout bounds the whole run
+	dumpTimeout, err := pgInstance.GetDumpTimeout()
+	if err != nil {
+		return w.failBackup(job, backupRepo, backup, fmt.Errorf("invalid dump_timeout: %w", err))
+	}
+
+	// pg_dump can't dump a newer server; report that instead of a generic failure
+	if err := checkServerVersion(pgInstance, databaseName); err != nil {
+		return w.failBackup(job, backupRepo, backup, err)
+	}
+
+	// Pre-backup hook, e.g. to checkpoint or refresh a materialized view; the
+	// backup fails rather than dumping data the hook was meant to prepare
+	if strings.TrimSpace(pgInstance.PreBackupSQL) != "" {
+		w.logJobProgress(job.ID, backup.ID, "Running pre-backup SQL")
+		if err := runPreBackupSQL(pgInstance, databaseName); err != nil {
+			return w.failBackup(job, backupRepo, backup, err)
+		}
+		w.logJobProgress(job.ID, backup.ID, "Pre-backup SQL completed")
+	}
+
+	dumpCtx, cancelDump := context.Background(), context.CancelFunc(func() {})
+	if dumpTimeout > 0 {
+		dumpCtx, cancelDump = context.WithTimeout(context.Background(), dumpTimeout)
+	}
+	defer cancelDump()
+	cmd := exec.CommandContext(dumpCtx, config.ToolPath(config.ToolPgDump), args...)
 
 	// Set password via environment variable
 	cmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", pgInstance.Password))
+	cmd.Env = append(cmd.Env, pgInstance.SSLEnv()...)
+	cmd.Env = append(cmd.Env, config.ConnectTimeoutEnv()...)
+	cmd.Env = append(cmd.Env, pgInstance.PGOptionsEnv()...)
 
 	// Log pg_dump version for debugging
-	if versionCmd := exec.Command("pg_dump", "--version"); versionCmd != nil {
-		if versionOutput, versionErr := versionCmd.Output(); versionErr == nil {
-			w.logJobProgress(job.ID, backup.ID, "pg_dump version: %s", string(versionOutput))
-		}
+	if version, versionErr := config.ToolVersion(config.ToolPgDump); versionErr == nil {
+		w.logJobProgress(job.ID, backup.ID, "pg_dump version: %s", version)
 	}
 
 	w.logJobProgress(job.ID, backup.ID, "Executing pg_dump: %s@%s:%d/%s", pgInstance.Username, pgInstance.Host, pgInstance.Port, databaseName)
+	if len(pgInstance.DumpOptions) > 0 {
+		w.logJobProgress(job.ID, backup.ID, "pg_dump options: %s", strings.Join(pgInstance.DumpOptions, " "))
+	}
 
-	// Execute backup and capture both stdout and stderr
-	output, err := cmd.CombinedOutput()
+	// Execute backup, reporting --verbose progress as it happens and keeping
+	// the output for error messages
+	progress := &dumpProgressWriter{onProgress: func(line string) {
+		w.logJobProgress(job.ID, backup.ID, "pg_dump: %s", line)
+	}}
+	cmd.Stderr = progress
+	var uncompressedSize int64 // Only known up front for gzip; otherwise the file size
+	if pgInstance.Compression == config.CompressionGzip {
+		w.logJobProgress(job.ID, backup.ID, "Compressing with gzip (level %d)", pgInstance.GetCompressionLevel())
+		uncompressedSize, err = runGzipDump(cmd, localPath, pgInstance.GetCompressionLevel(), pgInstance.MaxBackupSizeBytes)
+	} else {
+		cmd.Stdout = progress
+		err = cmd.Run()
+	}
+	output := progress.Bytes()
+	if cause := dumpTimeoutCause(dumpCtx, output, pgInstance); err != nil && cause != "" {
+		err = errors.New(cause)
+	} else if err != nil {
+		if poolerErr := pgInstance.PoolerError("pg_dump", string(output)); poolerErr != nil {
+			err = poolerErr
+		}
+	}
+	if errors.Is(err, config.ErrBackupSizeLimit) {
+		return w.discardBackup(job, backupRepo, backup, localPath, err)
+	}
 	if err != nil {
-		backup.Status = models.BackupStatusFailed
-		errorMsg := fmt.Sprintf("pg_dump failed: %v\nOutput: %s", err, string(output))
-		backup.ErrorMessage = errorMsg
+		backup.Fail(fmt.Sprintf("pg_dump failed: %v\nOutput: %s", err, string(output)))
 		endTime := time.Now()
 		backup.EndTime = &endTime
 
 		if err := backupRepo.Update(backup); err != nil {
 			return fmt.Errorf("failed to update backup record: %w", err)
 		}
+		if err := backupRepo.SetFailureOutput(backup.ID, string(output)); err != nil {
+			w.logJobProgress(job.ID, backup.ID, "Failed to store pg_dump output: %v", err)
+		}
 		w.logJobProgress(job.ID, backup.ID, "pg_dump failed: %v\nOutput: %s", err, string(output))
 		return fmt.Errorf("pg_dump failed: %w", err)
 	}
 
 	w.logJobProgress(job.ID, backup.ID, "pg_dump completed successfully")
 
+	// A previous attempt of this backup may have stored its failed output
+	if err := backupRepo.SetFailureOutput(backup.ID, ""); err != nil {
+		w.logJobProgress(job.ID, backup.ID, "Failed to clear stale pg_dump output: %v", err)
+	}
+
 	// Get file size
 	fileInfo, err := os.Stat(localPath)
 	if err != nil {
-		backup.Status = models.BackupStatusFailed
-		backup.ErrorMessage = fmt.Sprintf("failed to get file info: %v", err)
+		backup.Fail(fmt.Sprintf("failed to get file info: %v", err))
 		endTime := time.Now()
 		backup.EndTime = &endTime
 
@@ -327,129 +527,683 @@ func (w *Worker) processBackupJob(job *Job) error {
 
 	backup.FileSize = fileInfo.Size()
 	backup.FilePath = localPath
+	backup.Format = DumpFormatPlain
+	if pgInstance.Compression == config.CompressionGzip {
+		backup.Format = DumpFormatPlainGzip
+	}
+	if uncompressedSize == 0 {
+		uncompressedSize = backup.FileSize
+	}
 	w.logJobProgress(job.ID, backup.ID, "File size: %d bytes", backup.FileSize)
 
+	if err := pgInstance.CheckBackupSize(backup.FileSize); err != nil {
+		return w.discardBackup(job, backupRepo, backup, localPath, err)
+	}
+
+	// pg_dump can exit 0 yet write next to nothing, e.g. for the wrong database
+	if minSize := minBackupSize(); uncompressedSize < minSize {
+		err := fmt.Errorf("dump is suspiciously small: %d bytes uncompressed (%d on disk), expected at least %d (BACKUP_MIN_SIZE_BYTES)",
+			uncompressedSize, backup.FileSize, minSize)
+		return w.discardBackup(job, backupRepo, backup, localPath, err)
+	}
+
+	// A missing checksum only disables duplicate detection, so don't fail the backup
+	if checksum, err := dumpChecksum(localPath, pgInstance.Compression == config.CompressionGzip); err != nil {
+		w.logJobProgress(job.ID, backup.ID, "Failed to compute checksum: %v", err)
+	} else {
+		backup.Checksum = checksum
+		w.logJobProgress(job.ID, backup.ID, "Checksum (sha256): %s", checksum)
+	}
+
+	// With object storage configured, a backup only counts once it's uploaded
+	if err := w.uploadBackup(job, backup, localPath); err != nil {
+		return w.discardBackup(job, backupRepo, backup, localPath, err)
+	}
+
 	// Update backup status to completed
 	backup.Status = models.BackupStatusCompleted
 	endTime := time.Now()
 	backup.EndTime = &endTime
+	backup.RecordTransferStats(uncompressedSize)
+	w.logJobProgress(job.ID, backup.ID, "Uncompressed: %d bytes, ratio %.2f, throughput %.2f MB/s",
+		backup.UncompressedSize, backup.CompressionRatio, backup.ThroughputMBps)
 
 	if err := backupRepo.Update(backup); err != nil {
 		return fmt.Errorf("failed to update backup record: %w", err)
 	}
+	job.Payload["file_size"] = backup.FileSize
 
 	w.logJobProgress(job.ID, backup.ID, "Backup completed successfully")
+
+	// Sent once the backup is stored (uploaded when S3 is configured); the
+	// backup is kept even when the receiver is down
+	if pgInstance.PostBackupWebhook != "" {
+		if err := sendPostBackupWebhook(pgInstance.PostBackupWebhook, pgInstance.Name, backup); err != nil {
+			w.logJobProgress(job.ID, backup.ID, "⚠️ Post-backup webhook failed: %v", err)
+		} else {
+			w.logJobProgress(job.ID, backup.ID, "Post-backup webhook sent")
+		}
+	}
 	return nil
 }
 
 // processRestoreJob processes a restore job
 func (w *Worker) processRestoreJob(job *Job) error {
-	w.logInfo("Processing restore job %s", job.ID)
+	w.logInfo(job, "Processing restore job %s", job.ID)
 
-	// Extract parameters from job payload
-	backupID, ok := job.Payload["backup_id"].(string)
-	if !ok {
-		return fmt.Errorf("missing backup_id in job payload")
+	payload, err := job.RestorePayload()
+	if err != nil {
+		return err
 	}
+	backupID, postgresID, databaseName := payload.BackupID, payload.PostgresID, payload.DatabaseName
 
-	postgresID, ok := job.Payload["postgres_id"].(string)
-	if !ok {
-		return fmt.Errorf("missing postgres_id in job payload")
+	// Plain validation only reads the archive; everything else runs a restore
+	if !payload.ValidateOnly || payload.ScratchRestore {
+		release, err := w.jobQueue.acquireRestoreSlot(job)
+		if err != nil {
+			return err
+		}
+		defer release()
 	}
 
-	databaseName, ok := job.Payload["database_name"].(string)
-	if !ok {
-		return fmt.Errorf("missing database_name in job payload")
+	if payload.ValidateOnly {
+		w.logJobProgress(job.ID, backupID, "Validating backup %s (dry run, %s/%s is not touched)", backupID, postgresID, databaseName)
+		return w.validateRestore(job, payload)
+	}
+
+	// Record who restored what where before touching the target
+	restoreRepo := database.NewRestoreRepository(w.dbService)
+	restore := &database.RestoreRecord{
+		ID:                 config.NewID("restore"),
+		JobID:              job.ID,
+		BackupID:           backupID,
+		TargetPostgreSQLID: postgresID,
+		TargetDatabase:     databaseName,
+		RequestedBy:        job.CreatedBy(),
+		Status:             database.RestoreStatusInProgress,
+		StartTime:          time.Now(),
+	}
+	if backup, err := database.NewBackupRepository(w.dbService).GetByID(backupID); err == nil {
+		if backup.Trashed() {
+			return fmt.Errorf("backup %s is in the trash; restore it from the trash first", backupID)
+		}
+		restore.SourcePostgreSQLID = backup.PostgreSQLID
+	}
+	if err := restoreRepo.Create(restore); err != nil {
+		return fmt.Errorf("failed to create restore record: %w", err)
 	}
 
 	w.logJobProgress(job.ID, backupID, "Restore started for backup %s to %s/%s", backupID, postgresID, databaseName)
 
-	// TODO: Implement actual restore logic here
-	// For now, simulate restore process
-	time.Sleep(3 * time.Second) // Simulate restore time
+	// Table-level restores of custom-format archives
+	if payload.TableLevel() {
+		err := w.restoreTables(job, payload)
+		w.finishRestoreRecord(job, restoreRepo, restore, err)
+		if err != nil {
+			w.logJobProgress(job.ID, backupID, "Restore failed: %v", err)
+			return err
+		}
+		w.logJobProgress(job.ID, backupID, "Restore completed successfully")
+		return nil
+	}
 
+	err = w.restoreDatabase(job, payload)
+	w.finishRestoreRecord(job, restoreRepo, restore, err)
+	if err != nil {
+		w.logJobProgress(job.ID, backupID, "Restore failed: %v", err)
+		return err
+	}
 	w.logJobProgress(job.ID, backupID, "Restore completed successfully")
 	return nil
 }
 
+// finishRestoreRecord stores the outcome of a restore; restoreErr is nil on success
+func (w *Worker) finishRestoreRecord(job *Job, restoreRepo *database.RestoreRepository, restore *database.RestoreRecord, restoreErr error) {
+	restore.Status = database.RestoreStatusCompleted
+	if restoreErr != nil {
+		restore.Status = database.RestoreStatusFailed
+		restore.ErrorMessage = restoreErr.Error()
+	}
+	endTime := time.Now()
+	restore.EndTime = &endTime
+
+	if err := restoreRepo.Finish(restore); err != nil {
+		w.logJobProgress(job.ID, restore.BackupID, "Failed to update restore record %s: %v", restore.ID, err)
+	}
+}
+
 // processCleanupJob processes a cleanup job
 func (w *Worker) processCleanupJob(job *Job) error {
-	w.logInfo("Processing cleanup job %s", job.ID)
+	w.logInfo(job, "Processing cleanup job %s", job.ID)
 
-	// Extract parameters from job payload
-	postgresID, ok := job.Payload["postgres_id"].(string)
-	if !ok {
-		return fmt.Errorf("missing postgres_id in job payload")
+	payload, err := job.CleanupPayload()
+	if err != nil {
+		return err
+	}
+	postgresID, backupType := payload.PostgresID, payload.BackupType
+
+	if payload.Purge {
+		return w.purgeInstance(job, postgresID)
+	}
+	if payload.EmptyTrash {
+		return w.emptyTrash(job)
+	}
+
+	w.logJobProgress(job.ID, "", "Cleanup started for %s (%s)", postgresID, backupType)
+
+	pgRepo := database.NewPostgreSQLRepository(w.dbService)
+	pgInstance, err := pgRepo.GetByID(postgresID)
+	if err != nil {
+		return fmt.Errorf("failed to get postgres instance: %w", err)
 	}
 
-	backupTypeStr, ok := job.Payload["backup_type"].(string)
+	policy := pgInstance.GetRetentionPolicy(w.dbService.GlobalRetentionPolicy())
+	if !policy.Covers(string(backupType)) {
+		w.logJobProgress(job.ID, "", "Retention sets no limit for %s backups, nothing to clean up", backupType)
+		return nil
+	}
+
+	backupRepo := database.NewBackupRepository(w.dbService)
+	backups, err := backupRepo.GetCompletedByType(postgresID, backupType)
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	dryRun := payload.DryRun
+	if dryRun {
+		w.logJobProgress(job.ID, "", "Dry run: nothing will be deleted or archived")
+	}
+	plan := make([]CleanupCandidate, 0)
+
+	// Positions are counted per database, newest first; protected backups are
+	// outside retention and don't take a slot
+	positions := make(map[string]int)
+	now := time.Now()
+	deleted, archived := 0, 0
+	for _, backup := range backups {
+		if backup.Protected {
+			continue
+		}
+		position := positions[backup.DatabaseName]
+		positions[backup.DatabaseName]++
+		if !policy.ShouldDelete(string(backupType), position, backup.CreatedAt, now) {
+			continue
+		}
+
+		if dryRun {
+			candidate := CleanupCandidate{
+				BackupID:     backup.ID,
+				DatabaseName: backup.DatabaseName,
+				CreatedAt:    backup.CreatedAt,
+				FilePath:     backup.FilePath,
+				S3Key:        backup.S3Key,
+				Action:       "delete",
+			}
+			if policy.ArchiveStorageClass != "" && backup.S3Key != "" {
+				if backup.StorageClass == policy.ArchiveStorageClass {
+					continue
+				}
+				candidate.Action = "archive"
+			}
+			plan = append(plan, candidate)
+			w.logJobProgress(job.ID, backup.ID, "Dry run: would %s backup %s (%s, created %s, file %q, S3 key %q)",
+				candidate.Action, backup.ID, backup.DatabaseName, backup.CreatedAt.Format(time.RFC3339), backup.FilePath, backup.S3Key)
+			continue
+		}
+
+		// Uploaded copies are kept in the archive class; only the local file goes
+		if policy.ArchiveStorageClass != "" && backup.S3Key != "" {
+			if w.archiveBackup(job, backupRepo, backup, policy.ArchiveStorageClass) {
+				archived++
+			}
+			continue
+		}
+
+		// Same path as the trash purge, so the uploaded copy goes too
+		if w.removeBackup(job, backupRepo, backup) {
+			deleted++
+		}
+	}
+
+	if dryRun {
+		job.Payload["cleanup_plan"] = plan
+		w.logJobProgress(job.ID, "", "Dry run completed: %d backups exceed retention (%s retention)", len(plan), policy.GetMode())
+		return nil
+	}
+
+	w.logJobProgress(job.ID, "", "Cleanup completed: removed %d backups, archived %d (%s retention)", deleted, archived, policy.GetMode())
+	return nil
+}
+
+// CleanupCandidate is a backup a dry-run cleanup found outside retention.
+// Action is "delete", or "archive" when the policy moves uploads to an archive class.
+type CleanupCandidate struct {
+	BackupID     string    `json:"backup_id"`
+	DatabaseName string    `json:"database_name"`
+	CreatedAt    time.Time `json:"created_at"`
+	FilePath     string    `json:"file_path,omitempty"`
+	S3Key        string    `json:"s3_key,omitempty"`
+	Action       string    `json:"action"`
+}
+
+// storageClassSetter is implemented by object stores that can archive objects
+type storageClassSetter interface {
+	SetStorageClass(key, storageClass string) error
+}
+
+// archiveBackup moves a backup's uploaded copy to storageClass, records it and
+// removes the local file. It reports whether the backup was archived now.
+func (w *Worker) archiveBackup(job *Job, backupRepo *database.BackupRepository, backup *models.BackupInfo, storageClass string) bool {
+	if backup.StorageClass == storageClass {
+		return false
+	}
+
+	archiver, ok := w.jobQueue.getObjectStore().(storageClassSetter)
 	if !ok {
-		return fmt.Errorf("missing backup_type in job payload")
+		w.logJobProgress(job.ID, backup.ID, "No object storage configured, cannot archive %s", backup.S3Key)
+		return false
+	}
+	if err := archiver.SetStorageClass(backup.S3Key, storageClass); err != nil {
+		w.logJobProgress(job.ID, backup.ID, "Failed to archive %s: %v", backup.S3Key, err)
+		return false
+	}
+	if err := backupRepo.SetStorageClass(backup.ID, storageClass); err != nil {
+		w.logJobProgress(job.ID, backup.ID, "Failed to record storage class: %v", err)
+		return false
 	}
 
-	backupType := models.BackupType(backupTypeStr)
+	if backup.FilePath != "" {
+		if err := os.Remove(backup.FilePath); err != nil && !os.IsNotExist(err) {
+			w.logJobProgress(job.ID, backup.ID, "Failed to remove file %s: %v", backup.FilePath, err)
+		}
+	}
+	return true
+}
 
-	w.logJobProgress(job.ID, "", "Cleanup started for %s (%s)", postgresID, backupType)
+// purgeInstance deletes every backup of an instance from disk, object storage
+// and the backups table, then deletes the instance. The instance is only
+// removed once all backups are gone, so a failed purge can be retried.
+func (w *Worker) purgeInstance(job *Job, postgresID string) error {
+	w.logJobProgress(job.ID, "", "Purge started for instance %s", postgresID)
 
-	// TODO: Implement actual cleanup logic here
-	// For now, simulate cleanup process
-	time.Sleep(1 * time.Second) // Simulate cleanup time
+	backupRepo := database.NewBackupRepository(w.dbService)
+	backups, err := backupRepo.GetAll(database.FilterByPostgreSQLID(postgresID), database.IncludeTrashed())
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	deleted, failed := 0, 0
+	for _, backup := range backups {
+		if w.removeBackup(job, backupRepo, backup) {
+			deleted++
+		} else {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("purge incomplete: removed %d backups, %d failed; instance %s kept", deleted, failed, postgresID)
+	}
 
-	w.logJobProgress(job.ID, "", "Cleanup completed successfully")
+	pgRepo := database.NewPostgreSQLRepository(w.dbService)
+	if err := pgRepo.Delete(postgresID); err != nil {
+		return fmt.Errorf("failed to delete instance after purging backups: %w", err)
+	}
+
+	w.logJobProgress(job.ID, "", "Purge completed: removed %d backups and instance %s", deleted, postgresID)
 	return nil
 }
 
-// logInfo logs informational messages
-func (w *Worker) logInfo(format string, args ...interface{}) {
-	message := fmt.Sprintf(format, args...)
-	log.Printf("[WORKER] %s", message)
+// emptyTrash permanently deletes the trashed backups whose grace period has
+// ended. Backups that fail stay in the trash for the next run.
+func (w *Worker) emptyTrash(job *Job) error {
+	w.logJobProgress(job.ID, "", "Purging expired backups from the trash")
 
-	// Also log to database
-	entry := &database.LogEntry{
-		Timestamp: time.Now(),
-		Level:     "INFO",
-		Component: "WORKER",
-		Message:   message,
+	backupRepo := database.NewBackupRepository(w.dbService)
+	backups, err := backupRepo.GetExpiredTrash(time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to list trashed backups: %w", err)
+	}
+
+	deleted, failed := 0, 0
+	for _, backup := range backups {
+		if w.removeBackup(job, backupRepo, backup) {
+			deleted++
+		} else {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("trash purge incomplete: removed %d backups, %d failed", deleted, failed)
+	}
+	w.logJobProgress(job.ID, "", "Trash purge completed: removed %d backups", deleted)
+	return nil
+}
+
+// removeBackup deletes a backup from disk, object storage and the backups
+// table, in that order, and reports whether all of it is gone
+func (w *Worker) removeBackup(job *Job, backupRepo *database.BackupRepository, backup *models.BackupInfo) bool {
+	if backup.FilePath != "" {
+		if err := os.Remove(backup.FilePath); err != nil && !os.IsNotExist(err) {
+			w.logJobProgress(job.ID, backup.ID, "Failed to remove file %s: %v", backup.FilePath, err)
+			return false
+		}
+	}
+	if backup.S3Key != "" {
+		store := w.jobQueue.getObjectStore()
+		if store == nil {
+			w.logJobProgress(job.ID, backup.ID, "No object storage configured, cannot delete %s", backup.S3Key)
+			return false
+		}
+		if err := store.DeleteFile(backup.S3Key); err != nil {
+			w.logJobProgress(job.ID, backup.ID, "Failed to delete %s: %v", backup.S3Key, err)
+			return false
+		}
+	}
+	if err := backupRepo.Delete(backup.ID); err != nil {
+		w.logJobProgress(job.ID, backup.ID, "Failed to delete backup record: %v", err)
+		return false
 	}
-	w.logRepo.Create(entry)
+	return true
 }
 
-// logError logs error messages
-func (w *Worker) logError(format string, args ...interface{}) {
-	message := fmt.Sprintf(format, args...)
-	log.Printf("[WORKER] ERROR: %s", message)
+// logInfo logs worker messages. Messages about a job carry its ID and the
+// ID of the API request that created it; job is nil for the others.
+func (w *Worker) logInfo(job *Job, format string, args ...interface{}) {
+	w.writeLog(logger.LogLevelInfo, job, fmt.Sprintf(format, args...))
+}
 
-	// Also log to database
-	entry := &database.LogEntry{
-		Timestamp: time.Now(),
-		Level:     "ERROR",
-		Component: "WORKER",
-		Message:   message,
+// logError logs error messages, like logInfo
+func (w *Worker) logError(job *Job, format string, args ...interface{}) {
+	w.writeLog(logger.LogLevelError, job, fmt.Sprintf(format, args...))
+}
+
+// writeLog logs a message about job, or about the worker when job is nil
+func (w *Worker) writeLog(level logger.LogLevel, job *Job, message string) {
+	var jobID, requestID string
+	if job != nil {
+		jobID, requestID = job.ID, job.RequestID()
 	}
-	w.logRepo.Create(entry)
+	w.writeJobLog(level, jobID, "", requestID, message)
 }
 
 // logJobProgress logs job progress with job and backup context
 func (w *Worker) logJobProgress(jobID, backupID, format string, args ...interface{}) {
-	message := fmt.Sprintf(format, args...)
-	log.Printf("[WORKER] Job %s: %s", jobID, message)
+	w.writeJobLog(logger.LogLevelInfo, jobID, backupID, w.currentRequestID(jobID), fmt.Sprintf(format, args...))
+}
+
+// writeJobLog emits a message and saves it to the logs table with its job,
+// backup and request ID, any of which may be empty
+func (w *Worker) writeJobLog(level logger.LogLevel, jobID, backupID, requestID, message string) {
+	logger.Emit(logger.Entry{Level: level, Component: "WORKER", JobID: jobID, BackupID: backupID, RequestID: requestID, Message: message})
 
 	// Also log to database with job context
 	entry := &database.LogEntry{
 		Timestamp: time.Now(),
-		Level:     "INFO",
+		Level:     string(level),
 		Component: "WORKER",
-		JobID:     jobID, // Use full job ID
+		JobID:     jobID,
 		BackupID:  backupID,
 		Message:   message,
 	}
+	if requestID != "" {
+		if details, err := json.Marshal(map[string]string{"request_id": requestID}); err == nil {
+			entry.Details = string(details)
+		}
+	}
 
 	if err := w.logRepo.Create(entry); err != nil {
-		log.Printf("[WORKER] Failed to save log to database: %v", err)
+		logger.Emit(logger.Entry{Level: logger.LogLevelError, Component: "WORKER", Message: fmt.Sprintf("Failed to save log to database: %v", err)})
+	}
+}
+
+// Disk space preflight defaults
+const (
+	defaultDiskSpaceFactor = 1.5
+	defaultMinFreeSpaceMB  = 100
+)
+
+// defaultMinBackupSize is the smallest plausible uncompressed dump; pg_dump's
+// header alone is larger
+const defaultMinBackupSize = 256
+
+// minBackupSize returns the minimum uncompressed dump size, configurable via BACKUP_MIN_SIZE_BYTES
+func minBackupSize() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("BACKUP_MIN_SIZE_BYTES"), 10, 64); err == nil && value >= 0 {
+		return value
+	}
+	return defaultMinBackupSize
+}
+
+// diskSpaceFactor returns the multiple of the last dump size that must be
+// free, configurable via BACKUP_DISK_SPACE_FACTOR
+func diskSpaceFactor() float64 {
+	if value, err := strconv.ParseFloat(os.Getenv("BACKUP_DISK_SPACE_FACTOR"), 64); err == nil && value > 0 {
+		return value
+	}
+	return defaultDiskSpaceFactor
+}
+
+// minFreeSpaceMB returns the free space always required, configurable via BACKUP_MIN_FREE_SPACE_MB
+func minFreeSpaceMB() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("BACKUP_MIN_FREE_SPACE_MB"), 10, 64); err == nil && value >= 0 {
+		return value
+	}
+	return defaultMinFreeSpaceMB
+}
+
+// backupTempDir returns the directory dumps of an instance are written to
+func backupTempDir(pgInstance *config.PostgreSQLConfig) string {
+	if pgInstance.TempDir != "" {
+		return pgInstance.TempDir
+	}
+	if tempDir := os.Getenv("BACKUP_TEMP_DIR"); tempDir != "" {
+		return tempDir
+	}
+	return "/tmp/postgres-backups"
+}
+
+// checkDiskSpace estimates the size of the next dump as the last completed
+// backup of the database times BACKUP_DISK_SPACE_FACTOR, and fails when the temp
+// dir has less room than that (or than BACKUP_MIN_FREE_SPACE_MB)
+func checkDiskSpace(backupRepo *database.BackupRepository, tempDir, postgresID, databaseName string) error {
+	available, err := availableDiskSpace(tempDir)
+	if err != nil {
+		return nil // Can't measure, don't block the backup
+	}
+
+	factor := diskSpaceFactor()
+	required := minFreeSpaceMB() * 1024 * 1024
+	var lastSize int64
+	if latest, err := backupRepo.GetLatestCompleted(postgresID); err == nil {
+		for _, backup := range latest {
+			if backup.DatabaseName == databaseName {
+				lastSize = backup.FileSize
+			}
+		}
+	}
+	if estimate := int64(float64(lastSize) * factor); estimate > required {
+		required = estimate
+	}
+
+	if available < required {
+		return fmt.Errorf("insufficient disk space in %s: %.1f MB available, about %.1f MB needed (last backup %.1f MB x %.1f)",
+			tempDir, float64(available)/1024/1024, float64(required)/1024/1024, float64(lastSize)/1024/1024, factor)
+	}
+	return nil
+}
+
+// dumpTimeoutCause explains a pg_dump failure caused by the instance's
+// dump, statement or lock timeout, or returns ""
+func dumpTimeoutCause(ctx context.Context, output []byte, pgInstance *config.PostgreSQLConfig) string {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Sprintf("dump_timeout exceeded: pg_dump was stopped after %s", pgInstance.DumpTimeout)
+	}
+
+	text := string(output)
+	switch {
+	case pgInstance.LockTimeout != "" && (strings.Contains(text, "canceling statement due to lock timeout") ||
+		strings.Contains(text, "could not obtain lock on")):
+		return fmt.Sprintf("lock_timeout exceeded: could not acquire a table lock within %s, another transaction holds a conflicting lock", pgInstance.LockTimeout)
+	case pgInstance.StatementTimeout != "" && strings.Contains(text, "canceling statement due to statement timeout"):
+		return fmt.Sprintf("statement_timeout exceeded: a statement ran longer than %s", pgInstance.StatementTimeout)
+	}
+	return ""
+}
+
+// failBackup marks a backup that stopped before or during its dump as failed,
+// so no error path leaves it in progress, and returns err
+func (w *Worker) failBackup(job *Job, backupRepo *database.BackupRepository, backup *models.BackupInfo, err error) error {
+	backup.Fail(err.Error())
+	endTime := time.Now()
+	backup.EndTime = &endTime
+
+	if updateErr := backupRepo.Update(backup); updateErr != nil {
+		return fmt.Errorf("failed to update backup record: %w", updateErr)
+	}
+	w.logJobProgress(job.ID, backup.ID, "⚠️ Backup failed: %v", err)
+	return err
+}
+
+// discardBackup marks a backup whose file failed a size check as failed and
+// removes the file
+func (w *Worker) discardBackup(job *Job, backupRepo *database.BackupRepository, backup *models.BackupInfo, localPath string, err error) error {
+	os.Remove(localPath)
+
+	backup.Fail(err.Error())
+	backup.FilePath = ""
+	endTime := time.Now()
+	backup.EndTime = &endTime
+
+	if updateErr := backupRepo.Update(backup); updateErr != nil {
+		return fmt.Errorf("failed to update backup record: %w", updateErr)
+	}
+	w.logJobProgress(job.ID, backup.ID, "⚠️ Backup aborted: %v", err)
+	return err
+}
+
+// sizeLimitWriter counts the bytes written through it and fails once they
+// pass limit (0 = no limit)
+type sizeLimitWriter struct {
+	w       io.Writer
+	limit   int64
+	written int64
+}
+
+func (l *sizeLimitWriter) Write(p []byte) (int, error) {
+	if l.limit > 0 && l.written+int64(len(p)) > l.limit {
+		l.written += int64(len(p))
+		return 0, config.ErrBackupSizeLimit
+	}
+	n, err := l.w.Write(p)
+	l.written += int64(n)
+	return n, err
+}
+
+func (l *sizeLimitWriter) exceeded() bool {
+	return l.limit > 0 && l.written > l.limit
+}
+
+// countingWriter counts the bytes passed through to w
+type countingWriter struct {
+	w     io.Writer
+	count int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// runGzipDump runs pg_dump with stdout streamed through gzip into localPath and
+// returns the uncompressed size of the dump; stderr is left to the caller. Once
+// the compressed file passes maxSize bytes the stream is cut, which stops
+// pg_dump, and an ErrBackupSizeLimit error is returned.
+func runGzipDump(cmd *exec.Cmd, localPath string, level int, maxSize int64) (int64, error) {
+	file, err := os.Create(localPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer file.Close()
+
+	limited := &sizeLimitWriter{w: file, limit: maxSize}
+	gz, err := gzip.NewWriterLevel(limited, level)
+	if err != nil {
+		return 0, err
+	}
+
+	counted := &countingWriter{w: gz}
+	cmd.Stdout = counted
+
+	runErr := cmd.Run()
+	if runErr == nil {
+		if err := gz.Close(); err != nil {
+			runErr = fmt.Errorf("failed to finish gzip stream: %w", err)
+		}
+	}
+	if limited.exceeded() {
+		return counted.count, fmt.Errorf("%w: dump grew past max_backup_size_bytes (%d), pg_dump was stopped", config.ErrBackupSizeLimit, maxSize)
+	}
+	if runErr != nil {
+		return counted.count, runErr
+	}
+	if err := file.Close(); err != nil {
+		return counted.count, fmt.Errorf("failed to close backup file: %w", err)
+	}
+	return counted.count, nil
+}
+
+// dumpChecksum returns the SHA-256 of a dump's uncompressed content. The
+// "Started on"/"Completed on" comments pg_dump adds in verbose mode are skipped
+// so identical data produces identical checksums.
+func dumpChecksum(path string, gzipped bool) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if gzipped {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return "", err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	hash := sha256.New()
+	buffered := bufio.NewReader(reader)
+	for {
+		line, err := buffered.ReadBytes('\n')
+		if len(line) > 0 && !bytes.HasPrefix(line, []byte("-- Started on ")) && !bytes.HasPrefix(line, []byte("-- Completed on ")) {
+			hash.Write(line)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// currentRequestID returns the request ID of the job being processed when it matches jobID
+func (w *Worker) currentRequestID(jobID string) string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.currentJob == nil || w.currentJob.ID != jobID {
+		return ""
 	}
+	return w.currentJob.RequestID()
 }
 
 // generateBackupID generates a unique backup ID
 func generateBackupID() string {
-	return fmt.Sprintf("backup_%d", time.Now().UnixNano())
+	return config.NewID("backup")
 }
@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// panicOnMarshal is a payload value whose first JSON encoding, when the job
+// handler decodes the payload, panics. It stands in for any bug a malformed
+// payload can trigger in a handler; later encodings (e.g. storing the job
+// status) succeed.
+type panicOnMarshal struct {
+	panicked *atomic.Bool
+}
+
+func (p panicOnMarshal) MarshalJSON() ([]byte, error) {
+	if !p.panicked.Swap(true) {
+		panic("malformed payload")
+	}
+	return []byte("null"), nil
+}
+
+func TestWorkerSurvivesBadJobs(t *testing.T) {
+	q := newOfflineQueue(t, 1)
+	jobs := make(chan *Job, 3)
+	w := NewWorker("test-worker", jobs, q.dbService, q.logRepo, q)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Start(ctx)
+
+	panicking := &Job{ID: "job-panic", Type: JobTypeBackup, MaxRetries: 3,
+		Payload: map[string]interface{}{"postgres_id": panicOnMarshal{panicked: &atomic.Bool{}}}}
+	missingKeys := &Job{ID: "job-missing-keys", Type: JobTypeBackup, MaxRetries: 3,
+		Payload: map[string]interface{}{}}
+	nilPayload := &Job{ID: "job-nil-payload", Type: JobTypeBaseBackup, MaxRetries: 3}
+	jobs <- panicking
+	jobs <- missingKeys
+	jobs <- nilPayload
+
+	deadline := time.Now().Add(10 * time.Second)
+	for w.GetStatus().JobsHandled < 3 {
+		select {
+		case <-w.Done():
+			t.Fatalf("worker exited after %d jobs", w.GetStatus().JobsHandled)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("worker handled %d of 3 jobs", w.GetStatus().JobsHandled)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A panic fails the job for good: retrying would panic again
+	if panicking.Status != JobStatusFailed || !strings.Contains(panicking.Error, "panicked") {
+		t.Errorf("panicking job: status %s, error %q; want failed with the panic", panicking.Status, panicking.Error)
+	}
+	for _, job := range []*Job{missingKeys, nilPayload} {
+		if job.Error == "" || job.Status == JobStatusCompleted {
+			t.Errorf("job %s: status %s, error %q; want an error", job.ID, job.Status, job.Error)
+		}
+	}
+
+	select {
+	case <-w.Done():
+		t.Fatal("worker exited")
+	default:
+	}
+}